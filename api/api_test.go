@@ -0,0 +1,145 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"ledfx/auth"
+	"ledfx/config"
+	"ledfx/discovery"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+const testToken = "lfx_test"
+
+// setupTestConfig points the global config at a temporary file holding a
+// single device with a matching virtual, a preset, a user color and gradient
+// and a read-only token, and lists a WLED device "porch" as found by discovery.
+func setupTestConfig(t *testing.T) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "go_config.json")
+	if err := os.WriteFile(path, []byte("{}\n"), 0644); err != nil {
+		t.Fatalf("Error writing test config: %v\n", err)
+	}
+	config.GlobalViper = viper.New()
+	config.WriteDelay = 0
+	config.GlobalViper.SetConfigFile(path)
+	if err := config.Replace(config.Config{
+		Devices: []config.Device{{
+			Id:   "couch",
+			Type: "wled",
+			Config: config.DeviceConfig{
+				Name:       "Couch",
+				IpAddress:  "127.0.0.1",
+				PixelCount: 36,
+			},
+		}},
+		Virtuals: []config.Virtual{{
+			Id:       "couch",
+			IsDevice: "couch",
+			Config:   config.VirtualConfig{Name: "Couch"},
+			Segments: [][]interface{}{{"couch", 0, 35, false}},
+		}},
+		Presets: []config.Preset{{
+			Id:     "blue",
+			Name:   "Blue",
+			Type:   "singleColor",
+			Config: config.EffectConfig{Color: "#0000ff"},
+		}},
+		UserColors:    map[string]string{"Sunset": "#ff8000"},
+		UserGradients: map[string]string{"Dusk": "linear-gradient(90deg, #ff8000 0%, #000080 100%)"},
+		Auth: config.AuthConfig{
+			Tokens: []config.ApiToken{{
+				Id:    "abc",
+				Name:  "Script",
+				Scope: "read",
+				Hash:  auth.HashToken(testToken),
+			}},
+		},
+	}); err != nil {
+		t.Fatalf("Error setting test config: %v\n", err)
+	}
+	discovery.Default = discovery.New()
+	discovery.Default.Add("wled", discovery.Found{
+		Name: "Porch",
+		Mac:  "a0b1c2d3e4f5",
+		Device: config.Device{
+			Id:     "porch",
+			Type:   "wled",
+			Config: config.DeviceConfig{Name: "Porch", IpAddress: "127.0.0.3", PixelCount: 60},
+		},
+		Virtuals: []config.Virtual{{
+			Id:       "porch",
+			IsDevice: "porch",
+			Config:   config.VirtualConfig{Name: "Porch"},
+			Segments: [][]interface{}{{"porch", 0, 59, false}},
+		}},
+	})
+}
+
+// doRequest runs a request against a mux with all API routes registered
+func doRequest(t *testing.T, method, path string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if s, ok := body.(string); ok {
+			buf.WriteString(s)
+		} else if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatalf("Error encoding request body: %v\n", err)
+		}
+	}
+	mux := http.NewServeMux()
+	HandleApi(mux)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(method, path, &buf))
+	return rec
+}
+
+// decodeResponse decodes the recorded JSON body into v
+func decodeResponse(t *testing.T, rec *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.NewDecoder(rec.Body).Decode(v); err != nil {
+		t.Fatalf("Error decoding response body %q: %v\n", rec.Body.String(), err)
+	}
+}
+
+// expectError checks the status code and that the body is a JSON error
+func expectError(t *testing.T, rec *httptest.ResponseRecorder, code int) {
+	t.Helper()
+	if rec.Code != code {
+		t.Fatalf("Expected status %d, got %d: %s", code, rec.Code, rec.Body.String())
+	}
+	var resp errorResponse
+	decodeResponse(t, rec, &resp)
+	if resp.Status != "error" || resp.Error == "" {
+		t.Errorf("Expected JSON error body, got %+v", resp)
+	}
+}
+
+func TestPathSegments(t *testing.T) {
+	cases := []struct {
+		q string
+		a []string
+	}{
+		{"/api/virtuals/foo", []string{"foo"}},
+		{"/api/virtuals/foo/effects/", []string{"foo", "effects"}},
+		{"/api/virtuals/", nil},
+	}
+	for _, c := range cases {
+		guess := pathSegments(c.q, "/api/virtuals/")
+		if len(guess) != len(c.a) {
+			t.Errorf("Failed to split %s: expected %v but got %v", c.q, c.a, guess)
+			continue
+		}
+		for i := range guess {
+			if guess[i] != c.a[i] {
+				t.Errorf("Failed to split %s: expected %v but got %v", c.q, c.a, guess)
+			}
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"ledfx/auth"
+	"ledfx/config"
+	"net/http"
+)
+
+type authResponse struct {
+	Enabled        bool     `json:"enabled"`
+	Scope          string   `json:"scope"`
+	AllowedOrigins []string `json:"allowed_origins"`
+}
+
+// tokenInfo is an ApiToken without its hash
+type tokenInfo struct {
+	Id      string `json:"id"`
+	Name    string `json:"name"`
+	Scope   string `json:"scope"`
+	Created int64  `json:"created"`
+}
+
+type tokensResponse struct {
+	Tokens []tokenInfo `json:"tokens"`
+}
+
+type createTokenRequest struct {
+	Name  string `json:"name"`
+	Scope string `json:"scope"`
+}
+
+type createTokenResponse struct {
+	Token tokenInfo `json:"token"`
+	// Secret is only ever returned here
+	Secret string `json:"secret"`
+}
+
+type passwordRequest struct {
+	Password string `json:"password"`
+}
+
+type originsRequest struct {
+	AllowedOrigins []string `json:"allowed_origins"`
+}
+
+// handleAuth reports whether authentication is on and the scope of the caller
+func handleAuth(w http.ResponseWriter, r *http.Request) {
+	conf := config.Snapshot().Auth
+	scope := auth.FromContext(r.Context())
+	if !auth.Enabled(conf) {
+		scope = auth.ScopeControl
+	}
+	writeJSON(w, http.StatusOK, authResponse{
+		Enabled:        auth.Enabled(conf),
+		Scope:          string(scope),
+		AllowedOrigins: conf.AllowedOrigins,
+	})
+}
+
+func handleTokens(w http.ResponseWriter, r *http.Request) {
+	SetHeader(w)
+	switch r.Method {
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		conf := config.Snapshot()
+		tokens := make([]tokenInfo, 0, len(conf.Auth.Tokens))
+		for _, token := range conf.Auth.Tokens {
+			tokens = append(tokens, newTokenInfo(token))
+		}
+		writeJSON(w, http.StatusOK, tokensResponse{Tokens: tokens})
+	case http.MethodPost:
+		var req createTokenRequest
+		if err := decodeBody(r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if req.Scope == "" {
+			req.Scope = string(auth.ScopeRead)
+		}
+		scope, err := auth.ParseScope(req.Scope)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		token, secret, err := auth.AddToken(req.Name, scope)
+		if err != nil {
+			code := http.StatusInternalServerError
+			if req.Name == "" {
+				code = http.StatusBadRequest
+			}
+			writeError(w, code, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, createTokenResponse{Token: newTokenInfo(token), Secret: secret})
+	default:
+		writeMethodNotAllowed(w, r, http.MethodGet, http.MethodPost)
+	}
+}
+
+func handleToken(w http.ResponseWriter, r *http.Request) {
+	SetHeader(w)
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	segments := pathSegments(r.URL.Path, "/api/auth/tokens/")
+	if len(segments) != 1 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("path '%s' %w", r.URL.Path, errNotFound))
+		return
+	}
+	if r.Method != http.MethodDelete {
+		writeMethodNotAllowed(w, r, http.MethodDelete)
+		return
+	}
+	if !tokenExists(segments[0]) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("token '%s' %w", segments[0], errNotFound))
+		return
+	}
+	if err := auth.RemoveToken(segments[0]); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlePassword sets the password, an empty password removes it
+func handlePassword(w http.ResponseWriter, r *http.Request) {
+	SetHeader(w)
+	switch r.Method {
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusOK)
+	case http.MethodPut:
+		var req passwordRequest
+		if err := decodeBody(r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := auth.SetPassword(req.Password); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeMethodNotAllowed(w, r, http.MethodPut)
+	}
+}
+
+func handleOrigins(w http.ResponseWriter, r *http.Request) {
+	SetHeader(w)
+	switch r.Method {
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusOK)
+	case http.MethodPut:
+		var req originsRequest
+		if err := decodeBody(r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		for _, origin := range req.AllowedOrigins {
+			if origin == "" {
+				writeError(w, http.StatusBadRequest, errors.New("allowed origins may not be empty"))
+				return
+			}
+		}
+		if err := auth.SetAllowedOrigins(req.AllowedOrigins); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeMethodNotAllowed(w, r, http.MethodPut)
+	}
+}
+
+func newTokenInfo(token config.ApiToken) tokenInfo {
+	return tokenInfo{Id: token.Id, Name: token.Name, Scope: token.Scope, Created: token.Created}
+}
+
+func tokenExists(id string) bool {
+	for _, token := range config.Snapshot().Auth.Tokens {
+		if token.Id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// redactedConfig returns a copy of the config without credential hashes
+func redactedConfig() config.Config {
+	conf := config.Snapshot()
+	conf.Auth.PasswordHash = ""
+	for i := range conf.Auth.Tokens {
+		conf.Auth.Tokens[i].Hash = ""
+	}
+	return conf
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"ledfx/auth"
+	"ledfx/config"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAuthTokens(t *testing.T) {
+	setupTestConfig(t)
+
+	rec := doRequest(t, http.MethodPost, "/api/auth/tokens", createTokenRequest{Name: "ci", Scope: "control"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var created createTokenResponse
+	decodeResponse(t, rec, &created)
+	if !strings.HasPrefix(created.Secret, "lfx_") || created.Token.Scope != "control" {
+		t.Errorf("Unexpected token: %+v", created)
+	}
+	if scope, ok := auth.TokenScope(config.Snapshot().Auth, created.Secret); !ok || scope != auth.ScopeControl {
+		t.Errorf("Created token does not authenticate")
+	}
+
+	rec = doRequest(t, http.MethodGet, "/api/auth/tokens", nil)
+	var list tokensResponse
+	decodeResponse(t, rec, &list)
+	if len(list.Tokens) != 2 {
+		t.Errorf("Unexpected tokens: %+v", list.Tokens)
+	}
+
+	expectError(t, doRequest(t, http.MethodPost, "/api/auth/tokens", createTokenRequest{Name: "bad", Scope: "admin"}), http.StatusBadRequest)
+	expectError(t, doRequest(t, http.MethodPost, "/api/auth/tokens", createTokenRequest{Scope: "read"}), http.StatusBadRequest)
+
+	rec = doRequest(t, http.MethodDelete, "/api/auth/tokens/"+created.Token.Id, nil)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+	expectError(t, doRequest(t, http.MethodDelete, "/api/auth/tokens/"+created.Token.Id, nil), http.StatusNotFound)
+}
+
+func TestConfigRedactsCredentials(t *testing.T) {
+	setupTestConfig(t)
+	if err := auth.SetPassword("hunter2"); err != nil {
+		t.Fatalf("Error setting password: %v\n", err)
+	}
+	rec := doRequest(t, http.MethodGet, "/api/config", nil)
+	body := rec.Body.String()
+	conf := config.Snapshot().Auth
+	if strings.Contains(body, conf.PasswordHash) || strings.Contains(body, conf.Tokens[0].Hash) {
+		t.Errorf("Config response leaks credential hashes: %s", body)
+	}
+	if conf.Tokens[0].Hash == "" {
+		t.Errorf("Redacting the response modified the config")
+	}
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"errors"
+	"ledfx/config"
+	"ledfx/config/bundle"
+	"ledfx/logger"
+	"ledfx/virtual"
+	"net/http"
+)
+
+type importRequest struct {
+	// Mode is merge (the default) or replace
+	Mode   string        `json:"mode"`
+	DryRun bool          `json:"dry_run"`
+	Bundle bundle.Bundle `json:"bundle"`
+}
+
+type importResponse struct {
+	Mode    bundle.Mode     `json:"mode"`
+	DryRun  bool            `json:"dry_run"`
+	Changes []bundle.Change `json:"changes"`
+}
+
+func handleExport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Disposition", `attachment; filename="ledfx-bundle.json"`)
+	writeJSON(w, http.StatusOK, bundle.Export(config.Snapshot()))
+}
+
+func handleImport(w http.ResponseWriter, r *http.Request) {
+	SetHeader(w)
+	switch r.Method {
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusOK)
+	case http.MethodPost:
+		var req importRequest
+		if err := decodeBody(r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		mode, err := bundle.ParseMode(req.Mode)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		changes, err := bundle.Import(req.Bundle, mode, true)
+		if err == nil && !req.DryRun {
+			// Only what was released may change, an edit in between fails
+			// the import instead
+			releaseChanged(changes)
+			changes, err = bundle.ImportExpecting(req.Bundle, mode, changes)
+		}
+		var invalid *bundle.ValidationError
+		switch {
+		case errors.As(err, &invalid):
+			writeError(w, http.StatusBadRequest, err)
+			return
+		case errors.Is(err, bundle.ErrChanged):
+			writeError(w, http.StatusConflict, err)
+			return
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, importResponse{Mode: mode, DryRun: req.DryRun, Changes: changes})
+	default:
+		writeMethodNotAllowed(w, r, http.MethodPost)
+	}
+}
+
+// releaseChanged stops the effects of active virtuals an import is about to
+// update or remove, and releases the devices it updates or removes
+func releaseChanged(changes []bundle.Change) {
+	for _, change := range changes {
+		if change.Op == "add" {
+			continue
+		}
+		var err error
+		switch change.Kind {
+		case "device":
+			err = virtual.ReleaseDevice(change.Id)
+		case "virtual":
+			if virt, ok := getVirtual(change.Id); ok && virt.Active {
+				err = virtual.StopVirtual(virt.Id)
+			}
+		}
+		if err != nil {
+			logger.Logger.WithField("category", "HTTP API").Warn(err)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"ledfx/config"
+	"ledfx/config/bundle"
+	"net/http"
+	"testing"
+)
+
+func TestExportImport(t *testing.T) {
+	setupTestConfig(t)
+	rec := doRequest(t, http.MethodGet, "/api/config/export", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var b bundle.Bundle
+	decodeResponse(t, rec, &b)
+	if len(b.Devices) != 1 || len(b.Virtuals) != 1 || len(b.Presets) != 1 {
+		t.Fatalf("Unexpected bundle: %+v", b)
+	}
+
+	b.Devices[0].Config.PixelCount = 10
+	rec = doRequest(t, http.MethodPost, "/api/config/import", importRequest{Mode: "replace", DryRun: true, Bundle: b})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a segment off the device, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	b.Devices[0].Config.PixelCount = 36
+	b.Presets = []config.Preset{}
+	rec = doRequest(t, http.MethodPost, "/api/config/import", importRequest{Mode: "replace", Bundle: b})
+	var resp importResponse
+	decodeResponse(t, rec, &resp)
+	if rec.Code != http.StatusOK || len(resp.Changes) != 1 || resp.Changes[0].Id != "blue" {
+		t.Errorf("Unexpected import response %d: %+v", rec.Code, resp)
+	}
+	if presets := config.Snapshot().Presets; len(presets) != 0 {
+		t.Errorf("Replace kept presets: %+v", presets)
+	}
+
+	rec = doRequest(t, http.MethodPost, "/api/config/import", importRequest{Mode: "overwrite", Bundle: b})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown mode, got %d", rec.Code)
+	}
+}
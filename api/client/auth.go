@@ -0,0 +1,66 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+type AuthStatus struct {
+	Enabled        bool     `json:"enabled"`
+	Scope          string   `json:"scope"`
+	AllowedOrigins []string `json:"allowed_origins"`
+}
+
+type Token struct {
+	Id      string `json:"id"`
+	Name    string `json:"name"`
+	Scope   string `json:"scope"`
+	Created int64  `json:"created"`
+}
+
+// Auth reports whether authentication is on and the scope of the client
+func (c *Client) Auth(ctx context.Context) (AuthStatus, error) {
+	var status AuthStatus
+	return status, c.do(ctx, http.MethodGet, "/api/auth", nil, &status)
+}
+
+func (c *Client) Tokens(ctx context.Context) ([]Token, error) {
+	var resp struct {
+		Tokens []Token `json:"tokens"`
+	}
+	return resp.Tokens, c.do(ctx, http.MethodGet, "/api/auth/tokens", nil, &resp)
+}
+
+// CreateToken creates a token with the scope "read" or "control" and returns
+// its secret, which the server doesn't keep
+func (c *Client) CreateToken(ctx context.Context, name, scope string) (Token, string, error) {
+	req := struct {
+		Name  string `json:"name"`
+		Scope string `json:"scope"`
+	}{name, scope}
+	var resp struct {
+		Token  Token  `json:"token"`
+		Secret string `json:"secret"`
+	}
+	err := c.do(ctx, http.MethodPost, "/api/auth/tokens", req, &resp)
+	return resp.Token, resp.Secret, err
+}
+
+func (c *Client) DeleteToken(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/auth/tokens/"+escape(id), nil, nil)
+}
+
+// SetPassword sets the password, an empty password removes it
+func (c *Client) SetPassword(ctx context.Context, password string) error {
+	req := struct {
+		Password string `json:"password"`
+	}{password}
+	return c.do(ctx, http.MethodPut, "/api/auth/password", req, nil)
+}
+
+func (c *Client) SetAllowedOrigins(ctx context.Context, origins []string) error {
+	req := struct {
+		AllowedOrigins []string `json:"allowed_origins"`
+	}{origins}
+	return c.do(ctx, http.MethodPut, "/api/auth/origins", req, nil)
+}
@@ -0,0 +1,347 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"ledfx/config"
+	"net/http"
+)
+
+// The bridge request types mirror the ones in ledfx/audio/audiobridge so
+// this package can be used without cgo. The client tests check them against
+// the OpenAPI document.
+
+type AirPlayInput struct {
+	Name    string `json:"name"`
+	Port    int    `json:"port"`
+	Verbose bool   `json:"verbose,omitempty"`
+}
+
+type AirPlayOutput struct {
+	SearchKey string `json:"search_key"`
+	// SearchType is either "name" or "ip"
+	SearchType string `json:"search_type"`
+	Verbose    bool   `json:"verbose,omitempty"`
+}
+
+type CaptureInput struct {
+	AudioDevice *config.AudioDevice `json:"audio_device,omitempty"`
+	Verbose     bool                `json:"verbose,omitempty"`
+}
+
+type YouTubeCtl struct {
+	// Action is one of download, play, pause, resume, stop, next or previous
+	Action string `json:"action"`
+	URL    string `json:"url"`
+}
+
+type TrackInfo struct {
+	Artist        string `json:"artist,omitempty"`
+	Title         string `json:"title,omitempty"`
+	Duration      string `json:"duration,omitempty"`
+	SampleRate    int64  `json:"samplerate,omitempty"`
+	FileSize      int64  `json:"filesize,omitempty"`
+	URL           string `json:"url"`
+	AudioChannels int    `json:"audio_channels,omitempty"`
+}
+
+type YouTubeInfo struct {
+	IsPlaying       bool        `json:"is_playing"`
+	PercentComplete float32     `json:"percent_complete"`
+	Paused          bool        `json:"paused"`
+	TrackIndex      int         `json:"track_index"`
+	NowPlaying      TrackInfo   `json:"now_playing"`
+	Queued          []TrackInfo `json:"queued"`
+}
+
+type SynthInput struct {
+	// Kind is one of silence, sine_sweep, pink_noise, click or wav
+	Kind    string  `json:"kind"`
+	Level   float64 `json:"level,omitempty"`
+	StartHz float64 `json:"start_hz,omitempty"`
+	EndHz   float64 `json:"end_hz,omitempty"`
+	// Period is the length of a sweep in seconds
+	Period  float64 `json:"period,omitempty"`
+	BPM     float64 `json:"bpm,omitempty"`
+	Seed    int64   `json:"seed,omitempty"`
+	Path    string  `json:"path,omitempty"`
+	Verbose bool    `json:"verbose,omitempty"`
+}
+
+type FileCtl struct {
+	// Action is one of load, add, play, pause, resume, stop, next, previous,
+	// seek or loop
+	Action string `json:"action"`
+	// Sources are paths of audio files or M3U playlists, or stream URLs
+	Sources []string `json:"sources,omitempty"`
+	Index   int      `json:"index,omitempty"`
+	// Position is in seconds
+	Position float64 `json:"position,omitempty"`
+	// Loop is one of off, track or playlist
+	Loop string `json:"loop,omitempty"`
+}
+
+type FileTrack struct {
+	Source string `json:"source"`
+	Title  string `json:"title,omitempty"`
+	Artist string `json:"artist,omitempty"`
+	// Duration is in seconds
+	Duration float64 `json:"duration,omitempty"`
+}
+
+type FileInfo struct {
+	Playing    bool        `json:"playing"`
+	Paused     bool        `json:"paused"`
+	TrackIndex int         `json:"track_index"`
+	Position   float64     `json:"position"`
+	Loop       string      `json:"loop"`
+	Queue      []FileTrack `json:"queue"`
+}
+
+// MixerCtl changes a mixer input, nil fields keep their value
+type MixerCtl struct {
+	Input    string   `json:"input"`
+	Gain     *float64 `json:"gain,omitempty"`
+	Mute     *bool    `json:"mute,omitempty"`
+	Priority *int     `json:"priority,omitempty"`
+	DuckGain *float64 `json:"duck_gain,omitempty"`
+}
+
+// MixerLevel is a meter reading in dBFS
+type MixerLevel struct {
+	RMS  float64 `json:"rms"`
+	Peak float64 `json:"peak"`
+}
+
+type MixerInput struct {
+	Name     string `json:"name"`
+	Settings struct {
+		Gain     float64 `json:"gain"`
+		Mute     bool    `json:"mute"`
+		Priority int     `json:"priority"`
+	} `json:"settings"`
+	Level  MixerLevel `json:"level"`
+	Active bool       `json:"active"`
+	Ducked bool       `json:"ducked"`
+}
+
+type MixerInfo struct {
+	DuckGain float64      `json:"duck_gain"`
+	Level    MixerLevel   `json:"level"`
+	Inputs   []MixerInput `json:"inputs"`
+}
+
+// BridgeOutput is an output of the bridge, Value depends on the type
+type BridgeOutput struct {
+	ID     string          `json:"id"`
+	Type   string          `json:"type"`
+	Volume float64         `json:"volume"`
+	Muted  bool            `json:"muted"`
+	Value  json.RawMessage `json:"Value"`
+}
+
+// OutputDelay is the delay of a bridge output, "leds" is the LED analysis
+type OutputDelay struct {
+	Output  string  `json:"output"`
+	Delay   float64 `json:"delay_ms"`
+	Latency float64 `json:"latency_ms"`
+}
+
+// RecorderConfig is where and how to record
+type RecorderConfig struct {
+	Dir string `json:"dir"`
+	// Container is wav or flac
+	Container string `json:"container,omitempty"`
+	Prefix    string `json:"prefix,omitempty"`
+	// MaxBytes and MaxDuration in seconds start a new file, 0 never does
+	MaxBytes    int64   `json:"max_bytes,omitempty"`
+	MaxDuration float64 `json:"max_duration,omitempty"`
+}
+
+type RecorderInfo struct {
+	Recording bool           `json:"recording"`
+	Config    RecorderConfig `json:"config"`
+	File      string         `json:"file,omitempty"`
+	// Duration is in seconds
+	Duration float64  `json:"duration"`
+	Files    []string `json:"files"`
+	Error    string   `json:"error,omitempty"`
+}
+
+type verbose struct {
+	Verbose bool `json:"verbose,omitempty"`
+}
+
+func (c *Client) SetInputAirPlay(ctx context.Context, in AirPlayInput) error {
+	return c.do(ctx, http.MethodPost, "/api/bridge/set/input/airplay", in, nil)
+}
+
+func (c *Client) SetInputYouTube(ctx context.Context, verboseLogging bool) error {
+	return c.do(ctx, http.MethodPost, "/api/bridge/set/input/youtube", verbose{verboseLogging}, nil)
+}
+
+func (c *Client) SetInputFile(ctx context.Context, verboseLogging bool) error {
+	return c.do(ctx, http.MethodPost, "/api/bridge/set/input/file", verbose{verboseLogging}, nil)
+}
+
+func (c *Client) SetInputSynth(ctx context.Context, in SynthInput) error {
+	return c.do(ctx, http.MethodPost, "/api/bridge/set/input/synth", in, nil)
+}
+
+func (c *Client) SetInputCapture(ctx context.Context, in CaptureInput) error {
+	return c.do(ctx, http.MethodPost, "/api/bridge/set/input/capture", in, nil)
+}
+
+func (c *Client) AddOutputAirPlay(ctx context.Context, out AirPlayOutput) error {
+	return c.do(ctx, http.MethodPost, "/api/bridge/add/output/airplay", out, nil)
+}
+
+func (c *Client) AddOutputLocal(ctx context.Context, verboseLogging bool) error {
+	return c.do(ctx, http.MethodPost, "/api/bridge/add/output/local", verbose{verboseLogging}, nil)
+}
+
+// AddOutputRecorder adds the recorder output, and starts recording if start
+// is set
+func (c *Client) AddOutputRecorder(ctx context.Context, start *RecorderConfig) error {
+	req := struct {
+		Start *RecorderConfig `json:"start,omitempty"`
+	}{start}
+	return c.do(ctx, http.MethodPost, "/api/bridge/add/output/recorder", req, nil)
+}
+
+func (c *Client) YouTubeSet(ctx context.Context, ctl YouTubeCtl) error {
+	return c.do(ctx, http.MethodPost, "/api/bridge/ctl/youtube/set", ctl, nil)
+}
+
+func (c *Client) YouTubeInfo(ctx context.Context) (YouTubeInfo, error) {
+	var info YouTubeInfo
+	return info, c.do(ctx, http.MethodGet, "/api/bridge/ctl/youtube/info", nil, &info)
+}
+
+func (c *Client) FileSet(ctx context.Context, ctl FileCtl) error {
+	return c.do(ctx, http.MethodPost, "/api/bridge/ctl/file/set", ctl, nil)
+}
+
+func (c *Client) FileInfo(ctx context.Context) (FileInfo, error) {
+	var info FileInfo
+	return info, c.do(ctx, http.MethodGet, "/api/bridge/ctl/file/info", nil, &info)
+}
+
+// StartRecording records to new files as conf describes
+func (c *Client) StartRecording(ctx context.Context, conf RecorderConfig) error {
+	req := struct {
+		Action string         `json:"action"`
+		Config RecorderConfig `json:"config"`
+	}{"start", conf}
+	return c.do(ctx, http.MethodPost, "/api/bridge/ctl/recorder/set", req, nil)
+}
+
+// StopRecording finishes the file being recorded
+func (c *Client) StopRecording(ctx context.Context) error {
+	req := struct {
+		Action string `json:"action"`
+	}{"stop"}
+	return c.do(ctx, http.MethodPost, "/api/bridge/ctl/recorder/set", req, nil)
+}
+
+func (c *Client) RecorderInfo(ctx context.Context) (RecorderInfo, error) {
+	var info RecorderInfo
+	return info, c.do(ctx, http.MethodGet, "/api/bridge/ctl/recorder/info", nil, &info)
+}
+
+func (c *Client) MixerSet(ctx context.Context, ctl MixerCtl) error {
+	return c.do(ctx, http.MethodPost, "/api/bridge/ctl/mixer/set", ctl, nil)
+}
+
+// MixerRemove stops the input called name and removes it from the mixer
+func (c *Client) MixerRemove(ctx context.Context, name string) error {
+	req := struct {
+		Input string `json:"input"`
+	}{name}
+	return c.do(ctx, http.MethodPost, "/api/bridge/ctl/mixer/remove", req, nil)
+}
+
+func (c *Client) MixerInfo(ctx context.Context) (MixerInfo, error) {
+	var info MixerInfo
+	return info, c.do(ctx, http.MethodGet, "/api/bridge/ctl/mixer/info", nil, &info)
+}
+
+func (c *Client) Outputs(ctx context.Context) ([]BridgeOutput, error) {
+	var info struct {
+		Outputs []BridgeOutput `json:"outputs"`
+	}
+	err := c.do(ctx, http.MethodGet, "/api/bridge/ctl/output/info", nil, &info)
+	return info.Outputs, err
+}
+
+// SetOutputVolume scales the bridge output with the given ID by volume, from
+// 0 to 1
+func (c *Client) SetOutputVolume(ctx context.Context, id string, volume float64) error {
+	req := struct {
+		ID     string  `json:"id"`
+		Volume float64 `json:"volume"`
+	}{id, volume}
+	return c.do(ctx, http.MethodPost, "/api/bridge/ctl/output/set", req, nil)
+}
+
+func (c *Client) SetOutputMute(ctx context.Context, id string, muted bool) error {
+	req := struct {
+		ID   string `json:"id"`
+		Mute bool   `json:"mute"`
+	}{id, muted}
+	return c.do(ctx, http.MethodPost, "/api/bridge/ctl/output/set", req, nil)
+}
+
+// RemoveOutput stops and removes the bridge output with the given ID
+func (c *Client) RemoveOutput(ctx context.Context, id string) error {
+	req := struct {
+		ID string `json:"id"`
+	}{id}
+	return c.do(ctx, http.MethodPost, "/api/bridge/ctl/output/remove", req, nil)
+}
+
+// SetDelay holds the bridge output called output back by ms milliseconds
+func (c *Client) SetDelay(ctx context.Context, output string, ms float64) error {
+	req := struct {
+		Output string  `json:"output"`
+		Delay  float64 `json:"delay_ms"`
+	}{output, ms}
+	return c.do(ctx, http.MethodPost, "/api/bridge/ctl/delay/set", req, nil)
+}
+
+// SyncDelays delays every bridge output to play in time with the slowest
+// AirPlay output
+func (c *Client) SyncDelays(ctx context.Context) error {
+	req := struct {
+		Sync bool `json:"sync"`
+	}{true}
+	return c.do(ctx, http.MethodPost, "/api/bridge/ctl/delay/set", req, nil)
+}
+
+func (c *Client) Delays(ctx context.Context) ([]OutputDelay, error) {
+	var info struct {
+		Outputs []OutputDelay `json:"outputs"`
+	}
+	err := c.do(ctx, http.MethodGet, "/api/bridge/ctl/delay/info", nil, &info)
+	return info.Outputs, err
+}
+
+// StopAirPlayServer stops the AirPlay input server
+func (c *Client) StopAirPlayServer(ctx context.Context) error {
+	req := struct {
+		Action string `json:"action"`
+	}{"stop"}
+	return c.do(ctx, http.MethodPost, "/api/bridge/ctl/airplay/set", req, nil)
+}
+
+// AirPlayClients returns the raw client list, whose entries hold connection
+// state that doesn't round trip through JSON
+func (c *Client) AirPlayClients(ctx context.Context) (json.RawMessage, error) {
+	body, err := c.doRaw(ctx, http.MethodGet, "/api/bridge/ctl/airplay/clients", nil)
+	return json.RawMessage(body), err
+}
+
+// Artwork returns the PNG artwork of the current track
+func (c *Client) Artwork(ctx context.Context) ([]byte, error) {
+	return c.doRaw(ctx, http.MethodGet, "/api/bridge/artwork", nil)
+}
@@ -0,0 +1,179 @@
+// Package client is a typed Go client for the LedFx HTTP API described by
+// /api/openapi.json.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"ledfx/api/openapi"
+	"ledfx/config"
+	"ledfx/config/bundle"
+	"ledfx/config/legacy"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Error is returned for every non-2xx response
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("ledfx api: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+type Client struct {
+	baseURL string
+	http    *http.Client
+	token   string
+}
+
+// New returns a client for the server at baseURL, e.g. http://localhost:8080.
+// A nil httpClient uses http.DefaultClient.
+func New(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		http:    httpClient,
+	}
+}
+
+// WithToken returns a copy of the client that authenticates with an API token
+func (c *Client) WithToken(token string) *Client {
+	copied := *c
+	copied.token = token
+	return &copied
+}
+
+// do sends in as the JSON request body (if non-nil) and decodes the JSON
+// response into out (if non-nil)
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	body, err := c.doRaw(ctx, method, path, in)
+	if err != nil {
+		return err
+	}
+	if out == nil || len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("error decoding response from %s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+func (c *Client) doRaw(ctx context.Context, method, path string, in interface{}) ([]byte, error) {
+	var reqBody io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding request body: %w", err)
+		}
+		reqBody = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error sending %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response from %s %s: %w", method, path, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &Error{StatusCode: resp.StatusCode, Message: errorMessage(body)}
+	}
+	return body, nil
+}
+
+// errorMessage extracts the message from JSON or plain text error bodies
+func errorMessage(body []byte) string {
+	var e struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &e) == nil && e.Error != "" {
+		return e.Error
+	}
+	return strings.TrimSpace(string(body))
+}
+
+func escape(id string) string {
+	return url.PathEscape(id)
+}
+
+// ############## BEGIN META ##############
+
+// OpenAPI fetches the API description from the server
+func (c *Client) OpenAPI(ctx context.Context) (*openapi.Document, error) {
+	var doc openapi.Document
+	return &doc, c.do(ctx, http.MethodGet, "/api/openapi.json", nil, &doc)
+}
+
+func (c *Client) Config(ctx context.Context) (*config.Config, error) {
+	var conf config.Config
+	return &conf, c.do(ctx, http.MethodGet, "/api/config", nil, &conf)
+}
+
+// ExportConfig returns the devices, virtuals, presets and user colors and
+// gradients as a bundle for ImportConfig
+func (c *Client) ExportConfig(ctx context.Context) (*bundle.Bundle, error) {
+	var b bundle.Bundle
+	return &b, c.do(ctx, http.MethodGet, "/api/config/export", nil, &b)
+}
+
+// ImportConfig validates a bundle and merges it into or replaces the config.
+// With dryRun the config is left as it is. It returns the changes.
+func (c *Client) ImportConfig(ctx context.Context, b bundle.Bundle, mode bundle.Mode, dryRun bool) ([]bundle.Change, error) {
+	req := struct {
+		Mode   bundle.Mode   `json:"mode"`
+		DryRun bool          `json:"dry_run"`
+		Bundle bundle.Bundle `json:"bundle"`
+	}{mode, dryRun, b}
+	var resp struct {
+		Changes []bundle.Change `json:"changes"`
+	}
+	return resp.Changes, c.do(ctx, http.MethodPost, "/api/config/import", req, &resp)
+}
+
+// ImportLegacyConfig merges a Python LedFx config.json into the config and
+// reports what could not be translated
+func (c *Client) ImportLegacyConfig(ctx context.Context, data []byte) (*legacy.Report, error) {
+	var report legacy.Report
+	return &report, c.do(ctx, http.MethodPost, "/api/config/import/legacy", json.RawMessage(data), &report)
+}
+
+func (c *Client) AudioDevices(ctx context.Context) ([]config.AudioDevice, error) {
+	var devices []config.AudioDevice
+	return devices, c.do(ctx, http.MethodGet, "/api/audio", nil, &devices)
+}
+
+// Schema returns the effect schemas as raw JSON values
+func (c *Client) Schema(ctx context.Context) (map[string]json.RawMessage, error) {
+	var schema map[string]json.RawMessage
+	return schema, c.do(ctx, http.MethodGet, "/api/schema", nil, &schema)
+}
+
+// Colors returns the color and gradient tables as raw JSON values
+func (c *Client) Colors(ctx context.Context) (map[string]json.RawMessage, error) {
+	var colors map[string]json.RawMessage
+	return colors, c.do(ctx, http.MethodGet, "/api/colors", nil, &colors)
+}
+
+// ############### END META ###############
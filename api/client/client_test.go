@@ -0,0 +1,453 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"ledfx/api"
+	"ledfx/api/openapi"
+	"ledfx/auth"
+	"ledfx/config"
+	"ledfx/config/bundle"
+	"ledfx/discovery"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// uncovered lists operations the client deliberately has no method for
+var uncovered = map[string]string{
+	"statPollWebsocket": "websocket, use gorilla/websocket directly",
+	"ctlAirPlayInfo":    "not implemented by the server",
+}
+
+func setupTestConfig(t *testing.T) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "go_config.json")
+	if err := os.WriteFile(path, []byte("{}\n"), 0644); err != nil {
+		t.Fatalf("Error writing test config: %v\n", err)
+	}
+	config.GlobalViper = viper.New()
+	config.WriteDelay = 0
+	config.GlobalViper.SetConfigFile(path)
+	if err := config.Replace(config.Config{
+		Devices: []config.Device{{
+			Id:     "couch",
+			Type:   "wled",
+			Config: config.DeviceConfig{Name: "Couch", IpAddress: "127.0.0.1", PixelCount: 36},
+		}},
+		Virtuals: []config.Virtual{{
+			Id:       "couch",
+			IsDevice: "couch",
+			Config:   config.VirtualConfig{Name: "Couch"},
+			Segments: [][]interface{}{{"couch", 0, 35, false}},
+		}},
+		Presets: []config.Preset{{
+			Id:     "blue",
+			Name:   "Blue",
+			Type:   "singleColor",
+			Config: config.EffectConfig{Color: "#0000ff"},
+		}},
+	}); err != nil {
+		t.Fatalf("Error setting test config: %v\n", err)
+	}
+	discovery.Default = discovery.New()
+	for name, ip := range map[string]string{"Porch": "127.0.0.5", "Garage": "127.0.0.6"} {
+		discovery.Default.Add("wled", discovery.Found{
+			Name:   name,
+			Device: config.Device{Type: "wled", Config: config.DeviceConfig{Name: name, IpAddress: ip, PixelCount: 60}},
+		})
+	}
+}
+
+// fakeBridge answers bridge routes without starting a bridge
+func fakeBridge(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/api/bridge/ctl/youtube/info":
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"is_playing": true, "percent_complete": 12.50, "paused": false, "track_index": 0, "now_playing": {"title": "Song", "duration": "3m0s", "url": "https://example.com"}, "queued": null}`))
+	case "/api/bridge/ctl/file/info":
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"playing": true, "paused": false, "track_index": 1, "position": 2.5, "loop": "off", "queue": [{"source": "/music/a.flac", "title": "a", "duration": 180}, {"source": "http://radio.example/live"}]}`))
+	case "/api/bridge/ctl/recorder/info":
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"recording": false, "config": {"dir": "/var/lib/ledfx/recordings", "container": "wav", "prefix": "ledfx", "max_duration": 600}, "duration": 0, "files": ["/var/lib/ledfx/recordings/ledfx-20220304-201500.wav", "/var/lib/ledfx/recordings/ledfx-20220304-202500.wav"]}`))
+	case "/api/bridge/ctl/mixer/info":
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"duck_gain": 0.2, "level": {"rms": -20, "peak": -12}, "inputs": [{"name": "airplay_server", "settings": {"gain": 1, "mute": false, "priority": 1}, "level": {"rms": -18, "peak": -10}, "active": true, "ducked": false}, {"name": "local_capture", "settings": {"gain": 0.5, "mute": false, "priority": 0}, "level": {"rms": -30, "peak": -24}, "active": true, "ducked": true}]}`))
+	case "/api/bridge/ctl/output/info":
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"outputs": [{"id": "airplay:10.0.0.7:7000", "type": "airplay", "volume": 1, "muted": false, "Value": {"ip": "10.0.0.7", "port": 7000}}, {"id": "local", "type": "local", "volume": 0.5, "muted": true, "Value": {"identifier": "a1b2c3d4"}}]}`))
+	case "/api/bridge/ctl/delay/info":
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"outputs": [{"output": "airplay:10.0.0.7:7000", "delay_ms": 0, "latency_ms": 2000}, {"output": "leds", "delay_ms": 2000, "latency_ms": 0}]}`))
+	case "/api/bridge/ctl/airplay/clients":
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"clients": []}`))
+	case "/api/bridge/artwork":
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte{0x89, 'P', 'N', 'G'})
+	}
+}
+
+// recorder validates every request and response against the document and
+// remembers which operations were called
+type recorder struct {
+	t      *testing.T
+	doc    *openapi.Document
+	next   http.Handler
+	mu     sync.Mutex
+	called map[string]bool
+}
+
+func (rc *recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err := rc.doc.ValidateRequest(r.Method, r.URL.Path, body); err != nil {
+		rc.t.Errorf("Client request does not match the document: %v", err)
+	}
+	if op, ok := rc.doc.Find(r.Method, r.URL.Path); ok {
+		rc.mu.Lock()
+		rc.called[op.OperationId] = true
+		rc.mu.Unlock()
+	}
+
+	rec := httptest.NewRecorder()
+	rc.next.ServeHTTP(rec, r)
+	if err := rc.doc.ValidateResponse(r.Method, r.URL.Path, rec.Code, rec.Header().Get("Content-Type"), rec.Body.Bytes()); err != nil {
+		rc.t.Errorf("Server response does not match the document: %v", err)
+	}
+	for k, v := range rec.Header() {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(rec.Code)
+	w.Write(rec.Body.Bytes())
+}
+
+func newTestClient(t *testing.T) (*Client, *recorder) {
+	setupTestConfig(t)
+	doc, err := api.Spec()
+	if err != nil {
+		t.Fatalf("Error building OpenAPI document: %v\n", err)
+	}
+	mux := http.NewServeMux()
+	api.HandleApi(mux)
+	mux.HandleFunc("/api/bridge/", fakeBridge)
+	rc := &recorder{t: t, doc: doc, next: auth.Middleware(mux), called: make(map[string]bool)}
+	srv := httptest.NewServer(rc)
+	t.Cleanup(srv.Close)
+	return New(srv.URL, srv.Client()), rc
+}
+
+func TestClient(t *testing.T) {
+	c, rc := newTestClient(t)
+	ctx := context.Background()
+
+	check := func(name string, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("Error calling %s: %v\n", name, err)
+		}
+	}
+
+	doc, err := c.OpenAPI(ctx)
+	check("OpenAPI", err)
+	if doc.Info.Title != "LedFx" {
+		t.Errorf("Unexpected document info: %+v", doc.Info)
+	}
+	_, err = c.Config(ctx)
+	check("Config", err)
+	_, err = c.AudioDevices(ctx)
+	check("AudioDevices", err)
+	_, err = c.Schema(ctx)
+	check("Schema", err)
+	_, err = c.Colors(ctx)
+	check("Colors", err)
+	b, err := c.ExportConfig(ctx)
+	check("ExportConfig", err)
+	b.Presets = nil
+	changes, err := c.ImportConfig(ctx, *b, bundle.Replace, true)
+	check("ImportConfig", err)
+	if len(changes) != 1 || changes[0].Op != "remove" {
+		t.Errorf("Unexpected import changes: %+v", changes)
+	}
+	report, err := c.ImportLegacyConfig(ctx, []byte(`{"user_colors": {"Sunset": "#ff8000"}, "scenes": {"party": {}}}`))
+	check("ImportLegacyConfig", err)
+	if report.Colors != 1 || len(report.Untranslated) != 1 {
+		t.Errorf("Unexpected import report: %+v", report)
+	}
+
+	// Devices
+	devices, err := c.Devices(ctx)
+	check("Devices", err)
+	if len(devices) != 1 {
+		t.Errorf("Unexpected devices: %+v", devices)
+	}
+	dev, err := c.CreateDevice(ctx, config.Device{Type: "wled", Config: config.DeviceConfig{Name: "Shelf", IpAddress: "127.0.0.1", PixelCount: 10}})
+	check("CreateDevice", err)
+	if dev.Id != "shelf" {
+		t.Errorf("Unexpected device id '%s'", dev.Id)
+	}
+	_, err = c.Device(ctx, "shelf")
+	check("Device", err)
+	dev.Config.PixelCount = 20
+	_, err = c.ReplaceDevice(ctx, "shelf", dev)
+	check("ReplaceDevice", err)
+	dev, err = c.UpdateDevice(ctx, "shelf", map[string]interface{}{"config": map[string]interface{}{"name": "Top Shelf"}})
+	check("UpdateDevice", err)
+	if dev.Config.Name != "Top Shelf" || dev.Config.PixelCount != 20 {
+		t.Errorf("Device was not updated: %+v", dev)
+	}
+
+	// Discovery
+	pending, err := c.PendingDevices(ctx)
+	check("PendingDevices", err)
+	if len(pending) != 2 {
+		t.Fatalf("Expected 2 pending devices, got %+v", pending)
+	}
+	_, err = c.PendingDevice(ctx, "porch")
+	check("PendingDevice", err)
+	accepted, err := c.AcceptPendingDevice(ctx, "porch", map[string]interface{}{"config": map[string]interface{}{"name": "Front Porch"}})
+	check("AcceptPendingDevice", err)
+	if accepted.Id != "porch" || accepted.Config.Name != "Front Porch" || accepted.Config.PixelCount != 60 {
+		t.Errorf("Unexpected accepted device: %+v", accepted)
+	}
+	check("DismissPendingDevice", c.DismissPendingDevice(ctx, "garage"))
+	if _, err := c.PendingDevice(ctx, "garage"); !isStatus(err, http.StatusNotFound) {
+		t.Errorf("Expected 404 for a dismissed device, got %v", err)
+	}
+
+	// Power
+	_, err = c.ReplacePowerSupplies(ctx, []config.PowerSupply{{Id: "psu", Name: "Shelf PSU", MaxMilliamps: 4000}})
+	check("ReplacePowerSupplies", err)
+	dev.Config.Power.Supply = "psu"
+	_, err = c.ReplaceDevice(ctx, "shelf", dev)
+	check("ReplaceDevice", err)
+	if _, err := c.ReplacePowerSupplies(ctx, nil); !isStatus(err, http.StatusConflict) {
+		t.Errorf("Expected 409 removing a power supply in use, got %v", err)
+	}
+	supplies, err := c.PowerSupplies(ctx)
+	check("PowerSupplies", err)
+	if len(supplies) != 1 {
+		t.Errorf("Unexpected power supplies: %+v", supplies)
+	}
+	power, err := c.Power(ctx)
+	check("Power", err)
+	if power.Supplies["psu"].MaxMilliamps != 4000 {
+		t.Errorf("Unexpected power report: %+v", power)
+	}
+
+	// Virtuals
+	_, err = c.Virtuals(ctx)
+	check("Virtuals", err)
+	virt, err := c.CreateVirtual(ctx, config.Virtual{Config: config.VirtualConfig{Name: "Half Couch"}, Segments: [][]interface{}{{"couch", 0, 17, false}}})
+	check("CreateVirtual", err)
+	_, err = c.Virtual(ctx, virt.Id)
+	check("Virtual", err)
+	virt.Config.Name = "Half"
+	_, err = c.ReplaceVirtual(ctx, virt.Id, virt)
+	check("ReplaceVirtual", err)
+	_, err = c.UpdateVirtual(ctx, virt.Id, map[string]interface{}{"config": map[string]interface{}{"icon_name": "mdi:sofa"}})
+	check("UpdateVirtual", err)
+	_, err = c.SetVirtualEffect(ctx, virt.Id, config.Effect{Type: "singleColor", Config: config.EffectConfig{Color: "red"}})
+	check("SetVirtualEffect", err)
+	_, err = c.ReplaceVirtualEffect(ctx, virt.Id, config.Effect{Type: "singleColor", Config: config.EffectConfig{Color: "green"}})
+	check("ReplaceVirtualEffect", err)
+	e, err := c.UpdateVirtualEffect(ctx, virt.Id, map[string]interface{}{"config": map[string]interface{}{"background_color": "#000000"}})
+	check("UpdateVirtualEffect", err)
+	if e.Config.Color != "green" || e.Config.BackgroundColor != "#000000" {
+		t.Errorf("Effect was not updated: %+v", e)
+	}
+	_, err = c.VirtualEffect(ctx, virt.Id)
+	check("VirtualEffect", err)
+	_, err = c.VirtualPresets(ctx, virt.Id)
+	check("VirtualPresets", err)
+	e, err = c.ApplyPreset(ctx, virt.Id, "blue")
+	check("ApplyPreset", err)
+	if e.Config.Color != "#0000ff" {
+		t.Errorf("Preset was not applied: %+v", e)
+	}
+	check("ClearVirtualEffect", c.ClearVirtualEffect(ctx, virt.Id))
+	check("DeleteVirtual", c.DeleteVirtual(ctx, virt.Id))
+
+	// Effects
+	_, err = c.Effects(ctx)
+	check("Effects", err)
+	check("ClearEffects", c.ClearEffects(ctx))
+	et, err := c.EffectType(ctx, "singleColor")
+	check("EffectType", err)
+	if et.Name != "Single Color" {
+		t.Errorf("Unexpected effect type: %+v", et)
+	}
+	_, err = c.EffectTypePresets(ctx, "singleColor")
+	check("EffectTypePresets", err)
+
+	// Presets
+	_, err = c.Presets(ctx)
+	check("Presets", err)
+	preset, err := c.CreatePreset(ctx, config.Preset{Name: "Warm Red", Type: "singleColor", Config: config.EffectConfig{Color: "#ff2000"}})
+	check("CreatePreset", err)
+	_, err = c.Preset(ctx, preset.Id)
+	check("Preset", err)
+	_, err = c.ReplacePreset(ctx, preset.Id, preset)
+	check("ReplacePreset", err)
+	_, err = c.UpdatePreset(ctx, preset.Id, map[string]interface{}{"name": "Warmer Red"})
+	check("UpdatePreset", err)
+	check("DeletePreset", c.DeletePreset(ctx, preset.Id))
+
+	check("DeleteDevice", c.DeleteDevice(ctx, "shelf"))
+
+	// Colors
+	_, err = c.CreateColor(ctx, "Dawn", "#ff9000")
+	check("CreateColor", err)
+	_, err = c.SetVirtualEffect(ctx, "couch", config.Effect{Type: "singleColor", Config: config.EffectConfig{Color: "Dawn"}})
+	check("SetVirtualEffect", err)
+	_, err = c.ReplaceColor(ctx, "Dawn", "Dusk", "#ff6000")
+	check("ReplaceColor", err)
+	if e, _ := c.VirtualEffect(ctx, "couch"); e.Config.Color != "Dusk" {
+		t.Errorf("Renaming a color did not update the effect: %+v", e)
+	}
+	if err := c.DeleteColor(ctx, "Dusk"); !isStatus(err, http.StatusConflict) {
+		t.Errorf("Expected 409 deleting a color in use, got %v", err)
+	}
+	check("ClearVirtualEffect", c.ClearVirtualEffect(ctx, "couch"))
+	nv, err := c.Color(ctx, "Dusk")
+	check("Color", err)
+	if nv.Value != "#ff6000" {
+		t.Errorf("Unexpected color: %+v", nv)
+	}
+	check("DeleteColor", c.DeleteColor(ctx, "Dusk"))
+	_, err = c.CreateGradient(ctx, "Night", "linear-gradient(90deg, #000000 0%, #000080 100%)")
+	check("CreateGradient", err)
+	_, err = c.Gradient(ctx, "Night")
+	check("Gradient", err)
+	_, err = c.ReplaceGradient(ctx, "Night", "Midnight", "linear-gradient(90deg, #000000 0%, #000040 100%)")
+	check("ReplaceGradient", err)
+	check("DeleteGradient", c.DeleteGradient(ctx, "Midnight"))
+
+	// Bridge
+	check("SetInputAirPlay", c.SetInputAirPlay(ctx, AirPlayInput{Name: "LedFx", Port: 7000}))
+	check("SetInputYouTube", c.SetInputYouTube(ctx, true))
+	check("SetInputCapture", c.SetInputCapture(ctx, CaptureInput{AudioDevice: &config.AudioDevice{Id: "default"}}))
+	check("AddOutputAirPlay", c.AddOutputAirPlay(ctx, AirPlayOutput{SearchKey: "Kitchen", SearchType: "name"}))
+	check("AddOutputLocal", c.AddOutputLocal(ctx, false))
+	check("YouTubeSet", c.YouTubeSet(ctx, YouTubeCtl{Action: "play", URL: "https://example.com"}))
+	info, err := c.YouTubeInfo(ctx)
+	check("YouTubeInfo", err)
+	if info.NowPlaying.Title != "Song" || info.PercentComplete != 12.5 {
+		t.Errorf("Unexpected YouTube info: %+v", info)
+	}
+	check("SetInputSynth", c.SetInputSynth(ctx, SynthInput{Kind: "click", BPM: 128}))
+	check("SetInputFile", c.SetInputFile(ctx, false))
+	check("FileSet", c.FileSet(ctx, FileCtl{Action: "play", Sources: []string{"/music/a.flac"}}))
+	check("FileSet", c.FileSet(ctx, FileCtl{Action: "seek", Position: 2.5}))
+	fileInfo, err := c.FileInfo(ctx)
+	check("FileInfo", err)
+	if len(fileInfo.Queue) != 2 || fileInfo.Position != 2.5 {
+		t.Errorf("Unexpected file info: %+v", fileInfo)
+	}
+	check("AddOutputRecorder", c.AddOutputRecorder(ctx, nil))
+	check("RecorderSet", c.StartRecording(ctx, RecorderConfig{Dir: "/var/lib/ledfx/recordings", MaxDuration: 600}))
+	check("RecorderSet", c.StopRecording(ctx))
+	recInfo, err := c.RecorderInfo(ctx)
+	check("RecorderInfo", err)
+	if recInfo.Recording || len(recInfo.Files) != 2 || recInfo.Config.MaxDuration != 600 {
+		t.Errorf("Unexpected recorder info: %+v", recInfo)
+	}
+	gain, mute := 0.5, true
+	check("MixerSet", c.MixerSet(ctx, MixerCtl{Input: "local_capture", Gain: &gain, Mute: &mute}))
+	mixerInfo, err := c.MixerInfo(ctx)
+	check("MixerInfo", err)
+	if len(mixerInfo.Inputs) != 2 || !mixerInfo.Inputs[1].Ducked || mixerInfo.Inputs[0].Settings.Priority != 1 {
+		t.Errorf("Unexpected mixer info: %+v", mixerInfo)
+	}
+	check("MixerRemove", c.MixerRemove(ctx, "synth"))
+	check("OutputSet", c.SetOutputVolume(ctx, "local", 0.5))
+	check("OutputSet", c.SetOutputMute(ctx, "local", true))
+	outputs, err := c.Outputs(ctx)
+	check("OutputInfo", err)
+	if len(outputs) != 2 || outputs[0].ID != "airplay:10.0.0.7:7000" || !outputs[1].Muted {
+		t.Errorf("Unexpected outputs: %+v", outputs)
+	}
+	check("OutputRemove", c.RemoveOutput(ctx, "airplay:10.0.0.7:7000"))
+	check("DelaySet", c.SetDelay(ctx, "leds", 120))
+	check("DelaySet", c.SyncDelays(ctx))
+	delays, err := c.Delays(ctx)
+	check("DelayInfo", err)
+	if len(delays) != 2 || delays[1].Output != "leds" || delays[1].Delay != 2000 {
+		t.Errorf("Unexpected delays: %+v", delays)
+	}
+	check("StopAirPlayServer", c.StopAirPlayServer(ctx))
+	_, err = c.AirPlayClients(ctx)
+	check("AirPlayClients", err)
+	_, err = c.Artwork(ctx)
+	check("Artwork", err)
+
+	// Auth, turned on by creating the first token
+	status, err := c.Auth(ctx)
+	check("Auth", err)
+	if status.Enabled {
+		t.Errorf("Authentication should be off without credentials")
+	}
+	token, secret, err := c.CreateToken(ctx, "ci", "control")
+	check("CreateToken", err)
+	if _, err := c.Devices(ctx); !isStatus(err, http.StatusUnauthorized) {
+		t.Errorf("Expected 401 without a token, got %v", err)
+	}
+	ct := c.WithToken(secret)
+	status, err = ct.Auth(ctx)
+	check("Auth", err)
+	if !status.Enabled || status.Scope != "control" {
+		t.Errorf("Unexpected auth status: %+v", status)
+	}
+	tokens, err := ct.Tokens(ctx)
+	check("Tokens", err)
+	if len(tokens) != 1 || tokens[0].Id != token.Id {
+		t.Errorf("Unexpected tokens: %+v", tokens)
+	}
+	check("SetAllowedOrigins", ct.SetAllowedOrigins(ctx, []string{"http://localhost:3000"}))
+	check("SetPassword", ct.SetPassword(ctx, "hunter2"))
+	check("SetPassword", ct.SetPassword(ctx, ""))
+	check("DeleteToken", ct.DeleteToken(ctx, token.Id))
+	status, err = c.Auth(ctx)
+	check("Auth", err)
+	if status.Enabled {
+		t.Errorf("Authentication should be off after revoking the last token")
+	}
+
+	// Every operation in the document needs a client method
+	for _, path := range doc.SortedPaths() {
+		for _, op := range doc.Paths[path] {
+			if _, ok := uncovered[op.OperationId]; ok {
+				continue
+			}
+			if !rc.called[op.OperationId] {
+				t.Errorf("Operation '%s' (%s) has no client method", op.OperationId, path)
+			}
+		}
+	}
+}
+
+func isStatus(err error, code int) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == code
+}
+
+func TestClientError(t *testing.T) {
+	c, _ := newTestClient(t)
+	_, err := c.Device(context.Background(), "nope")
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected *Error, got %v", err)
+	}
+	if apiErr.StatusCode != http.StatusNotFound || !strings.Contains(apiErr.Message, "not found") {
+		t.Errorf("Unexpected error: %+v", apiErr)
+	}
+}
@@ -0,0 +1,332 @@
+package client
+
+import (
+	"context"
+	"ledfx/config"
+	"ledfx/device"
+	"ledfx/discovery"
+	"net/http"
+)
+
+// Update methods (PATCH) take any JSON-encodable value holding only the
+// fields to change, e.g. map[string]interface{}{"active": true}.
+
+// ############## BEGIN DEVICES ##############
+
+func (c *Client) Devices(ctx context.Context) ([]config.Device, error) {
+	var resp struct {
+		Devices []config.Device `json:"devices"`
+	}
+	return resp.Devices, c.do(ctx, http.MethodGet, "/api/devices", nil, &resp)
+}
+
+func (c *Client) Device(ctx context.Context, id string) (config.Device, error) {
+	return c.device(ctx, http.MethodGet, "/api/devices/"+escape(id), nil)
+}
+
+// CreateDevice adds a device. The server generates the id from the name if
+// it is empty.
+func (c *Client) CreateDevice(ctx context.Context, dev config.Device) (config.Device, error) {
+	return c.device(ctx, http.MethodPost, "/api/devices", dev)
+}
+
+func (c *Client) ReplaceDevice(ctx context.Context, id string, dev config.Device) (config.Device, error) {
+	return c.device(ctx, http.MethodPut, "/api/devices/"+escape(id), dev)
+}
+
+func (c *Client) UpdateDevice(ctx context.Context, id string, patch interface{}) (config.Device, error) {
+	return c.device(ctx, http.MethodPatch, "/api/devices/"+escape(id), patch)
+}
+
+// DeleteDevice removes a device and every virtual that belongs to it
+func (c *Client) DeleteDevice(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/devices/"+escape(id), nil, nil)
+}
+
+func (c *Client) device(ctx context.Context, method, path string, in interface{}) (config.Device, error) {
+	var resp struct {
+		Device config.Device `json:"device"`
+	}
+	return resp.Device, c.do(ctx, method, path, in, &resp)
+}
+
+// ############### END DEVICES ###############
+
+// ############## BEGIN DISCOVERY ##############
+
+// PendingDevices returns the devices found on the network that are not in
+// the config
+func (c *Client) PendingDevices(ctx context.Context) ([]discovery.Pending, error) {
+	var resp struct {
+		Pending []discovery.Pending `json:"pending"`
+	}
+	return resp.Pending, c.do(ctx, http.MethodGet, "/api/discovery/pending", nil, &resp)
+}
+
+func (c *Client) PendingDevice(ctx context.Context, id string) (discovery.Pending, error) {
+	var resp discovery.Pending
+	return resp, c.do(ctx, http.MethodGet, "/api/discovery/pending/"+escape(id), nil, &resp)
+}
+
+// AcceptPendingDevice merges fields into a pending device like UpdateDevice
+// and adds it to the config with its virtuals. Nil fields accept it as found.
+func (c *Client) AcceptPendingDevice(ctx context.Context, id string, fields interface{}) (config.Device, error) {
+	if fields == nil {
+		fields = struct{}{}
+	}
+	return c.device(ctx, http.MethodPost, "/api/discovery/pending/"+escape(id)+"/accept", fields)
+}
+
+// DismissPendingDevice removes a pending device, it is not listed again
+// until LedFx restarts
+func (c *Client) DismissPendingDevice(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/discovery/pending/"+escape(id), nil, nil)
+}
+
+// ############### END DISCOVERY ###############
+
+// ############## BEGIN POWER ##############
+
+// Power returns the estimated current draw of every device that sent a frame
+// lately and of every power supply
+func (c *Client) Power(ctx context.Context) (device.PowerReport, error) {
+	var resp device.PowerReport
+	return resp, c.do(ctx, http.MethodGet, "/api/power", nil, &resp)
+}
+
+func (c *Client) PowerSupplies(ctx context.Context) ([]config.PowerSupply, error) {
+	return c.supplies(ctx, http.MethodGet, nil)
+}
+
+// ReplacePowerSupplies sets the power supplies. Supplies that devices name
+// can not be removed.
+func (c *Client) ReplacePowerSupplies(ctx context.Context, supplies []config.PowerSupply) ([]config.PowerSupply, error) {
+	return c.supplies(ctx, http.MethodPut, map[string]interface{}{"supplies": supplies})
+}
+
+func (c *Client) supplies(ctx context.Context, method string, in interface{}) ([]config.PowerSupply, error) {
+	var resp struct {
+		Supplies []config.PowerSupply `json:"supplies"`
+	}
+	return resp.Supplies, c.do(ctx, method, "/api/power/supplies", in, &resp)
+}
+
+// ############### END POWER ###############
+
+// ############## BEGIN VIRTUALS ##############
+
+func (c *Client) Virtuals(ctx context.Context) ([]config.Virtual, error) {
+	var resp struct {
+		Virtuals []config.Virtual `json:"virtuals"`
+	}
+	return resp.Virtuals, c.do(ctx, http.MethodGet, "/api/virtuals", nil, &resp)
+}
+
+func (c *Client) Virtual(ctx context.Context, id string) (config.Virtual, error) {
+	return c.virtual(ctx, http.MethodGet, "/api/virtuals/"+escape(id), nil)
+}
+
+func (c *Client) CreateVirtual(ctx context.Context, virt config.Virtual) (config.Virtual, error) {
+	return c.virtual(ctx, http.MethodPost, "/api/virtuals", virt)
+}
+
+func (c *Client) ReplaceVirtual(ctx context.Context, id string, virt config.Virtual) (config.Virtual, error) {
+	return c.virtual(ctx, http.MethodPut, "/api/virtuals/"+escape(id), virt)
+}
+
+func (c *Client) UpdateVirtual(ctx context.Context, id string, patch interface{}) (config.Virtual, error) {
+	return c.virtual(ctx, http.MethodPatch, "/api/virtuals/"+escape(id), patch)
+}
+
+func (c *Client) DeleteVirtual(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/virtuals/"+escape(id), nil, nil)
+}
+
+func (c *Client) VirtualEffect(ctx context.Context, id string) (config.Effect, error) {
+	return c.effect(ctx, http.MethodGet, "/api/virtuals/"+escape(id)+"/effects", nil)
+}
+
+// SetVirtualEffect sets the effect of a virtual and activates it
+func (c *Client) SetVirtualEffect(ctx context.Context, id string, e config.Effect) (config.Effect, error) {
+	return c.effect(ctx, http.MethodPost, "/api/virtuals/"+escape(id)+"/effects", e)
+}
+
+func (c *Client) ReplaceVirtualEffect(ctx context.Context, id string, e config.Effect) (config.Effect, error) {
+	return c.effect(ctx, http.MethodPut, "/api/virtuals/"+escape(id)+"/effects", e)
+}
+
+func (c *Client) UpdateVirtualEffect(ctx context.Context, id string, patch interface{}) (config.Effect, error) {
+	return c.effect(ctx, http.MethodPatch, "/api/virtuals/"+escape(id)+"/effects", patch)
+}
+
+// ClearVirtualEffect removes the effect of a virtual and deactivates it
+func (c *Client) ClearVirtualEffect(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/virtuals/"+escape(id)+"/effects", nil, nil)
+}
+
+// VirtualPresets lists the presets matching the current effect of a virtual
+func (c *Client) VirtualPresets(ctx context.Context, id string) ([]config.Preset, error) {
+	return c.presets(ctx, "/api/virtuals/"+escape(id)+"/presets")
+}
+
+// ApplyPreset copies a preset into the effect of a virtual and activates it
+func (c *Client) ApplyPreset(ctx context.Context, id, presetId string) (config.Effect, error) {
+	req := struct {
+		PresetId string `json:"preset_id"`
+	}{presetId}
+	return c.effect(ctx, http.MethodPut, "/api/virtuals/"+escape(id)+"/presets", req)
+}
+
+func (c *Client) virtual(ctx context.Context, method, path string, in interface{}) (config.Virtual, error) {
+	var resp struct {
+		Virtual config.Virtual `json:"virtual"`
+	}
+	return resp.Virtual, c.do(ctx, method, path, in, &resp)
+}
+
+func (c *Client) effect(ctx context.Context, method, path string, in interface{}) (config.Effect, error) {
+	var resp struct {
+		Effect config.Effect `json:"effect"`
+	}
+	return resp.Effect, c.do(ctx, method, path, in, &resp)
+}
+
+// ############### END VIRTUALS ###############
+
+// ############## BEGIN EFFECTS ##############
+
+type EffectType struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type EffectPreset struct {
+	Name   string              `json:"name"`
+	Config config.EffectConfig `json:"config"`
+}
+
+type EffectPresets struct {
+	Status         string                  `json:"status"`
+	Effect         string                  `json:"effect"`
+	DefaultPresets map[string]EffectPreset `json:"default_presets"`
+	CustomPresets  map[string]EffectPreset `json:"custom_presets"`
+}
+
+// Effects returns the effect of every virtual that has one, keyed by virtual id
+func (c *Client) Effects(ctx context.Context) (map[string]config.Effect, error) {
+	var resp struct {
+		Effects map[string]config.Effect `json:"effects"`
+	}
+	return resp.Effects, c.do(ctx, http.MethodGet, "/api/effects", nil, &resp)
+}
+
+func (c *Client) ClearEffects(ctx context.Context) error {
+	return c.do(ctx, http.MethodDelete, "/api/effects", nil, nil)
+}
+
+func (c *Client) EffectType(ctx context.Context, effectType string) (EffectType, error) {
+	var resp EffectType
+	return resp, c.do(ctx, http.MethodGet, "/api/effects/"+escape(effectType), nil, &resp)
+}
+
+func (c *Client) EffectTypePresets(ctx context.Context, effectType string) (EffectPresets, error) {
+	var resp EffectPresets
+	return resp, c.do(ctx, http.MethodGet, "/api/effects/"+escape(effectType)+"/presets", nil, &resp)
+}
+
+// ############### END EFFECTS ###############
+
+// ############## BEGIN PRESETS ##############
+
+func (c *Client) Presets(ctx context.Context) ([]config.Preset, error) {
+	return c.presets(ctx, "/api/presets")
+}
+
+func (c *Client) Preset(ctx context.Context, id string) (config.Preset, error) {
+	return c.preset(ctx, http.MethodGet, "/api/presets/"+escape(id), nil)
+}
+
+func (c *Client) CreatePreset(ctx context.Context, preset config.Preset) (config.Preset, error) {
+	return c.preset(ctx, http.MethodPost, "/api/presets", preset)
+}
+
+func (c *Client) ReplacePreset(ctx context.Context, id string, preset config.Preset) (config.Preset, error) {
+	return c.preset(ctx, http.MethodPut, "/api/presets/"+escape(id), preset)
+}
+
+func (c *Client) UpdatePreset(ctx context.Context, id string, patch interface{}) (config.Preset, error) {
+	return c.preset(ctx, http.MethodPatch, "/api/presets/"+escape(id), patch)
+}
+
+func (c *Client) DeletePreset(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/presets/"+escape(id), nil, nil)
+}
+
+func (c *Client) presets(ctx context.Context, path string) ([]config.Preset, error) {
+	var resp struct {
+		Presets []config.Preset `json:"presets"`
+	}
+	return resp.Presets, c.do(ctx, http.MethodGet, path, nil, &resp)
+}
+
+func (c *Client) preset(ctx context.Context, method, path string, in interface{}) (config.Preset, error) {
+	var resp struct {
+		Preset config.Preset `json:"preset"`
+	}
+	return resp.Preset, c.do(ctx, method, path, in, &resp)
+}
+
+// ############### END PRESETS ###############
+
+// ############## BEGIN COLORS ##############
+
+// NamedValue is a user color or gradient
+type NamedValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func (c *Client) CreateColor(ctx context.Context, name, value string) (NamedValue, error) {
+	return c.named(ctx, http.MethodPost, "/api/colors", NamedValue{name, value})
+}
+
+func (c *Client) Color(ctx context.Context, name string) (NamedValue, error) {
+	return c.named(ctx, http.MethodGet, "/api/colors/"+escape(name), nil)
+}
+
+// ReplaceColor changes the value of a user color and renames it if newName
+// differs. Effects using the old name are updated.
+func (c *Client) ReplaceColor(ctx context.Context, name, newName, value string) (NamedValue, error) {
+	return c.named(ctx, http.MethodPut, "/api/colors/"+escape(name), NamedValue{newName, value})
+}
+
+// DeleteColor fails with 409 while an effect uses the color
+func (c *Client) DeleteColor(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, "/api/colors/"+escape(name), nil, nil)
+}
+
+func (c *Client) CreateGradient(ctx context.Context, name, value string) (NamedValue, error) {
+	return c.named(ctx, http.MethodPost, "/api/gradients", NamedValue{name, value})
+}
+
+func (c *Client) Gradient(ctx context.Context, name string) (NamedValue, error) {
+	return c.named(ctx, http.MethodGet, "/api/gradients/"+escape(name), nil)
+}
+
+// ReplaceGradient changes the value of a user gradient and renames it if
+// newName differs. Effects using the old name are updated.
+func (c *Client) ReplaceGradient(ctx context.Context, name, newName, value string) (NamedValue, error) {
+	return c.named(ctx, http.MethodPut, "/api/gradients/"+escape(name), NamedValue{newName, value})
+}
+
+// DeleteGradient fails with 409 while an effect uses the gradient
+func (c *Client) DeleteGradient(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, "/api/gradients/"+escape(name), nil, nil)
+}
+
+func (c *Client) named(ctx context.Context, method, path string, in interface{}) (NamedValue, error) {
+	var nv NamedValue
+	return nv, c.do(ctx, method, path, in, &nv)
+}
+
+// ############### END COLORS ###############
@@ -1,319 +1,278 @@
 package api
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
-	"ledfx/logger"
+	"ledfx/color"
+	"ledfx/config"
+	"ledfx/config/bundle"
 	"net/http"
+	"sort"
+	"strings"
 )
 
-func HandleColors() {
-	http.HandleFunc("/api/colors", func(w http.ResponseWriter, r *http.Request) {
+var errInUse = errors.New("is in use")
+
+type namedSet struct {
+	Builtin map[string]string `json:"builtin"`
+	User    map[string]string `json:"user"`
+}
+
+type colorsResponse struct {
+	Colors    namedSet `json:"colors"`
+	Gradients namedSet `json:"gradients"`
+}
+
+// namedValue is a user color or gradient
+type namedValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// namedKind describes user colors or user gradients, which are handled alike
+type namedKind struct {
+	kind    string
+	prefix  string
+	builtin map[string]string
+	check   func(string) error
+	// user returns the map of the kind in c, creating it if needed
+	user func(c *config.Config) map[string]string
+	// refs returns the effect config fields that may name one of the kind
+	refs func(e *config.EffectConfig) []*string
+}
+
+var (
+	userColors = namedKind{
+		kind:    "color",
+		prefix:  "/api/colors/",
+		builtin: color.LedFxColors,
+		check:   bundle.CheckColor,
+		user: func(c *config.Config) map[string]string {
+			if c.UserColors == nil {
+				c.UserColors = make(map[string]string)
+			}
+			return c.UserColors
+		},
+		refs: func(e *config.EffectConfig) []*string { return []*string{&e.Color, &e.BackgroundColor} },
+	}
+	userGradients = namedKind{
+		kind:    "gradient",
+		prefix:  "/api/gradients/",
+		builtin: color.LedFxGradients,
+		check:   bundle.CheckGradient,
+		user: func(c *config.Config) map[string]string {
+			if c.UserGradients == nil {
+				c.UserGradients = make(map[string]string)
+			}
+			return c.UserGradients
+		},
+		refs: func(e *config.EffectConfig) []*string { return []*string{&e.GradientName} },
+	}
+)
+
+func HandleColors(mux *http.ServeMux) {
+	mux.HandleFunc("/api/colors", userColors.handleCollection(getColors))
+	mux.HandleFunc("/api/colors/", userColors.handleItem)
+	mux.HandleFunc("/api/gradients", userGradients.handleCollection(nil))
+	mux.HandleFunc("/api/gradients/", userGradients.handleItem)
+}
+
+// getColors lists the builtin and user colors and gradients
+func getColors(w http.ResponseWriter, r *http.Request) {
+	conf := config.Snapshot()
+	writeJSON(w, http.StatusOK, colorsResponse{
+		Colors:    namedSet{Builtin: color.LedFxColors, User: nonNil(conf.UserColors)},
+		Gradients: namedSet{Builtin: color.LedFxGradients, User: nonNil(conf.UserGradients)},
+	})
+}
+
+// handleCollection creates user colors or gradients and serves get, if any
+func (k namedKind) handleCollection(get http.HandlerFunc) http.HandlerFunc {
+	allowed := []string{http.MethodPost}
+	if get != nil {
+		allowed = []string{http.MethodGet, http.MethodPost}
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
 		SetHeader(w)
+		switch {
+		case r.Method == http.MethodOptions:
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodGet && get != nil:
+			get(w, r)
+		case r.Method == http.MethodPost:
+			var nv namedValue
+			if err := decodeBody(r, &nv); err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+			if err := k.validate(nv); err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+			err := config.Update(func(c *config.Config) error {
+				user := k.user(c)
+				if _, exists := user[nv.Name]; exists {
+					return fmt.Errorf("%s '%s' %w", k.kind, nv.Name, errAlreadyExists)
+				}
+				user[nv.Name] = nv.Value
+				return nil
+			})
+			if err != nil {
+				writeError(w, k.status(err), err)
+				return
+			}
+			writeJSON(w, http.StatusCreated, nv)
+		default:
+			writeMethodNotAllowed(w, r, allowed...)
+		}
+	}
+}
+
+// handleItem gets, changes, renames or deletes a user color or gradient.
+// Renaming updates the virtuals and presets that refer to the old name.
+func (k namedKind) handleItem(w http.ResponseWriter, r *http.Request) {
+	SetHeader(w)
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
 
-		rawIn := json.RawMessage(`
-		{
-			"colors": {
-				"builtin": {
-					"red": "#ff0000",
-					"orange-deep": "#ff2800",
-					"orange": "#ff7800",
-					"yellow": "#ffc800",
-					"yellow-acid": "#a0ff00",
-					"green": "#00ff00",
-					"green-forest": "#228b22",
-					"green-spring": "#00ff7f",
-					"green-teal": "#008080",
-					"green-turquoise": "#00c78c",
-					"green-coral": "#00ff32",
-					"cyan": "#00ffff",
-					"blue": "#0000ff",
-					"blue-light": "#4169e1",
-					"blue-navy": "#000080",
-					"blue-aqua": "#00ffff",
-					"purple": "#800080",
-					"pink": "#ff00b2",
-					"magenta": "#ff00ff",
-					"black": "#000000",
-					"white": "#ffffff",
-					"gold": "#ffd700",
-					"hotpink": "#ff69b4",
-					"lightblue": "#add8e6",
-					"lightgreen": "#98fb98",
-					"lightpink": "#ffb6c1",
-					"lightyellow": "#ffffe0",
-					"maroon": "#800000",
-					"mint": "#bdfcc9",
-					"olive": "#556b2f",
-					"peach": "#ff6464",
-					"plum": "#dda0dd",
-					"sepia": "#5e2612",
-					"skyblue": "#87ceeb",
-					"steelblue": "#4682b4",
-					"tan": "#d2b48c",
-					"violetred": "#d02090"
-				},
-				"user": {}
-			},
-			"gradients": {
-				"builtin": {
-					"Rainbow": "linear-gradient(90deg, rgb(255, 0, 0) 0%, rgb(255, 120, 0) 14%, rgb(255, 200, 0) 28%, rgb(0, 255, 0) 42%, rgb(0, 199, 140) 56%, rgb(0, 0, 255) 70%, rgb(128, 0, 128) 84%, rgb(255, 0, 178) 98%)",
-					"Dancefloor": "linear-gradient(90deg, rgb(255, 0, 0) 0%, rgb(255, 0, 178) 50%, rgb(0, 0, 255) 100%)",
-					"Plasma": "linear-gradient(90deg, rgb(0, 0, 255) 0%, rgb(128, 0, 128) 25%, rgb(255, 0, 0) 50%, rgb(255, 40, 0) 75%, rgb(255, 200, 0) 100%)",
-					"Ocean": "linear-gradient(90deg, rgb(0, 255, 255) 0%, rgb(0, 0, 255) 100%)",
-					"Viridis": "linear-gradient(90deg, rgb(128, 0, 128) 0%, rgb(0, 0, 255) 25%, rgb(0, 128, 128) 50%, rgb(0, 255, 0) 75%, rgb(255, 200, 0) 100%)",
-					"Jungle": "linear-gradient(90deg, rgb(0, 255, 0) 0%, rgb(34, 139, 34) 50%, rgb(255, 120, 0) 100%)",
-					"Spring": "linear-gradient(90deg, rgb(255, 0, 178) 0%, rgb(255, 40, 0) 50%, rgb(255, 200, 0) 100%)",
-					"Winter": "linear-gradient(90deg, rgb(0, 199, 140) 0%, rgb(0, 255, 50) 100%)",
-					"Frost": "linear-gradient(90deg, rgb(0, 0, 255) 0%, rgb(0, 255, 255) 33%, rgb(128, 0, 128) 66%, rgb(255, 0, 178) 99%)",
-					"Sunset": "linear-gradient(90deg, rgb(0, 0, 128) 0%, rgb(255, 120, 0) 50%, rgb(255, 0, 0) 100%)",
-					"Borealis": "linear-gradient(90deg, rgb(255, 40, 0) 0%, rgb(128, 0, 128) 33%, rgb(0, 199, 140) 66%, rgb(0, 255, 0) 99%)",
-					"Rust": "linear-gradient(90deg, rgb(255, 40, 0) 0%, rgb(255, 0, 0) 100%)",
-					"Winamp": "linear-gradient(90deg, rgb(0, 255, 0) 0%, rgb(255, 200, 0) 25%, rgb(255, 120, 0) 50%, rgb(255, 40, 0) 75%, rgb(255, 0, 0) 100%)"
-				},
-				"user": {}
+	segments := pathSegments(r.URL.Path, k.prefix)
+	if len(segments) != 1 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("path '%s' %w", r.URL.Path, errNotFound))
+		return
+	}
+	name := segments[0]
+	conf := config.Snapshot()
+	value, ok := k.user(&conf)[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("user %s '%s' %w", k.kind, name, errNotFound))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, namedValue{Name: name, Value: value})
+	case http.MethodPut:
+		nv := namedValue{Name: name}
+		if err := decodeBody(r, &nv); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := k.validate(nv); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		err := config.Update(func(c *config.Config) error {
+			user := k.user(c)
+			if _, ok := user[name]; !ok {
+				return fmt.Errorf("user %s '%s' %w", k.kind, name, errNotFound)
 			}
-		}`)
-		var objmap map[string]*json.RawMessage
-		err := json.Unmarshal(rawIn, &objmap)
+			if nv.Name != name {
+				if _, exists := user[nv.Name]; exists {
+					return fmt.Errorf("%s '%s' %w", k.kind, nv.Name, errAlreadyExists)
+				}
+				delete(user, name)
+				k.rename(c, name, nv.Name)
+			}
+			user[nv.Name] = nv.Value
+			return nil
+		})
 		if err != nil {
-			fmt.Println(err)
+			writeError(w, k.status(err), err)
+			return
 		}
-		fmt.Println(objmap)
-
-		err = json.NewEncoder(w).Encode(objmap)
+		writeJSON(w, http.StatusOK, nv)
+	case http.MethodDelete:
+		err := config.Update(func(c *config.Config) error {
+			if users := k.usedBy(c, name); len(users) > 0 {
+				return fmt.Errorf("user %s '%s' %w by %s", k.kind, name, errInUse, strings.Join(users, ", "))
+			}
+			delete(k.user(c), name)
+			return nil
+		})
 		if err != nil {
-			logger.Logger.Warn(err)
+			writeError(w, k.status(err), err)
+			return
 		}
-		// json.NewEncoder(w).Encode(config.Schema.Effects)
-	})
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeMethodNotAllowed(w, r, http.MethodGet, http.MethodPut, http.MethodDelete)
+	}
+}
 
-	http.HandleFunc("/api/effects/singleColor/presets", func(w http.ResponseWriter, r *http.Request) {
-		SetHeader(w)
+func (k namedKind) validate(nv namedValue) error {
+	switch {
+	case nv.Name == "":
+		return fmt.Errorf("%s name is empty", k.kind)
+	case k.isBuiltin(nv.Name):
+		return fmt.Errorf("'%s' is a builtin %s", nv.Name, k.kind)
+	}
+	if err := k.check(nv.Value); err != nil {
+		return fmt.Errorf("invalid %s '%s': %w", k.kind, nv.Value, err)
+	}
+	return nil
+}
 
-		rawIn := json.RawMessage(`
-		{
-			"status": "success",
-			"effect": "singleColor",
-			"default_presets": {},
-			"custom_presets": {}
-		}`)
-		// ToDo: uncomment when preset-handling is there
-		// rawIn := json.RawMessage(`
-		// {
-		// 	"status": "success",
-		// 	"effect": "singleColor",
-		// 	"default_presets": {
-		// 		"reset": {
-		// 			"config": {},
-		// 			"name": "Reset"
-		// 		},
-		// 		"blue": {
-		// 			"config": {
-		// 				"background_brightness": 1.0,
-		// 				"background_color": "black",
-		// 				"blur": 0,
-		// 				"brightness": 1,
-		// 				"color": "blue",
-		// 				"decay": 1,
-		// 				"flip": false,
-		// 				"mirror": true,
-		// 				"modulate": false,
-		// 				"modulation_effect": "sine",
-		// 				"modulation_speed": 0.5,
-		// 				"speed": 5,
-		// 				"threshold": 0.7
-		// 			},
-		// 			"name": "Blue"
-		// 		},
-		// 		"cyan": {
-		// 			"config": {
-		// 				"background_brightness": 1.0,
-		// 				"background_color": "black",
-		// 				"blur": 0,
-		// 				"brightness": 1,
-		// 				"color": "cyan",
-		// 				"decay": 1,
-		// 				"flip": false,
-		// 				"mirror": true,
-		// 				"modulate": false,
-		// 				"modulation_effect": "sine",
-		// 				"modulation_speed": 0.5,
-		// 				"speed": 5,
-		// 				"threshold": 0.7
-		// 			},
-		// 			"name": "Cyan"
-		// 		},
-		// 		"green": {
-		// 			"config": {
-		// 				"background_brightness": 1.0,
-		// 				"background_color": "black",
-		// 				"blur": 0,
-		// 				"brightness": 1,
-		// 				"color": "green",
-		// 				"decay": 1,
-		// 				"flip": false,
-		// 				"mirror": true,
-		// 				"modulate": false,
-		// 				"modulation_effect": "sine",
-		// 				"modulation_speed": 0.5,
-		// 				"speed": 5,
-		// 				"threshold": 0.7
-		// 			},
-		// 			"name": "Green"
-		// 		},
-		// 		"magenta": {
-		// 			"config": {
-		// 				"background_brightness": 1.0,
-		// 				"background_color": "black",
-		// 				"blur": 0,
-		// 				"brightness": 1,
-		// 				"color": "magenta",
-		// 				"decay": 1,
-		// 				"flip": false,
-		// 				"mirror": true,
-		// 				"modulate": false,
-		// 				"modulation_effect": "sine",
-		// 				"modulation_speed": 0.5,
-		// 				"speed": 5,
-		// 				"threshold": 0.7
-		// 			},
-		// 			"name": "Magenta"
-		// 		},
-		// 		"orange": {
-		// 			"config": {
-		// 				"background_brightness": 1.0,
-		// 				"background_color": "black",
-		// 				"blur": 0,
-		// 				"brightness": 1,
-		// 				"color": "orange-deep",
-		// 				"decay": 1,
-		// 				"flip": false,
-		// 				"mirror": true,
-		// 				"modulate": false,
-		// 				"modulation_effect": "sine",
-		// 				"modulation_speed": 0.5,
-		// 				"speed": 5,
-		// 				"threshold": 0.7
-		// 			},
-		// 			"name": "Orange"
-		// 		},
-		// 		"pink": {
-		// 			"config": {
-		// 				"background_brightness": 1.0,
-		// 				"background_color": "black",
-		// 				"blur": 0,
-		// 				"brightness": 1,
-		// 				"color": "pink",
-		// 				"decay": 1,
-		// 				"flip": false,
-		// 				"mirror": true,
-		// 				"modulate": false,
-		// 				"modulation_effect": "sine",
-		// 				"modulation_speed": 0.5,
-		// 				"speed": 5,
-		// 				"threshold": 0.7
-		// 			},
-		// 			"name": "Pink"
-		// 		},
-		// 		"red": {
-		// 			"config": {
-		// 				"background_brightness": 1.0,
-		// 				"background_color": "black",
-		// 				"blur": 0,
-		// 				"brightness": 1,
-		// 				"color": "red",
-		// 				"decay": 1,
-		// 				"flip": false,
-		// 				"mirror": true,
-		// 				"modulate": false,
-		// 				"modulation_effect": "sine",
-		// 				"modulation_speed": 0.5,
-		// 				"speed": 5,
-		// 				"threshold": 0.7
-		// 			},
-		// 			"name": "Red"
-		// 		},
-		// 		"red-waves": {
-		// 			"config": {
-		// 				"background_brightness": 1.0,
-		// 				"background_color": "black",
-		// 				"blur": 6.2,
-		// 				"brightness": 1,
-		// 				"color": "red",
-		// 				"flip": false,
-		// 				"mirror": true,
-		// 				"modulate": true,
-		// 				"modulation_effect": "sine",
-		// 				"modulation_speed": 0.76,
-		// 				"speed": 0.62
-		// 			},
-		// 			"name": "Red Waves"
-		// 		},
-		// 		"steel-pulse": {
-		// 			"config": {
-		// 				"background_brightness": 1.0,
-		// 				"background_color": "black",
-		// 				"blur": 6.2,
-		// 				"brightness": 1,
-		// 				"color": "steelblue",
-		// 				"flip": false,
-		// 				"mirror": true,
-		// 				"modulate": true,
-		// 				"modulation_effect": "breath",
-		// 				"modulation_speed": 0.75,
-		// 				"speed": 0.62
-		// 			},
-		// 			"name": "Steel Pulse"
-		// 		},
-		// 		"turquoise-roll": {
-		// 			"config": {
-		// 				"background_brightness": 1.0,
-		// 				"background_color": "black",
-		// 				"blur": 6.2,
-		// 				"brightness": 1,
-		// 				"color": "green-turquoise",
-		// 				"flip": false,
-		// 				"mirror": false,
-		// 				"modulate": true,
-		// 				"modulation_effect": "sine",
-		// 				"modulation_speed": 0.76,
-		// 				"speed": 0.62
-		// 			},
-		// 			"name": "Turquoise Roll"
-		// 		},
-		// 		"yellow": {
-		// 			"config": {
-		// 				"background_brightness": 1.0,
-		// 				"background_color": "black",
-		// 				"blur": 0,
-		// 				"brightness": 1,
-		// 				"color": "yellow",
-		// 				"decay": 1,
-		// 				"flip": false,
-		// 				"mirror": true,
-		// 				"modulate": false,
-		// 				"modulation_effect": "sine",
-		// 				"modulation_speed": 0.5,
-		// 				"speed": 5,
-		// 				"threshold": 0.7
-		// 			},
-		// 			"name": "Yellow"
-		// 		}
-		// 	},
-		// 	"custom_presets": {}
-		// }`)
-		var objmap map[string]*json.RawMessage
-		err := json.Unmarshal(rawIn, &objmap)
-		if err != nil {
-			fmt.Println(err)
+// isBuiltin compares color names without case, like NewColor does
+func (k namedKind) isBuiltin(name string) bool {
+	if _, ok := k.builtin[name]; ok {
+		return true
+	}
+	_, ok := k.builtin[strings.ToLower(name)]
+	return ok && k.kind == "color"
+}
+
+func (k namedKind) status(err error) int {
+	switch {
+	case errors.Is(err, errNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errAlreadyExists), errors.Is(err, errInUse):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// effectConfigs returns the effect configs of every virtual and preset in c
+// by a description of their owner
+func effectConfigs(c *config.Config) map[string]*config.EffectConfig {
+	configs := make(map[string]*config.EffectConfig, len(c.Virtuals)+len(c.Presets))
+	for i := range c.Virtuals {
+		configs["virtual '"+c.Virtuals[i].Id+"'"] = &c.Virtuals[i].Effect.Config
+	}
+	for i := range c.Presets {
+		configs["preset '"+c.Presets[i].Id+"'"] = &c.Presets[i].Config
+	}
+	return configs
+}
+
+func (k namedKind) rename(c *config.Config, from, to string) {
+	for _, ec := range effectConfigs(c) {
+		for _, field := range k.refs(ec) {
+			if *field == from {
+				*field = to
+			}
 		}
-		fmt.Println(objmap)
+	}
+}
 
-		err = json.NewEncoder(w).Encode(objmap)
-		if err != nil {
-			logger.Logger.Warn(err)
+func (k namedKind) usedBy(c *config.Config, name string) []string {
+	var users []string
+	for owner, ec := range effectConfigs(c) {
+		for _, field := range k.refs(ec) {
+			if *field == name {
+				users = append(users, owner)
+				break
+			}
 		}
-		// json.NewEncoder(w).Encode(config.Schema.Effects)
-	})
+	}
+	sort.Strings(users)
+	return users
+}
+
+func nonNil(m map[string]string) map[string]string {
+	if m == nil {
+		return map[string]string{}
+	}
+	return m
 }
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"ledfx/config"
+	"net/http"
+	"testing"
+)
+
+func TestGetColors(t *testing.T) {
+	setupTestConfig(t)
+	rec := doRequest(t, http.MethodGet, "/api/colors", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	var resp colorsResponse
+	decodeResponse(t, rec, &resp)
+	if resp.Colors.Builtin["red"] != "#ff0000" || resp.Colors.User["Sunset"] != "#ff8000" || resp.Gradients.User["Dusk"] == "" {
+		t.Errorf("Unexpected colors: %+v", resp)
+	}
+}
+
+func TestCreateColor(t *testing.T) {
+	setupTestConfig(t)
+	rec := doRequest(t, http.MethodPost, "/api/colors", `{"name": "Mint Leaf", "value": "rgb(0, 255, 128)"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if value := config.Snapshot().UserColors["Mint Leaf"]; value != "rgb(0, 255, 128)" {
+		t.Errorf("Color was not created: %v", config.Snapshot().UserColors)
+	}
+
+	expectError(t, doRequest(t, http.MethodPost, "/api/colors", `{"name": "Sunset", "value": "#ff0000"}`), http.StatusConflict)
+	expectError(t, doRequest(t, http.MethodPost, "/api/colors", `{"name": "Red", "value": "#ff0000"}`), http.StatusBadRequest)
+	expectError(t, doRequest(t, http.MethodPost, "/api/colors", `{"name": "Bad", "value": "#ff"}`), http.StatusBadRequest)
+	expectError(t, doRequest(t, http.MethodPost, "/api/gradients", `{"name": "Bad", "value": "linear"}`), http.StatusBadRequest)
+}
+
+func TestRenameColor(t *testing.T) {
+	setupTestConfig(t)
+	rec := doRequest(t, http.MethodPatch, "/api/virtuals/couch", `{"effect": {"type": "singleColor", "config": {"color": "Sunset", "background_color": "Sunset"}}}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected an effect using a user color to be accepted, got %d: %s", rec.Code, rec.Body.String())
+	}
+	expectError(t, doRequest(t, http.MethodDelete, "/api/colors/Sunset", nil), http.StatusConflict)
+
+	rec = doRequest(t, http.MethodPut, "/api/colors/Sunset", `{"name": "Dawn", "value": "#ff9000"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	conf := config.Snapshot()
+	if e := conf.Virtuals[0].Effect.Config; e.Color != "Dawn" || e.BackgroundColor != "Dawn" {
+		t.Errorf("Effect still uses the old name: %+v", e)
+	}
+	if _, ok := conf.UserColors["Sunset"]; ok || conf.UserColors["Dawn"] != "#ff9000" {
+		t.Errorf("Color was not renamed: %v", conf.UserColors)
+	}
+	expectError(t, doRequest(t, http.MethodGet, "/api/colors/Sunset", nil), http.StatusNotFound)
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"fmt"
+	"ledfx/config"
+	"ledfx/device"
+	"ledfx/util"
+	"ledfx/virtual"
+	"net/http"
+)
+
+type devicesResponse struct {
+	Devices []config.Device `json:"devices"`
+}
+
+type deviceResponse struct {
+	Device config.Device `json:"device"`
+}
+
+func handleDevices(w http.ResponseWriter, r *http.Request) {
+	SetHeader(w)
+	switch r.Method {
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, devicesResponse{Devices: config.Snapshot().Devices})
+	case http.MethodPost:
+		var dev config.Device
+		if err := decodeBody(r, &dev); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if dev.Id == "" {
+			dev.Id = uniqueId(dev.Config.Name, deviceExists)
+		} else if deviceExists(dev.Id) {
+			writeError(w, http.StatusConflict, fmt.Errorf("device '%s' %w", dev.Id, errAlreadyExists))
+			return
+		}
+		if err := device.ValidateDevice(dev); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := device.CheckSupply(dev, config.Snapshot().PowerSupplies); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := device.AddDeviceToConfig(dev); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, deviceResponse{Device: dev})
+	default:
+		writeMethodNotAllowed(w, r, http.MethodGet, http.MethodPost)
+	}
+}
+
+func handleDevice(w http.ResponseWriter, r *http.Request) {
+	SetHeader(w)
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	segments := pathSegments(r.URL.Path, "/api/devices/")
+	if len(segments) != 1 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("path '%s' %w", r.URL.Path, errNotFound))
+		return
+	}
+	id := segments[0]
+
+	existing, ok := getDevice(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("device '%s' %w", id, errNotFound))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, deviceResponse{Device: existing})
+	case http.MethodPut, http.MethodPatch:
+		dev := existing
+		if r.Method == http.MethodPut {
+			dev = config.Device{}
+		}
+		if err := decodeBody(r, &dev); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if dev.Id != "" && dev.Id != id {
+			writeError(w, http.StatusBadRequest, errIdMismatch)
+			return
+		}
+		dev.Id = id
+		if err := device.ValidateDevice(dev); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := device.CheckSupply(dev, config.Snapshot().PowerSupplies); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := device.AddDeviceToConfig(dev); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, deviceResponse{Device: dev})
+	case http.MethodDelete:
+		if err := virtual.ReleaseDevice(id); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if err := device.RemoveDeviceFromConfig(id); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeMethodNotAllowed(w, r, http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete)
+	}
+}
+
+func getDevice(id string) (config.Device, bool) {
+	for _, dev := range config.Snapshot().Devices {
+		if dev.Id == id {
+			return dev, true
+		}
+	}
+	return config.Device{}, false
+}
+
+func deviceExists(id string) bool {
+	_, ok := getDevice(id)
+	return ok
+}
+
+// uniqueId generates an id from name, appending a counter if it is taken
+func uniqueId(name string, exists func(id string) bool) string {
+	base := util.GenerateId(name)
+	if base == "" {
+		return ""
+	}
+	id := base
+	for i := 2; exists(id); i++ {
+		id = fmt.Sprintf("%s-%d", base, i)
+	}
+	return id
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"ledfx/config"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestGetDevices(t *testing.T) {
+	setupTestConfig(t)
+	rec := doRequest(t, http.MethodGet, "/api/devices", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	var resp devicesResponse
+	decodeResponse(t, rec, &resp)
+	if len(resp.Devices) != 1 || resp.Devices[0].Id != "couch" {
+		t.Errorf("Unexpected devices: %+v", resp.Devices)
+	}
+}
+
+func TestCreateDevice(t *testing.T) {
+	cases := []struct {
+		name string
+		body interface{}
+		code int
+		id   string
+	}{
+		{"generated id", config.Device{Type: "wled", Config: config.DeviceConfig{Name: "Kitchen Strip", IpAddress: "127.0.0.1", PixelCount: 10}}, http.StatusCreated, "kitchen-strip"},
+		{"deduplicated id", config.Device{Type: "wled", Config: config.DeviceConfig{Name: "Couch", IpAddress: "127.0.0.1", PixelCount: 10}}, http.StatusCreated, "couch-2"},
+		{"explicit id conflict", config.Device{Id: "couch", Type: "wled", Config: config.DeviceConfig{Name: "Couch", IpAddress: "127.0.0.1", PixelCount: 10}}, http.StatusConflict, ""},
+		{"no pixels", config.Device{Type: "wled", Config: config.DeviceConfig{Name: "Bad", IpAddress: "127.0.0.1"}}, http.StatusBadRequest, ""},
+		{"unknown protocol", config.Device{Type: "wled", Config: config.DeviceConfig{Name: "Bad", IpAddress: "127.0.0.1", PixelCount: 1, UdpPacketType: "FOO"}}, http.StatusBadRequest, ""},
+		{"malformed body", "{", http.StatusBadRequest, ""},
+		{"empty body", nil, http.StatusBadRequest, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			setupTestConfig(t)
+			rec := doRequest(t, http.MethodPost, "/api/devices", c.body)
+			if c.code != http.StatusCreated {
+				expectError(t, rec, c.code)
+				return
+			}
+			if rec.Code != c.code {
+				t.Fatalf("Expected status %d, got %d: %s", c.code, rec.Code, rec.Body.String())
+			}
+			var resp deviceResponse
+			decodeResponse(t, rec, &resp)
+			if resp.Device.Id != c.id {
+				t.Errorf("Expected id %s, got %s", c.id, resp.Device.Id)
+			}
+			if !deviceExists(c.id) {
+				t.Errorf("Device %s was not added to config", c.id)
+			}
+		})
+	}
+}
+
+func TestGetDevice(t *testing.T) {
+	setupTestConfig(t)
+	rec := doRequest(t, http.MethodGet, "/api/devices/couch", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	var resp deviceResponse
+	decodeResponse(t, rec, &resp)
+	if resp.Device.Config.Name != "Couch" {
+		t.Errorf("Unexpected device: %+v", resp.Device)
+	}
+
+	expectError(t, doRequest(t, http.MethodGet, "/api/devices/nope", nil), http.StatusNotFound)
+	expectError(t, doRequest(t, http.MethodGet, "/api/devices/couch/extra", nil), http.StatusNotFound)
+}
+
+func TestPutDevice(t *testing.T) {
+	setupTestConfig(t)
+	dev := config.Device{Type: "wled", Config: config.DeviceConfig{Name: "Sofa", IpAddress: "127.0.0.2", PixelCount: 20}}
+	rec := doRequest(t, http.MethodPut, "/api/devices/couch", dev)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	got, _ := getDevice("couch")
+	if got.Config.Name != "Sofa" || got.Config.PixelCount != 20 {
+		t.Errorf("Device was not replaced: %+v", got)
+	}
+
+	// PUT replaces the whole resource, so missing fields fail validation
+	expectError(t, doRequest(t, http.MethodPut, "/api/devices/couch", map[string]string{"type": "wled"}), http.StatusBadRequest)
+
+	dev.Id = "other"
+	expectError(t, doRequest(t, http.MethodPut, "/api/devices/couch", dev), http.StatusBadRequest)
+}
+
+func TestPatchDevice(t *testing.T) {
+	setupTestConfig(t)
+	rec := doRequest(t, http.MethodPatch, "/api/devices/couch", `{"config": {"pixel_count": 50}}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	got, _ := getDevice("couch")
+	if got.Config.PixelCount != 50 || got.Config.Name != "Couch" {
+		t.Errorf("Device was not patched: %+v", got)
+	}
+
+	expectError(t, doRequest(t, http.MethodPatch, "/api/devices/couch", `{"config": {"pixel_count": -1}}`), http.StatusBadRequest)
+	if got, _ := getDevice("couch"); got.Config.PixelCount != 50 {
+		t.Errorf("Rejected patch modified the device: %+v", got)
+	}
+}
+
+func TestDeleteDevice(t *testing.T) {
+	setupTestConfig(t)
+	rec := doRequest(t, http.MethodDelete, "/api/devices/couch", nil)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if deviceExists("couch") {
+		t.Errorf("Device was not removed")
+	}
+	if virtualExists("couch") {
+		t.Errorf("Device virtual was not removed")
+	}
+	expectError(t, doRequest(t, http.MethodDelete, "/api/devices/couch", nil), http.StatusNotFound)
+}
+
+func TestDeleteStreamingDevice(t *testing.T) {
+	setupTestConfig(t)
+	// Stand in for the device, it gets a black frame once its virtual stops
+	conn, err := net.ListenPacket("udp", "127.0.0.1:21324")
+	if err != nil {
+		t.Skipf("WLED port is taken: %v", err)
+	}
+	defer conn.Close()
+	if err := config.Update(func(c *config.Config) error {
+		c.Virtuals[0].Active = true
+		return nil
+	}); err != nil {
+		t.Fatalf("Error activating virtual: %v\n", err)
+	}
+
+	rec := doRequest(t, http.MethodDelete, "/api/devices/couch", nil)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, _, err := conn.ReadFrom(make([]byte, 1500)); err != nil {
+		t.Errorf("Virtual streaming to the removed device was not stopped: %v", err)
+	}
+}
+
+func TestDevicesMethodNotAllowed(t *testing.T) {
+	setupTestConfig(t)
+	expectError(t, doRequest(t, http.MethodDelete, "/api/devices", nil), http.StatusMethodNotAllowed)
+	expectError(t, doRequest(t, http.MethodPost, "/api/devices/couch", nil), http.StatusMethodNotAllowed)
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"ledfx/discovery"
+	"net/http"
+)
+
+type pendingResponse struct {
+	Pending []discovery.Pending `json:"pending"`
+}
+
+// handlePendingDevices lists the devices discovery found that are not in the
+// config
+func handlePendingDevices(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, pendingResponse{Pending: discovery.List()})
+}
+
+// handlePendingDevice accepts a pending device into the config or dismisses it
+func handlePendingDevice(w http.ResponseWriter, r *http.Request) {
+	SetHeader(w)
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	segments := pathSegments(r.URL.Path, "/api/discovery/pending/")
+	if len(segments) == 0 || len(segments) > 2 || (len(segments) == 2 && segments[1] != "accept") {
+		writeError(w, http.StatusNotFound, fmt.Errorf("path '%s' %w", r.URL.Path, errNotFound))
+		return
+	}
+	id := segments[0]
+
+	pending, ok := discovery.Get(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("pending device '%s' %w", id, errNotFound))
+		return
+	}
+
+	if len(segments) == 2 {
+		if r.Method != http.MethodPost {
+			writeMethodNotAllowed(w, r, http.MethodPost)
+			return
+		}
+		dev := pending.Device
+		if err := decodeBody(r, &dev); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		dev, err := discovery.Accept(id, dev)
+		switch {
+		case errors.Is(err, discovery.ErrNotFound):
+			writeError(w, http.StatusNotFound, err)
+		case errors.Is(err, discovery.ErrExists):
+			writeError(w, http.StatusConflict, err)
+		case err != nil:
+			writeError(w, http.StatusBadRequest, err)
+		default:
+			writeJSON(w, http.StatusCreated, deviceResponse{Device: dev})
+		}
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, pending)
+	case http.MethodDelete:
+		if err := discovery.Dismiss(id); err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeMethodNotAllowed(w, r, http.MethodGet, http.MethodDelete)
+	}
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"fmt"
+	"ledfx/config"
+	"ledfx/effect"
+	"net/http"
+)
+
+type effectsResponse struct {
+	Effects map[string]config.Effect `json:"effects"`
+}
+
+type effectTypeResponse struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type effectPresetsResponse struct {
+	Status         string                         `json:"status"`
+	Effect         string                         `json:"effect"`
+	DefaultPresets map[string]effectPresetPayload `json:"default_presets"`
+	CustomPresets  map[string]effectPresetPayload `json:"custom_presets"`
+}
+
+type effectPresetPayload struct {
+	Name   string              `json:"name"`
+	Config config.EffectConfig `json:"config"`
+}
+
+// handleEffects lists the effect of every virtual, or clears all of them on DELETE
+func handleEffects(w http.ResponseWriter, r *http.Request) {
+	SetHeader(w)
+	switch r.Method {
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		effects := make(map[string]config.Effect)
+		for _, virt := range config.Snapshot().Virtuals {
+			if virt.Effect.Type != "" {
+				effects[virt.Id] = virt.Effect
+			}
+		}
+		writeJSON(w, http.StatusOK, effectsResponse{Effects: effects})
+	case http.MethodDelete:
+		for _, virt := range config.Snapshot().Virtuals {
+			if virt.Effect.Type == "" {
+				continue
+			}
+			if err := clearEffect(virt); err != nil {
+				writeError(w, http.StatusInternalServerError, err)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeMethodNotAllowed(w, r, http.MethodGet, http.MethodDelete)
+	}
+}
+
+// handleEffectType serves /api/effects/{type} and /api/effects/{type}/presets
+func handleEffectType(w http.ResponseWriter, r *http.Request) {
+	SetHeader(w)
+	switch r.Method {
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusOK)
+		return
+	case http.MethodGet:
+	default:
+		writeMethodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+
+	segments := pathSegments(r.URL.Path, "/api/effects/")
+	if len(segments) == 0 || len(segments) > 2 || (len(segments) == 2 && segments[1] != "presets") {
+		writeError(w, http.StatusNotFound, fmt.Errorf("path '%s' %w", r.URL.Path, errNotFound))
+		return
+	}
+	name, ok := effect.Types[segments[0]]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("effect type '%s' %w", segments[0], errNotFound))
+		return
+	}
+
+	if len(segments) == 1 {
+		writeJSON(w, http.StatusOK, effectTypeResponse{Id: segments[0], Name: name})
+		return
+	}
+
+	resp := effectPresetsResponse{
+		Status:         "success",
+		Effect:         segments[0],
+		DefaultPresets: map[string]effectPresetPayload{},
+		CustomPresets:  map[string]effectPresetPayload{},
+	}
+	for _, p := range presetsForType(segments[0]) {
+		resp.CustomPresets[p.Id] = effectPresetPayload{Name: p.Name, Config: p.Config}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
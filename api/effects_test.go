@@ -0,0 +1,55 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestEffects(t *testing.T) {
+	setupTestConfig(t)
+	rec := doRequest(t, http.MethodPost, "/api/virtuals/couch/effects", `{"type": "singleColor", "config": {"color": "blue"}}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doRequest(t, http.MethodGet, "/api/effects", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	var resp effectsResponse
+	decodeResponse(t, rec, &resp)
+	if resp.Effects["couch"].Type != "singleColor" {
+		t.Errorf("Unexpected effects: %+v", resp.Effects)
+	}
+
+	rec = doRequest(t, http.MethodDelete, "/api/effects", nil)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", rec.Code)
+	}
+	if got, _ := getVirtual("couch"); got.Effect.Type != "" || got.Active {
+		t.Errorf("Effects were not cleared: %+v", got)
+	}
+}
+
+func TestEffectType(t *testing.T) {
+	setupTestConfig(t)
+	rec := doRequest(t, http.MethodGet, "/api/effects/singleColor", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	var resp effectTypeResponse
+	decodeResponse(t, rec, &resp)
+	if resp.Name != "Single Color" {
+		t.Errorf("Unexpected effect type: %+v", resp)
+	}
+
+	rec = doRequest(t, http.MethodGet, "/api/effects/singleColor/presets", nil)
+	var presets effectPresetsResponse
+	decodeResponse(t, rec, &presets)
+	if presets.CustomPresets["blue"].Name != "Blue" {
+		t.Errorf("Unexpected effect presets: %+v", presets)
+	}
+
+	expectError(t, doRequest(t, http.MethodGet, "/api/effects/nope", nil), http.StatusNotFound)
+	expectError(t, doRequest(t, http.MethodPost, "/api/effects/singleColor", nil), http.StatusMethodNotAllowed)
+}
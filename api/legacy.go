@@ -0,0 +1,36 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"ledfx/config/legacy"
+	"net/http"
+)
+
+// handleLegacyImport merges an uploaded Python LedFx config.json into the
+// config and answers with what could not be translated
+func handleLegacyImport(w http.ResponseWriter, r *http.Request) {
+	SetHeader(w)
+	switch r.Method {
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusOK)
+	case http.MethodPost:
+		var data json.RawMessage
+		if err := decodeBody(r, &data); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		report, err := legacy.Import(data)
+		switch {
+		case errors.Is(err, legacy.ErrNotLegacy):
+			writeError(w, http.StatusBadRequest, err)
+			return
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+	default:
+		writeMethodNotAllowed(w, r, http.MethodPost)
+	}
+}
@@ -0,0 +1,434 @@
+// Package openapi builds an OpenAPI 3 description of the HTTP API from the Go
+// types the handlers actually decode and encode, so the document can't drift
+// from the code.
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+const Version = "3.0.3"
+
+// Operation describes one method on one path. Request and Response hold a
+// zero value of the body type (or nil when there is no body).
+type Operation struct {
+	Method      string
+	Path        string
+	Id          string
+	Summary     string
+	Tag         string
+	Request     interface{}
+	Response    interface{}
+	Status      int
+	ContentType string // Response content type, application/json if empty
+	Error       interface{}
+}
+
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Servers    []Server              `json:"servers,omitempty"`
+	Paths      map[string]PathItem   `json:"paths"`
+	Components Components            `json:"components"`
+	Security   []map[string][]string `json:"security,omitempty"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type Server struct {
+	Url string `json:"url"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+	In     string `json:"in,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+// PathItem maps lower case HTTP methods to operations
+type PathItem map[string]*OperationObject
+
+type OperationObject struct {
+	OperationId string              `json:"operationId"`
+	Summary     string              `json:"summary,omitempty"`
+	Tags        []string            `json:"tags,omitempty"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// Build assembles a document from a list of operations, generating component
+// schemas for every struct type they reference.
+func Build(title, version string, ops []Operation) (*Document, error) {
+	doc := &Document{
+		OpenAPI:    Version,
+		Info:       Info{Title: title, Version: version},
+		Servers:    []Server{{Url: "http://localhost:8080"}},
+		Paths:      make(map[string]PathItem),
+		Components: Components{Schemas: make(map[string]*Schema)},
+	}
+	g := &generator{schemas: doc.Components.Schemas, names: make(map[string]reflect.Type)}
+
+	ids := make(map[string]bool)
+	for _, op := range ops {
+		if ids[op.Id] {
+			return nil, fmt.Errorf("duplicate operation id '%s'", op.Id)
+		}
+		ids[op.Id] = true
+
+		obj, err := g.operation(op)
+		if err != nil {
+			return nil, fmt.Errorf("error describing operation '%s': %w", op.Id, err)
+		}
+		item, ok := doc.Paths[op.Path]
+		if !ok {
+			item = make(PathItem)
+			doc.Paths[op.Path] = item
+		}
+		method := strings.ToLower(op.Method)
+		if _, ok := item[method]; ok {
+			return nil, fmt.Errorf("operation %s %s is described twice", op.Method, op.Path)
+		}
+		item[method] = obj
+	}
+	return doc, nil
+}
+
+// MarshalIndent renders the document the way it is checked in
+func (d *Document) MarshalIndent() ([]byte, error) {
+	b, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// Find returns the operation matching a concrete request path, e.g.
+// ("GET", "/api/devices/couch") matches "/api/devices/{id}".
+func (d *Document) Find(method, path string) (*OperationObject, bool) {
+	for template, item := range d.Paths {
+		if !MatchPath(template, path) {
+			continue
+		}
+		if op, ok := item[strings.ToLower(method)]; ok {
+			return op, true
+		}
+	}
+	return nil, false
+}
+
+// MatchPath reports whether a concrete path fits a path template
+func MatchPath(template, path string) bool {
+	ts := strings.Split(strings.Trim(template, "/"), "/")
+	ps := strings.Split(strings.Trim(path, "/"), "/")
+	if len(ts) != len(ps) {
+		return false
+	}
+	for i := range ts {
+		if strings.HasPrefix(ts[i], "{") && strings.HasSuffix(ts[i], "}") {
+			if ps[i] == "" {
+				return false
+			}
+			continue
+		}
+		if ts[i] != ps[i] {
+			return false
+		}
+	}
+	return true
+}
+
+type generator struct {
+	schemas map[string]*Schema
+	names   map[string]reflect.Type
+}
+
+func (g *generator) operation(op Operation) (*OperationObject, error) {
+	obj := &OperationObject{
+		OperationId: op.Id,
+		Summary:     op.Summary,
+		Responses:   make(map[string]Response),
+	}
+	if op.Tag != "" {
+		obj.Tags = []string{op.Tag}
+	}
+
+	for _, seg := range strings.Split(op.Path, "/") {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			obj.Parameters = append(obj.Parameters, Parameter{
+				Name:     strings.Trim(seg, "{}"),
+				In:       "path",
+				Required: true,
+				Schema:   &Schema{Type: "string"},
+			})
+		}
+	}
+
+	if op.Request != nil {
+		s, err := g.schema(reflect.TypeOf(op.Request))
+		if err != nil {
+			return nil, err
+		}
+		obj.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{"application/json": {Schema: s}},
+		}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	resp := Response{Description: http.StatusText(status)}
+	if op.Response != nil {
+		contentType := op.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		s, err := g.schema(reflect.TypeOf(op.Response))
+		if err != nil {
+			return nil, err
+		}
+		if s.Format == "byte" && contentType != "application/json" {
+			s.Format = "binary"
+		}
+		resp.Content = map[string]MediaType{contentType: {Schema: s}}
+	}
+	obj.Responses[fmt.Sprint(status)] = resp
+
+	if op.Error != nil {
+		s, err := g.schema(reflect.TypeOf(op.Error))
+		if err != nil {
+			return nil, err
+		}
+		contentType := "application/json"
+		if s.Type == "string" {
+			contentType = "text/plain"
+		}
+		obj.Responses["default"] = Response{
+			Description: "Error",
+			Content:     map[string]MediaType{contentType: {Schema: s}},
+		}
+	}
+	return obj, nil
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	rawType      = reflect.TypeOf(json.RawMessage{})
+
+	marshalerType     = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+func implements(t, iface reflect.Type) bool {
+	return t.Implements(iface) || reflect.PtrTo(t).Implements(iface)
+}
+
+// schema returns the schema for t, registering named structs as components
+func (g *generator) schema(t reflect.Type) (*Schema, error) {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}, nil
+	case durationType:
+		return &Schema{Type: "integer", Format: "int64"}, nil
+	case rawType:
+		return &Schema{}, nil
+	}
+	// Custom encodings can't be inspected, so only their JSON type is known
+	if t.Kind() != reflect.Ptr && t.Kind() != reflect.Interface {
+		if implements(t, marshalerType) {
+			return &Schema{}, nil
+		}
+		if implements(t, textMarshalerType) {
+			return &Schema{Type: "string"}, nil
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		s, err := g.schema(t.Elem())
+		if err != nil || s.Ref != "" {
+			return s, err
+		}
+		s.Nullable = true
+		return s, nil
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}, nil
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return &Schema{Type: "integer", Format: "int32"}, nil
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}, nil
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}, nil
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}, nil
+	case reflect.String:
+		return &Schema{Type: "string"}, nil
+	case reflect.Interface:
+		return &Schema{}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice {
+			return &Schema{Type: "string", Format: "byte"}, nil
+		}
+		items, err := g.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "array", Items: items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("map key type %s can't be described", t.Key())
+		}
+		values, err := g.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "object", AdditionalProperties: values}, nil
+	case reflect.Struct:
+		return g.structSchema(t)
+	}
+	return nil, fmt.Errorf("type %s can't be described", t)
+}
+
+func (g *generator) structSchema(t reflect.Type) (*Schema, error) {
+	name := componentName(t)
+	if name == "" {
+		return g.properties(t)
+	}
+	if other, ok := g.names[name]; ok {
+		if other != t {
+			return nil, fmt.Errorf("component name '%s' is used by both %s and %s", name, other, t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}, nil
+	}
+	// Register before recursing so self-referencing types terminate
+	g.names[name] = t
+	g.schemas[name] = &Schema{}
+	s, err := g.properties(t)
+	if err != nil {
+		return nil, err
+	}
+	g.schemas[name] = s
+	return &Schema{Ref: "#/components/schemas/" + name}, nil
+}
+
+func (g *generator) properties(t reflect.Type) (*Schema, error) {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, ok := jsonName(f)
+		if !ok {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			embedded, err := g.properties(indirect(f.Type))
+			if err != nil {
+				return nil, err
+			}
+			for k, v := range embedded.Properties {
+				s.Properties[k] = v
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fs, err := g.schema(f.Type)
+		if err != nil {
+			return nil, fmt.Errorf("field %s.%s: %w", t.Name(), f.Name, err)
+		}
+		s.Properties[name] = fs
+	}
+	return s, nil
+}
+
+// jsonName returns the JSON key of a field and whether encoding/json uses it
+func jsonName(f reflect.StructField) (string, bool) {
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	name := strings.Split(tag, ",")[0]
+	if !f.IsExported() && !(f.Anonymous && name == "") {
+		return "", false
+	}
+	return name, true
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// componentName qualifies type names with their package, e.g. config.Device
+func componentName(t reflect.Type) string {
+	if t.Name() == "" {
+		return ""
+	}
+	pkg := t.PkgPath()
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+	return pkg + "." + t.Name()
+}
+
+// SortedPaths returns the document paths in a stable order
+func (d *Document) SortedPaths() []string {
+	paths := make([]string, 0, len(d.Paths))
+	for p := range d.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -0,0 +1,163 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type inner struct {
+	Value int `json:"value"`
+}
+
+type Embedded struct {
+	Shared string `json:"shared"`
+}
+
+type custom int
+
+func (c custom) MarshalJSON() ([]byte, error) {
+	return json.Marshal("custom")
+}
+
+type outer struct {
+	Embedded
+	Name     string  `json:"name"`
+	Optional *string `json:"optional,omitempty"`
+	Skipped  string  `json:"-"`
+	hidden   string
+	Inner    inner             `json:"inner"`
+	List     []inner           `json:"list"`
+	Map      map[string]string `json:"map"`
+	Any      interface{}       `json:"any"`
+	Bytes    []byte            `json:"bytes"`
+	Time     time.Time         `json:"time"`
+	Custom   custom            `json:"custom"`
+	Untagged bool
+}
+
+func TestSchema(t *testing.T) {
+	doc, err := Build("test", "v0", []Operation{
+		{Method: http.MethodGet, Path: "/outer/{id}", Id: "getOuter", Response: outer{}},
+	})
+	if err != nil {
+		t.Fatalf("Error building document: %v\n", err)
+	}
+	s := doc.Components.Schemas["openapi.outer"]
+	if s == nil {
+		t.Fatalf("Component openapi.outer was not generated: %v", doc.Components.Schemas)
+	}
+	cases := []struct {
+		q string
+		a Schema
+	}{
+		{"shared", Schema{Type: "string"}},
+		{"name", Schema{Type: "string"}},
+		{"optional", Schema{Type: "string", Nullable: true}},
+		{"inner", Schema{Ref: "#/components/schemas/openapi.inner"}},
+		{"map", Schema{Type: "object", AdditionalProperties: &Schema{Type: "string"}}},
+		{"any", Schema{}},
+		{"bytes", Schema{Type: "string", Format: "byte"}},
+		{"time", Schema{Type: "string", Format: "date-time"}},
+		{"custom", Schema{}},
+		{"Untagged", Schema{Type: "boolean"}},
+	}
+	for _, c := range cases {
+		guess, ok := s.Properties[c.q]
+		if !ok {
+			t.Errorf("Property %s is missing", c.q)
+			continue
+		}
+		if guess.Ref != c.a.Ref || guess.Type != c.a.Type || guess.Format != c.a.Format || guess.Nullable != c.a.Nullable {
+			t.Errorf("Property %s: expected %+v but got %+v", c.q, c.a, *guess)
+		}
+	}
+	for _, name := range []string{"Skipped", "hidden", "Embedded"} {
+		if _, ok := s.Properties[name]; ok {
+			t.Errorf("Property %s should not be documented", name)
+		}
+	}
+
+	params := doc.Paths["/outer/{id}"]["get"].Parameters
+	if len(params) != 1 || params[0].Name != "id" || params[0].In != "path" {
+		t.Errorf("Unexpected path parameters: %+v", params)
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	_, err := Build("test", "v0", []Operation{
+		{Method: http.MethodGet, Path: "/a", Id: "same"},
+		{Method: http.MethodGet, Path: "/b", Id: "same"},
+	})
+	if err == nil {
+		t.Errorf("Expected an error for duplicate operation ids")
+	}
+	_, err = Build("test", "v0", []Operation{
+		{Method: http.MethodGet, Path: "/a", Id: "a", Response: map[int]string{}},
+	})
+	if err == nil {
+		t.Errorf("Expected an error for non-string map keys")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	doc, err := Build("test", "v0", []Operation{
+		{Method: http.MethodPost, Path: "/outer/{id}", Id: "postOuter", Request: inner{}, Response: outer{}},
+	})
+	if err != nil {
+		t.Fatalf("Error building document: %v\n", err)
+	}
+	cases := []struct {
+		q string
+		e bool
+	}{
+		{`{"name": "a", "inner": {"value": 1}, "list": [{"value": 2}], "map": {"k": "v"}, "any": [1, "x"]}`, false},
+		{`{"optional": null, "list": null}`, false},
+		{`{"name": 1}`, true},
+		{`{"inner": {"value": 1.5}}`, true},
+		{`{"list": [{"value": "x"}]}`, true},
+		{`{"map": {"k": 1}}`, true},
+		{`{"extra": true}`, true},
+		{`[]`, true},
+	}
+	for _, c := range cases {
+		err := doc.ValidateResponse(http.MethodPost, "/outer/x", http.StatusOK, "application/json", []byte(c.q))
+		if (err != nil) != c.e {
+			t.Errorf("Validating %s: expected error %v but got %v", c.q, c.e, err)
+		}
+	}
+
+	if err := doc.ValidateResponse(http.MethodGet, "/outer/x", http.StatusOK, "application/json", []byte(`{}`)); err == nil {
+		t.Errorf("Expected an error for an undocumented method")
+	}
+	if err := doc.ValidateResponse(http.MethodPost, "/outer/x", http.StatusCreated, "application/json", []byte(`{}`)); err == nil {
+		t.Errorf("Expected an error for an undocumented status")
+	}
+	if err := doc.ValidateRequest(http.MethodPost, "/outer/x", []byte(`{"value": 3}`)); err != nil {
+		t.Errorf("Error validating request: %v", err)
+	}
+	if err := doc.ValidateRequest(http.MethodPost, "/outer/x", []byte(`{"valu": 3}`)); err == nil {
+		t.Errorf("Expected an error for an undocumented request property")
+	}
+}
+
+func TestMatchPath(t *testing.T) {
+	cases := []struct {
+		template string
+		path     string
+		a        bool
+	}{
+		{"/api/devices", "/api/devices", true},
+		{"/api/devices", "/api/devices/", true},
+		{"/api/devices/{id}", "/api/devices/couch", true},
+		{"/api/devices/{id}", "/api/devices", false},
+		{"/api/virtuals/{id}/effects", "/api/virtuals/couch/effects", true},
+		{"/api/virtuals/{id}/effects", "/api/virtuals/couch/presets", false},
+	}
+	for _, c := range cases {
+		if guess := MatchPath(c.template, c.path); guess != c.a {
+			t.Errorf("Matching %s against %s: expected %v but got %v", c.path, c.template, c.a, guess)
+		}
+	}
+}
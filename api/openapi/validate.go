@@ -0,0 +1,156 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ValidateResponse checks that a response to a request on a concrete path
+// is documented, and that a JSON body matches its schema
+func (d *Document) ValidateResponse(method, path string, status int, contentType string, body []byte) error {
+	op, ok := d.Find(method, path)
+	if !ok {
+		return fmt.Errorf("%s %s is not documented", method, path)
+	}
+	resp, ok := op.Responses[fmt.Sprint(status)]
+	if !ok {
+		if resp, ok = op.Responses["default"]; !ok || status < 400 {
+			return fmt.Errorf("%s %s: status %d is not documented", method, path, status)
+		}
+	}
+	if len(resp.Content) == 0 {
+		if len(body) != 0 {
+			return fmt.Errorf("%s %s: status %d is documented without a body, got %q", method, path, status, body)
+		}
+		return nil
+	}
+	media, ok := resp.Content["application/json"]
+	if !ok {
+		return nil
+	}
+	if !strings.HasPrefix(contentType, "application/json") {
+		return fmt.Errorf("%s %s: expected a JSON response, got content type '%s'", method, path, contentType)
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return fmt.Errorf("%s %s: error decoding response: %w", method, path, err)
+	}
+	if err := d.Validate(media.Schema, v); err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+// Validate checks a decoded JSON value against a schema. Objects may not
+// contain keys the schema doesn't declare, since that means the Go type and
+// the document have drifted apart.
+func (d *Document) Validate(s *Schema, v interface{}) error {
+	return d.validate(s, v, "$")
+}
+
+func (d *Document) validate(s *Schema, v interface{}, at string) error {
+	if s.Ref != "" {
+		ref, ok := d.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+		if !ok {
+			return fmt.Errorf("%s: unknown schema reference '%s'", at, s.Ref)
+		}
+		s = ref
+	}
+	if v == nil {
+		// encoding/json writes nil slices, maps and pointers as null
+		if s.Type == "" || s.Nullable || s.Type == "array" || s.Type == "object" {
+			return nil
+		}
+		return fmt.Errorf("%s: expected %s, got null", at, s.Type)
+	}
+
+	switch s.Type {
+	case "":
+		return nil
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return typeError(at, s.Type, v)
+		}
+	case "integer":
+		n, ok := v.(float64)
+		if !ok || n != float64(int64(n)) {
+			return typeError(at, s.Type, v)
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			return typeError(at, s.Type, v)
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			return typeError(at, s.Type, v)
+		}
+	case "array":
+		items, ok := v.([]interface{})
+		if !ok {
+			return typeError(at, s.Type, v)
+		}
+		for i, item := range items {
+			if err := d.validate(s.Items, item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return typeError(at, s.Type, v)
+		}
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			prop := s.AdditionalProperties
+			if p, ok := s.Properties[k]; ok {
+				prop = p
+			}
+			if prop == nil {
+				return fmt.Errorf("%s: property '%s' is not documented", at, k)
+			}
+			if err := d.validate(prop, obj[k], at+"."+k); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("%s: unknown schema type '%s'", at, s.Type)
+	}
+	return nil
+}
+
+func typeError(at, expected string, v interface{}) error {
+	return fmt.Errorf("%s: expected %s, got %T", at, expected, v)
+}
+
+// ValidateRequest checks that a request on a concrete path is documented and
+// that its body matches the documented request schema
+func (d *Document) ValidateRequest(method, path string, body []byte) error {
+	op, ok := d.Find(method, path)
+	if !ok {
+		return fmt.Errorf("%s %s is not documented", method, path)
+	}
+	if op.RequestBody == nil {
+		if len(body) != 0 {
+			return fmt.Errorf("%s %s is documented without a request body, got %q", method, path, body)
+		}
+		return nil
+	}
+	media, ok := op.RequestBody.Content["application/json"]
+	if !ok {
+		return nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return fmt.Errorf("%s %s: error decoding request: %w", method, path, err)
+	}
+	if err := d.Validate(media.Schema, v); err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	return nil
+}
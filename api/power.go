@@ -0,0 +1,71 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"ledfx/config"
+	"ledfx/device"
+	"net/http"
+)
+
+type suppliesRequest struct {
+	Supplies []config.PowerSupply `json:"supplies"`
+}
+
+type suppliesResponse struct {
+	Supplies []config.PowerSupply `json:"supplies"`
+}
+
+// handlePower reports the estimated draw of every device and power supply
+func handlePower(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, device.Power())
+}
+
+// handleSupplies lists or replaces the power supplies. A supply that a device
+// still names can not be removed.
+func handleSupplies(w http.ResponseWriter, r *http.Request) {
+	SetHeader(w)
+	switch r.Method {
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, suppliesResponse{Supplies: nonNilSupplies(config.Snapshot().PowerSupplies)})
+	case http.MethodPut:
+		var req suppliesRequest
+		if err := decodeBody(r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := device.ValidateSupplies(req.Supplies); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		err := config.Update(func(c *config.Config) error {
+			for _, dev := range c.Devices {
+				if device.CheckSupply(dev, req.Supplies) != nil {
+					return fmt.Errorf("power supply '%s' %w by device '%s'", dev.Config.Power.Supply, errInUse, dev.Id)
+				}
+			}
+			c.PowerSupplies = req.Supplies
+			return nil
+		})
+		if errors.Is(err, errInUse) {
+			writeError(w, http.StatusConflict, err)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, suppliesResponse{Supplies: nonNilSupplies(req.Supplies)})
+	default:
+		writeMethodNotAllowed(w, r, http.MethodGet, http.MethodPut)
+	}
+}
+
+func nonNilSupplies(list []config.PowerSupply) []config.PowerSupply {
+	if list == nil {
+		return []config.PowerSupply{}
+	}
+	return list
+}
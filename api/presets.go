@@ -0,0 +1,130 @@
+package api
+
+import (
+	"fmt"
+	"ledfx/config"
+	"ledfx/effect"
+	"net/http"
+)
+
+type presetsResponse struct {
+	Presets []config.Preset `json:"presets"`
+}
+
+type presetResponse struct {
+	Preset config.Preset `json:"preset"`
+}
+
+func handlePresets(w http.ResponseWriter, r *http.Request) {
+	SetHeader(w)
+	switch r.Method {
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, presetsResponse{Presets: config.Snapshot().Presets})
+	case http.MethodPost:
+		var preset config.Preset
+		if err := decodeBody(r, &preset); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if preset.Id == "" {
+			preset.Id = uniqueId(preset.Name, presetExists)
+		} else if presetExists(preset.Id) {
+			writeError(w, http.StatusConflict, fmt.Errorf("preset '%s' %w", preset.Id, errAlreadyExists))
+			return
+		}
+		if err := effect.ValidatePreset(preset); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if _, err := effect.AddPresetToConfig(preset); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, presetResponse{Preset: preset})
+	default:
+		writeMethodNotAllowed(w, r, http.MethodGet, http.MethodPost)
+	}
+}
+
+func handlePreset(w http.ResponseWriter, r *http.Request) {
+	SetHeader(w)
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	segments := pathSegments(r.URL.Path, "/api/presets/")
+	if len(segments) != 1 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("path '%s' %w", r.URL.Path, errNotFound))
+		return
+	}
+	id := segments[0]
+
+	existing, ok := getPreset(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("preset '%s' %w", id, errNotFound))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, presetResponse{Preset: existing})
+	case http.MethodPut, http.MethodPatch:
+		preset := existing
+		if r.Method == http.MethodPut {
+			preset = config.Preset{}
+		}
+		if err := decodeBody(r, &preset); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if preset.Id != "" && preset.Id != id {
+			writeError(w, http.StatusBadRequest, errIdMismatch)
+			return
+		}
+		preset.Id = id
+		if err := effect.ValidatePreset(preset); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if _, err := effect.AddPresetToConfig(preset); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, presetResponse{Preset: preset})
+	case http.MethodDelete:
+		if err := effect.RemovePresetFromConfig(id); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeMethodNotAllowed(w, r, http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete)
+	}
+}
+
+func presetsForType(effectType string) []config.Preset {
+	presets := make([]config.Preset, 0)
+	for _, p := range config.Snapshot().Presets {
+		if p.Type == effectType {
+			presets = append(presets, p)
+		}
+	}
+	return presets
+}
+
+func getPreset(id string) (config.Preset, bool) {
+	for _, p := range config.Snapshot().Presets {
+		if p.Id == id {
+			return p, true
+		}
+	}
+	return config.Preset{}, false
+}
+
+func presetExists(id string) bool {
+	_, ok := getPreset(id)
+	return ok
+}
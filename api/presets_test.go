@@ -0,0 +1,66 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestGetPresets(t *testing.T) {
+	setupTestConfig(t)
+	rec := doRequest(t, http.MethodGet, "/api/presets", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	var resp presetsResponse
+	decodeResponse(t, rec, &resp)
+	if len(resp.Presets) != 1 || resp.Presets[0].Id != "blue" {
+		t.Errorf("Unexpected presets: %+v", resp.Presets)
+	}
+}
+
+func TestCreatePreset(t *testing.T) {
+	setupTestConfig(t)
+	rec := doRequest(t, http.MethodPost, "/api/presets", `{"name": "Warm Red", "type": "singleColor", "config": {"color": "#ff2000"}}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp presetResponse
+	decodeResponse(t, rec, &resp)
+	if resp.Preset.Id != "warm-red" || !presetExists("warm-red") {
+		t.Errorf("Preset was not created: %+v", resp.Preset)
+	}
+
+	expectError(t, doRequest(t, http.MethodPost, "/api/presets", `{"id": "blue", "name": "Blue", "type": "singleColor"}`), http.StatusConflict)
+	expectError(t, doRequest(t, http.MethodPost, "/api/presets", `{"name": "Bad", "type": "nope"}`), http.StatusBadRequest)
+}
+
+func TestPresetResource(t *testing.T) {
+	setupTestConfig(t)
+
+	rec := doRequest(t, http.MethodGet, "/api/presets/blue", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+
+	rec = doRequest(t, http.MethodPatch, "/api/presets/blue", `{"name": "Navy"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got, _ := getPreset("blue"); got.Name != "Navy" || got.Config.Color != "#0000ff" {
+		t.Errorf("Preset was not patched: %+v", got)
+	}
+
+	rec = doRequest(t, http.MethodPut, "/api/presets/blue", `{"name": "Green", "type": "singleColor", "config": {"color": "green"}}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got, _ := getPreset("blue"); got.Name != "Green" || got.Config.Color != "green" {
+		t.Errorf("Preset was not replaced: %+v", got)
+	}
+
+	rec = doRequest(t, http.MethodDelete, "/api/presets/blue", nil)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", rec.Code)
+	}
+	expectError(t, doRequest(t, http.MethodGet, "/api/presets/blue", nil), http.StatusNotFound)
+}
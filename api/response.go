@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ledfx/logger"
+	"net/http"
+	"strings"
+)
+
+var (
+	errNotFound      = errors.New("not found")
+	errEmptyBody     = errors.New("request body is empty")
+	errIdMismatch    = errors.New("id in body does not match id in path")
+	errAlreadyExists = errors.New("already exists")
+)
+
+type errorResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+}
+
+// writeJSON encodes v as the response body with the given status code
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Logger.Warn(err)
+	}
+}
+
+// writeError sends err as a JSON error body with the given status code
+func writeError(w http.ResponseWriter, code int, err error) {
+	if code >= http.StatusInternalServerError {
+		logger.Logger.WithField("category", "HTTP API").Error(err)
+	} else {
+		logger.Logger.WithField("category", "HTTP API").Debug(err)
+	}
+	writeJSON(w, code, errorResponse{Status: "error", Error: err.Error()})
+}
+
+func writeMethodNotAllowed(w http.ResponseWriter, r *http.Request, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method '%s' is not allowed", r.Method))
+}
+
+// decodeBody decodes the JSON request body into v. When v already holds a
+// value, only the fields present in the body are overwritten.
+func decodeBody(r *http.Request, v interface{}) error {
+	if r.Body == nil || r.Body == http.NoBody {
+		return errEmptyBody
+	}
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+	return nil
+}
+
+// pathSegments returns the non-empty path elements following prefix,
+// e.g. pathSegments("/api/virtuals/foo/effects", "/api/virtuals/") -> [foo effects]
+func pathSegments(path, prefix string) []string {
+	var segments []string
+	for _, s := range strings.Split(strings.TrimPrefix(path, prefix), "/") {
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+	return segments
+}
@@ -7,9 +7,8 @@ import (
 	"net/http"
 )
 
-func HandleSchema() {
-	http.HandleFunc("/api/schema", func(w http.ResponseWriter, r *http.Request) {
-		SetHeader(w)
+func HandleSchema(mux *http.ServeMux) {
+	mux.HandleFunc("/api/schema", getOnly(func(w http.ResponseWriter, r *http.Request) {
 
 		rawIn := json.RawMessage(`
 	{
@@ -60,5 +59,5 @@ func HandleSchema() {
 			logger.Logger.Warn(err)
 		}
 		// json.NewEncoder(w).Encode(config.Schema.Effects)
-	})
+	}))
 }
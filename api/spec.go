@@ -0,0 +1,141 @@
+package api
+
+import (
+	"encoding/json"
+	"ledfx/api/openapi"
+	"ledfx/bridgeapi"
+	"ledfx/config"
+	"ledfx/config/bundle"
+	"ledfx/config/legacy"
+	"ledfx/constants"
+	"ledfx/device"
+	"ledfx/discovery"
+	"net/http"
+)
+
+const OpenAPIPath = "/api/openapi.json"
+
+// operations documents every route registered by HandleApi. The drift tests
+// in spec_test.go fail if a handler accepts a method that isn't listed here
+// or returns a body that doesn't match the listed type.
+var operations = []openapi.Operation{
+	{Method: http.MethodGet, Path: OpenAPIPath, Id: "getOpenAPI", Summary: "Get this document", Tag: "meta", Response: map[string]interface{}{}},
+	{Method: http.MethodGet, Path: "/api/audio", Id: "getAudioDevices", Summary: "List audio devices", Tag: "audio", Response: []config.AudioDevice{}},
+	{Method: http.MethodGet, Path: "/api/config", Id: "getConfig", Summary: "Get the running configuration", Tag: "config", Response: config.Config{}},
+	{Method: http.MethodGet, Path: "/api/config/export", Id: "exportConfig", Summary: "Export devices, virtuals, presets and user colors and gradients as a bundle", Tag: "config", Response: bundle.Bundle{}},
+	{Method: http.MethodPost, Path: "/api/config/import", Id: "importConfig", Summary: "Validate a bundle and merge it into or replace the configuration, or only list the changes", Tag: "config", Request: importRequest{}, Response: importResponse{}},
+	{Method: http.MethodPost, Path: "/api/config/import/legacy", Id: "importLegacyConfig", Summary: "Merge a Python LedFx config.json into the configuration", Tag: "config", Request: json.RawMessage{}, Response: legacy.Report{}},
+	{Method: http.MethodGet, Path: "/api/schema", Id: "getSchema", Summary: "Get the effect schemas", Tag: "meta", Response: map[string]interface{}{}},
+	{Method: http.MethodGet, Path: "/api/colors", Id: "getColors", Summary: "Get the builtin and user colors and gradients", Tag: "colors", Response: colorsResponse{}},
+
+	{Method: http.MethodPost, Path: "/api/colors", Id: "createColor", Summary: "Create a user color", Tag: "colors", Request: namedValue{}, Response: namedValue{}, Status: http.StatusCreated},
+	{Method: http.MethodGet, Path: "/api/colors/{name}", Id: "getColor", Summary: "Get a user color", Tag: "colors", Response: namedValue{}},
+	{Method: http.MethodPut, Path: "/api/colors/{name}", Id: "replaceColor", Summary: "Change or rename a user color, renaming updates the effects using it", Tag: "colors", Request: namedValue{}, Response: namedValue{}},
+	{Method: http.MethodDelete, Path: "/api/colors/{name}", Id: "deleteColor", Summary: "Delete a user color no effect uses", Tag: "colors", Status: http.StatusNoContent},
+	{Method: http.MethodPost, Path: "/api/gradients", Id: "createGradient", Summary: "Create a user gradient", Tag: "colors", Request: namedValue{}, Response: namedValue{}, Status: http.StatusCreated},
+	{Method: http.MethodGet, Path: "/api/gradients/{name}", Id: "getGradient", Summary: "Get a user gradient", Tag: "colors", Response: namedValue{}},
+	{Method: http.MethodPut, Path: "/api/gradients/{name}", Id: "replaceGradient", Summary: "Change or rename a user gradient, renaming updates the effects using it", Tag: "colors", Request: namedValue{}, Response: namedValue{}},
+	{Method: http.MethodDelete, Path: "/api/gradients/{name}", Id: "deleteGradient", Summary: "Delete a user gradient no effect uses", Tag: "colors", Status: http.StatusNoContent},
+
+	{Method: http.MethodGet, Path: "/api/devices", Id: "listDevices", Summary: "List devices", Tag: "devices", Response: devicesResponse{}},
+	{Method: http.MethodPost, Path: "/api/devices", Id: "createDevice", Summary: "Create a device", Tag: "devices", Request: config.Device{}, Response: deviceResponse{}, Status: http.StatusCreated},
+	{Method: http.MethodGet, Path: "/api/devices/{id}", Id: "getDevice", Summary: "Get a device", Tag: "devices", Response: deviceResponse{}},
+	{Method: http.MethodPut, Path: "/api/devices/{id}", Id: "replaceDevice", Summary: "Replace a device", Tag: "devices", Request: config.Device{}, Response: deviceResponse{}},
+	{Method: http.MethodPatch, Path: "/api/devices/{id}", Id: "updateDevice", Summary: "Merge fields into a device", Tag: "devices", Request: config.Device{}, Response: deviceResponse{}},
+	{Method: http.MethodDelete, Path: "/api/devices/{id}", Id: "deleteDevice", Summary: "Delete a device and its virtuals", Tag: "devices", Status: http.StatusNoContent},
+
+	{Method: http.MethodGet, Path: "/api/discovery/pending", Id: "listPendingDevices", Summary: "List the devices found on the network that are not in the configuration", Tag: "discovery", Response: pendingResponse{}},
+	{Method: http.MethodGet, Path: "/api/discovery/pending/{id}", Id: "getPendingDevice", Summary: "Get a pending device", Tag: "discovery", Response: discovery.Pending{}},
+	{Method: http.MethodDelete, Path: "/api/discovery/pending/{id}", Id: "dismissPendingDevice", Summary: "Dismiss a pending device, it is not listed again until LedFx restarts", Tag: "discovery", Status: http.StatusNoContent},
+	{Method: http.MethodPost, Path: "/api/discovery/pending/{id}/accept", Id: "acceptPendingDevice", Summary: "Merge fields into a pending device and add it to the configuration with its virtuals", Tag: "discovery", Request: config.Device{}, Response: deviceResponse{}, Status: http.StatusCreated},
+
+	{Method: http.MethodGet, Path: "/api/power", Id: "getPower", Summary: "Get the estimated current draw of every device and power supply", Tag: "power", Response: device.PowerReport{}},
+	{Method: http.MethodGet, Path: "/api/power/supplies", Id: "listPowerSupplies", Summary: "List power supplies", Tag: "power", Response: suppliesResponse{}},
+	{Method: http.MethodPut, Path: "/api/power/supplies", Id: "replacePowerSupplies", Summary: "Replace the power supplies, those devices name can not be removed", Tag: "power", Request: suppliesRequest{}, Response: suppliesResponse{}},
+
+	{Method: http.MethodGet, Path: "/api/virtuals", Id: "listVirtuals", Summary: "List virtuals", Tag: "virtuals", Response: virtualsResponse{}},
+	{Method: http.MethodPost, Path: "/api/virtuals", Id: "createVirtual", Summary: "Create a virtual", Tag: "virtuals", Request: config.Virtual{}, Response: virtualResponse{}, Status: http.StatusCreated},
+	{Method: http.MethodGet, Path: "/api/virtuals/{id}", Id: "getVirtual", Summary: "Get a virtual", Tag: "virtuals", Response: virtualResponse{}},
+	{Method: http.MethodPut, Path: "/api/virtuals/{id}", Id: "replaceVirtual", Summary: "Replace a virtual", Tag: "virtuals", Request: config.Virtual{}, Response: virtualResponse{}},
+	{Method: http.MethodPatch, Path: "/api/virtuals/{id}", Id: "updateVirtual", Summary: "Merge fields into a virtual", Tag: "virtuals", Request: config.Virtual{}, Response: virtualResponse{}},
+	{Method: http.MethodDelete, Path: "/api/virtuals/{id}", Id: "deleteVirtual", Summary: "Delete a virtual", Tag: "virtuals", Status: http.StatusNoContent},
+	{Method: http.MethodGet, Path: "/api/virtuals/{id}/effects", Id: "getVirtualEffect", Summary: "Get the effect of a virtual", Tag: "virtuals", Response: effectResponse{}},
+	{Method: http.MethodPost, Path: "/api/virtuals/{id}/effects", Id: "setVirtualEffect", Summary: "Set the effect of a virtual and activate it", Tag: "virtuals", Request: config.Effect{}, Response: effectResponse{}},
+	{Method: http.MethodPut, Path: "/api/virtuals/{id}/effects", Id: "replaceVirtualEffect", Summary: "Replace the effect of a virtual", Tag: "virtuals", Request: config.Effect{}, Response: effectResponse{}},
+	{Method: http.MethodPatch, Path: "/api/virtuals/{id}/effects", Id: "updateVirtualEffect", Summary: "Merge fields into the effect of a virtual", Tag: "virtuals", Request: config.Effect{}, Response: effectResponse{}},
+	{Method: http.MethodDelete, Path: "/api/virtuals/{id}/effects", Id: "clearVirtualEffect", Summary: "Clear the effect of a virtual", Tag: "virtuals", Status: http.StatusNoContent},
+	{Method: http.MethodGet, Path: "/api/virtuals/{id}/presets", Id: "listVirtualPresets", Summary: "List presets for the effect of a virtual", Tag: "virtuals", Response: presetsResponse{}},
+	{Method: http.MethodPut, Path: "/api/virtuals/{id}/presets", Id: "applyVirtualPreset", Summary: "Apply a preset to a virtual", Tag: "virtuals", Request: applyPresetRequest{}, Response: effectResponse{}},
+
+	{Method: http.MethodGet, Path: "/api/effects", Id: "listEffects", Summary: "List the effect of every virtual", Tag: "effects", Response: effectsResponse{}},
+	{Method: http.MethodDelete, Path: "/api/effects", Id: "clearEffects", Summary: "Clear the effect of every virtual", Tag: "effects", Status: http.StatusNoContent},
+	{Method: http.MethodGet, Path: "/api/effects/{type}", Id: "getEffectType", Summary: "Get an effect type", Tag: "effects", Response: effectTypeResponse{}},
+	{Method: http.MethodGet, Path: "/api/effects/{type}/presets", Id: "getEffectTypePresets", Summary: "Get the presets of an effect type", Tag: "effects", Response: effectPresetsResponse{}},
+
+	{Method: http.MethodGet, Path: "/api/presets", Id: "listPresets", Summary: "List presets", Tag: "presets", Response: presetsResponse{}},
+	{Method: http.MethodPost, Path: "/api/presets", Id: "createPreset", Summary: "Create a preset", Tag: "presets", Request: config.Preset{}, Response: presetResponse{}, Status: http.StatusCreated},
+	{Method: http.MethodGet, Path: "/api/presets/{id}", Id: "getPreset", Summary: "Get a preset", Tag: "presets", Response: presetResponse{}},
+	{Method: http.MethodPut, Path: "/api/presets/{id}", Id: "replacePreset", Summary: "Replace a preset", Tag: "presets", Request: config.Preset{}, Response: presetResponse{}},
+	{Method: http.MethodPatch, Path: "/api/presets/{id}", Id: "updatePreset", Summary: "Merge fields into a preset", Tag: "presets", Request: config.Preset{}, Response: presetResponse{}},
+	{Method: http.MethodDelete, Path: "/api/presets/{id}", Id: "deletePreset", Summary: "Delete a preset", Tag: "presets", Status: http.StatusNoContent},
+
+	{Method: http.MethodGet, Path: "/api/auth", Id: "getAuth", Summary: "Get whether authentication is on and the scope of the caller", Tag: "auth", Response: authResponse{}},
+	{Method: http.MethodGet, Path: "/api/auth/tokens", Id: "listTokens", Summary: "List API tokens", Tag: "auth", Response: tokensResponse{}},
+	{Method: http.MethodPost, Path: "/api/auth/tokens", Id: "createToken", Summary: "Create an API token, the secret is only returned once", Tag: "auth", Request: createTokenRequest{}, Response: createTokenResponse{}, Status: http.StatusCreated},
+	{Method: http.MethodDelete, Path: "/api/auth/tokens/{id}", Id: "deleteToken", Summary: "Revoke an API token", Tag: "auth", Status: http.StatusNoContent},
+	{Method: http.MethodPut, Path: "/api/auth/password", Id: "setPassword", Summary: "Set the password, an empty password removes it", Tag: "auth", Request: passwordRequest{}, Status: http.StatusNoContent},
+	{Method: http.MethodPut, Path: "/api/auth/origins", Id: "setAllowedOrigins", Summary: "Set the origin allow-list", Tag: "auth", Request: originsRequest{}, Status: http.StatusNoContent},
+}
+
+// Spec builds the OpenAPI document for the whole HTTP API, bridge included
+func Spec() (*openapi.Document, error) {
+	ops := make([]openapi.Operation, 0, len(operations))
+	for _, op := range operations {
+		op.Error = errorResponse{}
+		ops = append(ops, op)
+	}
+	doc, err := openapi.Build("LedFx", constants.VERSION, append(ops, bridgeapi.Operations()...))
+	if err != nil {
+		return nil, err
+	}
+	// Credentials are only required once a token or password is configured
+	doc.Components.SecuritySchemes = map[string]openapi.SecurityScheme{
+		"token":    {Type: "http", Scheme: "bearer"},
+		"password": {Type: "http", Scheme: "basic"},
+	}
+	doc.Security = []map[string][]string{{}, {"token": {}}, {"password": {}}}
+	return doc, nil
+}
+
+func handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	SetHeader(w)
+	switch r.Method {
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		doc, err := Spec()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, doc)
+	default:
+		writeMethodNotAllowed(w, r, http.MethodGet)
+	}
+}
+
+// getOnly wraps handlers that only serve GET with a JSON body
+func getOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		SetHeader(w)
+		switch r.Method {
+		case http.MethodOptions:
+			w.WriteHeader(http.StatusOK)
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			next(w, r)
+		default:
+			writeMethodNotAllowed(w, r, http.MethodGet)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"ledfx/api/openapi"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite api/openapi.json from the handler types")
+
+const goldenPath = "openapi.json"
+
+// TestOpenAPIGolden fails when the handler types change without the checked
+// in document being regenerated with `go test ./api -run OpenAPIGolden -update`
+func TestOpenAPIGolden(t *testing.T) {
+	doc, err := Spec()
+	if err != nil {
+		t.Fatalf("Error building OpenAPI document: %v\n", err)
+	}
+	guess, err := doc.MarshalIndent()
+	if err != nil {
+		t.Fatalf("Error encoding OpenAPI document: %v\n", err)
+	}
+	if *update {
+		if err := os.WriteFile(goldenPath, guess, 0644); err != nil {
+			t.Fatalf("Error writing %s: %v\n", goldenPath, err)
+		}
+	}
+	golden, err := os.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatalf("Error reading %s: %v\n", goldenPath, err)
+	}
+	if !bytes.Equal(golden, guess) {
+		t.Errorf("%s is out of date, regenerate it with -update", goldenPath)
+	}
+}
+
+func TestOpenAPIEndpoint(t *testing.T) {
+	setupTestConfig(t)
+	rec := doRequest(t, http.MethodGet, OpenAPIPath, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	var doc openapi.Document
+	decodeResponse(t, rec, &doc)
+	if doc.OpenAPI != openapi.Version || doc.Paths["/api/devices/{id}"]["patch"] == nil {
+		t.Errorf("Unexpected OpenAPI document: %+v", doc.Info)
+	}
+}
+
+// requestBodies holds a valid body for every documented operation that
+// takes one, keyed by operation id
+var requestBodies = map[string]string{
+	"importConfig":         `{"mode": "merge", "bundle": {"version": 1, "user_colors": {"Sunset": "#ff8000"}}}`,
+	"importLegacyConfig":   `{"user_colors": {"Sunset": "#ff8000"}}`,
+	"createDevice":         `{"type": "wled", "config": {"name": "Shelf", "ip_address": "127.0.0.1", "pixel_count": 10}}`,
+	"replaceDevice":        `{"type": "wled", "config": {"name": "Sofa", "ip_address": "127.0.0.1", "pixel_count": 36}}`,
+	"updateDevice":         `{"config": {"pixel_count": 40}}`,
+	"createVirtual":        `{"config": {"name": "Half Couch"}, "segments": [["couch", 0, 17, false]]}`,
+	"replaceVirtual":       `{"config": {"name": "Sofa"}, "is_device": "couch"}`,
+	"updateVirtual":        `{"active": true, "effect": {"type": "singleColor", "config": {"color": "red"}}}`,
+	"setVirtualEffect":     `{"type": "singleColor", "config": {"color": "red"}}`,
+	"replaceVirtualEffect": `{"type": "singleColor", "config": {"color": "blue"}}`,
+	"updateVirtualEffect":  `{"type": "singleColor", "config": {"background_color": "#000000"}}`,
+	"applyVirtualPreset":   `{"preset_id": "blue"}`,
+	"createPreset":         `{"name": "Warm Red", "type": "singleColor", "config": {"color": "#ff2000"}}`,
+	"replacePreset":        `{"name": "Green", "type": "singleColor", "config": {"color": "green"}}`,
+	"updatePreset":         `{"name": "Navy"}`,
+	"createToken":          `{"name": "script", "scope": "read"}`,
+	"createColor":          `{"name": "Mint Leaf", "value": "#00ff80"}`,
+	"replaceColor":         `{"name": "Dawn", "value": "#ff9000"}`,
+	"createGradient":       `{"name": "Night", "value": "linear-gradient(90deg, #000000 0%, #000080 100%)"}`,
+	"replaceGradient":      `{"value": "linear-gradient(90deg, #ff0000 0%, #000080 100%)"}`,
+	"setPassword":          `{"password": "hunter2"}`,
+	"setAllowedOrigins":    `{"allowed_origins": ["http://localhost:3000"]}`,
+	"replacePowerSupplies": `{"supplies": [{"id": "psu", "name": "Shelf PSU", "max_milliamps": 4000}]}`,
+	"acceptPendingDevice":  `{"config": {"name": "Porch Light"}}`,
+}
+
+// concretePath fills a path template with ids that exist in the test config
+func concretePath(template string) string {
+	id := "couch"
+	switch {
+	case strings.HasPrefix(template, "/api/presets/"):
+		id = "blue"
+	case strings.HasPrefix(template, "/api/auth/tokens/"):
+		id = "abc"
+	case strings.HasPrefix(template, "/api/discovery/"):
+		id = "porch"
+	}
+	name := "Sunset"
+	if strings.HasPrefix(template, "/api/gradients/") {
+		name = "Dusk"
+	}
+	return strings.NewReplacer("{id}", id, "{type}", "singleColor", "{name}", name).Replace(template)
+}
+
+var methods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// TestOpenAPIDrift calls every method on every documented path. Documented
+// operations must answer with their documented status and a body matching
+// their schema, and everything else must be refused with 405.
+func TestOpenAPIDrift(t *testing.T) {
+	doc, err := Spec()
+	if err != nil {
+		t.Fatalf("Error building OpenAPI document: %v\n", err)
+	}
+	for _, template := range doc.SortedPaths() {
+		if strings.HasPrefix(template, "/api/bridge/") {
+			// Registered by bridgeapi, see bridgeapi/routes_test.go
+			continue
+		}
+		path := concretePath(template)
+		for _, method := range methods {
+			t.Run(method+" "+template, func(t *testing.T) {
+				setupTestConfig(t)
+				op, documented := doc.Paths[template][strings.ToLower(method)]
+				var body interface{}
+				if documented && op.RequestBody != nil {
+					b, ok := requestBodies[op.OperationId]
+					if !ok {
+						t.Fatalf("No test request body for operation '%s'", op.OperationId)
+					}
+					body = b
+				}
+				rec := doRequest(t, method, path, body)
+				if !documented {
+					if rec.Code != http.StatusMethodNotAllowed {
+						t.Fatalf("Undocumented method answered with %d: %s", rec.Code, rec.Body.String())
+					}
+					return
+				}
+				if _, ok := op.Responses[fmt.Sprint(rec.Code)]; !ok {
+					t.Fatalf("Expected a documented success status, got %d: %s", rec.Code, rec.Body.String())
+				}
+				if err := doc.ValidateResponse(method, path, rec.Code, rec.Header().Get("Content-Type"), rec.Body.Bytes()); err != nil {
+					t.Errorf("Response does not match the document: %v", err)
+				}
+			})
+		}
+	}
+}
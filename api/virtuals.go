@@ -0,0 +1,287 @@
+package api
+
+import (
+	"fmt"
+	"ledfx/config"
+	"ledfx/effect"
+	"ledfx/virtual"
+	"net/http"
+)
+
+// defaultColor is played on virtuals whose effect has no color configured
+const defaultColor = "#ff0000"
+
+type virtualsResponse struct {
+	Virtuals []config.Virtual `json:"virtuals"`
+}
+
+type virtualResponse struct {
+	Virtual config.Virtual `json:"virtual"`
+}
+
+type effectResponse struct {
+	Effect config.Effect `json:"effect"`
+}
+
+type applyPresetRequest struct {
+	PresetId string `json:"preset_id"`
+}
+
+func handleVirtuals(w http.ResponseWriter, r *http.Request) {
+	SetHeader(w)
+	switch r.Method {
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, virtualsResponse{Virtuals: config.Snapshot().Virtuals})
+	case http.MethodPost:
+		var virt config.Virtual
+		if err := decodeBody(r, &virt); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if virt.Id == "" {
+			virt.Id = uniqueId(virt.Config.Name, virtualExists)
+		} else if virtualExists(virt.Id) {
+			writeError(w, http.StatusConflict, fmt.Errorf("virtual '%s' %w", virt.Id, errAlreadyExists))
+			return
+		}
+		fillEffectName(&virt.Effect)
+		if err := virtual.ValidateVirtual(virt); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if _, err := virtual.AddVirtualToConfig(virt); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if virt.Active {
+			if err := playVirtual(virt); err != nil {
+				writeError(w, http.StatusInternalServerError, err)
+				return
+			}
+		}
+		writeJSON(w, http.StatusCreated, virtualResponse{Virtual: virt})
+	default:
+		writeMethodNotAllowed(w, r, http.MethodGet, http.MethodPost)
+	}
+}
+
+func handleVirtual(w http.ResponseWriter, r *http.Request) {
+	SetHeader(w)
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	segments := pathSegments(r.URL.Path, "/api/virtuals/")
+	if len(segments) == 0 || len(segments) > 2 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("path '%s' %w", r.URL.Path, errNotFound))
+		return
+	}
+
+	existing, ok := getVirtual(segments[0])
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("virtual '%s' %w", segments[0], errNotFound))
+		return
+	}
+
+	if len(segments) == 1 {
+		handleVirtualResource(w, r, existing)
+		return
+	}
+
+	switch segments[1] {
+	case "effects":
+		handleVirtualEffect(w, r, existing)
+	case "presets":
+		handleVirtualPresets(w, r, existing)
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("path '%s' %w", r.URL.Path, errNotFound))
+	}
+}
+
+func handleVirtualResource(w http.ResponseWriter, r *http.Request, existing config.Virtual) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, virtualResponse{Virtual: existing})
+	case http.MethodPut, http.MethodPatch:
+		virt := existing
+		// Decoding reuses slice backing arrays, so don't let a rejected PATCH touch the stored segments
+		virt.Segments = copySegments(existing.Segments)
+		if r.Method == http.MethodPut {
+			virt = config.Virtual{}
+		}
+		if err := decodeBody(r, &virt); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if virt.Id != "" && virt.Id != existing.Id {
+			writeError(w, http.StatusBadRequest, errIdMismatch)
+			return
+		}
+		virt.Id = existing.Id
+		fillEffectName(&virt.Effect)
+		if err := virtual.ValidateVirtual(virt); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if _, err := virtual.AddVirtualToConfig(virt); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if virt.Active != existing.Active || (virt.Active && virt.Effect != existing.Effect) {
+			if err := playVirtual(virt); err != nil {
+				writeError(w, http.StatusInternalServerError, err)
+				return
+			}
+		}
+		writeJSON(w, http.StatusOK, virtualResponse{Virtual: virt})
+	case http.MethodDelete:
+		if existing.Active {
+			if err := virtual.StopVirtual(existing.Id); err != nil {
+				writeError(w, http.StatusInternalServerError, err)
+				return
+			}
+		}
+		if err := virtual.RemoveVirtualFromConfig(existing.Id); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeMethodNotAllowed(w, r, http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete)
+	}
+}
+
+func handleVirtualEffect(w http.ResponseWriter, r *http.Request, virt config.Virtual) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, effectResponse{Effect: virt.Effect})
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		e := virt.Effect
+		if r.Method != http.MethodPatch {
+			e = config.Effect{}
+		}
+		if err := decodeBody(r, &e); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		fillEffectName(&e)
+		if err := effect.ValidateEffect(e); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		// Setting a new effect turns the virtual on, updating it keeps the current state
+		wasActive := virt.Active
+		virt.Effect = e
+		if r.Method == http.MethodPost {
+			virt.Active = true
+		}
+		if _, err := virtual.AddVirtualToConfig(virt); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if virt.Active || wasActive {
+			if err := playVirtual(virt); err != nil {
+				writeError(w, http.StatusInternalServerError, err)
+				return
+			}
+		}
+		writeJSON(w, http.StatusOK, effectResponse{Effect: e})
+	case http.MethodDelete:
+		if err := clearEffect(virt); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeMethodNotAllowed(w, r, http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete)
+	}
+}
+
+func handleVirtualPresets(w http.ResponseWriter, r *http.Request, virt config.Virtual) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, presetsResponse{Presets: presetsForType(virt.Effect.Type)})
+	case http.MethodPut:
+		var req applyPresetRequest
+		if err := decodeBody(r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		preset, ok := getPreset(req.PresetId)
+		if !ok {
+			writeError(w, http.StatusNotFound, fmt.Errorf("preset '%s' %w", req.PresetId, errNotFound))
+			return
+		}
+		virt.Effect = config.Effect{Type: preset.Type, Config: preset.Config}
+		fillEffectName(&virt.Effect)
+		virt.Active = true
+		if _, err := virtual.AddVirtualToConfig(virt); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if err := playVirtual(virt); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, effectResponse{Effect: virt.Effect})
+	default:
+		writeMethodNotAllowed(w, r, http.MethodGet, http.MethodPut)
+	}
+}
+
+// playVirtual pushes the virtual's current active state and color to its device
+func playVirtual(virt config.Virtual) error {
+	clr := virt.Effect.Config.Color
+	if clr == "" {
+		clr = defaultColor
+	}
+	return virtual.PlayVirtual(virt.Id, virt.Active, clr)
+}
+
+// clearEffect removes the effect from a virtual and turns it off
+func clearEffect(virt config.Virtual) error {
+	wasActive := virt.Active
+	virt.Effect = config.Effect{}
+	virt.Active = false
+	if _, err := virtual.AddVirtualToConfig(virt); err != nil {
+		return err
+	}
+	if wasActive {
+		return virtual.StopVirtual(virt.Id)
+	}
+	return nil
+}
+
+func fillEffectName(e *config.Effect) {
+	if e.Name == "" {
+		e.Name = effect.Types[e.Type]
+	}
+}
+
+func copySegments(segments [][]interface{}) [][]interface{} {
+	if segments == nil {
+		return nil
+	}
+	out := make([][]interface{}, len(segments))
+	for i := range segments {
+		out[i] = append([]interface{}(nil), segments[i]...)
+	}
+	return out
+}
+
+func getVirtual(id string) (config.Virtual, bool) {
+	for _, virt := range config.Snapshot().Virtuals {
+		if virt.Id == id {
+			return virt, true
+		}
+	}
+	return config.Virtual{}, false
+}
+
+func virtualExists(id string) bool {
+	_, ok := getVirtual(id)
+	return ok
+}
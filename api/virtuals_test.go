@@ -0,0 +1,173 @@
+package api
+
+import (
+	"ledfx/config"
+	"net/http"
+	"testing"
+)
+
+func TestGetVirtuals(t *testing.T) {
+	setupTestConfig(t)
+	rec := doRequest(t, http.MethodGet, "/api/virtuals", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	var resp virtualsResponse
+	decodeResponse(t, rec, &resp)
+	if len(resp.Virtuals) != 1 || resp.Virtuals[0].Id != "couch" {
+		t.Errorf("Unexpected virtuals: %+v", resp.Virtuals)
+	}
+}
+
+func TestCreateVirtual(t *testing.T) {
+	cases := []struct {
+		name string
+		body interface{}
+		code int
+		id   string
+	}{
+		{"generated id", `{"config": {"name": "Half Couch"}, "segments": [["couch", 0, 17, false]]}`, http.StatusCreated, "half-couch"},
+		{"segment out of range", `{"config": {"name": "Too Long"}, "segments": [["couch", 0, 36, false]]}`, http.StatusBadRequest, ""},
+		{"unknown device", `{"config": {"name": "Ghost"}, "segments": [["ghost", 0, 1, false]]}`, http.StatusBadRequest, ""},
+		{"malformed segment", `{"config": {"name": "Bad"}, "segments": [["couch", 0]]}`, http.StatusBadRequest, ""},
+		{"unknown effect", `{"config": {"name": "Bad"}, "effect": {"type": "nope"}}`, http.StatusBadRequest, ""},
+		{"missing name", `{"segments": []}`, http.StatusBadRequest, ""},
+		{"conflict", config.Virtual{Id: "couch", Config: config.VirtualConfig{Name: "Couch"}}, http.StatusConflict, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			setupTestConfig(t)
+			rec := doRequest(t, http.MethodPost, "/api/virtuals", c.body)
+			if c.code != http.StatusCreated {
+				expectError(t, rec, c.code)
+				return
+			}
+			if rec.Code != c.code {
+				t.Fatalf("Expected status %d, got %d: %s", c.code, rec.Code, rec.Body.String())
+			}
+			var resp virtualResponse
+			decodeResponse(t, rec, &resp)
+			if resp.Virtual.Id != c.id || !virtualExists(c.id) {
+				t.Errorf("Expected virtual %s to be created, got %+v", c.id, resp.Virtual)
+			}
+		})
+	}
+}
+
+func TestGetVirtual(t *testing.T) {
+	setupTestConfig(t)
+	rec := doRequest(t, http.MethodGet, "/api/virtuals/couch", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	var resp virtualResponse
+	decodeResponse(t, rec, &resp)
+	if resp.Virtual.Config.Name != "Couch" {
+		t.Errorf("Unexpected virtual: %+v", resp.Virtual)
+	}
+	expectError(t, doRequest(t, http.MethodGet, "/api/virtuals/nope", nil), http.StatusNotFound)
+	expectError(t, doRequest(t, http.MethodGet, "/api/virtuals/couch/nope", nil), http.StatusNotFound)
+}
+
+func TestPutVirtual(t *testing.T) {
+	setupTestConfig(t)
+	rec := doRequest(t, http.MethodPut, "/api/virtuals/couch", `{"config": {"name": "Sofa"}, "is_device": "couch"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	got, _ := getVirtual("couch")
+	if got.Config.Name != "Sofa" || len(got.Segments) != 0 {
+		t.Errorf("Virtual was not replaced: %+v", got)
+	}
+	expectError(t, doRequest(t, http.MethodPut, "/api/virtuals/couch", `{"id": "other", "config": {"name": "Sofa"}}`), http.StatusBadRequest)
+}
+
+func TestPatchVirtual(t *testing.T) {
+	setupTestConfig(t)
+	rec := doRequest(t, http.MethodPatch, "/api/virtuals/couch", `{"active": true, "effect": {"type": "singleColor", "config": {"color": "#00ff00"}}}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	got, _ := getVirtual("couch")
+	if !got.Active || got.Effect.Name != "Single Color" || got.Config.Name != "Couch" {
+		t.Errorf("Virtual was not patched: %+v", got)
+	}
+
+	expectError(t, doRequest(t, http.MethodPatch, "/api/virtuals/couch", `{"segments": [["couch", 30, 40, false]]}`), http.StatusBadRequest)
+	if got, _ := getVirtual("couch"); got.Segments[0][2] != 35 {
+		t.Errorf("Rejected patch modified the virtual: %+v", got.Segments)
+	}
+}
+
+func TestDeleteVirtual(t *testing.T) {
+	setupTestConfig(t)
+	rec := doRequest(t, http.MethodDelete, "/api/virtuals/couch", nil)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if virtualExists("couch") {
+		t.Errorf("Virtual was not removed")
+	}
+	if !deviceExists("couch") {
+		t.Errorf("Removing a virtual must not remove its device")
+	}
+}
+
+func TestVirtualEffect(t *testing.T) {
+	setupTestConfig(t)
+
+	rec := doRequest(t, http.MethodPost, "/api/virtuals/couch/effects", `{"type": "singleColor", "config": {"color": "red"}}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got, _ := getVirtual("couch"); !got.Active || got.Effect.Config.Color != "red" {
+		t.Errorf("Effect was not set: %+v", got)
+	}
+
+	rec = doRequest(t, http.MethodPatch, "/api/virtuals/couch/effects", `{"config": {"background_color": "#000000"}}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got, _ := getVirtual("couch"); got.Effect.Config.Color != "red" || got.Effect.Config.BackgroundColor != "#000000" {
+		t.Errorf("Effect was not patched: %+v", got.Effect)
+	}
+
+	rec = doRequest(t, http.MethodGet, "/api/virtuals/couch/effects", nil)
+	var resp effectResponse
+	decodeResponse(t, rec, &resp)
+	if resp.Effect.Type != "singleColor" {
+		t.Errorf("Unexpected effect: %+v", resp.Effect)
+	}
+
+	expectError(t, doRequest(t, http.MethodPut, "/api/virtuals/couch/effects", `{"type": "singleColor", "config": {"color": "not a color"}}`), http.StatusBadRequest)
+	expectError(t, doRequest(t, http.MethodPut, "/api/virtuals/couch/effects", `{"type": "nope"}`), http.StatusBadRequest)
+
+	rec = doRequest(t, http.MethodDelete, "/api/virtuals/couch/effects", nil)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got, _ := getVirtual("couch"); got.Active || got.Effect.Type != "" {
+		t.Errorf("Effect was not cleared: %+v", got)
+	}
+}
+
+func TestVirtualPresets(t *testing.T) {
+	setupTestConfig(t)
+
+	rec := doRequest(t, http.MethodPut, "/api/virtuals/couch/presets", applyPresetRequest{PresetId: "blue"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got, _ := getVirtual("couch"); got.Effect.Config.Color != "#0000ff" || !got.Active {
+		t.Errorf("Preset was not applied: %+v", got)
+	}
+
+	rec = doRequest(t, http.MethodGet, "/api/virtuals/couch/presets", nil)
+	var resp presetsResponse
+	decodeResponse(t, rec, &resp)
+	if len(resp.Presets) != 1 || resp.Presets[0].Id != "blue" {
+		t.Errorf("Unexpected presets: %+v", resp.Presets)
+	}
+
+	expectError(t, doRequest(t, http.MethodPut, "/api/virtuals/couch/presets", applyPresetRequest{PresetId: "nope"}), http.StatusNotFound)
+}
@@ -0,0 +1,134 @@
+// Package analysis extracts what effects react to from mono audio: the
+// magnitude spectrum, mel band energies, onsets and the level.
+//
+// Two backends implement it. The aubio one needs cgo and libaubio and is left
+// out of builds without cgo or with the noaubio tag, the pure Go one is always
+// there.
+package analysis
+
+import (
+	"errors"
+	"fmt"
+	"ledfx/config"
+	"math"
+	"sort"
+)
+
+// Backend names an implementation of Analyzer
+type Backend string
+
+const (
+	// Go is the pure Go backend
+	Go Backend = "go"
+	// Aubio is the backend on top of libaubio
+	Aubio Backend = "aubio"
+)
+
+// MelBands is the number of mel bands, spaced like the Auditory Toolbox of
+// Malcolm Slaney
+const MelBands = 40
+
+// Options set up an Analyzer
+type Options struct {
+	SampleRate int
+	// FFTSize is the length of the analysis window, a power of two
+	FFTSize int
+	// HopSize is the number of samples passed to each call of Do, at most
+	// FFTSize
+	HopSize int
+}
+
+func (o Options) validate() error {
+	switch {
+	case o.SampleRate <= 0:
+		return fmt.Errorf("invalid sample rate %d", o.SampleRate)
+	case o.FFTSize < 2 || o.FFTSize&(o.FFTSize-1) != 0:
+		return fmt.Errorf("FFT size %d is not a power of two", o.FFTSize)
+	case o.HopSize <= 0 || o.HopSize > o.FFTSize:
+		return fmt.Errorf("hop size %d is not between 1 and the FFT size %d", o.HopSize, o.FFTSize)
+	}
+	return nil
+}
+
+// Analyzer analyses audio one hop at a time. The slices it returns are
+// reused by the next call to Do.
+type Analyzer interface {
+	// Do analyses the next hop of samples, which range from -1 to 1
+	Do(samples []float64)
+	// Spectrum returns the magnitude of the FFTSize/2+1 bins of the last
+	// window
+	Spectrum() []float64
+	// Mel returns the energy of each of the MelBands bands of the last
+	// window
+	Mel() []float64
+	// Onset returns whether an onset was detected in the last hop
+	Onset() bool
+	// RMS returns the root mean square of the last hop
+	RMS() float64
+	// Close frees what the analyzer holds, it must not be used afterwards
+	Close()
+}
+
+var ErrUnknownBackend = errors.New("unknown analysis backend")
+
+// backends are the backends compiled in
+var backends = map[Backend]func(o Options) (Analyzer, error){
+	Go: NewGo,
+}
+
+func init() {
+	config.RegisterCheck("audio.analysis", checkBackend)
+}
+
+// checkBackend fails for a backend that is not compiled in
+func checkBackend(name string) error {
+	if _, ok := backends[Backend(name)]; !ok {
+		return fmt.Errorf("%w '%s', have %v", ErrUnknownBackend, name, Backends())
+	}
+	return nil
+}
+
+// Backends returns the backends compiled in
+func Backends() []Backend {
+	list := make([]Backend, 0, len(backends))
+	for b := range backends {
+		list = append(list, b)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i] < list[j] })
+	return list
+}
+
+// Default returns aubio if it is compiled in, Go otherwise
+func Default() Backend {
+	if _, ok := backends[Aubio]; ok {
+		return Aubio
+	}
+	return Go
+}
+
+// New returns an analyzer of backend b, or of the default backend if b is
+// empty
+func New(b Backend, o Options) (Analyzer, error) {
+	if b == "" {
+		b = Default()
+	}
+	if err := checkBackend(string(b)); err != nil {
+		return nil, err
+	}
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+	return backends[b](o)
+}
+
+// RMS returns the root mean square of samples
+func RMS(samples []float64) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range samples {
+		sum += v * v
+	}
+	return math.Sqrt(sum / float64(len(samples)))
+}
@@ -0,0 +1,143 @@
+package analysis
+
+import (
+	"errors"
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"testing"
+)
+
+var testOptions = Options{SampleRate: 44100, FFTSize: 1024, HopSize: 735}
+
+// referenceAudio returns 4.25 seconds of reference audio at sampleRate and
+// the times its onsets start in seconds: after a quarter second of silence,
+// a note every half second, noise bursts and tones in turn
+func referenceAudio(sampleRate int) (samples []float64, onsets []float64) {
+	rng := rand.New(rand.NewSource(1))
+	samples = make([]float64, sampleRate*17/4)
+	for k := 0; k < 8; k++ {
+		start := 0.25 + 0.5*float64(k)
+		onsets = append(onsets, start)
+		first := int(start * float64(sampleRate))
+		for i := first; i < first+sampleRate/4; i++ {
+			t := float64(i-first) / float64(sampleRate)
+			if k%2 == 0 {
+				samples[i] += 0.8 * (rng.Float64()*2 - 1) * math.Exp(-t/0.03)
+			} else {
+				attack := math.Min(1, t/0.005)
+				samples[i] += 0.6 * attack * math.Sin(2*math.Pi*660*t) * math.Exp(-t/0.08)
+			}
+		}
+	}
+	return samples, onsets
+}
+
+// analyse runs a over samples hop by hop and calls fn after every hop
+func analyse(a Analyzer, samples []float64, hop int, fn func(hop int)) {
+	for i := 0; (i+1)*hop <= len(samples); i++ {
+		a.Do(samples[i*hop : (i+1)*hop])
+		fn(i)
+	}
+}
+
+func TestFFT(t *testing.T) {
+	const n = 64
+	rng := rand.New(rand.NewSource(1))
+	x := make([]float64, n)
+	for i := range x {
+		x[i] = rng.Float64()*2 - 1
+	}
+	norm := make([]float64, n/2+1)
+	newFFT(n).magnitude(norm, x)
+	for k := range norm {
+		var sum complex128
+		for i, v := range x {
+			sum += complex(v, 0) * cmplx.Exp(complex(0, -2*math.Pi*float64(k*i)/n))
+		}
+		if math.Abs(cmplx.Abs(sum)-norm[k]) > 1e-9 {
+			t.Errorf("Bin %d: expected %g, got %g", k, cmplx.Abs(sum), norm[k])
+		}
+	}
+}
+
+func TestMel(t *testing.T) {
+	a, _ := NewGo(testOptions)
+	// 1kHz peaks in band 12, the first logarithmic one
+	tone := make([]float64, testOptions.FFTSize)
+	for i := range tone {
+		tone[i] = math.Sin(2 * math.Pi * 1000 * float64(i) / float64(testOptions.SampleRate))
+	}
+	analyse(a, tone, testOptions.HopSize, func(int) {})
+	loudest := 0
+	for n, e := range a.Mel() {
+		if e < 0 {
+			t.Errorf("Band %d has negative energy %g", n, e)
+		}
+		if e > a.Mel()[loudest] {
+			loudest = n
+		}
+	}
+	if loudest != 12 {
+		t.Errorf("Expected 1kHz loudest in band 12, got %d: %v", loudest, a.Mel())
+	}
+}
+
+func TestOnset(t *testing.T) {
+	a, _ := NewGo(testOptions)
+	samples, onsets := referenceAudio(testOptions.SampleRate)
+	hopSeconds := float64(testOptions.HopSize) / float64(testOptions.SampleRate)
+	var detected []float64
+	analyse(a, samples, testOptions.HopSize, func(hop int) {
+		if a.Onset() {
+			detected = append(detected, float64(hop)*hopSeconds)
+		}
+	})
+	if len(detected) != len(onsets) {
+		t.Fatalf("Expected %d onsets, got them at %v\n", len(onsets), detected)
+	}
+	for i, at := range detected {
+		// Reported up to three hops late
+		if at < onsets[i] || at > onsets[i]+4*hopSeconds {
+			t.Errorf("Expected an onset at %.3fs, got %.3fs", onsets[i], at)
+		}
+	}
+}
+
+func TestRMS(t *testing.T) {
+	a, _ := NewGo(testOptions)
+	hop := make([]float64, testOptions.HopSize)
+	analyse(a, hop, testOptions.HopSize, func(int) {})
+	if a.RMS() != 0 || a.Onset() {
+		t.Errorf("Expected silence, got an RMS of %g", a.RMS())
+	}
+	for i := range hop {
+		hop[i] = 0.5 * math.Sin(2*math.Pi*float64(i)/float64(len(hop)))
+	}
+	if rms := RMS(hop); math.Abs(rms-0.5/math.Sqrt2) > 1e-9 {
+		t.Errorf("Expected %g, got %g", 0.5/math.Sqrt2, rms)
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New("nope", testOptions); !errors.Is(err, ErrUnknownBackend) {
+		t.Errorf("Expected an unknown backend, got %v", err)
+	}
+	if _, err := New(Go, Options{SampleRate: 44100, FFTSize: 1000, HopSize: 735}); err == nil {
+		t.Errorf("Expected an error for an FFT size that is no power of two")
+	}
+	if _, err := New(Go, Options{SampleRate: 96000, FFTSize: 1024, HopSize: 1600}); err == nil {
+		t.Errorf("Expected an error for a hop longer than the window")
+	}
+	if err := checkBackend(string(Go)); err != nil {
+		t.Errorf("Expected the Go backend to pass the config check, got %v", err)
+	}
+	if err := checkBackend("fft"); !errors.Is(err, ErrUnknownBackend) {
+		t.Errorf("Expected the config check to fail for an unknown backend, got %v", err)
+	}
+	if a, err := New("", testOptions); err != nil {
+		t.Errorf("Error with the default backend %s: %v", Default(), err)
+	} else {
+		a.Close()
+	}
+}
@@ -0,0 +1,74 @@
+//go:build cgo && !noaubio
+// +build cgo,!noaubio
+
+package analysis
+
+import (
+	"fmt"
+
+	aubio "github.com/simonassank/aubio-go"
+)
+
+func init() {
+	backends[Aubio] = NewAubio
+}
+
+// aubioAnalyzer is the backend on top of libaubio. RMS is computed in Go,
+// aubio-go has no binding for the level.
+type aubioAnalyzer struct {
+	hopSize int
+	pvoc    *aubio.PhaseVoc
+	melbank *aubio.FilterBank
+	onset   *aubio.Onset
+
+	spectrum []float64
+	bands    []float64
+	isOnset  bool
+	rms      float64
+}
+
+// NewAubio returns an analyzer of the aubio backend
+func NewAubio(o Options) (Analyzer, error) {
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+	a := &aubioAnalyzer{hopSize: o.HopSize}
+	var err error
+	if a.pvoc, err = aubio.NewPhaseVoc(uint(o.FFTSize), uint(o.HopSize)); err != nil {
+		return nil, fmt.Errorf("error initializing new Aubio phase vocoder: %w", err)
+	}
+	a.melbank = aubio.NewFilterBank(MelBands, uint(o.FFTSize))
+	a.melbank.SetMelCoeffsSlaney(uint(o.SampleRate))
+	if a.onset, err = aubio.NewOnset(aubio.SpecFlux, uint(o.FFTSize), uint(o.HopSize), uint(o.SampleRate)); err != nil {
+		a.pvoc.Free()
+		return nil, fmt.Errorf("error initializing new Aubio onset: %w", err)
+	}
+	return a, nil
+}
+
+// Do takes exactly a hop of samples, aubio reads that many whatever it is
+// passed
+func (a *aubioAnalyzer) Do(samples []float64) {
+	in := aubio.NewSimpleBufferData(uint(a.hopSize), samples)
+	defer in.Free()
+	a.pvoc.Do(in)
+	a.melbank.Do(a.pvoc.Grain())
+	a.onset.Do(in)
+
+	a.spectrum = a.pvoc.Grain().Norm()
+	a.bands = a.melbank.Buffer().Slice()
+	a.isOnset = a.onset.Buffer().Slice()[0] > 0
+	a.rms = RMS(samples)
+}
+
+func (a *aubioAnalyzer) Spectrum() []float64 { return a.spectrum }
+func (a *aubioAnalyzer) Mel() []float64      { return a.bands }
+func (a *aubioAnalyzer) Onset() bool         { return a.isOnset }
+func (a *aubioAnalyzer) RMS() float64        { return a.rms }
+
+// Close frees the phase vocoder and onset detector, aubio-go cannot free a
+// filterbank
+func (a *aubioAnalyzer) Close() {
+	a.pvoc.Free()
+	a.onset.Free()
+}
@@ -0,0 +1,71 @@
+package analysis
+
+import (
+	"math"
+	"math/bits"
+)
+
+// fft is a radix-2 FFT of a fixed size with its twiddle factors and bit
+// reversal table worked out once
+type fft struct {
+	n       int
+	cos     []float64
+	sin     []float64
+	reverse []int
+	re, im  []float64
+}
+
+func newFFT(n int) *fft {
+	f := &fft{
+		n:       n,
+		cos:     make([]float64, n/2),
+		sin:     make([]float64, n/2),
+		reverse: make([]int, n),
+		re:      make([]float64, n),
+		im:      make([]float64, n),
+	}
+	for i := range f.cos {
+		f.cos[i] = math.Cos(2 * math.Pi * float64(i) / float64(n))
+		f.sin[i] = -math.Sin(2 * math.Pi * float64(i) / float64(n))
+	}
+	shift := 64 - bits.TrailingZeros(uint(n))
+	for i := range f.reverse {
+		f.reverse[i] = int(bits.Reverse64(uint64(i)) >> shift)
+	}
+	return f
+}
+
+// magnitude sets norm, n/2+1 long, to the magnitude of the spectrum of the
+// real signal x, n long
+func (f *fft) magnitude(norm, x []float64) {
+	for i, r := range f.reverse {
+		f.re[r] = x[i]
+		f.im[r] = 0
+	}
+	for size := 2; size <= f.n; size <<= 1 {
+		half, step := size/2, f.n/size
+		for start := 0; start < f.n; start += size {
+			for k := 0; k < half; k++ {
+				c, s := f.cos[k*step], f.sin[k*step]
+				i, j := start+k, start+k+half
+				tre := f.re[j]*c - f.im[j]*s
+				tim := f.re[j]*s + f.im[j]*c
+				f.re[j], f.im[j] = f.re[i]-tre, f.im[i]-tim
+				f.re[i], f.im[i] = f.re[i]+tre, f.im[i]+tim
+			}
+		}
+	}
+	for i := range norm {
+		norm[i] = math.Hypot(f.re[i], f.im[i])
+	}
+}
+
+// hann returns a periodic Hann window of n samples, the hanningz window of
+// aubio
+func hann(n int) []float64 {
+	w := make([]float64, n)
+	for i := range w {
+		w[i] = 0.5 * (1 - math.Cos(2*math.Pi*float64(i)/float64(n)))
+	}
+	return w
+}
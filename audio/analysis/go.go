@@ -0,0 +1,56 @@
+package analysis
+
+// goAnalyzer is the pure Go backend. Like an aubio phase vocoder it slides
+// a window of FFTSize samples along by a hop on every call to Do.
+type goAnalyzer struct {
+	window   []float64
+	frame    []float64
+	windowed []float64
+	fft      *fft
+	mel      *melFilterbank
+	onset    *onsetDetector
+
+	spectrum []float64
+	bands    []float64
+	isOnset  bool
+	rms      float64
+}
+
+// NewGo returns an analyzer of the pure Go backend
+func NewGo(o Options) (Analyzer, error) {
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+	bins := o.FFTSize/2 + 1
+	return &goAnalyzer{
+		window:   hann(o.FFTSize),
+		frame:    make([]float64, o.FFTSize),
+		windowed: make([]float64, o.FFTSize),
+		fft:      newFFT(o.FFTSize),
+		mel:      newMelFilterbank(bins, float64(o.SampleRate)),
+		onset:    newOnsetDetector(o),
+		spectrum: make([]float64, bins),
+		bands:    make([]float64, MelBands),
+	}, nil
+}
+
+func (a *goAnalyzer) Do(samples []float64) {
+	if len(samples) > len(a.frame) {
+		samples = samples[len(samples)-len(a.frame):]
+	}
+	copy(a.frame, a.frame[len(samples):])
+	copy(a.frame[len(a.frame)-len(samples):], samples)
+	for i, v := range a.frame {
+		a.windowed[i] = v * a.window[i]
+	}
+	a.fft.magnitude(a.spectrum, a.windowed)
+	a.mel.do(a.bands, a.spectrum)
+	a.rms = RMS(samples)
+	a.isOnset = a.onset.do(a.spectrum, a.rms)
+}
+
+func (a *goAnalyzer) Spectrum() []float64 { return a.spectrum }
+func (a *goAnalyzer) Mel() []float64      { return a.bands }
+func (a *goAnalyzer) Onset() bool         { return a.isOnset }
+func (a *goAnalyzer) RMS() float64        { return a.rms }
+func (a *goAnalyzer) Close()              {}
@@ -0,0 +1,84 @@
+package analysis
+
+// melFilterbank weights the magnitude spectrum into triangular bands of
+// unit area, each row of coeffs being a band
+type melFilterbank struct {
+	coeffs [][]float64
+}
+
+// newMelFilterbank returns the MelBands bands of Slaney's Auditory Toolbox
+// for a spectrum of bins bins at sampleRate. The bands are laid out exactly
+// like aubio_filterbank_set_mel_coeffs_slaney does, so both backends agree.
+func newMelFilterbank(bins int, sampleRate float64) *melFilterbank {
+	const (
+		lowestFrequency = 133.3333
+		linearSpacing   = 66.66666666
+		logSpacing      = 1.0711703
+		linearFilters   = 13
+		logFilters      = MelBands - linearFilters
+	)
+	// 13 linear bands up to 1kHz, then logarithmic ones. Band n spans
+	// freqs[n] to freqs[n+2], peaking at freqs[n+1].
+	freqs := make([]float64, MelBands+2)
+	for i := 0; i < linearFilters; i++ {
+		freqs[i] = lowestFrequency + float64(i)*linearSpacing
+	}
+	lastLinear := freqs[linearFilters-1]
+	scale := 1.0
+	for i := linearFilters; i < len(freqs); i++ {
+		scale *= logSpacing
+		freqs[i] = lastLinear * scale
+	}
+
+	binFreqs := make([]float64, bins)
+	for i := range binFreqs {
+		binFreqs[i] = float64(i) * sampleRate / float64((bins-1)*2)
+	}
+
+	fb := &melFilterbank{coeffs: make([][]float64, MelBands)}
+	for n := range fb.coeffs {
+		band := make([]float64, bins)
+		fb.coeffs[n] = band
+		lower, center, upper := freqs[n], freqs[n+1], freqs[n+2]
+		height := 2 / (upper - lower)
+
+		// The first bin above the lower edge
+		bin := 0
+		for ; bin < bins-1; bin++ {
+			if binFreqs[bin] <= lower && binFreqs[bin+1] > lower {
+				bin++
+				break
+			}
+		}
+		rise := height / (center - lower)
+		for ; bin < bins-1; bin++ {
+			band[bin] = (binFreqs[bin] - lower) * rise
+			if binFreqs[bin+1] >= center {
+				bin++
+				break
+			}
+		}
+		fall := height / (upper - center)
+		for ; bin < bins-1; bin++ {
+			band[bin] += (upper - binFreqs[bin]) * fall
+			if band[bin] < 0 {
+				band[bin] = 0
+			}
+			if binFreqs[bin+1] >= upper {
+				break
+			}
+		}
+	}
+	return fb
+}
+
+// do sets out to the energy of each band of the magnitude spectrum norm
+func (fb *melFilterbank) do(out, norm []float64) {
+	for n, band := range fb.coeffs {
+		var sum float64
+		for i, w := range band {
+			sum += w * norm[i]
+		}
+		out[n] = sum
+	}
+}
@@ -0,0 +1,137 @@
+package analysis
+
+import (
+	"math"
+	"sort"
+)
+
+// Parameters of onset detection, the defaults aubio uses for its specflux
+// method
+const (
+	onsetThreshold = 0.18
+	// onsetSilence is the level in dB below which no onset is reported
+	onsetSilence = -70
+	// onsetMinGap is the shortest time between two onsets in seconds
+	onsetMinGap = 0.05
+	// whitenRelax is how many seconds a spectral peak takes to decay by
+	// 60dB, whitenFloor the least a bin is divided by
+	whitenRelax = 100
+	whitenFloor = 1
+	// compression scales magnitudes before their logarithm is taken
+	compression = 10
+)
+
+// onsetDetector finds onsets in the spectral flux, the summed increase of
+// the whitened and compressed magnitude of every bin from one window to the
+// next
+type onsetDetector struct {
+	peaks  []float64
+	decay  float64
+	prev   []float64
+	picker peakPicker
+
+	hopSeconds float64
+	// sinceOnset is the time since the last onset in seconds
+	sinceOnset float64
+}
+
+func newOnsetDetector(o Options) *onsetDetector {
+	bins := o.FFTSize/2 + 1
+	hopSeconds := float64(o.HopSize) / float64(o.SampleRate)
+	return &onsetDetector{
+		peaks:      make([]float64, bins),
+		decay:      math.Pow(0.001, hopSeconds/whitenRelax),
+		prev:       make([]float64, bins),
+		picker:     peakPicker{threshold: onsetThreshold},
+		hopSeconds: hopSeconds,
+		sinceOnset: math.Inf(1),
+	}
+}
+
+// do returns whether the hop of level rms with the magnitude spectrum norm
+// holds an onset
+func (d *onsetDetector) do(norm []float64, rms float64) bool {
+	var flux float64
+	for i, v := range norm {
+		d.peaks[i] = math.Max(v, math.Max(d.peaks[i]*d.decay, whitenFloor))
+		v = math.Log(1 + compression*v/d.peaks[i])
+		if v > d.prev[i] {
+			flux += v - d.prev[i]
+		}
+		d.prev[i] = v
+	}
+
+	d.sinceOnset += d.hopSeconds
+	if !d.picker.do(flux) || 20*math.Log10(rms) < onsetSilence || d.sinceOnset < onsetMinGap {
+		return false
+	}
+	d.sinceOnset = 0
+	return true
+}
+
+// peakPicker reports peaks of the onset detection function that rise above
+// its median and a share of its mean over the last few hops. It follows the
+// peak picker of aubio, so a peak is reported two hops after it happened.
+type peakPicker struct {
+	threshold float64
+	keep      [peakWindow]float64
+	proc      [peakWindow]float64
+	sorted    [peakWindow]float64
+	// peek are the last three thresholded values
+	peek [3]float64
+}
+
+const (
+	// peakWindow hops of the detection function are looked at, peakPost of
+	// them after the hop thresholded
+	peakWindow = 7
+	peakPost   = 5
+)
+
+func (p *peakPicker) do(v float64) bool {
+	copy(p.keep[:], p.keep[1:])
+	p.keep[peakWindow-1] = v
+
+	p.proc = p.keep
+	filtfilt(p.proc[:])
+	var mean float64
+	for _, x := range p.proc {
+		mean += x
+	}
+	mean /= peakWindow
+	p.sorted = p.proc
+	sort.Float64s(p.sorted[:])
+	median := p.sorted[peakWindow/2]
+
+	copy(p.peek[:], p.peek[1:])
+	p.peek[2] = p.proc[peakPost] - median - mean*p.threshold
+	return p.peek[1] > 0 && p.peek[1] > p.peek[0] && p.peek[1] > p.peek[2]
+}
+
+// filtfilt smooths x in place with a second order low pass run forwards
+// and backwards, which leaves no phase shift
+func filtfilt(x []float64) {
+	lowpass(x)
+	for i, j := 0, len(x)-1; i < j; i, j = i+1, j-1 {
+		x[i], x[j] = x[j], x[i]
+	}
+	lowpass(x)
+	for i, j := 0, len(x)-1; i < j; i, j = i+1, j-1 {
+		x[i], x[j] = x[j], x[i]
+	}
+}
+
+// lowpass is the biquad of the aubio peak picker, starting from rest
+func lowpass(x []float64) {
+	const (
+		b0, b1, b2 = 0.16, 0.32, 0.16
+		a1, a2     = -0.5949, 0.2348
+	)
+	var x1, x2, y1, y2 float64
+	for i, x0 := range x {
+		y0 := b0*x0 + b1*x1 + b2*x2 - a1*y1 - a2*y2
+		x[i] = y0
+		x1, x2 = x0, x1
+		y1, y2 = y0, y1
+	}
+}
@@ -0,0 +1,76 @@
+//go:build cgo && !noaubio
+// +build cgo,!noaubio
+
+package analysis
+
+import (
+	"math"
+	"testing"
+)
+
+// TestParity runs both backends over the reference audio. aubio computes in
+// single precision, so they agree up to a tolerance.
+func TestParity(t *testing.T) {
+	const (
+		spectrumTolerance = 1e-3
+		melTolerance      = 1e-2
+		// onsetTolerance is how many hops apart onsets may be reported
+		onsetTolerance = 2
+	)
+	for _, o := range []Options{testOptions, {SampleRate: 48000, FFTSize: 2048, HopSize: 800}} {
+		goA, _ := NewGo(o)
+		aubioA, err := NewAubio(o)
+		if err != nil {
+			t.Fatalf("Error initializing aubio: %v\n", err)
+		}
+		defer aubioA.Close()
+
+		samples, _ := referenceAudio(o.SampleRate)
+		var goOnsets, aubioOnsets []int
+		var spectrumErr, melErr float64
+		analyse(goA, samples, o.HopSize, func(hop int) {
+			aubioA.Do(samples[hop*o.HopSize : (hop+1)*o.HopSize])
+			spectrumErr = math.Max(spectrumErr, relativeError(goA.Spectrum(), aubioA.Spectrum()))
+			melErr = math.Max(melErr, relativeError(goA.Mel(), aubioA.Mel()))
+			if goA.Onset() {
+				goOnsets = append(goOnsets, hop)
+			}
+			if aubioA.Onset() {
+				aubioOnsets = append(aubioOnsets, hop)
+			}
+		})
+
+		if spectrumErr > spectrumTolerance {
+			t.Errorf("%+v: spectra differ by up to %g", o, spectrumErr)
+		}
+		if melErr > melTolerance {
+			t.Errorf("%+v: mel bands differ by up to %g", o, melErr)
+		}
+		if len(goOnsets) != len(aubioOnsets) {
+			t.Errorf("%+v: onsets differ, Go at hops %v, aubio at %v", o, goOnsets, aubioOnsets)
+			continue
+		}
+		for i := range goOnsets {
+			if d := goOnsets[i] - aubioOnsets[i]; d < -onsetTolerance || d > onsetTolerance {
+				t.Errorf("%+v: onset at hop %d in Go, %d in aubio", o, goOnsets[i], aubioOnsets[i])
+			}
+		}
+	}
+}
+
+// relativeError returns the largest difference between got and want
+// relative to the largest value of want
+func relativeError(got, want []float64) float64 {
+	if len(got) != len(want) {
+		return math.Inf(1)
+	}
+	var diff, scale float64
+	for i := range want {
+		diff = math.Max(diff, math.Abs(got[i]-want[i]))
+		scale = math.Max(scale, math.Abs(want[i]))
+	}
+	if scale == 0 {
+		return diff
+	}
+	return diff / scale
+}
@@ -1,46 +1,51 @@
 package audiobridge
 
 import (
+	"encoding/json"
 	"fmt"
 	"ledfx/integrations/airplay2"
 	log "ledfx/logger"
 )
 
 func (br *Bridge) StartAirPlayInput(name string, port int, verbose bool) error {
-	if br.inputType != -1 {
-		br.closeInput()
-	}
-	br.inputType = inputTypeAirPlayServer
+	br.closeInput(inputTypeAirPlayServer)
 
+	br.handlerMu.Lock()
+	defer br.handlerMu.Unlock()
 	if br.airplay == nil {
 		br.airplay = newAirPlayHandler()
 	}
 
+	in, err := br.addInput(inputTypeAirPlayServer)
+	if err != nil {
+		return err
+	}
+
 	br.airplay.server = airplay2.NewServer(airplay2.Config{
 		AdvertisementName: name,
 		Port:              port,
 		VerboseLogging:    verbose,
-	}, br.byteWriter)
+	}, in)
 
 	if err := br.airplay.server.Start(); err != nil {
+		_ = br.mixer.Remove(in.Name())
 		return fmt.Errorf("error starting AirPlay server: %w", err)
 	}
+
+	// Clients added before the server get its volume and track from now on
+	for _, client := range br.airplay.clients {
+		if err := br.airplay.server.AddClient(client); err != nil {
+			return fmt.Errorf("error adding AirPlay client to server: %w", err)
+		}
+	}
 	return nil
 }
 
 func (br *Bridge) AddAirPlayOutput(searchKey string, searchType AirPlaySearchType, verbose bool) error {
-	if br.inputType == -1 {
+	if !br.hasInputs() {
 		return fmt.Errorf("an input source is required before an output source can be initialized")
 	}
 
-	if br.airplay == nil {
-		br.airplay = newAirPlayHandler()
-	}
-
-	if br.airplay.clients == nil {
-		br.airplay.clients = make([]*airplay2.Client, 0)
-	}
-
 	params := airplay2.ClientDiscoveryParameters{
 		Verbose: verbose,
 	}
@@ -59,11 +64,19 @@ func (br *Bridge) AddAirPlayOutput(searchKey string, searchType AirPlaySearchTyp
 		return fmt.Errorf("error initializing AirPlay client: %w", err)
 	}
 
+	br.handlerMu.Lock()
+	defer br.handlerMu.Unlock()
+	if br.airplay == nil {
+		br.airplay = newAirPlayHandler()
+	}
+
 	// Close any connections that would be a duplicate of our current connection.
-	for i := range br.airplay.clients {
-		if br.airplay.clients[i].RemoteIP().Equal(client.RemoteIP()) {
+	for _, prev := range append([]*airplay2.Client{}, br.airplay.clients...) {
+		if prev.RemoteIP().Equal(client.RemoteIP()) {
 			log.Logger.WithField("category", "AirPlay Client Init").Warnf("Closing previous session with matching remote address...")
-			br.airplay.clients[i].Close()
+			if err := br.removeOutput(airPlayOutputID(prev)); err != nil {
+				prev.Close()
+			}
 		}
 	}
 
@@ -99,20 +112,44 @@ func (aph *AirPlayHandler) Stop() {
 	}
 }
 
+// AirPlaySearchType is how the search key of an AirPlay output is matched.
+// It is encoded by name, "name" or "ip".
 type AirPlaySearchType string
 
 const (
 	AirPlaySearchByName AirPlaySearchType = "name"
-	AirPlaySearchByIP                     = "ip"
+	AirPlaySearchByIP   AirPlaySearchType = "ip"
 )
 
-func (a AirPlaySearchType) MarshalJSON() ([]byte, error) {
+func (a AirPlaySearchType) MarshalText() ([]byte, error) {
 	switch a {
-	case AirPlaySearchByName:
-		return []byte("0"), nil
-	case AirPlaySearchByIP:
-		return []byte("1"), nil
+	case AirPlaySearchByName, AirPlaySearchByIP:
+		return []byte(a), nil
+	default:
+		return nil, fmt.Errorf("unknown search type '%s'", string(a))
+	}
+}
+
+// UnmarshalJSON takes a name, or the numbers 0 for name and 1 for ip that
+// older versions wrote
+func (a *AirPlaySearchType) UnmarshalJSON(b []byte) error {
+	switch string(b) {
+	case "0":
+		*a = AirPlaySearchByName
+		return nil
+	case "1":
+		*a = AirPlaySearchByIP
+		return nil
+	}
+	var name string
+	if err := json.Unmarshal(b, &name); err != nil {
+		return fmt.Errorf("search type must be \"name\" or \"ip\": %w", err)
+	}
+	switch t := AirPlaySearchType(name); t {
+	case AirPlaySearchByName, AirPlaySearchByIP:
+		*a = t
+		return nil
 	default:
-		return nil, fmt.Errorf("unknown search type '%d'", a)
+		return fmt.Errorf("unknown search type '%s'", name)
 	}
 }
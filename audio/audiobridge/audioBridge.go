@@ -2,41 +2,50 @@ package audiobridge
 
 import (
 	"fmt"
-	"github.com/gordonklaus/portaudio"
 	"ledfx/audio"
 	"ledfx/audio/audiobridge/assets"
+	"ledfx/audio/audiobridge/mixer"
+	"ledfx/audio/pcm"
 	log "ledfx/logger"
 )
 
 // NewBridge initializes a new bridge between a source and destination audio device.
 func NewBridge(bufferCallback func(buf audio.Buffer)) (br *Bridge, err error) {
-	if err := portaudio.Initialize(); err != nil {
+	if err := initPortAudio(); err != nil {
 		return nil, fmt.Errorf("error initializing PortAudio: %w", err)
 	}
 	br = &Bridge{
 		bufferCallback: bufferCallback,
 		byteWriter:     audio.NewAsyncMultiWriter(),
-		inputType:      inputType(-1), // -1 signifies undefined
+		format:         pcm.CD,
 		done:           make(chan bool),
 		outputs:        make([]*OutputInfo, 0),
+		stages:         make(map[string]*stage),
+		subscribers:    make(map[chan OutputEvent]struct{}),
 	}
 
 	br.info = &Info{
 		br: br,
 	}
 
-	if err := br.byteWriter.AddWriter(&CallbackWrapper{
+	if err := br.AddOutputWriter(&CallbackWrapper{
 		Callback: bufferCallback,
-	}, "CallbackWrapper"); err != nil {
+	}, ledIdentifier); err != nil {
 		return nil, fmt.Errorf("error adding callback wrapper to writer: %w", err)
 	}
 
+	br.byteWriter.SetRemoveHandler(br.outputFailed)
+
+	br.mixer = mixer.New(br.byteWriter, br.format)
+	br.mixer.Start()
+
 	br.ctl = br.newController()
 	return br, nil
 }
 
 func (cbw *CallbackWrapper) Write(p []byte) (int, error) {
-	cbw.Callback(audio.BytesToAudioBuffer(p))
+	cbw.buf = audio.AppendAudioBuffer(cbw.buf[:0], p)
+	cbw.Callback(cbw.buf)
 	return len(p), nil
 }
 
@@ -55,6 +64,7 @@ func (br *Bridge) Stop() {
 			br.done <- true
 		}()
 	}()
+	br.handlerMu.Lock()
 	if br.airplay != nil {
 		log.Logger.WithField("category", "Audio Bridge").Warnf("Stopping AirPlay handler...")
 		br.airplay.Stop()
@@ -65,26 +75,85 @@ func (br *Bridge) Stop() {
 		br.local.Stop()
 	}
 
+	if br.file != nil {
+		log.Logger.WithField("category", "Audio Bridge").Warnf("Stopping file handler...")
+		br.file.handler.Quit()
+	}
+
+	if br.synth != nil {
+		log.Logger.WithField("category", "Audio Bridge").Warnf("Stopping synth handler...")
+		br.synth.handler.Quit()
+	}
+
+	if br.recorder != nil {
+		log.Logger.WithField("category", "Audio Bridge").Warnf("Finishing recording...")
+		_ = br.recorder.recorder.Stop()
+	}
+	br.handlerMu.Unlock()
+
+	log.Logger.WithField("category", "Audio Bridge").Warnf("Stopping mixer...")
+	br.mixer.Close()
+	br.byteWriter.RemoveAll()
+
 	log.Logger.WithField("category", "Audio Bridge").Warnf("Terminating PortAudio...")
-	_ = portaudio.Terminate()
+	_ = terminatePortAudio()
 }
 
-func (br *Bridge) closeInput() {
-	switch br.inputType {
+// closeInput stops the input of type t if there is one. Its mixer input
+// stays until it is replaced or removed.
+func (br *Bridge) closeInput(t inputType) {
+	switch t {
 	case inputTypeAirPlayServer:
-		if !br.airplay.server.Stopped() {
+		br.handlerMu.Lock()
+		defer br.handlerMu.Unlock()
+		if br.airplay != nil && br.airplay.server != nil && !br.airplay.server.Stopped() {
 			br.airplay.server.Stop()
 		}
 	case inputTypeLocal:
-		if !br.local.capture.Stopped() {
+		br.handlerMu.Lock()
+		defer br.handlerMu.Unlock()
+		if br.local != nil && br.local.capture != nil && !br.local.capture.Stopped() {
 			br.local.capture.Quit()
 		}
 	case inputTypeYoutube:
-		if !br.youtube.handler.Stopped() {
+		if br.youtube != nil && !br.youtube.handler.Stopped() {
 			br.youtube.handler.Quit()
+		}
+	case inputTypeFile:
+		if br.file != nil && !br.file.handler.Stopped() {
+			br.file.handler.Quit()
+		}
+	case inputTypeSynth:
+		if br.synth != nil {
+			br.synth.handler.Stop()
+		}
+	}
+}
 
+// RemoveInput stops the input called name and removes it from the mixer
+func (br *Bridge) RemoveInput(name string) error {
+	for t := inputTypeAirPlayServer; t <= inputTypeSynth; t++ {
+		if t.String() == name {
+			br.closeInput(t)
+			break
 		}
 	}
+	return br.mixer.Remove(name)
+}
+
+// addInput returns a new mixer input for an input of type t. The input
+// replaces the one of an earlier input of t and keeps its settings.
+func (br *Bridge) addInput(t inputType) (*mixer.Input, error) {
+	in, err := br.mixer.Add(t.String(), t.settings(), t.blocking())
+	if err != nil {
+		return nil, fmt.Errorf("error adding %s to the mixer: %w", t, err)
+	}
+	return in, nil
+}
+
+// hasInputs returns whether any input is mixed
+func (br *Bridge) hasInputs() bool {
+	return len(br.mixer.Inputs()) > 0
 }
 
 // Wait waits for the bridge to finish.
@@ -1,6 +1,7 @@
 package audiobridge
 
 import (
+	"encoding/json"
 	"fmt"
 	"ledfx/audio"
 	"ledfx/config"
@@ -194,3 +195,34 @@ func TestBridgeYouTube2LocalJSON(t *testing.T) {
 
 	br.Wait()
 }
+
+func TestAirPlaySearchTypeJSON(t *testing.T) {
+	b, err := AirPlayOutputJSON{SearchKey: "10.0.0.2", SearchType: AirPlaySearchByIP}.AsJSON()
+	if err != nil {
+		t.Fatalf("Error encoding: %v\n", err)
+	}
+	if !strings.Contains(string(b), `"search_type":"ip"`) {
+		t.Errorf("Expected the search type by name, got %s", b)
+	}
+	if _, err := (AirPlayOutputJSON{SearchType: "mdns"}).AsJSON(); err == nil {
+		t.Errorf("Expected an error encoding an unknown search type")
+	}
+
+	for body, want := range map[string]AirPlaySearchType{
+		`{"search_type": "name"}`: AirPlaySearchByName,
+		`{"search_type": "ip"}`:   AirPlaySearchByIP,
+		`{"search_type": 0}`:      AirPlaySearchByName,
+		`{"search_type": 1}`:      AirPlaySearchByIP,
+	} {
+		var conf AirPlayOutputJSON
+		if err := json.Unmarshal([]byte(body), &conf); err != nil || conf.SearchType != want {
+			t.Errorf("Expected %s from %s, got %s: %v", want, body, conf.SearchType, err)
+		}
+	}
+	for _, body := range []string{`{"search_type": "mdns"}`, `{"search_type": 2}`, `{"search_type": true}`} {
+		var conf AirPlayOutputJSON
+		if err := json.Unmarshal([]byte(body), &conf); err == nil {
+			t.Errorf("Expected an error decoding %s", body)
+		}
+	}
+}
@@ -23,6 +23,14 @@ func BenchmarkBytesToInt16Unsafe(b *testing.B) {
 	}
 }
 
+func BenchmarkAppendAudioBuffer(b *testing.B) {
+	var buf audio.Buffer
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buf = audio.AppendAudioBuffer(buf[:0], testBytes)
+	}
+}
+
 func bytesToAudioBufferUnsafe(p []byte) (out audio.Buffer) {
 	out = make([]int16, len(p))
 	var offset int
@@ -1,88 +1,59 @@
 package capture
 
 import (
-	"fmt"
-	"github.com/gordonklaus/portaudio"
-	"ledfx/audio"
+	"go.uber.org/atomic"
+	"io"
+	"ledfx/audio/pcm"
+	"ledfx/audio/pulse"
 	"ledfx/config"
 	log "ledfx/logger"
 )
 
-type Handler struct {
-	*portaudio.Stream
-	byteWriter *audio.AsyncMultiWriter
-	verbose    bool
-	stopped    bool
+// stream is the part of a PortAudio stream a handler stops, so that this
+// file builds without cgo
+type stream interface {
+	Abort() error
+	Close() error
 }
 
-func NewHandler(audioDevice config.AudioDevice, byteWriter *audio.AsyncMultiWriter, verbose bool) (h *Handler, err error) {
-	if verbose {
-		log.Logger.WithField("category", "Local Capture Init").Infof("Getting info for device '%s'...", audioDevice.Name)
-	}
-	dev, err := audio.GetPaDeviceInfo(audioDevice)
-	if err != nil {
-		return nil, fmt.Errorf("error getting PortAudio device info: %w", err)
-	}
-
-	p := portaudio.StreamParameters{
-		Input: portaudio.StreamDeviceParameters{
-			Device:   dev,
-			Channels: dev.MaxInputChannels,
-		},
-		SampleRate:      dev.DefaultSampleRate,
-		FramesPerBuffer: int(dev.DefaultSampleRate / 60),
-	}
-
-	h = &Handler{
-		byteWriter: byteWriter,
-		verbose:    verbose,
-	}
-
-	switch p.Input.Channels {
-	case 1:
-		if verbose {
-			log.Logger.WithField("category", "Local Capture Init").Infof("Opening stream with Mono2Stereo callback...")
-		}
-		if h.Stream, err = portaudio.OpenStream(p, h.mono2StereoCallback); err != nil {
-			return nil, fmt.Errorf("error opening mono Portaudio stream: %w", err)
-		}
-	case 2:
-		if verbose {
-			log.Logger.WithField("category", "Local Capture Init").Infof("Opening stream with Stereo callback...")
-		}
-		if h.Stream, err = portaudio.OpenStream(p, h.stereoCallback); err != nil {
-			return nil, fmt.Errorf("error opening stereo Portaudio stream: %w", err)
-		}
-	default:
-		return nil, fmt.Errorf("%d channel audio is unsupported (LedFX only supports stereo/mono)", p.Input.Channels)
-	}
-
-	if verbose {
-		log.Logger.WithField("category", "Local Capture Init").Infof("Starting stream...")
-	}
-	if err = h.Stream.Start(); err != nil {
-		return nil, fmt.Errorf("error starting capture stream: %w", err)
-	}
-
-	return h, nil
+type Handler struct {
+	stream stream
+	// rec records PulseAudio sources instead of stream
+	rec     *pulse.Recorder
+	out     io.Writer
+	conv    *pcm.Converter
+	verbose bool
+	stopped atomic.Bool
 }
 
-func (h *Handler) stereoCallback(in audio.Buffer) {
-	h.byteWriter.Write(in.AsBytes())
+// NewHandler captures audioDevice in its native rate and channels and writes
+// it to out converted to format. PulseAudio sources are recorded from the
+// server, everything else through PortAudio.
+func NewHandler(audioDevice config.AudioDevice, format pcm.Format, out io.Writer, verbose bool) (h *Handler, err error) {
+	if audioDevice.HostApi == pulse.HostApi {
+		return newPulseHandler(audioDevice, format, out, verbose)
+	}
+	return newPortAudioHandler(audioDevice, format, out, verbose)
 }
 
-func (h *Handler) mono2StereoCallback(in audio.Buffer) {
-	h.stereoCallback(in.ChannelMultiplier(2))
+// Format returns the format of the device being captured
+func (h *Handler) Format() pcm.Format {
+	return h.conv.From()
 }
 
 func (h *Handler) Quit() {
-	h.stopped = true
+	h.stopped.Store(true)
+	if h.rec != nil {
+		log.Logger.WithField("category", "Capture Handler").Warnf("Stopping PulseAudio recording...")
+		h.rec.Stop()
+		return
+	}
 	log.Logger.WithField("category", "Capture Handler").Warnf("Aborting stream...")
-	h.Stream.Abort()
+	h.stream.Abort()
 	log.Logger.WithField("category", "Capture Handler").Warnf("Closing stream...")
-	h.Stream.Close()
+	h.stream.Close()
 }
 
 func (h *Handler) Stopped() bool {
-	return h.stopped
+	return h.stopped.Load()
 }
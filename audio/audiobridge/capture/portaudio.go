@@ -0,0 +1,70 @@
+//go:build cgo
+// +build cgo
+
+package capture
+
+import (
+	"fmt"
+	"github.com/gordonklaus/portaudio"
+	"io"
+	"ledfx/audio"
+	"ledfx/audio/pcm"
+	"ledfx/config"
+	log "ledfx/logger"
+)
+
+// newPortAudioHandler captures audioDevice through PortAudio
+func newPortAudioHandler(audioDevice config.AudioDevice, format pcm.Format, out io.Writer, verbose bool) (h *Handler, err error) {
+	if verbose {
+		log.Logger.WithField("category", "Local Capture Init").Infof("Getting info for device '%s'...", audioDevice.Name)
+	}
+	dev, err := audio.GetPaDeviceInfo(audioDevice)
+	if err != nil {
+		return nil, fmt.Errorf("error getting PortAudio device info: %w", err)
+	}
+
+	p := portaudio.StreamParameters{
+		Input: portaudio.StreamDeviceParameters{
+			Device:   dev,
+			Channels: dev.MaxInputChannels,
+		},
+		SampleRate:      dev.DefaultSampleRate,
+		FramesPerBuffer: int(dev.DefaultSampleRate / 60),
+	}
+
+	h = &Handler{
+		out:     out,
+		verbose: verbose,
+	}
+
+	from := pcm.Format{
+		SampleRate: int(dev.DefaultSampleRate),
+		Channels:   p.Input.Channels,
+		Encoding:   pcm.Float32,
+	}
+	if h.conv, err = pcm.NewConverter(from, format); err != nil {
+		return nil, fmt.Errorf("error converting from device format (%s): %w", from, err)
+	}
+
+	if verbose {
+		log.Logger.WithField("category", "Local Capture Init").Infof("Opening stream (%s to %s)...", from, format)
+	}
+	s, err := portaudio.OpenStream(p, h.callback)
+	if err != nil {
+		return nil, fmt.Errorf("error opening Portaudio stream: %w", err)
+	}
+	h.stream = s
+
+	if verbose {
+		log.Logger.WithField("category", "Local Capture Init").Infof("Starting stream...")
+	}
+	if err = s.Start(); err != nil {
+		return nil, fmt.Errorf("error starting capture stream: %w", err)
+	}
+
+	return h, nil
+}
+
+func (h *Handler) callback(in []float32) {
+	h.out.Write(h.conv.ConvertFloat32(in))
+}
@@ -0,0 +1,17 @@
+//go:build !cgo
+// +build !cgo
+
+package capture
+
+import (
+	"fmt"
+	"io"
+	"ledfx/audio"
+	"ledfx/audio/pcm"
+	"ledfx/config"
+)
+
+// newPortAudioHandler fails, builds without cgo only capture PulseAudio
+func newPortAudioHandler(audioDevice config.AudioDevice, format pcm.Format, out io.Writer, verbose bool) (h *Handler, err error) {
+	return nil, fmt.Errorf("error capturing '%s': %w", audioDevice.Name, audio.ErrNoPortAudio)
+}
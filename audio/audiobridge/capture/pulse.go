@@ -0,0 +1,62 @@
+package capture
+
+import (
+	"fmt"
+	"io"
+	"ledfx/audio"
+	"ledfx/audio/pcm"
+	"ledfx/audio/pulse"
+	"ledfx/config"
+	log "ledfx/logger"
+)
+
+// newPulseHandler records the PulseAudio source of audioDevice, a sink
+// monitor or an input, with parec
+func newPulseHandler(audioDevice config.AudioDevice, format pcm.Format, out io.Writer, verbose bool) (h *Handler, err error) {
+	if verbose {
+		log.Logger.WithField("category", "Local Capture Init").Infof("Finding PulseAudio source '%s'...", audioDevice.Name)
+	}
+	source, err := audio.GetPulseSource(audioDevice)
+	if err != nil {
+		return nil, err
+	}
+
+	h = &Handler{
+		out:     out,
+		verbose: verbose,
+	}
+	from := pcm.Format{
+		SampleRate: source.SampleRate,
+		Channels:   source.Channels,
+		Encoding:   pcm.Float32,
+	}
+	if h.conv, err = pcm.NewConverter(from, format); err != nil {
+		return nil, fmt.Errorf("error converting from source format (%s): %w", from, err)
+	}
+
+	if verbose {
+		log.Logger.WithField("category", "Local Capture Init").Infof("Recording %s (%s to %s)...", source.Name, from, format)
+	}
+	if h.rec, err = pulse.Record(source.Name, from.SampleRate, from.Channels, pulseWriter{h}); err != nil {
+		return nil, fmt.Errorf("error recording PulseAudio source: %w", err)
+	}
+	go func() {
+		<-h.rec.Done()
+		if !h.stopped.Swap(true) {
+			log.Logger.WithField("category", "Capture Handler").Warnf("Recording %s stopped: %v", source.Name, h.rec.Err())
+		}
+	}()
+	return h, nil
+}
+
+// pulseWriter converts what parec records
+type pulseWriter struct {
+	h *Handler
+}
+
+func (w pulseWriter) Write(p []byte) (int, error) {
+	if _, err := w.h.out.Write(w.h.conv.Convert(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
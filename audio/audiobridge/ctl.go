@@ -3,6 +3,9 @@ package audiobridge
 import (
 	"errors"
 	"fmt"
+	"ledfx/audio/audiobridge/file"
+	"ledfx/audio/audiobridge/mixer"
+	"ledfx/audio/audiobridge/recorder"
 	"ledfx/audio/audiobridge/youtube"
 	"ledfx/integrations/airplay2"
 	"time"
@@ -108,51 +111,132 @@ func (ytc *YoutubeController) SongCompletionPercent() (youtube.CompletionPercent
 
 // --- END YOUTUBE CTL ---
 
+// --- BEGIN FILE CTL ---
+
+// File returns a *FileController
+func (c *Controller) File() *FileController {
+	return &FileController{
+		handler: c.br.file,
+	}
+}
+
+// Handler returns the file handler, which has its own controls
+func (fc *FileController) Handler() (*file.Handler, error) {
+	if fc.handler != nil {
+		if fc.handler.handler != nil {
+			return fc.handler.handler, nil
+		}
+	}
+	return nil, fmt.Errorf("file handler is not active")
+}
+
+// --- END FILE CTL ---
+
+// --- BEGIN RECORDER CTL ---
+
+// Recorder returns a *RecorderController
+func (c *Controller) Recorder() *RecorderController {
+	return &RecorderController{br: c.br}
+}
+
+// Recorder returns the recorder, which has its own controls
+func (rc *RecorderController) Recorder() (*recorder.Recorder, error) {
+	rc.br.handlerMu.Lock()
+	defer rc.br.handlerMu.Unlock()
+	handler := rc.br.recorder
+	if handler != nil {
+		if handler.recorder != nil {
+			return handler.recorder, nil
+		}
+	}
+	return nil, fmt.Errorf("recorder output is not active")
+}
+
+// --- END RECORDER CTL ---
+
+// --- BEGIN MIXER CTL ---
+
+// Mixer returns a *MixerController
+func (c *Controller) Mixer() *MixerController {
+	return &MixerController{
+		mixer: c.br.mixer,
+	}
+}
+
+// Info returns the state of the mixer and its inputs
+func (mc *MixerController) Info() MixerInfo {
+	return MixerInfo{
+		DuckGain: mc.mixer.DuckGain(),
+		Level:    mc.mixer.Level(),
+		Inputs:   mc.mixer.Inputs(),
+	}
+}
+
+// Set changes the settings of the input called name
+func (mc *MixerController) Set(name string, s mixer.Settings) error {
+	return mc.mixer.Set(name, s)
+}
+
+// --- END MIXER CTL ---
+
 // --- BEGIN LOCAL CTL ---
 
 // Local returns a *LocalController
 func (c *Controller) Local() *LocalController {
-	return &LocalController{
-		handler: c.br.local,
-	}
+	return &LocalController{br: c.br}
 }
 
 func (lc *LocalController) Stop() error {
-	if lc.handler != nil {
-		lc.handler.Stop()
+	lc.br.handlerMu.Lock()
+	defer lc.br.handlerMu.Unlock()
+	handler := lc.br.local
+	if handler != nil {
+		handler.Stop()
 		return nil
 	}
 	return fmt.Errorf("local handler is not active")
 }
 func (lc *LocalController) SetVerbose(enabled bool) error {
-	if lc.handler != nil {
-		lc.handler.verbose = enabled
+	lc.br.handlerMu.Lock()
+	defer lc.br.handlerMu.Unlock()
+	handler := lc.br.local
+	if handler != nil {
+		handler.verbose = enabled
 		return nil
 	}
 	return fmt.Errorf("local handler is not active")
 }
 func (lc *LocalController) QuitPlayback() error {
-	if lc.handler != nil {
-		if lc.handler.playback != nil {
-			lc.handler.playback.Quit()
+	lc.br.handlerMu.Lock()
+	defer lc.br.handlerMu.Unlock()
+	handler := lc.br.local
+	if handler != nil {
+		if handler.playback != nil {
+			handler.playback.Quit()
 			return nil
 		}
 	}
 	return fmt.Errorf("local playback is not active")
 }
 func (lc *LocalController) QuitCapture() error {
-	if lc.handler != nil {
-		if lc.handler.capture != nil {
-			lc.handler.capture.Quit()
+	lc.br.handlerMu.Lock()
+	defer lc.br.handlerMu.Unlock()
+	handler := lc.br.local
+	if handler != nil {
+		if handler.capture != nil {
+			handler.capture.Quit()
 			return nil
 		}
 	}
 	return fmt.Errorf("local capture is not active")
 }
 func (lc *LocalController) PlaybackIdentifier() (string, error) {
-	if lc.handler != nil {
-		if lc.handler.playback != nil {
-			return lc.handler.playback.Identifier(), nil
+	lc.br.handlerMu.Lock()
+	defer lc.br.handlerMu.Unlock()
+	handler := lc.br.local
+	if handler != nil {
+		if handler.playback != nil {
+			return handler.playback.Identifier(), nil
 		}
 	}
 	return "", fmt.Errorf("local playback is not active")
@@ -164,29 +248,36 @@ func (lc *LocalController) PlaybackIdentifier() (string, error) {
 
 // AirPlay returns an *AirPlayController
 func (c *Controller) AirPlay() *AirPlayController {
-	return &AirPlayController{
-		handler: c.br.airplay,
-	}
+	return &AirPlayController{br: c.br}
 }
 
 func (apc *AirPlayController) StopServer() error {
-	if apc.handler != nil {
-		if apc.handler.server != nil {
-			apc.handler.server.Stop()
+	apc.br.handlerMu.Lock()
+	defer apc.br.handlerMu.Unlock()
+	handler := apc.br.airplay
+	if handler != nil {
+		if handler.server != nil {
+			handler.server.Stop()
 			return nil
 		}
 	}
 	return fmt.Errorf("server is not active")
 }
 func (apc *AirPlayController) Clients() []*airplay2.Client {
-	if apc.handler != nil {
-		return apc.handler.clients
+	apc.br.handlerMu.Lock()
+	defer apc.br.handlerMu.Unlock()
+	handler := apc.br.airplay
+	if handler != nil {
+		return append([]*airplay2.Client(nil), handler.clients...)
 	}
 	return nil
 }
 func (apc *AirPlayController) Server() *airplay2.Server {
-	if apc.handler != nil {
-		return apc.handler.server
+	apc.br.handlerMu.Lock()
+	defer apc.br.handlerMu.Unlock()
+	handler := apc.br.airplay
+	if handler != nil {
+		return handler.server
 	}
 	return nil
 }
@@ -196,9 +287,22 @@ func (apc *AirPlayController) Server() *airplay2.Server {
 type YoutubeController struct {
 	handler *YoutubeHandler
 }
+type FileController struct {
+	handler *FileHandler
+}
+
+// RecorderController, LocalController and AirPlayController look up their
+// handler on each call, under the bridge's handlerMu, because outputs can
+// fail and close it at any time.
+type RecorderController struct {
+	br *Bridge
+}
+type MixerController struct {
+	mixer *mixer.Mixer
+}
 type LocalController struct {
-	handler *LocalHandler
+	br *Bridge
 }
 type AirPlayController struct {
-	handler *AirPlayHandler
+	br *Bridge
 }
@@ -0,0 +1,133 @@
+// Package delay holds back the audio of a bridge output, so outputs with
+// different latencies can be played in sync.
+package delay
+
+import (
+	"fmt"
+	"io"
+	"ledfx/audio/pcm"
+	"sync"
+	"time"
+)
+
+// Max is the longest delay of a line
+const Max = 10 * time.Second
+
+// Line is an io.Writer that passes audio on to another writer a fixed time
+// later. It counts time in audio, so it assumes audio is written in real
+// time, and the last Delay of audio stays in the line until more is written.
+type Line struct {
+	out    io.Writer
+	format pcm.Format
+
+	mu    sync.Mutex
+	delay time.Duration
+	// ring holds n bytes from head on, the audio not yet passed on
+	ring []byte
+	head int
+	n    int
+	buf  []byte
+}
+
+// New returns a line without delay that writes audio of format f to out
+func New(out io.Writer, f pcm.Format) *Line {
+	return &Line{out: out, format: f}
+}
+
+// Delay returns the delay of the line
+func (l *Line) Delay() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.delay
+}
+
+// SetDelay changes the delay of the line. A longer delay inserts silence and
+// a shorter one skips audio.
+func (l *Line) SetDelay(d time.Duration) error {
+	if d < 0 || d > Max {
+		return fmt.Errorf("delay %v is out of range 0-%v", d, Max)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	want := l.bytes(d)
+	switch {
+	case want > l.n:
+		l.grow(want)
+		// Silence goes before the audio held back
+		pad := want - l.n
+		l.head = (l.head - pad + len(l.ring)) % len(l.ring)
+		for i := 0; i < pad; i++ {
+			l.ring[(l.head+i)%len(l.ring)] = 0
+		}
+		l.n = want
+	case want < l.n:
+		l.head = (l.head + l.n - want) % max(len(l.ring), 1)
+		l.n = want
+	}
+	l.delay = d
+	return nil
+}
+
+// bytes returns the size of d of audio in whole frames
+func (l *Line) bytes(d time.Duration) int {
+	return int(d.Seconds()*float64(l.format.SampleRate)) * l.format.FrameSize()
+}
+
+// grow makes room for size bytes in the ring, keeping the bytes held
+func (l *Line) grow(size int) {
+	if size <= len(l.ring) {
+		return
+	}
+	ring := make([]byte, size)
+	copied := copy(ring, l.ring[l.head:min(l.head+l.n, len(l.ring))])
+	copy(ring[copied:], l.ring[:l.n-copied])
+	l.ring, l.head = ring, 0
+}
+
+// Write holds p back and writes as much of the audio held as p is long
+func (l *Line) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.n == 0 {
+		return l.out.Write(p)
+	}
+
+	l.grow(l.n + len(p))
+	// Put p behind the audio held, then take as much of the oldest out
+	tail := (l.head + l.n) % len(l.ring)
+	copied := copy(l.ring[tail:], p)
+	copy(l.ring, p[copied:])
+	l.n += len(p)
+	if cap(l.buf) < len(p) {
+		l.buf = make([]byte, len(p))
+	}
+	l.buf = l.buf[:len(p)]
+	l.read(l.buf)
+
+	if _, err := l.out.Write(l.buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// read moves len(dst) bytes from the head of the ring to dst
+func (l *Line) read(dst []byte) {
+	copied := copy(dst, l.ring[l.head:min(l.head+len(dst), len(l.ring))])
+	copy(dst[copied:], l.ring)
+	l.head = (l.head + len(dst)) % len(l.ring)
+	l.n -= len(dst)
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,95 @@
+package delay
+
+import (
+	"bytes"
+	"errors"
+	"ledfx/audio/pcm"
+	"testing"
+	"time"
+)
+
+// mono has a frame of 2 bytes every millisecond
+var mono = pcm.Format{SampleRate: 1000, Channels: 1, Encoding: pcm.Int16}
+
+// ramp returns n bytes counting up from start
+func ramp(start, n int) []byte {
+	p := make([]byte, n)
+	for i := range p {
+		p[i] = byte(start + i)
+	}
+	return p
+}
+
+func TestPassThrough(t *testing.T) {
+	var out bytes.Buffer
+	l := New(&out, pcm.CD)
+	if n, err := l.Write(ramp(1, 8)); n != 8 || err != nil {
+		t.Fatalf("Error writing: %d %v\n", n, err)
+	}
+	if !bytes.Equal(out.Bytes(), ramp(1, 8)) {
+		t.Errorf("Expected the audio unchanged, got %v", out.Bytes())
+	}
+}
+
+func TestDelay(t *testing.T) {
+	var out bytes.Buffer
+	l := New(&out, mono)
+	if err := l.SetDelay(3 * time.Millisecond); err != nil {
+		t.Fatalf("Error setting delay: %v\n", err)
+	}
+	l.Write(ramp(1, 4))
+	l.Write(ramp(5, 4))
+	l.Write(ramp(9, 10))
+	want := append(make([]byte, 6), ramp(1, 12)...)
+	if !bytes.Equal(out.Bytes(), want) {
+		t.Errorf("Expected %v, got %v", want, out.Bytes())
+	}
+}
+
+func TestChangeDelay(t *testing.T) {
+	var out bytes.Buffer
+	l := New(&out, mono)
+	l.SetDelay(2 * time.Millisecond)
+	l.Write(ramp(1, 6))
+
+	// Longer inserts silence before the audio held back
+	l.SetDelay(4 * time.Millisecond)
+	l.Write(ramp(7, 8))
+	want := []byte{0, 0, 0, 0, 1, 2, 0, 0, 0, 0, 3, 4, 5, 6}
+	if !bytes.Equal(out.Bytes(), want) {
+		t.Errorf("Expected %v, got %v", want, out.Bytes())
+	}
+
+	// Shorter skips the oldest audio held back
+	out.Reset()
+	l.SetDelay(1 * time.Millisecond)
+	l.Write(ramp(15, 2))
+	if want := []byte{13, 14}; !bytes.Equal(out.Bytes(), want) {
+		t.Errorf("Expected %v, got %v", want, out.Bytes())
+	}
+
+	// No delay writes what is held back no more
+	out.Reset()
+	l.SetDelay(0)
+	l.Write(ramp(17, 2))
+	if want := []byte{17, 18}; !bytes.Equal(out.Bytes(), want) {
+		t.Errorf("Expected %v, got %v", want, out.Bytes())
+	}
+	if err := l.SetDelay(Max + time.Second); err == nil {
+		t.Errorf("Expected an error for a delay over %v", Max)
+	}
+}
+
+type failWriter struct{}
+
+func (failWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("gone")
+}
+
+func TestWriteError(t *testing.T) {
+	l := New(failWriter{}, mono)
+	l.SetDelay(time.Millisecond)
+	if _, err := l.Write(ramp(1, 2)); err == nil {
+		t.Errorf("Expected the error of the output")
+	}
+}
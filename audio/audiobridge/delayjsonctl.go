@@ -0,0 +1,49 @@
+package audiobridge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	log "ledfx/logger"
+	"time"
+)
+
+// DelayCTLJSON sets the delay of an output, or of every output to play in
+// time with the slowest AirPlay output
+type DelayCTLJSON struct {
+	// Output is the writer name of the output, as listed by the delay info
+	Output string   `json:"output,omitempty"`
+	Delay  *float64 `json:"delay_ms,omitempty"`
+	// Sync sets every delay from the latencies AirPlay outputs reported, it
+	// needs no Output
+	Sync bool `json:"sync,omitempty"`
+}
+
+func (dctl DelayCTLJSON) AsJSON() ([]byte, error) {
+	return json.Marshal(&dctl)
+}
+
+// DelayInfoList is the delay of every output
+type DelayInfoList struct {
+	Outputs []DelayInfo `json:"outputs"`
+}
+
+// DelaySet takes a marshalled DelayCTLJSON
+func (j *JsonCTL) DelaySet(jsonData []byte) (err error) {
+	conf := DelayCTLJSON{}
+	if err := json.Unmarshal(jsonData, &conf); err != nil {
+		return fmt.Errorf("error unmarshalling JSON: %w", err)
+	}
+	if conf.Sync {
+		log.Logger.WithField("category", "Delay JSONCTL").Infof("Syncing output delays...")
+		return j.w.br.SyncDelays()
+	}
+	if conf.Output == "" || conf.Delay == nil {
+		return errors.New("an output and a delay are required")
+	}
+	return j.w.br.SetDelay(conf.Output, time.Duration(*conf.Delay*float64(time.Millisecond)))
+}
+
+func (j *JsonCTL) DelayGetInfo() (resultJson []byte, err error) {
+	return json.Marshal(DelayInfoList{Outputs: j.w.br.Delays()})
+}
@@ -0,0 +1,22 @@
+package audiobridge
+
+import (
+	"ledfx/audio/audiobridge/file"
+)
+
+type FileHandler struct {
+	handler *file.Handler
+}
+
+func (br *Bridge) StartFileInput(verbose bool) error {
+	br.closeInput(inputTypeFile)
+
+	in, err := br.addInput(inputTypeFile)
+	if err != nil {
+		return err
+	}
+	br.file = &FileHandler{
+		handler: file.NewHandler(in, br.format, verbose),
+	}
+	return nil
+}
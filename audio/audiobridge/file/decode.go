@@ -0,0 +1,197 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"ledfx/audio/pcm"
+	"ledfx/config"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+// Decoder opens source, skips offset into it and returns its audio in
+// format f. Closing the reader stops decoding.
+type Decoder func(ctx context.Context, source string, offset time.Duration, f pcm.Format) (io.ReadCloser, error)
+
+// IsStream returns whether source is an HTTP or Icecast stream rather than a
+// local file
+func IsStream(source string) bool {
+	u, err := url.Parse(source)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "icy":
+		return true
+	}
+	return false
+}
+
+// Decode decodes WAV files itself and everything else with ffmpeg. It
+// refuses streams while offline.
+func Decode(ctx context.Context, source string, offset time.Duration, f pcm.Format) (io.ReadCloser, error) {
+	if IsStream(source) && config.Snapshot().Offline {
+		return nil, ErrOffline
+	}
+	if !IsStream(source) && strings.EqualFold(filepath.Ext(source), ".wav") {
+		r, err := decodeWAV(source, offset, f)
+		if !errors.Is(err, pcm.ErrUnsupported) {
+			return r, err
+		}
+	}
+	return decodeFFmpeg(ctx, source, offset, f)
+}
+
+func decodeWAV(path string, offset time.Duration, f pcm.Format) (io.ReadCloser, error) {
+	fi, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	from, size, err := pcm.ReadWAVHeader(fi)
+	if err != nil {
+		fi.Close()
+		return nil, fmt.Errorf("error reading WAV header of '%s': %w", path, err)
+	}
+	conv, err := pcm.NewConverter(from, f)
+	if err != nil {
+		fi.Close()
+		return nil, err
+	}
+
+	skip := int64(offset.Seconds()*float64(from.SampleRate)) * int64(from.FrameSize())
+	if _, err := fi.Seek(skip, io.SeekCurrent); err != nil {
+		fi.Close()
+		return nil, fmt.Errorf("error seeking in '%s': %w", path, err)
+	}
+	var r io.Reader = fi
+	if size >= 0 {
+		r = io.LimitReader(fi, size-skip)
+	}
+	return &convertReader{
+		r:      r,
+		conv:   conv,
+		closer: fi,
+		buf:    make([]byte, 4096*from.FrameSize()),
+	}, nil
+}
+
+// convertReader converts everything read from r
+type convertReader struct {
+	r       io.Reader
+	conv    *pcm.Converter
+	closer  io.Closer
+	buf     []byte
+	pending []byte
+	err     error
+}
+
+func (cr *convertReader) Read(p []byte) (int, error) {
+	for len(cr.pending) == 0 {
+		if cr.err != nil {
+			return 0, cr.err
+		}
+		var n int
+		n, cr.err = cr.r.Read(cr.buf)
+		cr.pending = cr.conv.Convert(cr.buf[:n])
+	}
+	n := copy(p, cr.pending)
+	cr.pending = cr.pending[n:]
+	return n, nil
+}
+
+func (cr *convertReader) Close() error {
+	return cr.closer.Close()
+}
+
+// rawFormats are the ffmpeg formats of raw audio in each encoding
+var rawFormats = map[pcm.Encoding]string{
+	pcm.Int16:   "s16le",
+	pcm.Int24:   "s24le",
+	pcm.Int32:   "s32le",
+	pcm.Float32: "f32le",
+}
+
+func decodeFFmpeg(ctx context.Context, source string, offset time.Duration, f pcm.Format) (io.ReadCloser, error) {
+	in := ffmpeg.KwArgs{}
+	if offset > 0 {
+		in["ss"] = strconv.FormatFloat(offset.Seconds(), 'f', 3, 64)
+	}
+	if IsStream(source) {
+		in["reconnect"] = 1
+		in["reconnect_streamed"] = 1
+	}
+	out := ffmpeg.KwArgs{
+		"format": rawFormats[f.Encoding],
+		"acodec": "pcm_" + rawFormats[f.Encoding],
+		"ar":     f.SampleRate,
+		"ac":     f.Channels,
+	}
+
+	r, w := io.Pipe()
+	stream := ffmpeg.Input(source, in).Audio().Output("pipe:", out)
+	stream.Context = ctx
+	cmd := stream.WithOutput(w).Compile()
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("error starting ffmpeg: %w", err)
+	}
+	go func() {
+		if err := cmd.Wait(); err != nil {
+			w.CloseWithError(fmt.Errorf("error decoding '%s' with ffmpeg: %w", source, err))
+			return
+		}
+		w.Close()
+	}()
+	return r, nil
+}
+
+// Probe returns what can be found out about source without playing it
+func Probe(source string) Track {
+	t := Track{Source: source, Title: source}
+	if IsStream(source) {
+		return t
+	}
+	t.Title = strings.TrimSuffix(filepath.Base(source), filepath.Ext(source))
+
+	if strings.EqualFold(filepath.Ext(source), ".wav") {
+		if fi, err := os.Open(source); err == nil {
+			defer fi.Close()
+			if f, size, err := pcm.ReadWAVHeader(fi); err == nil && size >= 0 {
+				t.Duration = f.Duration(int(size)).Seconds()
+				return t
+			}
+		}
+	}
+
+	out, err := ffmpeg.ProbeWithTimeout(source, 5*time.Second, nil)
+	if err != nil {
+		return t
+	}
+	var probe struct {
+		Format struct {
+			Duration string            `json:"duration"`
+			Tags     map[string]string `json:"tags"`
+		} `json:"format"`
+	}
+	if json.Unmarshal([]byte(out), &probe) != nil {
+		return t
+	}
+	t.Duration, _ = strconv.ParseFloat(probe.Format.Duration, 64)
+	for k, v := range probe.Format.Tags {
+		switch strings.ToLower(k) {
+		case "title":
+			t.Title = v
+		case "artist":
+			t.Artist = v
+		}
+	}
+	return t
+}
@@ -0,0 +1,437 @@
+// Package file plays local audio files and HTTP/Icecast streams into the
+// audio bridge, in real time.
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"ledfx/audio/pcm"
+	log "ledfx/logger"
+	"sync"
+	"time"
+)
+
+// Loop is what happens when a track ends
+type Loop string
+
+const (
+	// LoopOff plays the queue once
+	LoopOff Loop = "off"
+	// LoopTrack repeats the current track
+	LoopTrack Loop = "track"
+	// LoopPlaylist starts the queue over after the last track
+	LoopPlaylist Loop = "playlist"
+)
+
+var (
+	ErrEmptyQueue  = errors.New("the queue is empty")
+	ErrNotPlaying  = errors.New("nothing is playing")
+	ErrNotSeekable = errors.New("streams can not be seeked")
+	ErrOffline     = errors.New("streams can not be played offline")
+)
+
+// Info is the state of a handler
+type Info struct {
+	Playing bool `json:"playing"`
+	Paused  bool `json:"paused"`
+	// TrackIndex is the index of the current track, -1 if there is none
+	TrackIndex int `json:"track_index"`
+	// Position is the position in the current track in seconds
+	Position float64 `json:"position"`
+	Loop     Loop    `json:"loop"`
+	Queue    []Track `json:"queue"`
+}
+
+// session is one run of the playback goroutine
+type session struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+type Handler struct {
+	out     io.Writer
+	format  pcm.Format
+	decode  Decoder
+	verbose bool
+
+	// ctl serializes the controls, which wait for the playback goroutine
+	// while mu is free for it
+	ctl sync.Mutex
+
+	mu       sync.Mutex
+	tracks   []Track
+	index    int
+	loop     Loop
+	playing  bool
+	paused   bool
+	resume   chan struct{}
+	position time.Duration
+	session  *session
+	stopped  bool
+}
+
+// NewHandler returns a handler that writes audio of format to out
+func NewHandler(out io.Writer, format pcm.Format, verbose bool) *Handler {
+	return &Handler{
+		out:     out,
+		format:  format,
+		decode:  Decode,
+		verbose: verbose,
+		index:   -1,
+		loop:    LoopOff,
+	}
+}
+
+// Load replaces the queue by sources, which are files, M3U playlists or
+// stream URLs, and stops playback
+func (h *Handler) Load(sources []string) error {
+	tracks, err := h.resolve(sources)
+	if err != nil {
+		return err
+	}
+	h.ctl.Lock()
+	defer h.ctl.Unlock()
+	h.stopSession()
+	h.mu.Lock()
+	h.tracks = tracks
+	h.index = -1
+	h.reset()
+	h.mu.Unlock()
+	return nil
+}
+
+// Add appends sources to the queue
+func (h *Handler) Add(sources []string) error {
+	tracks, err := h.resolve(sources)
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	h.tracks = append(h.tracks, tracks...)
+	h.mu.Unlock()
+	return nil
+}
+
+func (h *Handler) resolve(sources []string) ([]Track, error) {
+	if len(sources) == 0 {
+		return nil, errors.New("no sources given")
+	}
+	expanded, err := expand(sources)
+	if err != nil {
+		return nil, err
+	}
+	tracks := make([]Track, len(expanded))
+	for i, source := range expanded {
+		tracks[i] = Probe(source)
+	}
+	return tracks, nil
+}
+
+// Play plays the queue from the track with index
+func (h *Handler) Play(index int) error {
+	h.ctl.Lock()
+	defer h.ctl.Unlock()
+	h.mu.Lock()
+	n := len(h.tracks)
+	h.mu.Unlock()
+	if n == 0 {
+		return ErrEmptyQueue
+	}
+	if index < 0 || index >= n {
+		return fmt.Errorf("track index must be between 0 and %d", n-1)
+	}
+	h.start(index, 0, false)
+	return nil
+}
+
+// Next skips to the next track, after the last one it starts over
+func (h *Handler) Next() error {
+	return h.skip(1)
+}
+
+// Previous goes back to the previous track, before the first one it goes to
+// the last
+func (h *Handler) Previous() error {
+	return h.skip(-1)
+}
+
+func (h *Handler) skip(by int) error {
+	h.ctl.Lock()
+	defer h.ctl.Unlock()
+	h.mu.Lock()
+	n, index := len(h.tracks), h.index
+	h.mu.Unlock()
+	if n == 0 {
+		return ErrEmptyQueue
+	}
+	h.start(((index+by)%n+n)%n, 0, false)
+	return nil
+}
+
+// Seek continues the current track at position
+func (h *Handler) Seek(position time.Duration) error {
+	h.ctl.Lock()
+	defer h.ctl.Unlock()
+	h.mu.Lock()
+	index, paused := h.index, h.paused
+	var track Track
+	if index >= 0 {
+		track = h.tracks[index]
+	}
+	h.mu.Unlock()
+
+	switch {
+	case index < 0:
+		return ErrNotPlaying
+	case IsStream(track.Source):
+		return ErrNotSeekable
+	case position < 0 || (track.Duration > 0 && position.Seconds() > track.Duration):
+		return fmt.Errorf("position must be between 0 and %.3f seconds", track.Duration)
+	}
+	h.start(index, position, paused)
+	return nil
+}
+
+// Pause holds playback until Resume
+func (h *Handler) Pause() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.playing {
+		return ErrNotPlaying
+	}
+	if !h.paused {
+		h.paused = true
+		h.resume = make(chan struct{})
+	}
+	return nil
+}
+
+// Resume continues paused playback
+func (h *Handler) Resume() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.playing {
+		return ErrNotPlaying
+	}
+	h.unpause()
+	return nil
+}
+
+// SetLoop sets what happens when a track ends
+func (h *Handler) SetLoop(loop Loop) error {
+	switch loop {
+	case LoopOff, LoopTrack, LoopPlaylist:
+	default:
+		return fmt.Errorf("unknown loop mode '%s'", loop)
+	}
+	h.mu.Lock()
+	h.loop = loop
+	h.mu.Unlock()
+	return nil
+}
+
+// Stop stops playback and keeps the queue
+func (h *Handler) Stop() {
+	h.ctl.Lock()
+	defer h.ctl.Unlock()
+	h.stopSession()
+	h.mu.Lock()
+	h.reset()
+	h.mu.Unlock()
+}
+
+// Info returns the state of the handler
+func (h *Handler) Info() Info {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return Info{
+		Playing:    h.playing,
+		Paused:     h.paused,
+		TrackIndex: h.index,
+		Position:   h.position.Seconds(),
+		Loop:       h.loop,
+		Queue:      append([]Track{}, h.tracks...),
+	}
+}
+
+// Quit stops playback for good
+func (h *Handler) Quit() {
+	h.Stop()
+	h.mu.Lock()
+	h.stopped = true
+	h.mu.Unlock()
+}
+
+func (h *Handler) Stopped() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.stopped
+}
+
+// reset forgets the playback state. h.mu must be held.
+func (h *Handler) reset() {
+	h.unpause()
+	h.playing = false
+	h.position = 0
+}
+
+// unpause wakes a paused playback goroutine. h.mu must be held.
+func (h *Handler) unpause() {
+	if h.paused {
+		h.paused = false
+		close(h.resume)
+	}
+}
+
+// stopSession stops the playback goroutine and waits for it. h.ctl must be
+// held, h.mu must not be.
+func (h *Handler) stopSession() {
+	h.mu.Lock()
+	s := h.session
+	h.session = nil
+	h.mu.Unlock()
+	if s != nil {
+		s.cancel()
+		<-s.done
+	}
+}
+
+// start plays the track with index from offset in a new playback goroutine.
+// h.ctl must be held.
+func (h *Handler) start(index int, offset time.Duration, paused bool) {
+	h.stopSession()
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &session{cancel: cancel, done: make(chan struct{})}
+
+	h.mu.Lock()
+	h.unpause()
+	if paused {
+		h.paused = true
+		h.resume = make(chan struct{})
+	}
+	h.index = index
+	h.position = offset
+	h.playing = true
+	h.session = s
+	h.mu.Unlock()
+
+	go h.run(ctx, s, index, offset)
+}
+
+// run plays tracks from index until the queue ends or ctx is done
+func (h *Handler) run(ctx context.Context, s *session, index int, offset time.Duration) {
+	defer close(s.done)
+	failures := 0
+	for {
+		h.mu.Lock()
+		track := h.tracks[index]
+		h.mu.Unlock()
+
+		if h.verbose {
+			log.Logger.WithField("category", "File Player").Infof("Playing '%s' from %v", track.Source, offset)
+		}
+		err := h.playTrack(ctx, track, offset)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			failures++
+			log.Logger.WithField("category", "File Player").Errorf("Error playing '%s': %v", track.Source, err)
+		} else {
+			failures = 0
+		}
+
+		h.mu.Lock()
+		next, ok := h.following(index, failures)
+		if !ok {
+			if h.session == s {
+				h.session = nil
+				h.reset()
+			}
+			h.mu.Unlock()
+			return
+		}
+		index, offset = next, 0
+		h.index, h.position = index, 0
+		h.mu.Unlock()
+	}
+}
+
+// following returns the track to play after index, considering the loop
+// mode. Playback stops once every track failed in a row. h.mu must be held.
+func (h *Handler) following(index, failures int) (int, bool) {
+	n := len(h.tracks)
+	if failures > 0 && (failures >= n || h.loop == LoopTrack) {
+		return 0, false
+	}
+	switch {
+	case h.loop == LoopTrack:
+		return index, true
+	case index+1 < n:
+		return index + 1, true
+	case h.loop == LoopPlaylist:
+		return 0, true
+	}
+	return 0, false
+}
+
+// playTrack writes track from offset to the output, no faster than it plays
+func (h *Handler) playTrack(ctx context.Context, track Track, offset time.Duration) error {
+	r, err := h.decode(ctx, track.Source, offset, h.format)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	buf := make([]byte, pcm.ChunkFrames*h.format.FrameSize())
+	var played time.Duration
+	pacer := pcm.NewPacer()
+	for {
+		if resumed, err := h.waitPaused(ctx); err != nil {
+			return nil
+		} else if resumed {
+			pacer.Reset()
+		}
+
+		n, err := io.ReadFull(r, buf)
+		n -= n % h.format.FrameSize()
+		if n > 0 {
+			if _, err := h.out.Write(buf[:n]); err != nil {
+				return fmt.Errorf("error writing to output: %w", err)
+			}
+			d := h.format.Duration(n)
+			played += d
+			h.mu.Lock()
+			h.position = offset + played
+			h.mu.Unlock()
+
+			if pacer.Wait(ctx, d) != nil {
+				return nil
+			}
+		}
+		switch {
+		case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+			return nil
+		case err != nil:
+			return err
+		}
+	}
+}
+
+// waitPaused blocks while playback is paused and returns whether it was
+func (h *Handler) waitPaused(ctx context.Context) (bool, error) {
+	h.mu.Lock()
+	paused, resume := h.paused, h.resume
+	h.mu.Unlock()
+	if !paused {
+		return false, nil
+	}
+	select {
+	case <-resume:
+		return true, nil
+	case <-ctx.Done():
+		return true, ctx.Err()
+	}
+}
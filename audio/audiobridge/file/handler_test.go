@@ -0,0 +1,285 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"ledfx/audio/pcm"
+	"ledfx/config"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// recorder collects everything written to it
+type recorder struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.buf.Write(p)
+}
+
+func (r *recorder) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.buf.Len()
+}
+
+// fakeDecoder returns silence of the given length for every source and
+// remembers what was opened
+type fakeDecoder struct {
+	mu      sync.Mutex
+	length  time.Duration
+	opened  []string
+	offsets []time.Duration
+	fail    bool
+}
+
+func (d *fakeDecoder) decode(_ context.Context, source string, offset time.Duration, f pcm.Format) (io.ReadCloser, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.opened = append(d.opened, source)
+	d.offsets = append(d.offsets, offset)
+	if d.fail {
+		return nil, errors.New("broken")
+	}
+	n := int((d.length-offset).Seconds()*float64(f.SampleRate)) * f.FrameSize()
+	return ioutil.NopCloser(bytes.NewReader(make([]byte, n))), nil
+}
+
+func (d *fakeDecoder) opens() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string{}, d.opened...)
+}
+
+// writeWAV writes a file of a sine in format f
+func writeWAV(t *testing.T, path string, f pcm.Format, seconds float64) {
+	t.Helper()
+	n := int(seconds * float64(f.SampleRate))
+	samples := make([]float32, 0, n*f.Channels)
+	for i := 0; i < n; i++ {
+		for ch := 0; ch < f.Channels; ch++ {
+			samples = append(samples, float32(0.5*math.Sin(2*math.Pi*440*float64(i)/float64(f.SampleRate))))
+		}
+	}
+	data := pcm.Encode(nil, samples, f.Encoding)
+	var buf bytes.Buffer
+	if err := pcm.WriteWAVHeader(&buf, f, int64(len(data))); err != nil {
+		t.Fatalf("Error writing WAV header: %v\n", err)
+	}
+	buf.Write(data)
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Error writing WAV file: %v\n", err)
+	}
+}
+
+// waitFor polls cond until it holds or a second passed
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s\n", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPlayWAVPlaylist(t *testing.T) {
+	dir := t.TempDir()
+	writeWAV(t, filepath.Join(dir, "one.wav"), pcm.Format{SampleRate: 22050, Channels: 1, Encoding: pcm.Int16}, 0.1)
+	writeWAV(t, filepath.Join(dir, "two.wav"), pcm.Format{SampleRate: 48000, Channels: 6, Encoding: pcm.Float32}, 0.1)
+	playlist := "#EXTM3U\n#EXTINF:0,One\none.wav\n\n" + filepath.Join(dir, "two.wav") + "\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "list.m3u"), []byte(playlist), 0644); err != nil {
+		t.Fatalf("Error writing playlist: %v\n", err)
+	}
+
+	out := &recorder{}
+	h := NewHandler(out, pcm.CD, false)
+	if err := h.Load([]string{filepath.Join(dir, "list.m3u")}); err != nil {
+		t.Fatalf("Error loading playlist: %v\n", err)
+	}
+	info := h.Info()
+	if len(info.Queue) != 2 || info.Queue[0].Title != "one" || math.Abs(info.Queue[1].Duration-0.1) > 0.001 {
+		t.Fatalf("Unexpected queue: %+v\n", info.Queue)
+	}
+
+	start := time.Now()
+	if err := h.Play(0); err != nil {
+		t.Fatalf("Error playing: %v\n", err)
+	}
+	waitFor(t, "the playlist to end", func() bool { return !h.Info().Playing })
+	if elapsed := time.Since(start); elapsed < 180*time.Millisecond {
+		t.Errorf("Expected playback in real time, 200ms of audio took %v", elapsed)
+	}
+	// Both files are converted to CD audio
+	if want := 2 * 4410 * pcm.CD.FrameSize(); math.Abs(float64(out.Len()-want)) > float64(8*pcm.CD.FrameSize()) {
+		t.Errorf("Expected about %d bytes, got %d", want, out.Len())
+	}
+	if info := h.Info(); info.TrackIndex != -1 && info.Position != 0 {
+		t.Errorf("Expected a reset position after the end, got %+v", info)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	h := NewHandler(ioutil.Discard, pcm.CD, false)
+	for name, sources := range map[string][]string{
+		"missing": {filepath.Join(t.TempDir(), "missing.flac")},
+		"empty":   {""},
+		"none":    nil,
+	} {
+		if err := h.Load(sources); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if err := h.Play(0); !errors.Is(err, ErrEmptyQueue) {
+		t.Errorf("Expected an empty queue, got %v", err)
+	}
+	if err := h.Pause(); !errors.Is(err, ErrNotPlaying) {
+		t.Errorf("Expected nothing to pause, got %v", err)
+	}
+	if err := h.SetLoop("forever"); err == nil {
+		t.Errorf("Expected an error for an unknown loop mode")
+	}
+}
+
+func newFakeHandler(t *testing.T, length time.Duration, sources ...string) (*Handler, *fakeDecoder, *recorder) {
+	t.Helper()
+	d := &fakeDecoder{length: length}
+	out := &recorder{}
+	h := NewHandler(out, pcm.CD, false)
+	h.decode = d.decode
+	if err := h.Load(sources); err != nil {
+		t.Fatalf("Error loading sources: %v\n", err)
+	}
+	t.Cleanup(h.Quit)
+	return h, d, out
+}
+
+func TestLoopAndSkip(t *testing.T) {
+	h, d, _ := newFakeHandler(t, 20*time.Millisecond, "http://a/1", "http://a/2")
+	h.SetLoop(LoopTrack)
+	h.Play(1)
+	waitFor(t, "the track to repeat", func() bool { return len(d.opens()) >= 3 })
+	for _, source := range d.opens() {
+		if source != "http://a/2" {
+			t.Fatalf("Expected the second track to repeat, got %v\n", d.opens())
+		}
+	}
+
+	h.SetLoop(LoopPlaylist)
+	waitFor(t, "the playlist to start over", func() bool {
+		opens := d.opens()
+		return len(opens) > 4 && opens[len(opens)-1] == "http://a/2" && opens[len(opens)-2] == "http://a/1"
+	})
+
+	h.Stop()
+	if info := h.Info(); info.Playing || len(info.Queue) != 2 {
+		t.Errorf("Expected stopped playback with the queue kept, got %+v", info)
+	}
+	h.SetLoop(LoopOff)
+	h.Play(0)
+	if err := h.Previous(); err != nil || h.Info().TrackIndex != 1 {
+		t.Errorf("Expected previous to wrap to the last track, got %v %d", err, h.Info().TrackIndex)
+	}
+	if err := h.Next(); err != nil || h.Info().TrackIndex != 0 {
+		t.Errorf("Expected next to wrap to the first track, got %v %d", err, h.Info().TrackIndex)
+	}
+}
+
+func TestSeekAndPause(t *testing.T) {
+	h, d, out := newFakeHandler(t, time.Second, "http://a/stream")
+	h.Play(0)
+	if err := h.Seek(time.Second / 2); !errors.Is(err, ErrNotSeekable) {
+		t.Errorf("Expected streams not to seek, got %v", err)
+	}
+
+	dir := t.TempDir()
+	writeWAV(t, filepath.Join(dir, "a.wav"), pcm.CD, 1)
+	h.Load([]string{filepath.Join(dir, "a.wav")})
+	h.Play(0)
+	if err := h.Seek(2 * time.Second); err == nil {
+		t.Errorf("Expected an error seeking past the end")
+	}
+	if err := h.Seek(600 * time.Millisecond); err != nil {
+		t.Fatalf("Error seeking: %v\n", err)
+	}
+	waitFor(t, "the decoder to start at 600ms", func() bool {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return d.offsets[len(d.offsets)-1] == 600*time.Millisecond
+	})
+	waitFor(t, "the position to move", func() bool { return h.Info().Position > 0.6 })
+
+	if err := h.Pause(); err != nil {
+		t.Fatalf("Error pausing: %v\n", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	paused := out.Len()
+	time.Sleep(50 * time.Millisecond)
+	if out.Len() != paused {
+		t.Errorf("Audio was written while paused")
+	}
+	// Seeking keeps the pause
+	h.Seek(100 * time.Millisecond)
+	if info := h.Info(); !info.Paused || info.Position != 0.1 {
+		t.Errorf("Expected a paused seek to 100ms, got %+v", info)
+	}
+	h.Resume()
+	waitFor(t, "playback to resume", func() bool { return out.Len() > paused })
+}
+
+func TestAllTracksFail(t *testing.T) {
+	h, d, _ := newFakeHandler(t, time.Second, "http://a/1", "http://a/2")
+	d.fail = true
+	h.SetLoop(LoopPlaylist)
+	h.Play(0)
+	waitFor(t, "playback to give up", func() bool { return !h.Info().Playing })
+	if opens := d.opens(); len(opens) != 2 {
+		t.Errorf("Expected every track to be tried once, got %v", opens)
+	}
+}
+
+func TestIsStream(t *testing.T) {
+	for source, want := range map[string]bool{
+		"http://radio.example/live": true,
+		"HTTPS://radio.example/a":   true,
+		"/music/song.mp3":           false,
+		"C:\\music\\song.mp3":       false,
+		"song.flac":                 false,
+	} {
+		if got := IsStream(source); got != want {
+			t.Errorf("IsStream(%q) = %v, expected %v", source, got, want)
+		}
+	}
+}
+
+func TestDecodeOffline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "go_config.json")
+	if err := os.WriteFile(path, []byte("{}\n"), 0644); err != nil {
+		t.Fatalf("Error writing test config: %v\n", err)
+	}
+	config.GlobalViper = viper.New()
+	config.WriteDelay = 0
+	config.GlobalViper.SetConfigFile(path)
+	if err := config.Replace(config.Config{Offline: true}); err != nil {
+		t.Fatalf("Error setting test config: %v\n", err)
+	}
+	defer config.Replace(config.Config{})
+
+	if _, err := Decode(context.Background(), "http://radio.example/live", 0, pcm.CD); !errors.Is(err, ErrOffline) {
+		t.Errorf("Expected ErrOffline for a stream, got %v", err)
+	}
+}
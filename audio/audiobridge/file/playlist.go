@@ -0,0 +1,97 @@
+package file
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Track is one entry of the queue
+type Track struct {
+	Source string `json:"source"`
+	Title  string `json:"title,omitempty"`
+	Artist string `json:"artist,omitempty"`
+	// Duration is in seconds, 0 for streams and files of unknown length
+	Duration float64 `json:"duration,omitempty"`
+}
+
+// isPlaylist returns whether source is an M3U playlist
+func isPlaylist(source string) bool {
+	switch strings.ToLower(filepath.Ext(source)) {
+	case ".m3u", ".m3u8":
+		return !IsStream(source)
+	}
+	return false
+}
+
+// expand replaces the playlists among sources by their entries and checks
+// that every local file exists
+func expand(sources []string) ([]string, error) {
+	out := make([]string, 0, len(sources))
+	for _, source := range sources {
+		switch {
+		case source == "":
+			return nil, fmt.Errorf("empty source")
+		case IsStream(source):
+			out = append(out, source)
+		case isPlaylist(source):
+			entries, err := readPlaylist(source)
+			if err != nil {
+				return nil, err
+			}
+			if out, err = appendExpanded(out, entries); err != nil {
+				return nil, fmt.Errorf("error in playlist '%s': %w", source, err)
+			}
+		default:
+			if _, err := os.Stat(source); err != nil {
+				return nil, err
+			}
+			out = append(out, source)
+		}
+	}
+	return out, nil
+}
+
+// appendExpanded appends the entries of a playlist, which may not contain
+// playlists themselves
+func appendExpanded(out []string, entries []string) ([]string, error) {
+	for _, entry := range entries {
+		if isPlaylist(entry) {
+			return nil, fmt.Errorf("nested playlist '%s'", entry)
+		}
+	}
+	expanded, err := expand(entries)
+	if err != nil {
+		return nil, err
+	}
+	return append(out, expanded...), nil
+}
+
+// readPlaylist returns the entries of an M3U playlist. Relative paths are
+// relative to the playlist.
+func readPlaylist(path string) ([]string, error) {
+	fi, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fi.Close()
+
+	var entries []string
+	sc := bufio.NewScanner(fi)
+	for sc.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(sc.Text(), "\ufeff"))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !IsStream(line) && !filepath.IsAbs(line) {
+			line = filepath.Join(filepath.Dir(path), line)
+		}
+		entries = append(entries, line)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("error reading playlist '%s': %w", path, err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,106 @@
+package audiobridge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ledfx/audio/audiobridge/file"
+	log "ledfx/logger"
+	"time"
+)
+
+type FileAction string
+
+const (
+	// FileActionLoad replaces the queue with the requested sources
+	FileActionLoad FileAction = "load"
+	// FileActionAdd appends the requested sources to the queue
+	FileActionAdd FileAction = "add"
+	// FileActionPlay loads the requested sources if there are any and plays
+	// the queue from the requested index
+	FileActionPlay FileAction = "play"
+	// FileActionPause pauses playback
+	FileActionPause FileAction = "pause"
+	// FileActionResume resumes/unpauses playback
+	FileActionResume FileAction = "resume"
+	// FileActionStop stops playback and keeps the queue
+	FileActionStop FileAction = "stop"
+	// FileActionNext skips to the next track
+	FileActionNext FileAction = "next"
+	// FileActionPrevious goes back to the previous track
+	FileActionPrevious FileAction = "previous"
+	// FileActionSeek continues the current track at the requested position
+	FileActionSeek FileAction = "seek"
+	// FileActionLoop sets what happens when a track ends
+	FileActionLoop FileAction = "loop"
+)
+
+type FileCTLJSON struct {
+	Action FileAction `json:"action"`
+	// Sources are paths of audio files or M3U playlists, or HTTP/Icecast
+	// stream URLs
+	Sources []string `json:"sources,omitempty"`
+	Index   int      `json:"index,omitempty"`
+	// Position is in seconds
+	Position float64   `json:"position,omitempty"`
+	Loop     file.Loop `json:"loop,omitempty"`
+}
+
+func (fctl FileCTLJSON) AsJSON() ([]byte, error) {
+	return json.Marshal(&fctl)
+}
+
+// FileSet takes a marshalled FileCTLJSON
+func (j *JsonCTL) FileSet(jsonData []byte) (err error) {
+	conf := FileCTLJSON{}
+	if err := json.Unmarshal(jsonData, &conf); err != nil {
+		return fmt.Errorf("error unmarshalling JSON: %w", err)
+	}
+	h, err := j.w.br.Controller().File().Handler()
+	if err != nil {
+		return err
+	}
+
+	switch conf.Action {
+	case FileActionLoad:
+		log.Logger.WithField("category", "File JSONCTL").Infof("Loading %d sources...", len(conf.Sources))
+		return h.Load(conf.Sources)
+	case FileActionAdd:
+		return h.Add(conf.Sources)
+	case FileActionPlay:
+		if len(conf.Sources) > 0 {
+			if err := h.Load(conf.Sources); err != nil {
+				return err
+			}
+		}
+		log.Logger.WithField("category", "File JSONCTL").Infof("Starting file playback...")
+		return h.Play(conf.Index)
+	case FileActionPause:
+		return h.Pause()
+	case FileActionResume:
+		return h.Resume()
+	case FileActionStop:
+		h.Stop()
+		return nil
+	case FileActionNext:
+		return h.Next()
+	case FileActionPrevious:
+		return h.Previous()
+	case FileActionSeek:
+		if conf.Position < 0 {
+			return errors.New("position must not be negative")
+		}
+		return h.Seek(time.Duration(conf.Position * float64(time.Second)))
+	case FileActionLoop:
+		return h.SetLoop(conf.Loop)
+	}
+	return fmt.Errorf("unknown action '%s'", conf.Action)
+}
+
+func (j *JsonCTL) FileGetInfo() (resultJson []byte, err error) {
+	h, err := j.w.br.Controller().File().Handler()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(h.Info())
+}
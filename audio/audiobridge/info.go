@@ -1,6 +1,9 @@
 package audiobridge
 
-import "fmt"
+import (
+	"ledfx/audio/audiobridge/mixer"
+	"ledfx/audio/pcm"
+)
 
 func (br *Bridge) Info() *Info {
 	return br.info
@@ -10,21 +13,43 @@ type Info struct {
 	br *Bridge
 }
 
+// InputType returns the name of the only input, "multiple" while several
+// inputs are mixed or "unspecified" without any
 func (i *Info) InputType() string {
-	switch i.br.inputType {
-	case inputTypeYoutube:
-		return "youtube"
-	case inputTypeLocal:
-		return "local_capture"
-	case inputTypeAirPlayServer:
-		return "airplay_server"
-	case -1:
+	inputs := i.br.mixer.Inputs()
+	switch len(inputs) {
+	case 0:
 		return "unspecified"
+	case 1:
+		return inputs[0].Name
 	default:
-		return fmt.Sprintf("unknown (%d)", i.br.inputType)
+		return "multiple"
 	}
 }
 
+// Inputs returns the state of every input of the mixer
+func (i *Info) Inputs() []mixer.InputInfo {
+	return i.br.mixer.Inputs()
+}
+
+// Level returns the level of the mix passed to every output
+func (i *Info) Level() mixer.Level {
+	return i.br.mixer.Level()
+}
+
+// AllOutputs returns a copy of the list of outputs
 func (i *Info) AllOutputs() []*OutputInfo {
-	return i.br.outputs
+	i.br.outputsMu.Lock()
+	defer i.br.outputsMu.Unlock()
+	outputs := make([]*OutputInfo, len(i.br.outputs))
+	for j, o := range i.br.outputs {
+		cp := *o
+		outputs[j] = &cp
+	}
+	return outputs
+}
+
+// Format returns the format of the audio passed to every output
+func (i *Info) Format() pcm.Format {
+	return i.br.format
 }
@@ -3,6 +3,8 @@ package audiobridge
 import (
 	"encoding/json"
 	"fmt"
+	"ledfx/audio/audiobridge/recorder"
+	"ledfx/audio/audiobridge/synth"
 	"ledfx/config"
 )
 
@@ -52,6 +54,17 @@ func (l LocalOutputJSON) AsJSON() ([]byte, error) {
 	return json.Marshal(&l)
 }
 
+// RecorderOutputJSON configures a recorder output
+type RecorderOutputJSON struct {
+	// Start starts recording right away if set
+	Start   *recorder.Config `json:"start,omitempty"`
+	Verbose bool             `json:"verbose,omitempty"`
+}
+
+func (r RecorderOutputJSON) AsJSON() ([]byte, error) {
+	return json.Marshal(&r)
+}
+
 type YouTubeInputJSON struct {
 	Verbose bool `json:"verbose,omitempty"`
 }
@@ -60,6 +73,25 @@ func (y YouTubeInputJSON) AsJSON() ([]byte, error) {
 	return json.Marshal(&y)
 }
 
+// FileInputJSON configures a file input
+type FileInputJSON struct {
+	Verbose bool `json:"verbose,omitempty"`
+}
+
+func (f FileInputJSON) AsJSON() ([]byte, error) {
+	return json.Marshal(&f)
+}
+
+// SynthInputJSON configures a generated input
+type SynthInputJSON struct {
+	synth.Config
+	Verbose bool `json:"verbose,omitempty"`
+}
+
+func (s SynthInputJSON) AsJSON() ([]byte, error) {
+	return json.Marshal(&s)
+}
+
 // JSONWrapper returns an interpreter for JSON-based configuration
 // parameters.
 func (br *Bridge) JSONWrapper() *BridgeJSONWrapper {
@@ -121,6 +153,18 @@ func (w *BridgeJSONWrapper) AddLocalOutput(jsonData []byte) (err error) {
 	return nil
 }
 
+// AddRecorderOutput takes a marshalled RecorderOutputJSON
+func (w *BridgeJSONWrapper) AddRecorderOutput(jsonData []byte) (err error) {
+	conf := RecorderOutputJSON{}
+	if err := json.Unmarshal(jsonData, &conf); err != nil {
+		return fmt.Errorf("error unmarshalling JSON: %w", err)
+	}
+	if err := w.br.AddRecorderOutput(conf.Start, conf.Verbose); err != nil {
+		return fmt.Errorf("error adding recorder output: %w", err)
+	}
+	return nil
+}
+
 // StartYouTubeInput takes a marshalled YouTubeInputJSON
 func (w *BridgeJSONWrapper) StartYouTubeInput(jsonData []byte) (err error) {
 	conf := YouTubeInputJSON{}
@@ -132,3 +176,27 @@ func (w *BridgeJSONWrapper) StartYouTubeInput(jsonData []byte) (err error) {
 	}
 	return nil
 }
+
+// StartFileInput takes a marshalled FileInputJSON
+func (w *BridgeJSONWrapper) StartFileInput(jsonData []byte) (err error) {
+	conf := FileInputJSON{}
+	if err := json.Unmarshal(jsonData, &conf); err != nil {
+		return fmt.Errorf("error unmarshalling JSON: %w", err)
+	}
+	if err := w.br.StartFileInput(conf.Verbose); err != nil {
+		return fmt.Errorf("error starting file input: %w", err)
+	}
+	return nil
+}
+
+// StartSynthInput takes a marshalled SynthInputJSON
+func (w *BridgeJSONWrapper) StartSynthInput(jsonData []byte) (err error) {
+	conf := SynthInputJSON{}
+	if err := json.Unmarshal(jsonData, &conf); err != nil {
+		return fmt.Errorf("error unmarshalling JSON: %w", err)
+	}
+	if err := w.br.StartSynthInput(conf.Config, conf.Verbose); err != nil {
+		return fmt.Errorf("error starting synth input: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package audiobridge
+
+import (
+	"fmt"
+	"ledfx/audio/audiobridge/delay"
+	log "ledfx/logger"
+	"sort"
+	"time"
+)
+
+// DelayInfo is the delay of an output of the bridge
+type DelayInfo struct {
+	// Output is the writer name of the output, "leds" for the LED analysis
+	Output string `json:"output"`
+	// Delay is how long the output is held back in milliseconds
+	Delay float64 `json:"delay_ms"`
+	// Latency is how long the output said it takes to play audio in
+	// milliseconds, only AirPlay outputs say
+	Latency float64 `json:"latency_ms"`
+}
+
+// SetDelay holds the output called name back by d
+func (br *Bridge) SetDelay(name string, d time.Duration) error {
+	br.stageMu.Lock()
+	defer br.stageMu.Unlock()
+	st, ok := br.stages[name]
+	if !ok {
+		return fmt.Errorf("no output called '%s'", name)
+	}
+	log.Logger.WithField("category", "Audio Bridge").Infof("Delaying %s by %v", name, d)
+	return st.line.SetDelay(d)
+}
+
+// SyncDelays delays every output by the latency of the slowest AirPlay output
+// less its own, so they all play in time with it
+func (br *Bridge) SyncDelays() error {
+	latencies := br.latencies()
+	var slowest time.Duration
+	for _, l := range latencies {
+		if l > slowest {
+			slowest = l
+		}
+	}
+
+	br.stageMu.Lock()
+	defer br.stageMu.Unlock()
+	for name, st := range br.stages {
+		d := slowest - latencies[name]
+		if d > delay.Max {
+			d = delay.Max
+		}
+		if err := st.line.SetDelay(d); err != nil {
+			return fmt.Errorf("error delaying %s: %w", name, err)
+		}
+	}
+	log.Logger.WithField("category", "Audio Bridge").Infof("Synced outputs to a latency of %v", slowest)
+	return nil
+}
+
+// Delays returns the delay of the LED analysis and every output by name
+func (br *Bridge) Delays() []DelayInfo {
+	latencies := br.latencies()
+	names := []string{ledIdentifier}
+	for _, o := range br.Info().AllOutputs() {
+		names = append(names, o.ID)
+	}
+
+	br.stageMu.Lock()
+	defer br.stageMu.Unlock()
+	infos := make([]DelayInfo, 0, len(names))
+	for _, name := range names {
+		if st, ok := br.stages[name]; ok {
+			infos = append(infos, DelayInfo{
+				Output:  name,
+				Delay:   milliseconds(st.line.Delay()),
+				Latency: milliseconds(latencies[name]),
+			})
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Output < infos[j].Output })
+	return infos
+}
+
+// latencies returns the latency the AirPlay outputs reported by writer name
+func (br *Bridge) latencies() map[string]time.Duration {
+	latencies := make(map[string]time.Duration)
+	for _, client := range br.Controller().AirPlay().Clients() {
+		latencies[airPlayOutputID(client)] = client.Latency()
+	}
+	return latencies
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
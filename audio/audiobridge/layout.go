@@ -1,13 +1,21 @@
 package audiobridge
 
 import (
+	"fmt"
 	"ledfx/audio"
+	"ledfx/audio/audiobridge/mixer"
+	"ledfx/audio/pcm"
+	"sync"
 )
 
 // Bridge can wire up an audio source to multiple destinations
 // seamlessly and with minimal delay.
 type Bridge struct {
-	inputType inputType
+	// mixer combines every input and writes to byteWriter
+	mixer *mixer.Mixer
+
+	// format is the format of the audio written to byteWriter
+	format pcm.Format
 
 	bufferCallback func(buf audio.Buffer)
 	byteWriter     *audio.AsyncMultiWriter
@@ -15,6 +23,20 @@ type Bridge struct {
 	airplay *AirPlayHandler
 	local   *LocalHandler
 	youtube *YoutubeHandler
+	file    *FileHandler
+	synth   *SynthHandler
+
+	recorder *RecorderHandler
+
+	// handlerMu guards airplay, local and recorder and what they hold.
+	// Outputs fail on the goroutines writing to them, so the remove handler
+	// takes it as well as the API. It is taken before outputsMu and stageMu.
+	handlerMu sync.Mutex
+
+	// stages hold back and scale every output written to byteWriter by
+	// writer name
+	stageMu sync.Mutex
+	stages  map[string]*stage
 
 	ctl *Controller
 
@@ -24,10 +46,15 @@ type Bridge struct {
 
 	info *Info
 
-	outputs []*OutputInfo
+	// outputsMu guards outputs and subscribers, outputs may fail while
+	// audio is written
+	outputsMu   sync.Mutex
+	outputs     []*OutputInfo
+	subscribers map[chan OutputEvent]struct{}
 }
 
-// inputType indicates the audio source a bridge will use
+// inputType indicates an audio source of a bridge. A bridge mixes one input
+// of every type at most.
 type inputType int8
 
 const (
@@ -42,11 +69,55 @@ const (
 	// A Bridge with an inputType as inputTypeYoutube will stream audio
 	// from provided videos to all outputs.
 	inputTypeYoutube
+
+	// A Bridge with an inputType as inputTypeFile will play local audio
+	// files and HTTP streams to all outputs.
+	inputTypeFile
+
+	// A Bridge with an inputType as inputTypeSynth will play a generated
+	// test signal to all outputs.
+	inputTypeSynth
 )
 
-// CallbackWrapper wraps a buffer Callback into a struct
+// String returns the name of t, which is also the name of its mixer input
+func (t inputType) String() string {
+	switch t {
+	case inputTypeYoutube:
+		return "youtube"
+	case inputTypeLocal:
+		return "local_capture"
+	case inputTypeAirPlayServer:
+		return "airplay_server"
+	case inputTypeFile:
+		return "file"
+	case inputTypeSynth:
+		return "synth"
+	default:
+		return fmt.Sprintf("unknown (%d)", t)
+	}
+}
+
+// settings returns the mixer settings an input of type t starts with. AirPlay
+// ducks the other inputs while it streams.
+func (t inputType) settings() mixer.Settings {
+	s := mixer.Settings{Gain: 1}
+	if t == inputTypeAirPlayServer {
+		s.Priority = 1
+	}
+	return s
+}
+
+// blocking returns whether an input of type t writes faster than real time
+// or paces itself, rather than following the clock of a device or a peer
+func (t inputType) blocking() bool {
+	return t != inputTypeAirPlayServer && t != inputTypeLocal
+}
+
+// CallbackWrapper wraps a buffer Callback into a struct. The buffer is reused,
+// Callback must not keep it after returning.
 type CallbackWrapper struct {
 	Callback func(buf audio.Buffer)
+	buf      audio.Buffer
 }
 
 // BridgeJSONWrapper wraps a bridge with a JSON interpreter
@@ -62,11 +133,17 @@ const (
 	outputTypeLocal     OutputType = "local"
 	outputTypeGeneric   OutputType = "generic"
 	outputTypeBluetooth OutputType = "bluetooth"
+	outputTypeRecorder  OutputType = "recorder"
 )
 
 type OutputInfo struct {
-	Type  OutputType `json:"type"`
-	Value interface{}
+	// ID names the output in every call about it and stays the same when
+	// the output is added again
+	ID     string     `json:"id"`
+	Type   OutputType `json:"type"`
+	Volume float64    `json:"volume"`
+	Muted  bool       `json:"muted"`
+	Value  interface{}
 }
 type AirPlayOutputInfo struct {
 	IP          string `json:"ip"`
@@ -78,14 +155,19 @@ type AirPlayOutputInfo struct {
 	SampleRate  int    `json:"sample_rate"`
 }
 type LocalOutputInfo struct {
-	Device     string `json:"device"`
-	Identifier string `json:"identifier"`
-	SampleRate int    `json:"sample_rate"`
-	Channels   int8   `json:"channels"`
+	Device     string     `json:"device"`
+	Identifier string     `json:"identifier"`
+	SampleRate int        `json:"sample_rate"`
+	Channels   int8       `json:"channels"`
+	Format     pcm.Format `json:"format"`
 }
 type GenericOutputInfo struct {
 	Identifier string `json:"identifier"`
 }
+type RecorderOutputInfo struct {
+	Identifier string     `json:"identifier"`
+	Format     pcm.Format `json:"format"`
+}
 type BluetoothOutputInfo struct {
 	// TODO
 }
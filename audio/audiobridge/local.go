@@ -19,22 +19,22 @@ func newLocalHandler(verbose bool) *LocalHandler {
 }
 
 func (br *Bridge) StartLocalInput(audioDevice config.AudioDevice, verbose bool) (err error) {
-	if br.inputType != -1 {
-		br.closeInput()
-	}
-
-	br.inputType = inputTypeLocal
+	br.closeInput(inputTypeLocal)
 
+	br.handlerMu.Lock()
+	defer br.handlerMu.Unlock()
 	if br.local == nil {
 		br.local = newLocalHandler(verbose)
 	}
 
-	if br.local.capture != nil {
-		br.local.capture.Quit()
+	in, err := br.addInput(inputTypeLocal)
+	if err != nil {
+		return err
 	}
 
 	log.Logger.WithField("category", "Local Capture Init").Infof("Initializing new capture handler...")
-	if br.local.capture, err = capture.NewHandler(audioDevice, br.byteWriter, verbose); err != nil {
+	if br.local.capture, err = capture.NewHandler(audioDevice, br.format, in, verbose); err != nil {
+		_ = br.mixer.Remove(in.Name())
 		return fmt.Errorf("error initializing new capture handler: %w", err)
 	}
 
@@ -42,6 +42,8 @@ func (br *Bridge) StartLocalInput(audioDevice config.AudioDevice, verbose bool)
 }
 
 func (br *Bridge) AddLocalOutput(verbose bool) (err error) {
+	br.handlerMu.Lock()
+	defer br.handlerMu.Unlock()
 	if br.local == nil {
 		br.local = newLocalHandler(verbose)
 	}
@@ -50,15 +52,14 @@ func (br *Bridge) AddLocalOutput(verbose bool) (err error) {
 		if verbose {
 			log.Logger.WithField("category", "Local Playback Init").Warnln("Local playback already exists! Resetting playback handler...")
 		}
-		id := br.local.playback.Identifier()
-		br.local.playback.Quit()
-		if err := br.byteWriter.RemoveWriter(id); err != nil {
-			return fmt.Errorf("error removing writer: %w", err)
+		if err := br.removeOutput(localIdentifier); err != nil {
+			// It failed and is being removed already
+			br.local.playback.Quit()
 		}
 	}
 
 	log.Logger.WithField("category", "Local Playback Init").Infof("Initializing new playback handler...")
-	if br.local.playback, err = playback.NewHandler(verbose); err != nil {
+	if br.local.playback, err = playback.NewHandler(br.format, verbose); err != nil {
 		return fmt.Errorf("error initializing new playback handler: %w", err)
 	}
 
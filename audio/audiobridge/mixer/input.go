@@ -0,0 +1,148 @@
+package mixer
+
+import (
+	"errors"
+	"ledfx/audio/pcm"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned by writes to an input that was removed
+var ErrClosed = errors.New("mixer input is closed")
+
+const (
+	// bufferTime is how much audio an input holds
+	bufferTime = 250 * time.Millisecond
+	// primeChunks is how many chunks an input must hold before it plays,
+	// at the start and after running dry, to ride out jitter
+	primeChunks = 2
+)
+
+// Input is an io.Writer of one source of the mixer, in the format of the
+// mixer
+type Input struct {
+	name     string
+	format   pcm.Format
+	blocking bool
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	fifo    []float32
+	head    int
+	n       int
+	pending []byte
+	samples []float32
+	primed  bool
+	closed  bool
+
+	// state of the mixer, guarded by its mutex
+	settings Settings
+	duck     float32
+	playedAt int64
+	meter    meter
+}
+
+func newInput(name string, f pcm.Format, blocking bool) *Input {
+	in := &Input{
+		name:     name,
+		format:   f,
+		blocking: blocking,
+		fifo:     make([]float32, int(bufferTime.Seconds()*float64(f.SampleRate))*f.Channels),
+		duck:     1,
+		playedAt: -1,
+		meter:    meter{level: Level{RMS: MinLevel, Peak: MinLevel}},
+	}
+	in.cond = sync.NewCond(&in.mu)
+	return in
+}
+
+// Name returns the name of the input
+func (in *Input) Name() string {
+	return in.name
+}
+
+// Write queues p for the mixer. Partial frames are kept for the next write.
+func (in *Input) Write(p []byte) (int, error) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if in.closed {
+		return 0, ErrClosed
+	}
+
+	frameSize := in.format.FrameSize()
+	data := p
+	if len(in.pending) > 0 {
+		data = append(in.pending, p...)
+	}
+	whole := len(data) - len(data)%frameSize
+	in.samples = pcm.Decode(in.samples[:0], data[:whole], in.format.Encoding)
+	in.pending = append(in.pending[:0], data[whole:]...)
+
+	samples := in.samples
+	for len(samples) > 0 {
+		room := len(in.fifo) - in.n
+		if room == 0 {
+			if in.blocking {
+				in.cond.Wait()
+				if in.closed {
+					return 0, ErrClosed
+				}
+				continue
+			}
+			// Drop the oldest audio
+			drop := len(samples)
+			if drop > len(in.fifo) {
+				samples = samples[drop-len(in.fifo):]
+				drop = len(in.fifo)
+			}
+			drop -= drop % in.format.Channels
+			in.head = (in.head + drop) % len(in.fifo)
+			in.n -= drop
+			continue
+		}
+		if room > len(samples) {
+			room = len(samples)
+		}
+		tail := (in.head + in.n) % len(in.fifo)
+		copied := copy(in.fifo[tail:], samples[:room])
+		copy(in.fifo, samples[copied:room])
+		in.n += room
+		samples = samples[room:]
+	}
+	return len(p), nil
+}
+
+// pull fills dst with the oldest audio and returns whether the input is
+// playing. An input that runs dry is filled up with silence.
+func (in *Input) pull(dst []float32) bool {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if !in.primed {
+		if in.n < primeChunks*len(dst) {
+			return false
+		}
+		in.primed = true
+	}
+	n := len(dst)
+	if in.n < n {
+		n = in.n
+		in.primed = false
+	}
+	copied := copy(dst[:n], in.fifo[in.head:])
+	copy(dst[copied:n], in.fifo)
+	for i := n; i < len(dst); i++ {
+		dst[i] = 0
+	}
+	in.head = (in.head + n) % len(in.fifo)
+	in.n -= n
+	in.cond.Broadcast()
+	return true
+}
+
+// close makes writes fail from now on
+func (in *Input) close() {
+	in.mu.Lock()
+	in.closed = true
+	in.cond.Broadcast()
+	in.mu.Unlock()
+}
@@ -0,0 +1,388 @@
+// Package mixer combines several inputs of the audio bridge into one stream,
+// with gain, mute and priority ducking per input, and level meters.
+package mixer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"ledfx/audio/pcm"
+	log "ledfx/logger"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// MinLevel is the level of silence in dBFS
+	MinLevel = -100.0
+
+	// DefaultDuckGain is the gain of ducked inputs, about -14 dB
+	DefaultDuckGain = 0.2
+
+	// activeLevel is the peak above which an input counts as playing
+	activeLevel = -50.0
+	// holdTime is how long an input counts as playing after its last peak
+	// above activeLevel
+	holdTime = 500 * time.Millisecond
+	// attackTime and releaseTime are how long ducking takes to go from no
+	// gain change to silence and back
+	attackTime  = 50 * time.Millisecond
+	releaseTime = 500 * time.Millisecond
+	// meterTime is the window the level meters measure
+	meterTime = 50 * time.Millisecond
+)
+
+// Settings are the controls of an input
+type Settings struct {
+	// Gain is a linear factor, 1 leaves the input as it is
+	Gain float64 `json:"gain"`
+	Mute bool    `json:"mute"`
+	// Priority ducks every input of a lower priority while this input is
+	// playing
+	Priority int `json:"priority"`
+}
+
+func (s Settings) validate() error {
+	if s.Gain < 0 || s.Gain > 16 || math.IsNaN(s.Gain) {
+		return fmt.Errorf("gain %v is out of range 0-16", s.Gain)
+	}
+	return nil
+}
+
+// Level is a meter reading in dBFS, measured before gain
+type Level struct {
+	RMS  float64 `json:"rms"`
+	Peak float64 `json:"peak"`
+}
+
+// InputInfo is the state of an input
+type InputInfo struct {
+	Name     string   `json:"name"`
+	Settings Settings `json:"settings"`
+	Level    Level    `json:"level"`
+	// Active is whether the input is playing, which ducks lower priorities
+	Active bool `json:"active"`
+	// Ducked is whether a higher priority input is lowering this one
+	Ducked bool `json:"ducked"`
+}
+
+// Mixer reads its inputs in real time and writes their sum to an output
+type Mixer struct {
+	out    io.Writer
+	format pcm.Format
+
+	mu       sync.Mutex
+	inputs   []*Input
+	duckGain float64
+	frames   int64
+	meter    meter
+	cancel   context.CancelFunc
+	done     chan struct{}
+
+	// buffers of the mixing goroutine
+	sum []float32
+	in  []float32
+	buf []byte
+}
+
+// New returns a mixer that writes audio of format f to out
+func New(out io.Writer, f pcm.Format) *Mixer {
+	return &Mixer{
+		out:      out,
+		format:   f,
+		duckGain: DefaultDuckGain,
+		sum:      make([]float32, pcm.ChunkFrames*f.Channels),
+		in:       make([]float32, pcm.ChunkFrames*f.Channels),
+	}
+}
+
+// Format returns the format of the inputs and the output
+func (m *Mixer) Format() pcm.Format {
+	return m.format
+}
+
+// Add returns a new input called name. An input with the same name is closed
+// and its settings are kept, otherwise the input starts with s. Writes to
+// a blocking input wait for room, for sources that are faster than real time.
+// Writes to other inputs drop the oldest audio, for sources with their own
+// clock.
+func (m *Mixer) Add(name string, s Settings, blocking bool) (*Input, error) {
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	in := newInput(name, m.format, blocking)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	in.settings = s
+	if i := m.index(name); i >= 0 {
+		old := m.inputs[i]
+		in.settings = old.settings
+		old.close()
+		m.inputs[i] = in
+		return in, nil
+	}
+	m.inputs = append(m.inputs, in)
+	return in, nil
+}
+
+// Remove closes the input called name
+func (m *Mixer) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.index(name)
+	if i < 0 {
+		return fmt.Errorf("no input called '%s'", name)
+	}
+	m.inputs[i].close()
+	m.inputs = append(m.inputs[:i], m.inputs[i+1:]...)
+	return nil
+}
+
+// Set changes the settings of the input called name
+func (m *Mixer) Set(name string, s Settings) error {
+	if err := s.validate(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.index(name)
+	if i < 0 {
+		return fmt.Errorf("no input called '%s'", name)
+	}
+	m.inputs[i].settings = s
+	return nil
+}
+
+// Settings returns the settings of the input called name
+func (m *Mixer) Settings(name string) (Settings, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.index(name)
+	if i < 0 {
+		return Settings{}, fmt.Errorf("no input called '%s'", name)
+	}
+	return m.inputs[i].settings, nil
+}
+
+// SetDuckGain sets the gain of ducked inputs
+func (m *Mixer) SetDuckGain(gain float64) error {
+	if gain < 0 || gain > 1 {
+		return fmt.Errorf("duck gain %v is out of range 0-1", gain)
+	}
+	m.mu.Lock()
+	m.duckGain = gain
+	m.mu.Unlock()
+	return nil
+}
+
+// DuckGain returns the gain of ducked inputs
+func (m *Mixer) DuckGain() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.duckGain
+}
+
+// Inputs returns the state of every input in the order they were added
+func (m *Mixer) Inputs() []InputInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	infos := make([]InputInfo, len(m.inputs))
+	for i, in := range m.inputs {
+		infos[i] = InputInfo{
+			Name:     in.name,
+			Settings: in.settings,
+			Level:    in.meter.level,
+			Active:   m.active(in),
+			Ducked:   in.duck < 1,
+		}
+	}
+	return infos
+}
+
+// Level returns the level of the mix, after gain
+func (m *Mixer) Level() Level {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.meter.level
+}
+
+// index returns the position of the input called name or -1. m.mu must be
+// held.
+func (m *Mixer) index(name string) int {
+	for i, in := range m.inputs {
+		if in.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// active returns whether in played recently. m.mu must be held.
+func (m *Mixer) active(in *Input) bool {
+	return in.playedAt >= 0 && !in.settings.Mute && m.frames-in.playedAt < m.framesOf(holdTime)
+}
+
+func (m *Mixer) framesOf(d time.Duration) int64 {
+	return int64(d.Seconds() * float64(m.format.SampleRate))
+}
+
+// Start mixes in a new goroutine until Stop
+func (m *Mixer) Start() {
+	m.Stop()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	m.mu.Lock()
+	m.cancel, m.done = cancel, done
+	m.mu.Unlock()
+	go func() {
+		defer close(done)
+		m.run(ctx)
+	}()
+}
+
+// Stop stops mixing, the inputs stay
+func (m *Mixer) Stop() {
+	m.mu.Lock()
+	cancel, done := m.cancel, m.done
+	m.cancel, m.done = nil, nil
+	m.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// Close stops mixing and closes every input
+func (m *Mixer) Close() {
+	m.Stop()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, in := range m.inputs {
+		in.close()
+	}
+	m.inputs = nil
+}
+
+// run writes a chunk of the mix every chunk period until ctx is done
+func (m *Mixer) run(ctx context.Context) {
+	chunk := m.format.Duration(pcm.ChunkFrames * m.format.FrameSize())
+	pacer := pcm.NewPacer()
+	for {
+		if m.mix() {
+			m.buf = pcm.Encode(m.buf[:0], m.sum, m.format.Encoding)
+			if _, err := m.out.Write(m.buf); err != nil {
+				log.Logger.WithField("category", "Mixer").Errorf("Error writing to output: %v", err)
+			}
+		}
+		if pacer.Wait(ctx, chunk) != nil {
+			return
+		}
+	}
+}
+
+// mix sums a chunk of every input into m.sum and returns whether any input
+// had audio
+func (m *Mixer) mix() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.sum {
+		m.sum[i] = 0
+	}
+	top := math.MinInt32
+	for _, in := range m.inputs {
+		if m.active(in) && in.settings.Priority > top {
+			top = in.settings.Priority
+		}
+	}
+
+	attack := float32(1 / float64(m.framesOf(attackTime)))
+	release := float32(1 / float64(m.framesOf(releaseTime)))
+	channels := m.format.Channels
+	playing := false
+	for _, in := range m.inputs {
+		if !in.pull(m.in) {
+			continue
+		}
+		playing = true
+		if dBFS(in.meter.add(m.in, m.framesOf(meterTime)*int64(channels))) > activeLevel {
+			in.playedAt = m.frames
+		}
+
+		target := float32(1)
+		if in.settings.Priority < top {
+			target = float32(m.duckGain)
+		}
+		gain := float32(in.settings.Gain)
+		if in.settings.Mute {
+			gain = 0
+		}
+		for f := 0; f < pcm.ChunkFrames; f++ {
+			switch {
+			case in.duck > target:
+				in.duck = float32(math.Max(float64(in.duck-attack), float64(target)))
+			case in.duck < target:
+				in.duck = float32(math.Min(float64(in.duck+release), float64(target)))
+			}
+			g := gain * in.duck
+			for ch := 0; ch < channels; ch++ {
+				m.sum[f*channels+ch] += m.in[f*channels+ch] * g
+			}
+		}
+	}
+	m.frames += pcm.ChunkFrames
+	if !playing {
+		return false
+	}
+	for i, v := range m.sum {
+		if v > 1 {
+			m.sum[i] = 1
+		} else if v < -1 {
+			m.sum[i] = -1
+		}
+	}
+	m.meter.add(m.sum, m.framesOf(meterTime)*int64(channels))
+	return true
+}
+
+// meter measures the level of samples over a window
+type meter struct {
+	squares float64
+	peak    float64
+	n       int64
+	level   Level
+}
+
+// add measures samples, updates level once window samples were measured and
+// returns the peak amplitude of samples
+func (mt *meter) add(samples []float32, window int64) (peak float64) {
+	for _, v := range samples {
+		a := math.Abs(float64(v))
+		mt.squares += a * a
+		if a > peak {
+			peak = a
+		}
+	}
+	if peak > mt.peak {
+		mt.peak = peak
+	}
+	mt.n += int64(len(samples))
+	if mt.n < window {
+		return peak
+	}
+	mt.level = Level{
+		RMS:  dBFS(math.Sqrt(mt.squares / float64(mt.n))),
+		Peak: dBFS(mt.peak),
+	}
+	mt.squares, mt.peak, mt.n = 0, 0, 0
+	return peak
+}
+
+// dBFS returns the amplitude a in decibels relative to full scale
+func dBFS(a float64) float64 {
+	if a <= 0 {
+		return MinLevel
+	}
+	return math.Max(MinLevel, 20*math.Log10(a))
+}
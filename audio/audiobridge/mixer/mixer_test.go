@@ -0,0 +1,255 @@
+package mixer
+
+import (
+	"errors"
+	"io/ioutil"
+	"ledfx/audio/pcm"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+var mono = pcm.Format{SampleRate: 44100, Channels: 1, Encoding: pcm.Float32}
+
+// feed writes frames of the constant v to in
+func feed(t *testing.T, in *Input, v float32, frames int) {
+	t.Helper()
+	samples := make([]float32, frames)
+	for i := range samples {
+		samples[i] = v
+	}
+	if _, err := in.Write(pcm.Encode(nil, samples, pcm.Float32)); err != nil {
+		t.Fatalf("Error writing to %s: %v\n", in.Name(), err)
+	}
+}
+
+func add(t *testing.T, m *Mixer, name string, s Settings) *Input {
+	t.Helper()
+	in, err := m.Add(name, s, false)
+	if err != nil {
+		t.Fatalf("Error adding %s: %v\n", name, err)
+	}
+	return in
+}
+
+func near(a, b float32) bool {
+	return math.Abs(float64(a-b)) < 1e-4
+}
+
+func TestGainMuteAndClipping(t *testing.T) {
+	m := New(ioutil.Discard, mono)
+	if m.mix() {
+		t.Errorf("Expected no audio without inputs")
+	}
+	a := add(t, m, "a", Settings{Gain: 0.5})
+	b := add(t, m, "b", Settings{Gain: 1})
+	feed(t, a, 0.4, 8*pcm.ChunkFrames)
+	feed(t, b, 0.1, 8*pcm.ChunkFrames)
+
+	if !m.mix() || !near(m.sum[0], 0.3) || !near(m.sum[pcm.ChunkFrames-1], 0.3) {
+		t.Fatalf("Expected 0.4 * 0.5 + 0.1, got %v\n", m.sum[0])
+	}
+	m.Set("b", Settings{Gain: 1, Mute: true})
+	if m.mix(); !near(m.sum[0], 0.2) {
+		t.Errorf("Expected the muted input to be silent, got %v", m.sum[0])
+	}
+	m.Set("a", Settings{Gain: 4})
+	if m.mix(); m.sum[0] != 1 {
+		t.Errorf("Expected the sum to clip at 1, got %v", m.sum[0])
+	}
+	if err := m.Set("c", Settings{Gain: 1}); err == nil {
+		t.Errorf("Expected an error for an unknown input")
+	}
+	if err := m.Set("a", Settings{Gain: -1}); err == nil {
+		t.Errorf("Expected an error for a negative gain")
+	}
+}
+
+func TestPrimeAndUnderrun(t *testing.T) {
+	m := New(ioutil.Discard, mono)
+	in := add(t, m, "a", Settings{Gain: 1})
+	feed(t, in, 0.5, pcm.ChunkFrames)
+	if m.mix() {
+		t.Fatalf("Expected the input to wait for %d chunks\n", primeChunks)
+	}
+	feed(t, in, 0.5, pcm.ChunkFrames+10)
+	m.mix()
+	m.mix()
+	// The third chunk runs dry after 10 frames
+	if !m.mix() || !near(m.sum[9], 0.5) || m.sum[10] != 0 {
+		t.Errorf("Expected 10 frames and silence, got %v %v", m.sum[9], m.sum[10])
+	}
+	if m.mix() {
+		t.Errorf("Expected the input to wait again after running dry")
+	}
+}
+
+func TestDucking(t *testing.T) {
+	m := New(ioutil.Discard, mono)
+	background := add(t, m, "capture", Settings{Gain: 1})
+	airplay := add(t, m, "airplay", Settings{Gain: 1, Priority: 1})
+
+	chunks := func(d time.Duration) int {
+		return int(d.Seconds()*44100)/pcm.ChunkFrames + 1
+	}
+	run := func(chunks int, loud bool) {
+		for i := 0; i < chunks; i++ {
+			feed(t, background, 0.5, pcm.ChunkFrames)
+			if loud {
+				feed(t, airplay, 0.01, pcm.ChunkFrames)
+			} else {
+				feed(t, airplay, 0, pcm.ChunkFrames)
+			}
+			m.mix()
+		}
+	}
+	feed(t, background, 0.5, pcm.ChunkFrames)
+	feed(t, airplay, 0, pcm.ChunkFrames)
+	run(1, false)
+	if info := m.Inputs(); info[0].Ducked || info[1].Active {
+		t.Fatalf("Expected no ducking during silence, got %+v\n", info)
+	}
+
+	// The input lags a chunk behind, its first chunk was silence
+	run(chunks(attackTime)+1, true)
+	info := m.Inputs()
+	if !info[0].Ducked || !info[1].Active || info[1].Ducked {
+		t.Fatalf("Expected the capture to be ducked, got %+v\n", info)
+	}
+	if want := float32(0.5*DefaultDuckGain + 0.01); !near(m.sum[pcm.ChunkFrames-1], want) {
+		t.Errorf("Expected %v after the attack, got %v", want, m.sum[pcm.ChunkFrames-1])
+	}
+
+	// The duck holds after the priority input went quiet, then releases
+	run(chunks(holdTime)-2, false)
+	if !m.Inputs()[0].Ducked {
+		t.Errorf("Expected the duck to hold")
+	}
+	run(chunks(releaseTime)+2, false)
+	if m.Inputs()[0].Ducked || !near(m.sum[0], 0.5) {
+		t.Errorf("Expected the duck to release, got %v", m.sum[0])
+	}
+}
+
+func TestLevels(t *testing.T) {
+	m := New(ioutil.Discard, mono)
+	in := add(t, m, "a", Settings{Gain: 0.1})
+	if l := m.Inputs()[0].Level; l.Peak != MinLevel || l.RMS != MinLevel {
+		t.Errorf("Expected silence before any audio, got %+v", l)
+	}
+	for i := 0; i < 10; i++ {
+		feed(t, in, 0.5, pcm.ChunkFrames)
+		m.mix()
+	}
+	// Input levels are measured before gain, the mix after
+	if l := m.Inputs()[0].Level; math.Abs(l.Peak+6.02) > 0.01 || math.Abs(l.RMS+6.02) > 0.01 {
+		t.Errorf("Expected -6 dBFS, got %+v", l)
+	}
+	if l := m.Level(); math.Abs(l.Peak+26.02) > 0.01 {
+		t.Errorf("Expected -26 dBFS, got %+v", l)
+	}
+}
+
+func TestReplaceAndRemove(t *testing.T) {
+	m := New(ioutil.Discard, mono)
+	old := add(t, m, "a", Settings{Gain: 1})
+	m.Set("a", Settings{Gain: 0.3, Priority: 2})
+	in := add(t, m, "a", Settings{Gain: 1})
+	if _, err := old.Write(make([]byte, 4)); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected the replaced input to be closed, got %v", err)
+	}
+	if s, _ := m.Settings("a"); s.Gain != 0.3 || s.Priority != 2 {
+		t.Errorf("Expected the settings to be kept, got %+v", s)
+	}
+	if err := m.Remove("a"); err != nil || len(m.Inputs()) != 0 {
+		t.Fatalf("Error removing: %v\n", err)
+	}
+	if _, err := in.Write(make([]byte, 4)); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected the removed input to be closed, got %v", err)
+	}
+	if err := m.Remove("a"); err == nil {
+		t.Errorf("Expected an error removing twice")
+	}
+}
+
+func TestBlockingWrite(t *testing.T) {
+	m := New(ioutil.Discard, mono)
+	in, _ := m.Add("a", Settings{Gain: 1}, true)
+	full := len(in.fifo)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	var err error
+	go func() {
+		defer wg.Done()
+		_, err = in.Write(pcm.Encode(nil, make([]float32, full+pcm.ChunkFrames), pcm.Float32))
+	}()
+	time.Sleep(20 * time.Millisecond)
+	in.mu.Lock()
+	n := in.n
+	in.mu.Unlock()
+	if n != full {
+		t.Fatalf("Expected a full buffer, got %d of %d\n", n, full)
+	}
+	m.mix()
+	wg.Wait()
+	if err != nil {
+		t.Errorf("Error writing: %v", err)
+	}
+
+	// Dropping the oldest audio instead
+	live := add(t, m, "live", Settings{Gain: 1})
+	feed(t, live, 0.1, full)
+	feed(t, live, 0.2, pcm.ChunkFrames)
+	live.pull(m.in)
+	if !near(m.in[0], 0.1) || !near(live.fifo[(live.head+live.n-1)%full], 0.2) || live.n != full-pcm.ChunkFrames {
+		t.Errorf("Expected the oldest chunk to be dropped")
+	}
+}
+
+func TestPartialFrames(t *testing.T) {
+	stereo := pcm.Format{SampleRate: 44100, Channels: 2, Encoding: pcm.Int16}
+	m := New(ioutil.Discard, stereo)
+	in := add(t, m, "a", Settings{Gain: 1})
+	p := pcm.Encode(nil, make([]float32, 2*primeChunks*pcm.ChunkFrames), pcm.Int16)
+	in.Write(p[:3])
+	in.Write(p[3:])
+	if in.n != len(p)/2 || !m.mix() {
+		t.Errorf("Expected partial frames to be joined, got %d samples", in.n)
+	}
+}
+
+func TestRealTime(t *testing.T) {
+	out := &counter{}
+	m := New(out, pcm.CD)
+	in, _ := m.Add("a", Settings{Gain: 1}, true)
+	m.Start()
+	defer m.Close()
+	start := time.Now()
+	in.Write(make([]byte, pcm.CD.FrameSize()*44100/5))
+	for out.bytes() < 44100/5*pcm.CD.FrameSize()-2*pcm.ChunkFrames*pcm.CD.FrameSize() {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("Expected 200ms of audio to take about 200ms, took %v", elapsed)
+	}
+}
+
+type counter struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (c *counter) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.n += len(p)
+	return len(p), nil
+}
+
+func (c *counter) bytes() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.n
+}
@@ -0,0 +1,92 @@
+package audiobridge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ledfx/audio/audiobridge/mixer"
+	log "ledfx/logger"
+)
+
+// MixerCTLJSON changes an input of the mixer. Fields left out keep their
+// value.
+type MixerCTLJSON struct {
+	// Input is the name of the input, as listed by the mixer info
+	Input    string   `json:"input"`
+	Gain     *float64 `json:"gain,omitempty"`
+	Mute     *bool    `json:"mute,omitempty"`
+	Priority *int     `json:"priority,omitempty"`
+	// DuckGain is the gain of every ducked input, it needs no Input
+	DuckGain *float64 `json:"duck_gain,omitempty"`
+}
+
+func (mctl MixerCTLJSON) AsJSON() ([]byte, error) {
+	return json.Marshal(&mctl)
+}
+
+// MixerRemoveJSON names an input to stop and remove from the mixer
+type MixerRemoveJSON struct {
+	Input string `json:"input"`
+}
+
+func (mr MixerRemoveJSON) AsJSON() ([]byte, error) {
+	return json.Marshal(&mr)
+}
+
+// MixerInfo is the state of the mixer
+type MixerInfo struct {
+	DuckGain float64           `json:"duck_gain"`
+	Level    mixer.Level       `json:"level"`
+	Inputs   []mixer.InputInfo `json:"inputs"`
+}
+
+// MixerSet takes a marshalled MixerCTLJSON
+func (j *JsonCTL) MixerSet(jsonData []byte) (err error) {
+	conf := MixerCTLJSON{}
+	if err := json.Unmarshal(jsonData, &conf); err != nil {
+		return fmt.Errorf("error unmarshalling JSON: %w", err)
+	}
+	m := j.w.br.mixer
+
+	if conf.DuckGain != nil {
+		if err := m.SetDuckGain(*conf.DuckGain); err != nil {
+			return err
+		}
+	}
+	if conf.Input == "" {
+		if conf.DuckGain == nil {
+			return errors.New("an input is required")
+		}
+		return nil
+	}
+
+	s, err := m.Settings(conf.Input)
+	if err != nil {
+		return err
+	}
+	if conf.Gain != nil {
+		s.Gain = *conf.Gain
+	}
+	if conf.Mute != nil {
+		s.Mute = *conf.Mute
+	}
+	if conf.Priority != nil {
+		s.Priority = *conf.Priority
+	}
+	log.Logger.WithField("category", "Mixer JSONCTL").Infof("Setting %s to %+v", conf.Input, s)
+	return m.Set(conf.Input, s)
+}
+
+// MixerRemove takes a marshalled MixerRemoveJSON
+func (j *JsonCTL) MixerRemove(jsonData []byte) (err error) {
+	conf := MixerRemoveJSON{}
+	if err := json.Unmarshal(jsonData, &conf); err != nil {
+		return fmt.Errorf("error unmarshalling JSON: %w", err)
+	}
+	log.Logger.WithField("category", "Mixer JSONCTL").Infof("Removing %s...", conf.Input)
+	return j.w.br.RemoveInput(conf.Input)
+}
+
+func (j *JsonCTL) MixerGetInfo() (resultJson []byte, err error) {
+	return json.Marshal(j.w.br.Controller().Mixer().Info())
+}
@@ -0,0 +1,312 @@
+package audiobridge
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"ledfx/audio"
+	"ledfx/audio/audiobridge/delay"
+	"ledfx/audio/pcm"
+	"ledfx/integrations/airplay2"
+	log "ledfx/logger"
+	"net"
+	"strconv"
+	"sync"
+)
+
+const (
+	// ledIdentifier is the writer name of the LED analysis path
+	ledIdentifier = "leds"
+	// localIdentifier is the ID of the local playback output
+	localIdentifier = "local"
+)
+
+// airPlayOutputID returns the ID of the output to client, which stays the
+// same for the device
+func airPlayOutputID(client *airplay2.Client) string {
+	return "airplay:" + net.JoinHostPort(client.RemoteIP().String(), strconv.Itoa(client.RemotePort()))
+}
+
+type OutputEventType string
+
+const (
+	OutputAdded   OutputEventType = "added"
+	OutputChanged OutputEventType = "changed"
+	OutputRemoved OutputEventType = "removed"
+	// OutputFailed outputs were removed after failing to write
+	OutputFailed OutputEventType = "failed"
+)
+
+// OutputEvent is a change to the outputs of the bridge
+type OutputEvent struct {
+	Type   OutputEventType `json:"type"`
+	Output OutputInfo      `json:"output"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// stage holds back and scales the audio of an output. It outlives the
+// output, so an output added again keeps its settings.
+type stage struct {
+	line *delay.Line
+	gain *gain
+}
+
+// AddOutputWriter adds wr as an output called name behind a delay line and
+// a gain. It replaces an output of the same name.
+func (br *Bridge) AddOutputWriter(wr io.Writer, name string) error {
+	br.stageMu.Lock()
+	defer br.stageMu.Unlock()
+	st, ok := br.stages[name]
+	if !ok {
+		st = &stage{gain: &gain{format: br.format, volume: 1}}
+		st.line = delay.New(st.gain, br.format)
+		br.stages[name] = st
+	}
+	st.gain.setOut(wr)
+	return br.byteWriter.AddWriter(st.line, name)
+}
+
+// removeOutputWriter stops writing to the output called name
+func (br *Bridge) removeOutputWriter(name string) error {
+	if err := br.byteWriter.RemoveWriter(name); err != nil && !errors.Is(err, audio.WriterNotFound) {
+		return err
+	}
+	return nil
+}
+
+// addOutput lists info, replacing an output of the same ID
+func (br *Bridge) addOutput(info *OutputInfo) {
+	br.stageMu.Lock()
+	if st, ok := br.stages[info.ID]; ok {
+		info.Volume, info.Muted = st.gain.settings()
+	}
+	br.stageMu.Unlock()
+
+	br.outputsMu.Lock()
+	defer br.outputsMu.Unlock()
+	for i, o := range br.outputs {
+		if o.ID == info.ID {
+			br.outputs = append(br.outputs[:i], br.outputs[i+1:]...)
+			break
+		}
+	}
+	br.outputs = append(br.outputs, info)
+	br.emit(OutputEvent{Type: OutputAdded, Output: *info})
+}
+
+// takeOutput unlists the output with the given ID and returns it, nil if
+// there is none
+func (br *Bridge) takeOutput(id string) *OutputInfo {
+	br.outputsMu.Lock()
+	defer br.outputsMu.Unlock()
+	for i, o := range br.outputs {
+		if o.ID == id {
+			br.outputs = append(br.outputs[:i], br.outputs[i+1:]...)
+			return o
+		}
+	}
+	return nil
+}
+
+// RemoveOutput stops and removes the output with the given ID
+func (br *Bridge) RemoveOutput(id string) error {
+	br.handlerMu.Lock()
+	defer br.handlerMu.Unlock()
+	return br.removeOutput(id)
+}
+
+// removeOutput is RemoveOutput, br.handlerMu must be held
+func (br *Bridge) removeOutput(id string) error {
+	info := br.takeOutput(id)
+	if info == nil {
+		return fmt.Errorf("no output '%s'", id)
+	}
+	log.Logger.WithField("category", "Audio Bridge").Infof("Removing output %s...", id)
+	err := br.removeOutputWriter(id)
+	br.closeOutput(info)
+
+	br.outputsMu.Lock()
+	br.emit(OutputEvent{Type: OutputRemoved, Output: *info})
+	br.outputsMu.Unlock()
+	return err
+}
+
+// outputFailed unlists the output called name after it failed to write
+func (br *Bridge) outputFailed(name string, err error) {
+	br.handlerMu.Lock()
+	defer br.handlerMu.Unlock()
+	info := br.takeOutput(name)
+	if info == nil {
+		return
+	}
+	log.Logger.WithField("category", "Audio Bridge").Warnf("Output %s failed: %v", name, err)
+	br.closeOutput(info)
+
+	br.outputsMu.Lock()
+	br.emit(OutputEvent{Type: OutputFailed, Output: *info, Error: err.Error()})
+	br.outputsMu.Unlock()
+}
+
+// closeOutput stops the handler behind an output that was unlisted.
+// br.handlerMu must be held.
+func (br *Bridge) closeOutput(info *OutputInfo) {
+	switch info.Type {
+	case outputTypeAirPlay:
+		if br.airplay == nil {
+			return
+		}
+		for i, client := range br.airplay.clients {
+			if airPlayOutputID(client) != info.ID {
+				continue
+			}
+			br.airplay.clients = append(br.airplay.clients[:i], br.airplay.clients[i+1:]...)
+			if br.airplay.server != nil {
+				br.airplay.server.RemoveClient(client)
+			}
+			client.Close()
+			return
+		}
+	case outputTypeLocal:
+		if br.local != nil && br.local.playback != nil {
+			br.local.playback.Quit()
+			br.local.playback = nil
+		}
+	case outputTypeRecorder:
+		if br.recorder != nil {
+			_ = br.recorder.recorder.Stop()
+			br.recorder = nil
+		}
+	}
+}
+
+// SetOutputVolume scales the output with the given ID by volume, from 0 to 1
+func (br *Bridge) SetOutputVolume(id string, volume float64) error {
+	if volume < 0 || volume > 1 {
+		return fmt.Errorf("volume %g is out of range 0-1", volume)
+	}
+	return br.changeOutput(id, func(g *gain) { g.setVolume(volume) })
+}
+
+// SetOutputMute silences the output with the given ID, or brings it back
+func (br *Bridge) SetOutputMute(id string, muted bool) error {
+	return br.changeOutput(id, func(g *gain) { g.setMuted(muted) })
+}
+
+// changeOutput runs fn on the gain of a listed output and tells subscribers
+func (br *Bridge) changeOutput(id string, fn func(g *gain)) error {
+	br.outputsMu.Lock()
+	defer br.outputsMu.Unlock()
+	var info *OutputInfo
+	for _, o := range br.outputs {
+		if o.ID == id {
+			info = o
+		}
+	}
+	if info == nil {
+		return fmt.Errorf("no output '%s'", id)
+	}
+
+	br.stageMu.Lock()
+	st := br.stages[id]
+	br.stageMu.Unlock()
+	fn(st.gain)
+	info.Volume, info.Muted = st.gain.settings()
+	br.emit(OutputEvent{Type: OutputChanged, Output: *info})
+	return nil
+}
+
+// SubscribeOutputs returns a channel that receives every change to the
+// outputs. Events a subscriber has no room for are dropped. cancel stops the
+// subscription and closes the channel.
+func (br *Bridge) SubscribeOutputs() (events <-chan OutputEvent, cancel func()) {
+	ch := make(chan OutputEvent, 16)
+	br.outputsMu.Lock()
+	br.subscribers[ch] = struct{}{}
+	br.outputsMu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			br.outputsMu.Lock()
+			delete(br.subscribers, ch)
+			br.outputsMu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// emit passes e on to every subscriber. br.outputsMu must be held.
+func (br *Bridge) emit(e OutputEvent) {
+	for ch := range br.subscribers {
+		select {
+		case ch <- e:
+		default:
+			log.Logger.WithField("category", "Audio Bridge").Warnf("Dropping %s event of %s, a subscriber is behind", e.Type, e.Output.ID)
+		}
+	}
+}
+
+// gain scales the audio of an output
+type gain struct {
+	format pcm.Format
+
+	mu      sync.Mutex
+	out     io.Writer
+	volume  float64
+	muted   bool
+	samples []float32
+	buf     []byte
+}
+
+func (g *gain) setOut(out io.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.out = out
+}
+
+func (g *gain) setVolume(volume float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.volume = volume
+}
+
+func (g *gain) setMuted(muted bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.muted = muted
+}
+
+func (g *gain) settings() (volume float64, muted bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.volume, g.muted
+}
+
+// Write writes p scaled by the volume, or silence of the same length while
+// muted, so outputs that play in real time keep their pace
+func (g *gain) Write(p []byte) (int, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	switch {
+	case g.muted || g.volume == 0:
+		if cap(g.buf) < len(p) {
+			g.buf = make([]byte, len(p))
+		}
+		g.buf = g.buf[:len(p)]
+		for i := range g.buf {
+			g.buf[i] = 0
+		}
+	case g.volume == 1:
+		return g.out.Write(p)
+	default:
+		g.samples = pcm.Decode(g.samples[:0], p, g.format.Encoding)
+		for i := range g.samples {
+			g.samples[i] *= float32(g.volume)
+		}
+		g.buf = pcm.Encode(g.buf[:0], g.samples, g.format.Encoding)
+	}
+	if _, err := g.out.Write(g.buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
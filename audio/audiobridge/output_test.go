@@ -0,0 +1,149 @@
+package audiobridge
+
+import (
+	"bytes"
+	"errors"
+	"ledfx/audio"
+	"ledfx/audio/pcm"
+	"testing"
+	"time"
+)
+
+// newOutputBridge returns a bridge with outputs but without PortAudio or
+// inputs
+func newOutputBridge() *Bridge {
+	br := &Bridge{
+		byteWriter:  audio.NewAsyncMultiWriter(),
+		format:      pcm.CD,
+		stages:      make(map[string]*stage),
+		subscribers: make(map[chan OutputEvent]struct{}),
+	}
+	br.info = &Info{br: br}
+	br.ctl = br.newController()
+	br.byteWriter.SetRemoveHandler(br.outputFailed)
+	return br
+}
+
+type failWriter struct{}
+
+func (failWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("unplugged")
+}
+
+func nextEvent(t *testing.T, events <-chan OutputEvent) OutputEvent {
+	t.Helper()
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(time.Second):
+		t.Fatalf("Expected an output event\n")
+		return OutputEvent{}
+	}
+}
+
+func TestOutputLifecycle(t *testing.T) {
+	br := newOutputBridge()
+	events, cancel := br.SubscribeOutputs()
+	defer cancel()
+
+	if err := br.AddRecorderOutput(nil, false); err != nil {
+		t.Fatalf("Error adding recorder: %v\n", err)
+	}
+	if e := nextEvent(t, events); e.Type != OutputAdded || e.Output.ID != recorderIdentifier || e.Output.Volume != 1 {
+		t.Errorf("Unexpected event: %+v", e)
+	}
+
+	if err := br.SetOutputMute(recorderIdentifier, true); err != nil {
+		t.Fatalf("Error muting: %v\n", err)
+	}
+	if e := nextEvent(t, events); e.Type != OutputChanged || !e.Output.Muted {
+		t.Errorf("Unexpected event: %+v", e)
+	}
+	if err := br.SetOutputVolume("nope", 0.5); err == nil {
+		t.Errorf("Expected an error for an unknown output")
+	}
+
+	if err := br.RemoveOutput(recorderIdentifier); err != nil {
+		t.Fatalf("Error removing: %v\n", err)
+	}
+	if e := nextEvent(t, events); e.Type != OutputRemoved || e.Output.ID != recorderIdentifier {
+		t.Errorf("Unexpected event: %+v", e)
+	}
+	if br.recorder != nil || len(br.Info().AllOutputs()) != 0 {
+		t.Errorf("Expected the recorder to be gone")
+	}
+
+	// Added again, it stays muted
+	br.AddRecorderOutput(nil, false)
+	if e := nextEvent(t, events); !e.Output.Muted {
+		t.Errorf("Expected the recorder to keep its settings: %+v", e)
+	}
+}
+
+func TestOutputFailed(t *testing.T) {
+	br := newOutputBridge()
+	events, cancel := br.SubscribeOutputs()
+	defer cancel()
+
+	br.AddOutputWriter(failWriter{}, "broken")
+	br.addOutput(&OutputInfo{ID: "broken", Type: outputTypeGeneric})
+	nextEvent(t, events)
+
+	br.byteWriter.Write(make([]byte, 4))
+	if e := nextEvent(t, events); e.Type != OutputFailed || e.Output.ID != "broken" || e.Error != "unplugged" {
+		t.Errorf("Unexpected event: %+v", e)
+	}
+	if len(br.Info().AllOutputs()) != 0 {
+		t.Errorf("Expected the failed output to be unlisted")
+	}
+}
+
+func TestGain(t *testing.T) {
+	var out bytes.Buffer
+	g := &gain{format: pcm.CD, volume: 1, out: &out}
+	// 0.5 and -0.5
+	p := []byte{0x00, 0x40, 0x00, 0xc0}
+
+	g.Write(p)
+	g.setVolume(0.5)
+	g.Write(p)
+	g.setMuted(true)
+	g.Write(p)
+	want := []byte{0x00, 0x40, 0x00, 0xc0, 0x00, 0x20, 0x00, 0xe0, 0, 0, 0, 0}
+	if !bytes.Equal(out.Bytes(), want) {
+		t.Errorf("Expected %v, got %v", want, out.Bytes())
+	}
+}
+
+func TestOutputFailedWhileAdding(t *testing.T) {
+	br := newOutputBridge()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			br.Controller().Recorder().Recorder()
+			br.Delays()
+		}
+	}()
+
+	// Each recorder's writer is swapped for one that fails, so the
+	// multiwriter unlists it on its own goroutine while the next is added
+	for i := 0; i < 100; i++ {
+		if err := br.AddRecorderOutput(nil, false); err != nil {
+			t.Fatalf("Error adding recorder: %v\n", err)
+		}
+		br.AddOutputWriter(failWriter{}, recorderIdentifier)
+		br.byteWriter.Write(make([]byte, 4))
+	}
+	<-done
+
+	// The last write fails too, which unlists and closes the recorder
+	deadline := time.Now().Add(time.Second)
+	for len(br.Info().AllOutputs()) != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := br.Controller().Recorder().Recorder(); err == nil || len(br.Info().AllOutputs()) != 0 {
+		t.Errorf("Expected the failed recorder to be unlisted and closed")
+	}
+}
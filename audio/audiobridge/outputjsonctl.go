@@ -0,0 +1,71 @@
+package audiobridge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	log "ledfx/logger"
+)
+
+// OutputCTLJSON changes an output. Fields left out keep their value.
+type OutputCTLJSON struct {
+	// ID is the ID of the output, as listed by the output info
+	ID     string   `json:"id"`
+	Volume *float64 `json:"volume,omitempty"`
+	Mute   *bool    `json:"mute,omitempty"`
+}
+
+func (octl OutputCTLJSON) AsJSON() ([]byte, error) {
+	return json.Marshal(&octl)
+}
+
+// OutputRemoveJSON names an output to stop and remove
+type OutputRemoveJSON struct {
+	ID string `json:"id"`
+}
+
+func (orm OutputRemoveJSON) AsJSON() ([]byte, error) {
+	return json.Marshal(&orm)
+}
+
+// OutputList is every output of the bridge
+type OutputList struct {
+	Outputs []*OutputInfo `json:"outputs"`
+}
+
+// OutputSet takes a marshalled OutputCTLJSON
+func (j *JsonCTL) OutputSet(jsonData []byte) (err error) {
+	conf := OutputCTLJSON{}
+	if err := json.Unmarshal(jsonData, &conf); err != nil {
+		return fmt.Errorf("error unmarshalling JSON: %w", err)
+	}
+	if conf.ID == "" {
+		return errors.New("an output ID is required")
+	}
+	if conf.Volume != nil {
+		log.Logger.WithField("category", "Output JSONCTL").Infof("Setting volume of %s to %g", conf.ID, *conf.Volume)
+		if err := j.w.br.SetOutputVolume(conf.ID, *conf.Volume); err != nil {
+			return err
+		}
+	}
+	if conf.Mute != nil {
+		log.Logger.WithField("category", "Output JSONCTL").Infof("Setting mute of %s to %t", conf.ID, *conf.Mute)
+		if err := j.w.br.SetOutputMute(conf.ID, *conf.Mute); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OutputRemove takes a marshalled OutputRemoveJSON
+func (j *JsonCTL) OutputRemove(jsonData []byte) (err error) {
+	conf := OutputRemoveJSON{}
+	if err := json.Unmarshal(jsonData, &conf); err != nil {
+		return fmt.Errorf("error unmarshalling JSON: %w", err)
+	}
+	return j.w.br.RemoveOutput(conf.ID)
+}
+
+func (j *JsonCTL) OutputGetInfo() (resultJson []byte, err error) {
+	return json.Marshal(OutputList{Outputs: j.w.br.Info().AllOutputs()})
+}
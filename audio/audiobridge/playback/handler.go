@@ -1,10 +1,15 @@
+//go:build cgo
+// +build cgo
+
 package playback
 
 import (
+	"encoding/binary"
 	"fmt"
 	"github.com/gordonklaus/portaudio"
 	"io"
 	"ledfx/audio"
+	"ledfx/audio/pcm"
 	log "ledfx/logger"
 	"ledfx/util"
 )
@@ -13,9 +18,12 @@ type WindowsHandler struct {
 	identifier string
 	stream     *portaudio.Stream
 	outDev     *portaudio.DeviceInfo
-	buf        audio.Buffer
-	verbose    bool
-	done       bool
+	conv       *pcm.Converter
+	// pending holds converted bytes that do not fill buf yet
+	pending []byte
+	buf     audio.Buffer
+	verbose bool
+	done    bool
 }
 
 func (wh *WindowsHandler) Device() string {
@@ -23,48 +31,64 @@ func (wh *WindowsHandler) Device() string {
 }
 
 func (wh *WindowsHandler) SampleRate() int {
-	return int(wh.outDev.DefaultSampleRate)
+	return wh.conv.To().SampleRate
 }
 
 func (wh *WindowsHandler) NumChannels() int8 {
-	return int8(wh.outDev.MaxOutputChannels)
+	return int8(wh.conv.To().Channels)
+}
+
+// Format returns the format the device plays
+func (wh *WindowsHandler) Format() pcm.Format {
+	return wh.conv.To()
 }
 
 func (wh *WindowsHandler) CurrentBufferSize() int {
 	return len(wh.buf)
 }
 
-func NewHandler(verbose bool) (h *WindowsHandler, err error) {
+// NewHandler plays audio of format on the default output device, in the
+// sample rate of the device and in stereo, or mono for mono devices
+func NewHandler(format pcm.Format, verbose bool) (h *WindowsHandler, err error) {
 	h = &WindowsHandler{
 		identifier: util.RandString(8),
 		verbose:    verbose,
-		buf:        make([]int16, 1408/2),
 	}
 
 	if h.outDev, err = portaudio.DefaultOutputDevice(); err != nil {
 		return nil, fmt.Errorf("error getting default output device: %w", err)
 	}
 
+	to := pcm.Format{
+		SampleRate: int(h.outDev.DefaultSampleRate),
+		Channels:   2,
+		Encoding:   pcm.Int16,
+	}
+	if h.outDev.MaxOutputChannels == 1 {
+		to.Channels = 1
+	}
+	if h.conv, err = pcm.NewConverter(format, to); err != nil {
+		return nil, fmt.Errorf("error converting to device format (%s): %w", to, err)
+	}
+	framesPerBuffer := to.SampleRate / 60
+	h.buf = make(audio.Buffer, framesPerBuffer*to.Channels)
+
 	if verbose {
 		log.Logger.WithField("category", "Local Playback Init").Infof("Default output device: %s", h.outDev.Name)
-		log.Logger.WithField("category", "Local Capture Init").Infof("Opening stream... (%dCH/16-bit @%vhz)", h.outDev.MaxOutputChannels, h.outDev.DefaultSampleRate)
+		log.Logger.WithField("category", "Local Playback Init").Infof("Opening stream... (%s)", to)
 	}
 
-	// Ensure format compatibility with the data sent over Player.Write()
-	h.outDev.DefaultSampleRate = 44100
-	h.outDev.MaxOutputChannels = 2
-
 	if h.stream, err = portaudio.OpenDefaultStream(
 		0,
-		h.outDev.MaxOutputChannels,
-		h.outDev.DefaultSampleRate,
-		int(h.outDev.DefaultSampleRate/60),
+		to.Channels,
+		float64(to.SampleRate),
+		framesPerBuffer,
 		h.buf,
 	); err != nil {
 		return nil, fmt.Errorf("error opening PortAudio stream: %w", err)
 	}
 	if verbose {
-		log.Logger.WithField("category", "Local Capture Init").Infof("Starting stream...")
+		log.Logger.WithField("category", "Local Playback Init").Infof("Starting stream...")
 	}
 	if err = h.stream.Start(); err != nil {
 		return nil, fmt.Errorf("error starting stream: %w", err)
@@ -72,12 +96,21 @@ func NewHandler(verbose bool) (h *WindowsHandler, err error) {
 	return h, nil
 }
 
+// Write converts p to the format of the device and plays every full buffer
 func (wh *WindowsHandler) Write(p []byte) (n int, err error) {
 	if wh.done {
 		return 0, io.EOF
 	}
-	copy(wh.buf, audio.BytesToAudioBuffer(p))
-	_ = wh.stream.Write()
+	wh.pending = append(wh.pending, wh.conv.Convert(p)...)
+	size := len(wh.buf) * 2
+	played := 0
+	for ; len(wh.pending)-played >= size; played += size {
+		for i := range wh.buf {
+			wh.buf[i] = int16(binary.LittleEndian.Uint16(wh.pending[played+2*i:]))
+		}
+		_ = wh.stream.Write()
+	}
+	wh.pending = append(wh.pending[:0], wh.pending[played:]...)
 	return len(p), nil
 }
 
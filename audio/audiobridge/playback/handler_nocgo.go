@@ -0,0 +1,14 @@
+//go:build !cgo
+// +build !cgo
+
+package playback
+
+import (
+	"ledfx/audio"
+	"ledfx/audio/pcm"
+)
+
+// NewHandler fails, builds without cgo have no PortAudio to play through
+func NewHandler(format pcm.Format, verbose bool) (Handler, error) {
+	return nil, audio.ErrNoPortAudio
+}
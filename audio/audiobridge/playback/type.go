@@ -1,5 +1,7 @@
 package playback
 
+import "ledfx/audio/pcm"
+
 type Handler interface {
 	Write(p []byte) (n int, err error)
 
@@ -9,6 +11,7 @@ type Handler interface {
 	SampleRate() int
 	CurrentBufferSize() int
 	NumChannels() int8
+	Format() pcm.Format
 
 	Quit()
 }
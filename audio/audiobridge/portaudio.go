@@ -0,0 +1,14 @@
+//go:build cgo
+// +build cgo
+
+package audiobridge
+
+import "github.com/gordonklaus/portaudio"
+
+func initPortAudio() error {
+	return portaudio.Initialize()
+}
+
+func terminatePortAudio() error {
+	return portaudio.Terminate()
+}
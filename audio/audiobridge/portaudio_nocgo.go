@@ -0,0 +1,14 @@
+//go:build !cgo
+// +build !cgo
+
+package audiobridge
+
+// Builds without cgo have no PortAudio, local devices fail to open instead
+
+func initPortAudio() error {
+	return nil
+}
+
+func terminatePortAudio() error {
+	return nil
+}
@@ -0,0 +1,37 @@
+package audiobridge
+
+import (
+	"fmt"
+	"ledfx/audio/audiobridge/recorder"
+	log "ledfx/logger"
+)
+
+// recorderIdentifier is the writer name of the recorder output
+const recorderIdentifier = "recorder"
+
+type RecorderHandler struct {
+	recorder *recorder.Recorder
+}
+
+// AddRecorderOutput adds an output that writes what the bridge plays to disk
+// while recording. A non-nil conf starts recording right away.
+func (br *Bridge) AddRecorderOutput(conf *recorder.Config, verbose bool) error {
+	br.handlerMu.Lock()
+	defer br.handlerMu.Unlock()
+	if br.recorder == nil {
+		log.Logger.WithField("category", "Recorder Init").Infof("Initializing new recorder...")
+		br.recorder = &RecorderHandler{
+			recorder: recorder.New(br.format, verbose),
+		}
+		if err := br.wireRecorderOutput(br.recorder.recorder); err != nil {
+			br.recorder = nil
+			return fmt.Errorf("error wiring recorder output: %w", err)
+		}
+	}
+	if conf != nil {
+		if err := br.recorder.recorder.Start(*conf); err != nil {
+			return fmt.Errorf("error starting recording: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+// Package recorder is an output of the audio bridge that writes what the
+// bridge plays to WAV or FLAC files, so it can be replayed later.
+package recorder
+
+import (
+	"errors"
+	"fmt"
+	"ledfx/audio/pcm"
+	log "ledfx/logger"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Container is the file format of recordings
+type Container string
+
+const (
+	// WAV files hold the audio exactly as the bridge plays it
+	WAV Container = "wav"
+	// FLAC files are compressed without loss, they need ffmpeg
+	FLAC Container = "flac"
+)
+
+const (
+	// minBytes is the smallest size files can be rotated at
+	minBytes = 64 * 1024
+	// maxWAVBytes is the largest size of a WAV file, the sizes in its header
+	// are 32 bits
+	maxWAVBytes = 0xffffffff
+)
+
+var ErrNotRecording = errors.New("nothing is being recorded")
+
+// Config is where and how to record
+type Config struct {
+	// Dir is the directory of the recordings, it is created if needed
+	Dir       string    `json:"dir"`
+	Container Container `json:"container,omitempty"`
+	// Prefix starts every file name, "ledfx" by default
+	Prefix string `json:"prefix,omitempty"`
+	// MaxBytes starts a new file once the current one is this large, 0 keeps
+	// one file up to the 4 GiB a WAV file can hold. FLAC files may overshoot
+	// it by what ffmpeg buffers.
+	MaxBytes int64 `json:"max_bytes,omitempty"`
+	// MaxDuration starts a new file after this many seconds, 0 keeps one file
+	MaxDuration float64 `json:"max_duration,omitempty"`
+}
+
+// withDefaults fills in the defaults and checks the values
+func (c Config) withDefaults() (Config, error) {
+	if c.Container == "" {
+		c.Container = WAV
+	}
+	if c.Prefix == "" {
+		c.Prefix = "ledfx"
+	}
+	switch {
+	case c.Dir == "":
+		return c, errors.New("a directory is required")
+	case c.Container != WAV && c.Container != FLAC:
+		return c, fmt.Errorf("unknown container '%s'", c.Container)
+	case filepath.Base(c.Prefix) != c.Prefix:
+		return c, fmt.Errorf("prefix '%s' must not contain a path", c.Prefix)
+	case c.MaxBytes < 0 || (c.MaxBytes > 0 && c.MaxBytes < minBytes):
+		return c, fmt.Errorf("max bytes must be 0 or at least %d", minBytes)
+	case c.MaxDuration < 0:
+		return c, errors.New("max duration must not be negative")
+	}
+	return c, nil
+}
+
+// Info is the state of a recorder
+type Info struct {
+	Recording bool   `json:"recording"`
+	Config    Config `json:"config"`
+	// File is the path of the file being written
+	File string `json:"file,omitempty"`
+	// Duration is the length of the file being written in seconds
+	Duration float64 `json:"duration"`
+	// Files are the paths of the files of the last recording, oldest first
+	Files []string `json:"files"`
+	// Error is why the last recording stopped, if it failed
+	Error string `json:"error,omitempty"`
+}
+
+// Recorder is an io.Writer of the bridge output that records while started
+// and discards the audio otherwise
+type Recorder struct {
+	format  pcm.Format
+	verbose bool
+	now     func() time.Time
+
+	mu      sync.Mutex
+	config  Config
+	on      bool
+	seg     segment
+	frames  int64
+	files   []string
+	lastErr error
+}
+
+// New returns a recorder of audio in format f
+func New(f pcm.Format, verbose bool) *Recorder {
+	return &Recorder{format: f, verbose: verbose, now: time.Now}
+}
+
+// Start records to new files as c describes, after stopping a recording in
+// progress
+func (r *Recorder) Start(c Config) error {
+	c, err := c.withDefaults()
+	if err != nil {
+		return err
+	}
+	if c.Container == FLAC {
+		if err := checkFFmpeg(); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return fmt.Errorf("error creating '%s': %w", c.Dir, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stop()
+	r.config = c
+	r.files = nil
+	r.lastErr = nil
+	if err := r.open(); err != nil {
+		return err
+	}
+	r.on = true
+	return nil
+}
+
+// Stop finishes the file being written
+func (r *Recorder) Stop() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.on {
+		return ErrNotRecording
+	}
+	return r.stop()
+}
+
+// Info returns the state of the recorder
+func (r *Recorder) Info() Info {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	info := Info{
+		Recording: r.on,
+		Config:    r.config,
+		Files:     append([]string{}, r.files...),
+	}
+	if r.seg != nil {
+		info.File = r.seg.Path()
+		info.Duration = r.format.Duration(int(r.frames) * r.format.FrameSize()).Seconds()
+	}
+	if r.lastErr != nil {
+		info.Error = r.lastErr.Error()
+	}
+	return info
+}
+
+// Write records p while recording. Errors stop the recording rather than
+// the output.
+func (r *Recorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.on {
+		return len(p), nil
+	}
+	if err := r.write(p); err != nil {
+		log.Logger.WithField("category", "Recorder").Errorf("Error recording, stopping: %v", err)
+		r.stop()
+		r.lastErr = err
+	}
+	return len(p), nil
+}
+
+// write writes p to the current file and rotates files at the limits. r.mu
+// must be held.
+func (r *Recorder) write(p []byte) error {
+	frameSize := r.format.FrameSize()
+	maxFrames := int64(r.config.MaxDuration * float64(r.format.SampleRate))
+	maxBytes := r.config.MaxBytes
+	if maxBytes == 0 && r.config.Container == WAV {
+		maxBytes = maxWAVBytes
+	}
+	for len(p) > 0 {
+		n := len(p)
+		if maxFrames > 0 {
+			if room := int(maxFrames-r.frames) * frameSize; n > room {
+				n = room
+			}
+		}
+		if size, exact := r.seg.Size(); exact && maxBytes > 0 {
+			if room := int(maxBytes - size); n > room {
+				n = room - room%frameSize
+			}
+		}
+		if n > 0 {
+			if _, err := r.seg.Write(p[:n]); err != nil {
+				return fmt.Errorf("error writing '%s': %w", r.seg.Path(), err)
+			}
+			r.frames += int64(n / frameSize)
+			p = p[n:]
+		}
+
+		full := maxFrames > 0 && r.frames >= maxFrames
+		if size, _ := r.seg.Size(); maxBytes > 0 && maxBytes-size < int64(frameSize) {
+			full = true
+		}
+		if full {
+			if err := r.rotate(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// rotate finishes the current file and opens the next. r.mu must be held.
+func (r *Recorder) rotate() error {
+	if err := r.closeSegment(); err != nil {
+		return err
+	}
+	return r.open()
+}
+
+// open starts a new file. r.mu must be held.
+func (r *Recorder) open() (err error) {
+	path := r.nextPath()
+	switch r.config.Container {
+	case FLAC:
+		r.seg, err = newFLACSegment(path, r.format)
+	default:
+		r.seg, err = newWAVSegment(path, r.format)
+	}
+	if err != nil {
+		r.seg = nil
+		return fmt.Errorf("error creating '%s': %w", path, err)
+	}
+	if r.verbose {
+		log.Logger.WithField("category", "Recorder").Infof("Recording to '%s' (%s)", path, r.format)
+	}
+	r.frames = 0
+	return nil
+}
+
+// nextPath returns a file name of the current time that isn't taken
+func (r *Recorder) nextPath() string {
+	base := fmt.Sprintf("%s-%s", r.config.Prefix, r.now().Format("20060102-150405"))
+	path := filepath.Join(r.config.Dir, fmt.Sprintf("%s.%s", base, r.config.Container))
+	for i := 2; ; i++ {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			return path
+		}
+		path = filepath.Join(r.config.Dir, fmt.Sprintf("%s-%d.%s", base, i, r.config.Container))
+	}
+}
+
+// closeSegment finishes the current file. r.mu must be held.
+func (r *Recorder) closeSegment() error {
+	if r.seg == nil {
+		return nil
+	}
+	seg := r.seg
+	r.seg = nil
+	r.files = append(r.files, seg.Path())
+	if err := seg.Close(); err != nil {
+		return fmt.Errorf("error finishing '%s': %w", seg.Path(), err)
+	}
+	return nil
+}
+
+// stop ends the recording. r.mu must be held.
+func (r *Recorder) stop() error {
+	r.on = false
+	return r.closeSegment()
+}
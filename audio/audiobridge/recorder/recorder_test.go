@@ -0,0 +1,149 @@
+package recorder
+
+import (
+	"errors"
+	"io/ioutil"
+	"ledfx/audio/pcm"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newRecorder returns a recorder of CD audio whose clock is stopped
+func newRecorder() *Recorder {
+	r := New(pcm.CD, false)
+	r.now = func() time.Time { return time.Date(2022, 3, 4, 20, 15, 0, 0, time.UTC) }
+	return r
+}
+
+// readWAV returns the format and audio size of the WAV file at path
+func readWAV(t *testing.T, path string) (pcm.Format, int64) {
+	t.Helper()
+	fi, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Error opening %s: %v\n", path, err)
+	}
+	defer fi.Close()
+	f, size, err := pcm.ReadWAVHeader(fi)
+	if err != nil {
+		t.Fatalf("Error reading %s: %v\n", path, err)
+	}
+	if st, _ := fi.Stat(); st.Size() != wavHeaderSize+size {
+		t.Errorf("%s: header says %d bytes of audio, the file has %d", path, size, st.Size()-wavHeaderSize)
+	}
+	return f, size
+}
+
+func TestRecordWAV(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "gig")
+	r := newRecorder()
+	r.Write(make([]byte, 1000))
+	if err := r.Stop(); !errors.Is(err, ErrNotRecording) {
+		t.Errorf("Expected nothing to stop, got %v", err)
+	}
+
+	if err := r.Start(Config{Dir: dir}); err != nil {
+		t.Fatalf("Error starting: %v\n", err)
+	}
+	for i := 0; i < 100; i++ {
+		if n, err := r.Write(make([]byte, 1408)); n != 1408 || err != nil {
+			t.Fatalf("Error writing: %d %v\n", n, err)
+		}
+	}
+	info := r.Info()
+	if !info.Recording || info.File != filepath.Join(dir, "ledfx-20220304-201500.wav") || math.Abs(info.Duration-35200.0/44100) > 1e-6 {
+		t.Errorf("Unexpected info while recording: %+v", info)
+	}
+	if err := r.Stop(); err != nil {
+		t.Fatalf("Error stopping: %v\n", err)
+	}
+	if f, size := readWAV(t, info.File); f != pcm.CD || size != 140800 {
+		t.Errorf("Expected 140800 bytes of CD audio, got %d of %s", size, f)
+	}
+	if info := r.Info(); info.Recording || len(info.Files) != 1 {
+		t.Errorf("Unexpected info after stopping: %+v", info)
+	}
+
+	// The next recording in the same second gets a new name
+	r.Start(Config{Dir: dir})
+	r.Stop()
+	if files := r.Info().Files; files[0] != filepath.Join(dir, "ledfx-20220304-201500-2.wav") {
+		t.Errorf("Expected a numbered file, got %v", files)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	dir := t.TempDir()
+	r := newRecorder()
+	// 1 second is 176400 bytes
+	if err := r.Start(Config{Dir: dir, Prefix: "fx", MaxDuration: 1}); err != nil {
+		t.Fatalf("Error starting: %v\n", err)
+	}
+	r.Write(make([]byte, 176400*2+1000))
+	r.Stop()
+	files := r.Info().Files
+	if len(files) != 3 {
+		t.Fatalf("Expected 3 files, got %v\n", files)
+	}
+	for i, want := range []int64{176400, 176400, 1000} {
+		if _, size := readWAV(t, files[i]); size != want {
+			t.Errorf("File %d: expected %d bytes, got %d", i, want, size)
+		}
+	}
+
+	r.Start(Config{Dir: dir, Prefix: "size", MaxBytes: 100044})
+	for i := 0; i < 200; i++ {
+		r.Write(make([]byte, 1408))
+	}
+	r.Stop()
+	files = r.Info().Files
+	if len(files) != 3 {
+		t.Fatalf("Expected 3 files, got %v\n", files)
+	}
+	for i, want := range []int64{100000, 100000, 200*1408 - 200000} {
+		if _, size := readWAV(t, files[i]); size != want {
+			t.Errorf("File %d: expected %d bytes, got %d", i, want, size)
+		}
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	r := newRecorder()
+	for _, c := range []Config{
+		{},
+		{Dir: t.TempDir(), Container: "mp3"},
+		{Dir: t.TempDir(), Prefix: "../up"},
+		{Dir: t.TempDir(), MaxBytes: 100},
+		{Dir: t.TempDir(), MaxDuration: -1},
+	} {
+		if err := r.Start(c); err == nil {
+			t.Errorf("Expected an error for %+v", c)
+		}
+	}
+	if r.Info().Recording {
+		t.Errorf("Expected no recording after errors")
+	}
+}
+
+func TestRecordFLAC(t *testing.T) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		t.Skip("ffmpeg is not installed")
+	}
+	r := newRecorder()
+	if err := r.Start(Config{Dir: t.TempDir(), Container: FLAC}); err != nil {
+		t.Fatalf("Error starting: %v\n", err)
+	}
+	for i := 0; i < 100; i++ {
+		r.Write(make([]byte, 1408))
+	}
+	if err := r.Stop(); err != nil {
+		t.Fatalf("Error stopping: %v\n", err)
+	}
+	fi, err := ioutil.ReadFile(r.Info().Files[0])
+	if err != nil || len(fi) < 4 || string(fi[:4]) != "fLaC" {
+		t.Errorf("Expected a FLAC file, got %v", err)
+	}
+}
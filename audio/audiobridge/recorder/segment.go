@@ -0,0 +1,155 @@
+package recorder
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"ledfx/audio/pcm"
+	"os"
+	"os/exec"
+
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+// segment is one file of a recording
+type segment interface {
+	io.Writer
+	Path() string
+	// Size returns the size of the file so far and whether it is exact, as
+	// opposed to lagging behind an encoder
+	Size() (int64, bool)
+	Close() error
+}
+
+// wavSegment writes a WAV file and fills in its sizes when it is closed
+type wavSegment struct {
+	path   string
+	format pcm.Format
+	file   *os.File
+	buf    *bufio.Writer
+	size   int64
+}
+
+func newWAVSegment(path string, f pcm.Format) (*wavSegment, error) {
+	fi, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, err
+	}
+	s := &wavSegment{path: path, format: f, file: fi, buf: bufio.NewWriterSize(fi, 64*1024)}
+	if err := pcm.WriteWAVHeader(s.buf, f, -1); err != nil {
+		fi.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *wavSegment) Write(p []byte) (int, error) {
+	n, err := s.buf.Write(p)
+	s.size += int64(n)
+	return n, err
+}
+
+func (s *wavSegment) Path() string {
+	return s.path
+}
+
+func (s *wavSegment) Size() (int64, bool) {
+	return wavHeaderSize + s.size, true
+}
+
+// wavHeaderSize is the size of the header pcm.WriteWAVHeader writes
+const wavHeaderSize = 44
+
+func (s *wavSegment) Close() error {
+	err := s.buf.Flush()
+	if err == nil {
+		_, err = s.file.Seek(0, io.SeekStart)
+	}
+	if err == nil {
+		err = pcm.WriteWAVHeader(s.file, s.format, s.size)
+	}
+	if cerr := s.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// rawFormats are the ffmpeg formats of raw audio in each encoding
+var rawFormats = map[pcm.Encoding]string{
+	pcm.Int16:   "s16le",
+	pcm.Int24:   "s24le",
+	pcm.Int32:   "s32le",
+	pcm.Float32: "f32le",
+}
+
+// checkFFmpeg returns an error if ffmpeg can't be found
+func checkFFmpeg() error {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		return errors.New("recording FLAC needs ffmpeg, which was not found")
+	}
+	return nil
+}
+
+// flacSegment pipes audio through ffmpeg into a FLAC file
+type flacSegment struct {
+	path string
+	w    *io.PipeWriter
+	done chan error
+}
+
+func newFLACSegment(path string, f pcm.Format) (*flacSegment, error) {
+	if _, err := os.Stat(path); err == nil {
+		return nil, os.ErrExist
+	}
+	in := ffmpeg.KwArgs{
+		"format": rawFormats[f.Encoding],
+		"ar":     f.SampleRate,
+		"ac":     f.Channels,
+	}
+	out := ffmpeg.KwArgs{
+		"format": "flac",
+		"acodec": "flac",
+	}
+	// FLAC has no float samples
+	if f.Encoding == pcm.Float32 || f.Encoding == pcm.Int32 {
+		out["sample_fmt"] = "s32"
+	}
+
+	r, w := io.Pipe()
+	cmd := ffmpeg.Input("pipe:", in).Output(path, out).WithInput(r).Compile()
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("error starting ffmpeg: %w", err)
+	}
+	s := &flacSegment{path: path, w: w, done: make(chan error, 1)}
+	go func() {
+		err := cmd.Wait()
+		r.CloseWithError(errors.New("ffmpeg exited"))
+		s.done <- err
+	}()
+	return s, nil
+}
+
+func (s *flacSegment) Write(p []byte) (int, error) {
+	return s.w.Write(p)
+}
+
+func (s *flacSegment) Path() string {
+	return s.path
+}
+
+func (s *flacSegment) Size() (int64, bool) {
+	fi, err := os.Stat(s.path)
+	if err != nil {
+		return 0, false
+	}
+	return fi.Size(), false
+}
+
+func (s *flacSegment) Close() error {
+	s.w.Close()
+	if err := <-s.done; err != nil {
+		return fmt.Errorf("error encoding with ffmpeg: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package audiobridge
+
+import (
+	"encoding/json"
+	"fmt"
+	"ledfx/audio/audiobridge/recorder"
+	log "ledfx/logger"
+)
+
+type RecorderAction string
+
+const (
+	// RecorderActionStart starts recording to new files, after finishing a
+	// recording in progress
+	RecorderActionStart RecorderAction = "start"
+	// RecorderActionStop finishes the file being written
+	RecorderActionStop RecorderAction = "stop"
+)
+
+type RecorderCTLJSON struct {
+	Action RecorderAction `json:"action"`
+	// Config is where and how to record, for RecorderActionStart
+	Config recorder.Config `json:"config,omitempty"`
+}
+
+func (rctl RecorderCTLJSON) AsJSON() ([]byte, error) {
+	return json.Marshal(&rctl)
+}
+
+// RecorderSet takes a marshalled RecorderCTLJSON
+func (j *JsonCTL) RecorderSet(jsonData []byte) (err error) {
+	conf := RecorderCTLJSON{}
+	if err := json.Unmarshal(jsonData, &conf); err != nil {
+		return fmt.Errorf("error unmarshalling JSON: %w", err)
+	}
+	rec, err := j.w.br.Controller().Recorder().Recorder()
+	if err != nil {
+		return err
+	}
+
+	switch conf.Action {
+	case RecorderActionStart:
+		log.Logger.WithField("category", "Recorder JSONCTL").Infof("Recording to '%s'...", conf.Config.Dir)
+		return rec.Start(conf.Config)
+	case RecorderActionStop:
+		log.Logger.WithField("category", "Recorder JSONCTL").Infof("Stopping recording...")
+		return rec.Stop()
+	}
+	return fmt.Errorf("unknown action '%s'", conf.Action)
+}
+
+func (j *JsonCTL) RecorderGetInfo() (resultJson []byte, err error) {
+	rec, err := j.w.br.Controller().Recorder().Recorder()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(rec.Info())
+}
@@ -0,0 +1,29 @@
+package audiobridge
+
+import (
+	"fmt"
+	"ledfx/audio/audiobridge/synth"
+)
+
+type SynthHandler struct {
+	handler *synth.Handler
+}
+
+// StartSynthInput plays a generated signal, for testing effects and running
+// without a sound source
+func (br *Bridge) StartSynthInput(conf synth.Config, verbose bool) error {
+	br.closeInput(inputTypeSynth)
+
+	in, err := br.addInput(inputTypeSynth)
+	if err != nil {
+		return err
+	}
+	br.synth = &SynthHandler{
+		handler: synth.NewHandler(in, verbose),
+	}
+	if err := br.synth.handler.Play(conf, br.format); err != nil {
+		_ = br.mixer.Remove(in.Name())
+		return fmt.Errorf("error generating %s: %w", conf.Kind, err)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package synth
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"ledfx/audio/pcm"
+	log "ledfx/logger"
+	"sync"
+)
+
+// Handler writes a source to the bridge in real time
+type Handler struct {
+	out     io.Writer
+	verbose bool
+
+	mu      sync.Mutex
+	config  Config
+	cancel  context.CancelFunc
+	done    chan struct{}
+	stopped bool
+}
+
+func NewHandler(out io.Writer, verbose bool) *Handler {
+	return &Handler{out: out, verbose: verbose}
+}
+
+// Play replaces the current signal by the one c describes, in format f
+func (h *Handler) Play(c Config, f pcm.Format) error {
+	src, err := New(c, f)
+	if err != nil {
+		return err
+	}
+	h.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h.mu.Lock()
+	h.config = c
+	h.cancel = cancel
+	h.done = make(chan struct{})
+	h.stopped = false
+	done := h.done
+	h.mu.Unlock()
+
+	if h.verbose {
+		log.Logger.WithField("category", "Synth").Infof("Generating %s (%s)", c.Kind, f)
+	}
+	go func() {
+		defer close(done)
+		if err := h.run(ctx, src); err != nil {
+			log.Logger.WithField("category", "Synth").Errorf("Error generating %s: %v", c.Kind, err)
+		}
+	}()
+	return nil
+}
+
+// Config returns the signal being played
+func (h *Handler) Config() Config {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.config
+}
+
+// run writes src no faster than it plays until ctx is done
+func (h *Handler) run(ctx context.Context, src *Source) error {
+	buf := make([]byte, pcm.ChunkFrames*src.Format().FrameSize())
+	chunk := src.Format().Duration(len(buf))
+	pacer := pcm.NewPacer()
+	for {
+		if _, err := io.ReadFull(src, buf); err != nil {
+			return err
+		}
+		if _, err := h.out.Write(buf); err != nil {
+			return fmt.Errorf("error writing to output: %w", err)
+		}
+		if pacer.Wait(ctx, chunk) != nil {
+			return nil
+		}
+	}
+}
+
+// Stop stops the signal
+func (h *Handler) Stop() {
+	h.mu.Lock()
+	cancel, done := h.cancel, h.done
+	h.cancel, h.done = nil, nil
+	h.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+func (h *Handler) Quit() {
+	h.Stop()
+	h.mu.Lock()
+	h.stopped = true
+	h.mu.Unlock()
+}
+
+func (h *Handler) Stopped() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.stopped
+}
@@ -0,0 +1,276 @@
+// Package synth generates test audio for the audio bridge, so effects can be
+// run and tested without a sound source.
+package synth
+
+import (
+	"fmt"
+	"ledfx/audio/pcm"
+	"math"
+	"math/rand"
+	"os"
+)
+
+// Kind is the signal a source generates
+type Kind string
+
+const (
+	// Silence is a null input, for running without a sound source
+	Silence Kind = "silence"
+	// SineSweep glides a sine logarithmically from StartHz to EndHz every
+	// Period seconds
+	SineSweep Kind = "sine_sweep"
+	// PinkNoise is noise with equal power per octave
+	PinkNoise Kind = "pink_noise"
+	// Click is a metronome at BPM, accenting the first of every four beats
+	Click Kind = "click"
+	// WAV replays the file at Path in a loop
+	WAV Kind = "wav"
+)
+
+// Config selects and tunes a signal. Zero values take the defaults.
+type Config struct {
+	Kind Kind `json:"kind"`
+	// Level is the peak amplitude from 0 to 1, 0.5 by default
+	Level float64 `json:"level,omitempty"`
+	// StartHz, EndHz and Period are for SineSweep, 20 Hz to 20 kHz in 10
+	// seconds by default
+	StartHz float64 `json:"start_hz,omitempty"`
+	EndHz   float64 `json:"end_hz,omitempty"`
+	Period  float64 `json:"period,omitempty"`
+	// BPM is for Click, 120 by default
+	BPM float64 `json:"bpm,omitempty"`
+	// Seed is for PinkNoise. The same seed makes the same noise.
+	Seed int64 `json:"seed,omitempty"`
+	// Path is the file for WAV
+	Path string `json:"path,omitempty"`
+}
+
+// withDefaults fills in the defaults and checks the ranges
+func (c Config) withDefaults(f pcm.Format) (Config, error) {
+	def := func(v *float64, d float64) {
+		if *v == 0 {
+			*v = d
+		}
+	}
+	def(&c.Level, 0.5)
+	def(&c.StartHz, 20)
+	def(&c.EndHz, 20000)
+	def(&c.Period, 10)
+	def(&c.BPM, 120)
+	nyquist := float64(f.SampleRate) / 2
+	switch {
+	case c.Level < 0 || c.Level > 1:
+		return c, fmt.Errorf("level %v is out of range 0-1", c.Level)
+	case c.StartHz < 0 || c.StartHz > nyquist || c.EndHz < 0 || c.EndHz > nyquist:
+		return c, fmt.Errorf("sweep frequencies must be between 0 and %v Hz", nyquist)
+	case c.Period < 0:
+		return c, fmt.Errorf("period must be positive")
+	case c.BPM < 1 || c.BPM > 1000:
+		return c, fmt.Errorf("BPM %v is out of range 1-1000", c.BPM)
+	}
+	return c, nil
+}
+
+// generator writes the next frames of a signal, interleaved
+type generator interface {
+	fill(dst []float32)
+}
+
+// Source is an endless io.Reader of a signal in a format. It is not safe for
+// concurrent use.
+type Source struct {
+	format  pcm.Format
+	gen     generator
+	samples []float32
+	pending []byte
+	buf     []byte
+}
+
+// New returns a source of the signal c describes in format f
+func New(c Config, f pcm.Format) (*Source, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	c, err := c.withDefaults(f)
+	if err != nil {
+		return nil, err
+	}
+	s := &Source{format: f}
+	rate := float64(f.SampleRate)
+	switch c.Kind {
+	case Silence:
+		s.gen = mono{f.Channels, func() float32 { return 0 }}
+	case SineSweep:
+		s.gen = mono{f.Channels, sweep(c, rate)}
+	case PinkNoise:
+		s.gen = mono{f.Channels, pink(c)}
+	case Click:
+		s.gen = mono{f.Channels, click(c, rate)}
+	case WAV:
+		if s.gen, err = loadWAV(c.Path, f); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown kind '%s'", c.Kind)
+	}
+	return s, nil
+}
+
+// Format returns the format of the source
+func (s *Source) Format() pcm.Format {
+	return s.format
+}
+
+// Read fills p with whole frames and never ends
+func (s *Source) Read(p []byte) (int, error) {
+	if len(s.pending) == 0 {
+		frames := len(p) / s.format.FrameSize()
+		if frames == 0 {
+			frames = 1
+		}
+		if cap(s.samples) < frames*s.format.Channels {
+			s.samples = make([]float32, frames*s.format.Channels)
+		}
+		s.samples = s.samples[:frames*s.format.Channels]
+		s.gen.fill(s.samples)
+		s.buf = pcm.Encode(s.buf[:0], s.samples, s.format.Encoding)
+		s.pending = s.buf
+	}
+	n := copy(p, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
+
+// mono plays a signal in every channel
+type mono struct {
+	channels int
+	next     func() float32
+}
+
+func (m mono) fill(dst []float32) {
+	for i := 0; i+m.channels <= len(dst); i += m.channels {
+		v := m.next()
+		for ch := 0; ch < m.channels; ch++ {
+			dst[i+ch] = v
+		}
+	}
+}
+
+func sweep(c Config, rate float64) func() float32 {
+	samples := int(c.Period * rate)
+	ratio := 1.0
+	if c.StartHz > 0 {
+		ratio = c.EndHz / c.StartHz
+	}
+	var i int
+	var phase float64
+	return func() float32 {
+		freq := c.StartHz * math.Pow(ratio, float64(i)/float64(samples))
+		if c.StartHz == 0 {
+			freq = c.EndHz * float64(i) / float64(samples)
+		}
+		v := float32(c.Level * math.Sin(phase))
+		phase = math.Mod(phase+2*math.Pi*freq/rate, 2*math.Pi)
+		if i++; i >= samples {
+			i = 0
+		}
+		return v
+	}
+}
+
+// pink filters white noise with Paul Kellet's economy filter
+func pink(c Config) func() float32 {
+	rnd := rand.New(rand.NewSource(c.Seed))
+	var b0, b1, b2 float64
+	return func() float32 {
+		white := rnd.Float64()*2 - 1
+		b0 = 0.99765*b0 + white*0.0990460
+		b1 = 0.96300*b1 + white*0.2965164
+		b2 = 0.57000*b2 + white*1.0526913
+		v := (b0 + b1 + b2 + white*0.1848) / 5
+		return float32(math.Max(-1, math.Min(1, v)) * c.Level)
+	}
+}
+
+// clickLength is how long a click rings, and clickDecay how fast it fades.
+// Onset detectors report a peak a few hops late and only while the audio is
+// not silent yet, so a click must still be heard 50ms after it started.
+const (
+	clickLength = 0.1
+	clickDecay  = 0.02
+)
+
+func click(c Config, rate float64) func() float32 {
+	beat := 60 / c.BPM * rate
+	var i float64
+	var n int
+	return func() float32 {
+		t := i / rate
+		var v float64
+		if t < clickLength {
+			freq := 1000.0
+			if n%4 == 0 {
+				freq = 1500
+			}
+			v = c.Level * math.Sin(2*math.Pi*freq*t) * math.Exp(-t/clickDecay)
+		}
+		if i++; i >= beat {
+			i -= beat
+			n++
+		}
+		return float32(v)
+	}
+}
+
+// loop replays samples
+type loop struct {
+	samples []float32
+	pos     int
+}
+
+func (l *loop) fill(dst []float32) {
+	for i := range dst {
+		dst[i] = l.samples[l.pos]
+		if l.pos++; l.pos >= len(l.samples) {
+			l.pos = 0
+		}
+	}
+}
+
+// loadWAV reads the file at path into memory, converted to f
+func loadWAV(path string, f pcm.Format) (*loop, error) {
+	if path == "" {
+		return nil, fmt.Errorf("WAV replay needs a path")
+	}
+	fi, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fi.Close()
+	from, size, err := pcm.ReadWAVHeader(fi)
+	if err != nil {
+		return nil, fmt.Errorf("error reading '%s': %w", path, err)
+	}
+	conv, err := pcm.NewConverter(from, pcm.Format{SampleRate: f.SampleRate, Channels: f.Channels, Encoding: pcm.Float32})
+	if err != nil {
+		return nil, err
+	}
+
+	var samples []float32
+	buf := make([]byte, 4096*from.FrameSize())
+	for size != 0 {
+		n, err := fi.Read(buf)
+		if size > 0 && int64(n) > size {
+			n = int(size)
+		}
+		size -= int64(n)
+		samples = pcm.Decode(samples, conv.Convert(buf[:n]), pcm.Float32)
+		if err != nil {
+			break
+		}
+	}
+	if len(samples) < f.Channels {
+		return nil, fmt.Errorf("'%s' has no audio", path)
+	}
+	return &loop{samples: samples[:len(samples)-len(samples)%f.Channels]}, nil
+}
@@ -0,0 +1,207 @@
+package synth
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"ledfx/audio/pcm"
+	"math"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+var mono44 = pcm.Format{SampleRate: 44100, Channels: 1, Encoding: pcm.Float32}
+
+// render returns seconds of the signal c describes as mono floats
+func render(t *testing.T, c Config, seconds float64) []float32 {
+	t.Helper()
+	src, err := New(c, mono44)
+	if err != nil {
+		t.Fatalf("Error creating %s source: %v\n", c.Kind, err)
+	}
+	buf := make([]byte, int(seconds*44100)*4)
+	if _, err := io.ReadFull(src, buf); err != nil {
+		t.Fatalf("Error reading %s: %v\n", c.Kind, err)
+	}
+	return pcm.Decode(nil, buf, pcm.Float32)
+}
+
+// crossings counts the rising zero crossings of samples
+func crossings(samples []float32) int {
+	n := 0
+	for i := 1; i < len(samples); i++ {
+		if samples[i-1] < 0 && samples[i] >= 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func TestSineSweep(t *testing.T) {
+	samples := render(t, Config{Kind: SineSweep, StartHz: 100, EndHz: 1600, Period: 4, Level: 0.8}, 4)
+	// The frequency doubles every second, so second k averages
+	// 100 * 2^k / ln 2 Hz
+	for second := 0; second < 4; second++ {
+		want := 100 * math.Pow(2, float64(second)) / math.Ln2
+		got := float64(crossings(samples[second*44100 : (second+1)*44100]))
+		if math.Abs(got-want)/want > 0.02 {
+			t.Errorf("Second %d: expected about %v Hz, got %v", second, want, got)
+		}
+	}
+	var peak float32
+	for _, v := range samples {
+		if v > peak {
+			peak = v
+		}
+	}
+	if math.Abs(float64(peak)-0.8) > 0.001 {
+		t.Errorf("Expected a peak of 0.8, got %v", peak)
+	}
+}
+
+func TestClick(t *testing.T) {
+	samples := render(t, Config{Kind: Click, BPM: 240}, 2)
+	// A beat every 250ms, silent in between
+	var onsets []int
+	for i := 1; i < len(samples); i++ {
+		if samples[i-1] == 0 && samples[i] != 0 && (len(onsets) == 0 || i-onsets[len(onsets)-1] > 2000) {
+			onsets = append(onsets, i)
+		}
+	}
+	if len(onsets) != 8 {
+		t.Fatalf("Expected 8 clicks in 2 seconds at 240 BPM, got %d\n", len(onsets))
+	}
+	for i := 1; i < len(onsets); i++ {
+		if d := onsets[i] - onsets[i-1]; math.Abs(float64(d)-11025) > 1 {
+			t.Errorf("Expected 11025 samples between clicks, got %d", d)
+		}
+	}
+	if v := samples[onsets[1]+int(clickLength*44100)+10]; v != 0 {
+		t.Errorf("Expected silence after a click, got %v", v)
+	}
+}
+
+// bandPower returns the power of samples between lo and hi Hz, by a DFT
+// of a few bins
+func bandPower(samples []float32, lo, hi float64) float64 {
+	var sum float64
+	n := float64(len(samples))
+	bins := 0
+	for f := lo; f < hi; f += (hi - lo) / 16 {
+		var re, im float64
+		for i, v := range samples {
+			a := 2 * math.Pi * f * float64(i) / 44100
+			re += float64(v) * math.Cos(a)
+			im -= float64(v) * math.Sin(a)
+		}
+		sum += (re*re + im*im) / n
+		bins++
+	}
+	return sum / float64(bins)
+}
+
+func TestPinkNoise(t *testing.T) {
+	a := render(t, Config{Kind: PinkNoise, Seed: 7}, 0.5)
+	b := render(t, Config{Kind: PinkNoise, Seed: 7}, 0.5)
+	c := render(t, Config{Kind: PinkNoise, Seed: 8}, 0.5)
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("The same seed made different noise\n")
+		}
+	}
+	if crossings(a) == 0 || a[100] == c[100] && a[200] == c[200] {
+		t.Errorf("Different seeds made the same noise")
+	}
+	// Pink noise loses 3 dB per octave, 12 dB over 4 octaves
+	low, high := bandPower(a, 200, 400), bandPower(a, 3200, 6400)
+	if db := 10 * math.Log10(low/high); db < 8 || db > 16 {
+		t.Errorf("Expected about 12 dB between 300 Hz and 4.8 kHz, got %.1f dB", db)
+	}
+}
+
+func TestWAVReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ramp.wav")
+	f := pcm.Format{SampleRate: 44100, Channels: 2, Encoding: pcm.Int16}
+	var buf bytes.Buffer
+	pcm.WriteWAVHeader(&buf, f, 40)
+	for i := 0; i < 10; i++ {
+		buf.Write(pcm.Encode(nil, []float32{float32(i) / 16, -float32(i) / 16}, pcm.Int16))
+	}
+	ioutil.WriteFile(path, buf.Bytes(), 0644)
+
+	samples := render(t, Config{Kind: WAV, Path: path}, 25.0/44100)
+	for i, v := range samples {
+		// The stereo file is mixed to mono and loops after 10 frames
+		if v != 0 {
+			t.Fatalf("Expected the channels to cancel out, got %v at %d\n", v, i)
+		}
+	}
+	src, _ := New(Config{Kind: WAV, Path: path}, f)
+	out := make([]byte, 25*4)
+	io.ReadFull(src, out)
+	got := pcm.Decode(nil, out, pcm.Int16)
+	if got[2*12] != 2.0/16 || got[2*12+1] != -2.0/16 {
+		t.Errorf("Expected the replay to loop, got %v", got[2*12:2*12+2])
+	}
+
+	if _, err := New(Config{Kind: WAV, Path: filepath.Join(t.TempDir(), "none.wav")}, f); err == nil {
+		t.Errorf("Expected an error for a missing file")
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	for _, c := range []Config{
+		{Kind: "square"},
+		{Kind: SineSweep, EndHz: 30000},
+		{Kind: Click, BPM: -1},
+		{Kind: Silence, Level: 2},
+		{Kind: WAV},
+	} {
+		if _, err := New(c, pcm.CD); err == nil {
+			t.Errorf("Expected an error for %+v", c)
+		}
+	}
+}
+
+type counter struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (c *counter) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.n += len(p)
+	return len(p), nil
+}
+
+func (c *counter) bytes() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.n
+}
+
+func TestHandlerRealTime(t *testing.T) {
+	out := &counter{}
+	h := NewHandler(out, false)
+	if err := h.Play(Config{Kind: Silence}, pcm.CD); err != nil {
+		t.Fatalf("Error playing: %v\n", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	h.Quit()
+	// 200ms of CD audio is 35280 bytes, allow for the first chunk and slow
+	// machines
+	if n := out.bytes(); n < 20000 || n > 35280+2*pcm.ChunkFrames*4 {
+		t.Errorf("Expected about 35280 bytes in 200ms, got %d", n)
+	}
+	stopped := out.bytes()
+	time.Sleep(30 * time.Millisecond)
+	if out.bytes() != stopped || !h.Stopped() {
+		t.Errorf("Audio was written after quitting")
+	}
+	if err := h.Play(Config{Kind: "square"}, pcm.CD); err == nil {
+		t.Errorf("Expected an error for an unknown kind")
+	}
+}
@@ -2,57 +2,71 @@ package audiobridge
 
 import (
 	"fmt"
-	"io"
 	"ledfx/audio/audiobridge/playback"
+	"ledfx/audio/audiobridge/recorder"
 	"ledfx/integrations/airplay2"
 )
 
 func (br *Bridge) wireAirPlayOutput(client *airplay2.Client) (err error) {
-	switch br.inputType {
-	case -1:
+	id := airPlayOutputID(client)
+	switch {
+	case !br.hasInputs():
 		err = fmt.Errorf("input source has not been defined")
-	case inputTypeAirPlayServer:
-		err = br.airplay.server.AddClient(client)
-	case inputTypeLocal:
-		fallthrough
-	case inputTypeYoutube:
-		err = br.AddOutputWriter(client, client.WriterID())
+	case br.airplay.server != nil && !br.airplay.server.Stopped():
+		if err = br.airplay.server.AddClient(client); err == nil {
+			err = br.AddOutputWriter(client, id)
+		}
 	default:
-		err = fmt.Errorf("unrecognized input type '%d'", br.inputType)
+		err = br.AddOutputWriter(client, id)
 	}
 	if err != nil {
-		br.outputs = append(br.outputs, &OutputInfo{
-			Type: outputTypeAirPlay,
-			Value: &AirPlayOutputInfo{
-				IP:          client.RemoteIP().String(),
-				Hostname:    client.Hostname(),
-				AdvertName:  client.Name(),
-				Type:        client.Type(),
-				Port:        client.RemotePort(),
-				SampleRate:  client.SampleRate(),
-				DeviceModel: client.DeviceModel(),
-			},
-		})
+		return err
 	}
-	return err
+	br.addOutput(&OutputInfo{
+		ID:   id,
+		Type: outputTypeAirPlay,
+		Value: &AirPlayOutputInfo{
+			IP:          client.RemoteIP().String(),
+			Hostname:    client.Hostname(),
+			AdvertName:  client.Name(),
+			Type:        client.Type(),
+			Port:        client.RemotePort(),
+			SampleRate:  client.SampleRate(),
+			DeviceModel: client.DeviceModel(),
+		},
+	})
+	return nil
 }
 
 func (br *Bridge) wireLocalOutput(handler playback.Handler) error {
-	if err := br.AddOutputWriter(handler, handler.Identifier()); err != nil {
+	if err := br.AddOutputWriter(handler, localIdentifier); err != nil {
 		return err
 	}
-	br.outputs = append(br.outputs, &OutputInfo{
+	br.addOutput(&OutputInfo{
+		ID:   localIdentifier,
 		Type: outputTypeLocal,
 		Value: &LocalOutputInfo{
 			Device:     handler.Device(),
 			Identifier: handler.Identifier(),
 			SampleRate: handler.SampleRate(),
 			Channels:   handler.NumChannels(),
+			Format:     handler.Format(),
 		},
 	})
 	return nil
 }
 
-func (br *Bridge) AddOutputWriter(wr io.Writer, name string) error {
-	return br.byteWriter.AddWriter(wr, name)
+func (br *Bridge) wireRecorderOutput(rec *recorder.Recorder) error {
+	if err := br.AddOutputWriter(rec, recorderIdentifier); err != nil {
+		return err
+	}
+	br.addOutput(&OutputInfo{
+		ID:   recorderIdentifier,
+		Type: outputTypeRecorder,
+		Value: &RecorderOutputInfo{
+			Identifier: recorderIdentifier,
+			Format:     br.format,
+		},
+	})
+	return nil
 }
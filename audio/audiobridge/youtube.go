@@ -1,7 +1,9 @@
 package audiobridge
 
 import (
+	"errors"
 	"ledfx/audio/audiobridge/youtube"
+	"ledfx/config"
 )
 
 type YoutubeHandler struct {
@@ -9,16 +11,17 @@ type YoutubeHandler struct {
 }
 
 func (br *Bridge) StartYoutubeInput(verbose bool) error {
-	if br.inputType != -1 {
-		br.closeInput()
+	if config.Snapshot().Offline {
+		return errors.New("YouTube can not be played offline")
 	}
+	br.closeInput(inputTypeYoutube)
 
-	br.inputType = inputTypeYoutube
-
-	if br.youtube == nil {
-		br.youtube = &YoutubeHandler{
-			handler: youtube.NewHandler(br.byteWriter, verbose),
-		}
+	in, err := br.addInput(inputTypeYoutube)
+	if err != nil {
+		return err
+	}
+	br.youtube = &YoutubeHandler{
+		handler: youtube.NewHandler(in, verbose),
 	}
 	return nil
 }
@@ -3,7 +3,7 @@ package youtube
 import (
 	"fmt"
 	"io"
-	"ledfx/audio"
+	"ledfx/audio/pcm"
 	log "ledfx/logger"
 	"ledfx/util"
 	"net/http"
@@ -23,12 +23,12 @@ import (
 )
 
 type Handler struct {
-	cl         *yt.Client
-	byteWriter *audio.AsyncMultiWriter
-	verbose    bool
-	p          *Player
-	pp         *PlaylistPlayer
-	stopped    bool
+	cl      *yt.Client
+	out     io.Writer
+	verbose bool
+	p       *Player
+	pp      *PlaylistPlayer
+	stopped bool
 
 	nowPlaying TrackInfo
 	history    []TrackInfo
@@ -47,15 +47,16 @@ func (h *Handler) Stopped() bool {
 	return h.stopped
 }
 
-func NewHandler(byteWriter *audio.AsyncMultiWriter, verbose bool) *Handler {
+// NewHandler returns a handler that writes the audio of videos to out
+func NewHandler(out io.Writer, verbose bool) *Handler {
 	h := &Handler{
 		cl: &yt.Client{
 			Debug:      false,
 			HTTPClient: http.DefaultClient,
 		},
-		byteWriter: byteWriter,
-		verbose:    verbose,
-		history:    make([]TrackInfo, 0),
+		out:     out,
+		verbose: verbose,
+		history: make([]TrackInfo, 0),
 		p: &Player{
 			mu:      &sync.Mutex{},
 			done:    atomic.NewBool(false),
@@ -63,7 +64,7 @@ func NewHandler(byteWriter *audio.AsyncMultiWriter, verbose bool) *Handler {
 			unpause: make(chan bool),
 			playing: atomic.NewBool(false),
 			in:      nil,
-			out:     byteWriter,
+			out:     out,
 		},
 	}
 	h.pp = &PlaylistPlayer{
@@ -199,8 +200,8 @@ func (h *Handler) downloadToMP3(url string) (videoInfo TrackInfo, tmp *os.File,
 
 Download:
 	format := video.Formats.WithAudioChannels().FindByQuality("tiny")
-	videoInfo.SampleRate = 44100
-	videoInfo.AudioChannels = 2
+	videoInfo.SampleRate = int64(pcm.CD.SampleRate)
+	videoInfo.AudioChannels = pcm.CD.Channels
 
 	reader, size, err := h.cl.GetStream(video, format)
 	if err != nil {
@@ -225,11 +226,11 @@ Download:
 	}
 
 	if h.verbose {
-		if err := ffmpeg.Input(tmpVideoNameAndPath).Audio().Output(audioFile, ffmpeg.KwArgs{"sample_fmt": "s16", "ar": "44100", "ac": 2}).OverWriteOutput().WithErrorOutput(os.Stderr).Run(); err != nil {
+		if err := ffmpeg.Input(tmpVideoNameAndPath).Audio().Output(audioFile, outputArgs(pcm.CD)).OverWriteOutput().WithErrorOutput(os.Stderr).Run(); err != nil {
 			return videoInfo, nil, fmt.Errorf("error converting YouTubeSet download to wav: %w", err)
 		}
 	} else {
-		if err := ffmpeg.Input(tmpVideoNameAndPath).Audio().Output(audioFile, ffmpeg.KwArgs{"sample_fmt": "s16", "ar": "44100", "ac": 2}).OverWriteOutput().Run(); err != nil {
+		if err := ffmpeg.Input(tmpVideoNameAndPath).Audio().Output(audioFile, outputArgs(pcm.CD)).OverWriteOutput().Run(); err != nil {
 			return videoInfo, nil, fmt.Errorf("error converting YouTubeSet download to wav: %w", err)
 		}
 	}
@@ -268,3 +269,15 @@ func logTrack(track, author string) {
 	pretty.Unset()
 	fmt.Println()
 }
+
+// outputArgs returns the ffmpeg output arguments that convert to raw audio
+// of f in a WAV file
+func outputArgs(f pcm.Format) ffmpeg.KwArgs {
+	codec := map[pcm.Encoding]string{
+		pcm.Int16:   "pcm_s16le",
+		pcm.Int24:   "pcm_s24le",
+		pcm.Int32:   "pcm_s32le",
+		pcm.Float32: "pcm_f32le",
+	}[f.Encoding]
+	return ffmpeg.KwArgs{"acodec": codec, "ar": f.SampleRate, "ac": f.Channels}
+}
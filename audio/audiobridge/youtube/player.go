@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"go.uber.org/atomic"
 	"io"
-	"ledfx/audio"
 	"os"
 	"sync"
 	"time"
@@ -20,7 +19,7 @@ type Player struct {
 	playing *atomic.Bool
 
 	in  *FileBuffer
-	out *audio.AsyncMultiWriter
+	out io.Writer
 
 	elapsed time.Duration
 }
@@ -17,8 +17,9 @@ var melbank *aubio.FilterBank
 var onset *aubio.Onset
 
 func CaptureDemo() {
+	audioConfig := config.Snapshot().Audio
 	// REMOVE THIS ONCE WE HAVE CONFIG VALIDATION
-	config.GlobalConfig.Audio.FrameRate = 60
+	audioConfig.FrameRate = 60
 
 	if err := portaudio.Initialize(); err != nil {
 		logger.Logger.Error(err)
@@ -26,13 +27,13 @@ func CaptureDemo() {
 	}
 	defer portaudio.Terminate()
 	// match our config device to a real portaudio device
-	di, err := GetPaDeviceInfo(config.GlobalConfig.Audio.Device)
+	di, err := GetPaDeviceInfo(audioConfig.Device)
 	if err != nil {
 		logger.Logger.Errorf("Audio device does not exist")
 		return
 	}
 	// frames per buffer
-	fpb := int(di.DefaultSampleRate) / config.GlobalConfig.Audio.FrameRate
+	fpb := int(di.DefaultSampleRate) / audioConfig.FrameRate
 
 	// phase vocoder
 	pvoc, err = aubio.NewPhaseVoc(fftSize, uint(fpb))
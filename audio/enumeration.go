@@ -8,14 +8,13 @@ import (
 	"ledfx/logger"
 	"os"
 	"text/tabwriter"
-
-	"github.com/gordonklaus/portaudio"
 )
 
 /*
 Creates a hash of hostapi idx and device name
 This ID should be the same regardless of device idx, meaning
-it won't change when other audio devices are added or removed
+it won't change when other audio devices are added or removed.
+PulseAudio sources use pulseHostApi and their source name.
 */
 func createId(i int, n string) string {
 	s := fmt.Sprintf("%d %s", i, n)
@@ -24,59 +23,23 @@ func createId(i int, n string) string {
 	return hex.EncodeToString(id.Sum(nil))
 }
 
-func GetPaDeviceInfo(ad config.AudioDevice) (d *portaudio.DeviceInfo, err error) {
-	hs, err := portaudio.HostApis()
-	if err != nil {
-		return
-	}
-	for i, h := range hs {
-		for _, d := range h.Devices {
-			if d.MaxInputChannels < 1 {
-				continue
-			}
-			if ad.Id == createId(i, d.Name) {
-				return d, nil
-			}
-		}
-	}
-	logger.Logger.Warn("Saved audio input device cannot be found. Reverting to default device.")
-	d, err = portaudio.DefaultInputDevice()
-	if err != nil {
-		return &portaudio.DeviceInfo{}, err
-	}
-	return d, err
+// DeviceEvent is an audio device that was plugged in, unplugged or changed
+type DeviceEvent struct {
+	// Type is added, removed or changed
+	Type   string             `json:"type"`
+	Device config.AudioDevice `json:"device"`
 }
 
+// GetAudioDevices lists the input devices of PortAudio, then on Linux the
+// PulseAudio or PipeWire sources, which include the monitors of sinks that
+// PortAudio hides
 func GetAudioDevices() (infos []config.AudioDevice, err error) {
-	err = portaudio.Initialize()
-	if err != nil {
-		logger.Logger.Error(err)
-		return
-	}
-	defer portaudio.Terminate()
-
-	hs, err := portaudio.HostApis()
-	if err != nil {
-		logger.Logger.Error(err)
-		return
-	}
-	for i, h := range hs {
-		for _, d := range h.Devices {
-			if d.MaxInputChannels < 1 {
-				continue
-			}
-			ad := config.AudioDevice{
-				Id:         createId(i, d.Name),
-				HostApi:    h.Name,
-				SampleRate: d.DefaultSampleRate,
-				Name:       d.Name,
-				Channels:   d.MaxInputChannels,
-				IsDefault:  d.Name == h.DefaultInputDevice.Name,
-			}
-			infos = append(infos, ad)
-		}
+	infos, err = getPaDevices()
+	pulseInfos, perr := pulseDevices()
+	if perr != nil {
+		logger.Logger.Warnf("Error listing PulseAudio sources: %v", perr)
 	}
-	return infos, err
+	return append(infos, pulseInfos...), err
 }
 
 func LogAudioDevices() {
@@ -5,4 +5,6 @@ import "errors"
 var (
 	NameCannotBeOmitted = errors.New("name must not be omitted")
 	WriterNotFound      = errors.New("writer was not found in the index map")
+	// ErrNoPortAudio is returned for local devices by builds without cgo
+	ErrNoPortAudio = errors.New("PortAudio is not available in builds without cgo")
 )
@@ -2,90 +2,74 @@ package audio
 
 import (
 	"fmt"
-	"go.uber.org/atomic"
+	"ledfx/audio/analysis"
+	"ledfx/audio/pcm"
 	"ledfx/color"
 	"ledfx/config"
 	"ledfx/virtual"
-
-	aubio "github.com/simonassank/aubio-go"
 )
 
-const (
-	fftSize         uint = 1024
-	framesPerBuffer uint = 44100 / 60
-	sampleRate      uint = 44100
-)
+const fftSize = 1024
 
 type FxHandler struct {
-	frameCount int
-	pvoc       *aubio.PhaseVoc
-	melbank    *aubio.FilterBank
-	onset      *aubio.Onset
-	highest    *atomic.Float64
+	format  pcm.Format
+	hopSize int
+	// mono holds the samples not analysed yet, less than a hop
+	mono     []float64
+	analyzer analysis.Analyzer
+	// onOnset runs for every onset found, playOnset outside of tests
+	onOnset func()
 }
 
-func NewFxHandler() (fx *FxHandler, err error) {
-	fx = new(FxHandler)
-	if fx.pvoc, err = aubio.NewPhaseVoc(fftSize, framesPerBuffer); err != nil {
-		return nil, fmt.Errorf("error initializing new Aubio phase vocoder: %w", err)
+// NewFxHandler returns a handler for buffers of format, which are mixed to
+// mono before analysis by backend, or the default backend if it is empty
+func NewFxHandler(format pcm.Format, backend analysis.Backend) (fx *FxHandler, err error) {
+	if err := format.Validate(); err != nil {
+		return nil, err
 	}
-
-	fx.melbank = aubio.NewFilterBank(40, fftSize)
-	fx.melbank.SetMelCoeffsSlaney(44100)
-
-	if fx.onset, err = aubio.NewOnset(aubio.Energy, fftSize, framesPerBuffer, sampleRate); err != nil {
-		return nil, fmt.Errorf("error initializing new Aubio onset: %w", err)
+	fx = &FxHandler{format: format, hopSize: format.SampleRate / 60, onOnset: playOnset}
+	fx.analyzer, err = analysis.New(backend, analysis.Options{
+		SampleRate: format.SampleRate,
+		FFTSize:    fftSize,
+		HopSize:    fx.hopSize,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error initializing audio analysis: %w", err)
 	}
-
-	fx.highest = atomic.NewFloat64(0.0)
-
 	return fx, nil
 }
 
 func (fx *FxHandler) Callback(buf Buffer) {
-	fx.frameCount += 1
-	simpleBuffer := aubio.NewSimpleBufferData(uint(len(buf)), buf.AsFloat64())
-	defer simpleBuffer.Free()
-	fx.pvoc.Do(simpleBuffer)
-	fx.melbank.Do(fx.pvoc.Grain())
-	fx.onset.Do(simpleBuffer)
-	bufSlice := fx.onset.Buffer().Slice()
-	sum := sumBufSlice(bufSlice)
-
-	/*normalized := int((sum / 4.0) * 48)
-
-	if sum > 0 {
-		for _, d := range config.GlobalConfig.Virtuals {
-			if d.Active && d.Effect.Type == "singleColor" {
-				if err := virtual.RepeatNSmooth(d.Id, true, "#ff0000", normalized); err != nil {
-					log.Logger.WithField("category", "Buffer FX Callback").Errorf("Error playing virtual: %v", err)
-					continue
-				}
-			}
+	fx.mono = downmix(fx.mono, buf, fx.format.Channels)
+	hops := 0
+	for ; (hops+1)*fx.hopSize <= len(fx.mono); hops++ {
+		fx.analyzer.Do(fx.mono[hops*fx.hopSize : (hops+1)*fx.hopSize])
+		if fx.analyzer.Onset() {
+			fx.onOnset()
 		}
 	}
-	*/
+	fx.mono = fx.mono[:copy(fx.mono, fx.mono[hops*fx.hopSize:])]
+}
 
-	if sum > 1.2 {
-		//fmt.Printf("%0.3f\n", sum)
-		for i, d := range config.GlobalConfig.Virtuals {
-			// ToDo: change singleColor to audioRandom after Effect-Type-Change is possible
-			if d.Active && config.GlobalConfig.Virtuals[i].Effect.Type == "singleColor" {
-				//fmt.Printf("%s\n", config.GlobalConfig.Virtuals[i].Effect.Type)
-				_ = virtual.PlayVirtual(config.GlobalConfig.Virtuals[i].Id, true, color.RandomColor())
-			}
+// playOnset flashes the active single color virtuals in a random color
+func playOnset() {
+	for _, d := range config.Snapshot().Virtuals {
+		// ToDo: change singleColor to audioRandom after Effect-Type-Change is possible
+		if d.Active && d.Effect.Type == "singleColor" {
+			_ = virtual.PlayVirtual(d.Id, true, color.RandomColor())
 		}
 	}
-
-	/*sum := sumBufSlice(bufSlice)*/
-	/*if err := virtual.PlayVirtual(config.GlobalConfig.Virtuals[0].Id, true, color.FromBufSliceSum(sum)); err != nil {
-		log.Logger.WithField("category", "FxHandler Callback").Errorf("Error during PlayVirtual(): %v", err)
-	}*/
 }
 
-func sumBufSlice(bufSlice []float64) (sum float64) {
-	for i := range bufSlice {
-		sum += bufSlice[i]
+// downmix appends the average of the channels of every frame of buf to dst,
+// scaled to -1 to 1
+func downmix(dst []float64, buf Buffer, channels int) []float64 {
+	for i := 0; i+channels <= len(buf); i += channels {
+		var sum float64
+		for _, v := range buf[i : i+channels] {
+			sum += float64(v)
+		}
+		dst = append(dst, sum/float64(channels)/32768)
 	}
-	return sum
+	return dst
 }
@@ -0,0 +1,40 @@
+package audio
+
+import (
+	"io"
+	"ledfx/audio/analysis"
+	"ledfx/audio/audiobridge/synth"
+	"ledfx/audio/pcm"
+	"testing"
+)
+
+func TestFxHandlerClickOnsets(t *testing.T) {
+	const (
+		bpm     = 120
+		seconds = 10
+	)
+	// The Go backend is in every build, aubio needs cgo
+	fx, err := NewFxHandler(pcm.CD, analysis.Go)
+	if err != nil {
+		t.Fatalf("Error creating handler: %v\n", err)
+	}
+	var onsets int
+	fx.onOnset = func() { onsets++ }
+
+	src, err := synth.New(synth.Config{Kind: synth.Click, BPM: bpm}, pcm.CD)
+	if err != nil {
+		t.Fatalf("Error creating click: %v\n", err)
+	}
+	// Buffers of a 60th of a second, as the bridge hands them over
+	buf := make([]byte, pcm.CD.SampleRate/60*pcm.CD.FrameSize())
+	for i := 0; i < seconds*60; i++ {
+		if _, err := io.ReadFull(src, buf); err != nil {
+			t.Fatalf("Error reading click: %v\n", err)
+		}
+		fx.Callback(BytesToAudioBuffer(buf))
+	}
+
+	if want := bpm * seconds / 60; onsets != want {
+		t.Errorf("Expected %d onsets for %d seconds at %d BPM, got %d", want, seconds, bpm, onsets)
+	}
+}
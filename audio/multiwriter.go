@@ -0,0 +1,313 @@
+package audio
+
+import (
+	"io"
+	log "ledfx/logger"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// ringSlots is how many writes the ring holds, at 8ms of audio a write
+	// about half a second
+	ringSlots = 64
+	// slotSize is the most bytes a slot holds, longer writes take several
+	// slots. It is a multiple of every frame size up to 8 channels of 32 bit
+	// samples and 6 of 24 bit ones.
+	slotSize = 12 * 1024
+	// maxLag is how many writes a consumer may fall behind before it drops
+	// some, and catchUpLag how far behind it is after dropping
+	maxLag     = ringSlots / 2
+	catchUpLag = ringSlots / 4
+)
+
+// slot is a write in the ring. seq is the sequence number of the write plus
+// one. mu is held for writing while the slot is filled and for reading
+// while a consumer copies it, so a consumer that is lapped mid-copy holds up
+// the writer for that copy rather than reading a torn write.
+type slot struct {
+	mu  sync.RWMutex
+	seq uint64
+	n   int
+	buf [slotSize]byte
+}
+
+// AsyncMultiWriter passes every write on to several writers without waiting
+// for them. Writes are copied into a ring the writers each read at their own
+// pace, a writer that falls too far behind drops the oldest writes instead of
+// holding up the others.
+//
+// Write must not be called concurrently. It does not allocate. It is
+// bounded-wait rather than lock-free: it only waits while a writer's copy of
+// the slot it reuses is being made, at most one slot copy per writer.
+type AsyncMultiWriter struct {
+	slots [ringSlots]slot
+	// head is the number of slots written
+	head uint64
+
+	// consumers is a []*consumer replaced on every change, so Write reads
+	// it without locking
+	consumers atomic.Value
+	mu        sync.Mutex
+	onRemove  func(name string, err error)
+}
+
+// consumer reads the ring for one writer from its own goroutine. The 64 bit
+// counters come first to be aligned on 32 bit platforms.
+type consumer struct {
+	// read is the sequence number of the next slot to read, stored once the
+	// slot before it is copied, and passed once that slot is written or
+	// dropped
+	read   uint64
+	passed uint64
+	// written and dropped count slots
+	written uint64
+	dropped uint64
+
+	name string
+	w    io.Writer
+	// notify wakes the consumer after a write, done stops it
+	notify chan struct{}
+	done   chan struct{}
+	buf    []byte
+}
+
+// WriterStats are the counters of a writer of an AsyncMultiWriter
+type WriterStats struct {
+	Name    string `json:"name"`
+	Written uint64 `json:"written"`
+	Dropped uint64 `json:"dropped"`
+	// Lag is how many writes the writer is behind
+	Lag uint64 `json:"lag"`
+}
+
+func NewAsyncMultiWriter() *AsyncMultiWriter {
+	bw := &AsyncMultiWriter{}
+	bw.consumers.Store([]*consumer{})
+	return bw
+}
+
+// SetRemoveHandler sets a func called with the name of every writer removed
+// after failing to write, and its error. fn is called from the goroutine of
+// the writer, it may call back into bw.
+func (bw *AsyncMultiWriter) SetRemoveHandler(fn func(name string, err error)) {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	bw.onRemove = fn
+}
+
+func (bw *AsyncMultiWriter) load() []*consumer {
+	return bw.consumers.Load().([]*consumer)
+}
+
+// AddWriter adds a writer under the provided name, it gets the writes from
+// now on. A writer already added under name is replaced.
+func (bw *AsyncMultiWriter) AddWriter(writer io.Writer, name string) error {
+	if name == "" {
+		return NameCannotBeOmitted
+	}
+	head := atomic.LoadUint64(&bw.head)
+	c := &consumer{
+		name:   name,
+		w:      writer,
+		notify: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+		read:   head,
+		passed: head,
+		buf:    make([]byte, 0, slotSize),
+	}
+
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	consumers := make([]*consumer, 0, len(bw.load())+1)
+	for _, old := range bw.load() {
+		if old.name == name {
+			close(old.done)
+			continue
+		}
+		consumers = append(consumers, old)
+	}
+	bw.consumers.Store(append(consumers, c))
+	go bw.consume(c)
+	return nil
+}
+
+// RemoveWriter removes the writer corresponding with the provided name. It
+// gets no writes after RemoveWriter returns, though one may still be under
+// way.
+//
+// Name cannot be omitted.
+func (bw *AsyncMultiWriter) RemoveWriter(id string) error {
+	if id == "" {
+		return NameCannotBeOmitted
+	}
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	if !bw.remove(id, nil) {
+		return WriterNotFound
+	}
+	return nil
+}
+
+// remove stops the consumer called name, or c if it is not nil and still
+// added under that name. bw.mu must be held.
+func (bw *AsyncMultiWriter) remove(name string, c *consumer) bool {
+	old := bw.load()
+	for i, o := range old {
+		if o.name != name || (c != nil && o != c) {
+			continue
+		}
+		consumers := make([]*consumer, 0, len(old)-1)
+		consumers = append(append(consumers, old[:i]...), old[i+1:]...)
+		bw.consumers.Store(consumers)
+		close(o.done)
+		return true
+	}
+	return false
+}
+
+// RemoveAll removes all writers referenced by (bw *AsyncMultiWriter).
+func (bw *AsyncMultiWriter) RemoveAll() {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	for _, c := range bw.load() {
+		close(c.done)
+	}
+	bw.consumers.Store([]*consumer{})
+}
+
+// Write copies p into the ring and wakes the writers. It never fails, writers
+// that fail are removed.
+func (bw *AsyncMultiWriter) Write(p []byte) (int, error) {
+	consumers := bw.load()
+	total := len(p)
+	for n := len(p); len(p) > 0; n = len(p) {
+		if n > slotSize {
+			n = slotSize
+		}
+		seq := bw.head
+		s := &bw.slots[seq%ringSlots]
+		s.mu.Lock()
+		s.n = copy(s.buf[:], p[:n])
+		s.seq = seq + 1
+		s.mu.Unlock()
+		atomic.StoreUint64(&bw.head, seq+1)
+		p = p[n:]
+	}
+
+	for _, c := range consumers {
+		select {
+		case c.notify <- struct{}{}:
+		default:
+		}
+	}
+	return total, nil
+}
+
+// consume passes the slots written on to c.w until c is removed
+func (bw *AsyncMultiWriter) consume(c *consumer) {
+	next := c.read
+	for {
+		select {
+		case <-c.notify:
+		case <-c.done:
+			return
+		}
+
+		for head := atomic.LoadUint64(&bw.head); next != head; head = atomic.LoadUint64(&bw.head) {
+			if head-next > maxLag {
+				log.Logger.WithField("category", "Named MultiWriter").Warnf("Writer '%s' fell %d writes behind, dropping %d", c.name, head-next, head-next-catchUpLag)
+				atomic.AddUint64(&c.dropped, head-next-catchUpLag)
+				next = head - catchUpLag
+			}
+			ok := bw.copySlot(c, next)
+			next++
+			atomic.StoreUint64(&c.read, next)
+			if !ok {
+				// Overwritten before it was copied
+				atomic.AddUint64(&c.dropped, 1)
+				atomic.StoreUint64(&c.passed, next)
+				continue
+			}
+
+			select {
+			case <-c.done:
+				return
+			default:
+			}
+			if _, err := c.w.Write(c.buf); err != nil {
+				bw.fail(c, err)
+				return
+			}
+			atomic.AddUint64(&c.written, 1)
+			atomic.StoreUint64(&c.passed, next)
+		}
+	}
+}
+
+// copySlot copies the slot of sequence number seq to c.buf and returns
+// whether it still held that write, rather than a later one
+func (bw *AsyncMultiWriter) copySlot(c *consumer, seq uint64) bool {
+	s := &bw.slots[seq%ringSlots]
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.seq != seq+1 {
+		return false
+	}
+	c.buf = append(c.buf[:0], s.buf[:s.n]...)
+	return true
+}
+
+// fail removes c after it failed to write and tells the remove handler
+func (bw *AsyncMultiWriter) fail(c *consumer, err error) {
+	bw.mu.Lock()
+	removed := bw.remove(c.name, c)
+	onRemove := bw.onRemove
+	bw.mu.Unlock()
+	if !removed {
+		return
+	}
+	log.Logger.WithField("category", "Named MultiWriter").Errorf("Removed writer '%s' after an error: %v", c.name, err)
+	if onRemove != nil {
+		onRemove(c.name, err)
+	}
+}
+
+// Flush waits until every writer has been passed every write so far, or
+// timeout has passed, and returns whether they all were
+func (bw *AsyncMultiWriter) Flush(timeout time.Duration) bool {
+	head := atomic.LoadUint64(&bw.head)
+	deadline := time.Now().Add(timeout)
+	for _, c := range bw.load() {
+		for atomic.LoadUint64(&c.passed) < head {
+			select {
+			case <-c.done:
+			default:
+				if time.Now().After(deadline) {
+					return false
+				}
+				time.Sleep(time.Millisecond)
+				continue
+			}
+			break
+		}
+	}
+	return true
+}
+
+// Stats returns the counters of every writer
+func (bw *AsyncMultiWriter) Stats() []WriterStats {
+	head := atomic.LoadUint64(&bw.head)
+	consumers := bw.load()
+	stats := make([]WriterStats, len(consumers))
+	for i, c := range consumers {
+		stats[i] = WriterStats{
+			Name:    c.name,
+			Written: atomic.LoadUint64(&c.written),
+			Dropped: atomic.LoadUint64(&c.dropped),
+			Lag:     head - atomic.LoadUint64(&c.passed),
+		}
+	}
+	return stats
+}
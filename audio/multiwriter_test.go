@@ -0,0 +1,310 @@
+package audio
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type failWriter struct{}
+
+func (failWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("gone")
+}
+
+// syncBuffer is a bytes.Buffer safe to read while it is written
+type syncBuffer struct {
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	writes int
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.writes++
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte{}, b.buf.Bytes()...)
+}
+
+func (b *syncBuffer) Writes() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.writes
+}
+
+// gateWriter blocks every write until the gate is opened
+type gateWriter struct {
+	syncBuffer
+	gate chan struct{}
+}
+
+func (g *gateWriter) Write(p []byte) (int, error) {
+	<-g.gate
+	return g.syncBuffer.Write(p)
+}
+
+func flush(t *testing.T, bw *AsyncMultiWriter) {
+	t.Helper()
+	if !bw.Flush(time.Second) {
+		t.Fatalf("Timed out flushing\n")
+	}
+}
+
+func TestMultiWriterRemovesFailed(t *testing.T) {
+	bw := NewAsyncMultiWriter()
+	defer bw.RemoveAll()
+	var mu sync.Mutex
+	var removed []string
+	bw.SetRemoveHandler(func(name string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		removed = append(removed, name)
+	})
+
+	var a, c syncBuffer
+	bw.AddWriter(&a, "a")
+	bw.AddWriter(failWriter{}, "b")
+	bw.AddWriter(&c, "c")
+	bw.AddWriter(failWriter{}, "d")
+	if n, err := bw.Write([]byte{1, 2}); n != 2 || err != nil {
+		t.Errorf("Expected the write to succeed, got %d %v", n, err)
+	}
+	bw.Write([]byte{3})
+	flush(t, bw)
+
+	mu.Lock()
+	sort.Strings(removed)
+	if len(removed) != 2 || removed[0] != "b" || removed[1] != "d" {
+		t.Errorf("Expected b and d removed, got %v", removed)
+	}
+	mu.Unlock()
+	if !bytes.Equal(a.Bytes(), []byte{1, 2, 3}) || !bytes.Equal(c.Bytes(), []byte{1, 2, 3}) {
+		t.Errorf("Expected every write to reach a and c, got %v and %v", a.Bytes(), c.Bytes())
+	}
+	if err := bw.RemoveWriter("b"); !errors.Is(err, WriterNotFound) {
+		t.Errorf("Expected b to be gone, got %v", err)
+	}
+	if err := bw.RemoveWriter("c"); err != nil {
+		t.Errorf("Error removing c: %v", err)
+	}
+	bw.Write([]byte{4})
+	flush(t, bw)
+	if len(c.Bytes()) != 3 || len(a.Bytes()) != 4 {
+		t.Errorf("Expected only a to get the last write")
+	}
+}
+
+func TestMultiWriterReplace(t *testing.T) {
+	bw := NewAsyncMultiWriter()
+	defer bw.RemoveAll()
+	var a, b syncBuffer
+	bw.AddWriter(&a, "out")
+	bw.AddWriter(&b, "out")
+	bw.Write([]byte{1})
+	flush(t, bw)
+	if len(a.Bytes()) != 0 || len(b.Bytes()) != 1 {
+		t.Errorf("Expected the second writer to replace the first")
+	}
+	bw.RemoveWriter("out")
+	bw.Write([]byte{2})
+	flush(t, bw)
+	if len(b.Bytes()) != 1 {
+		t.Errorf("Expected no writer left")
+	}
+}
+
+func TestMultiWriterSplitsLongWrites(t *testing.T) {
+	bw := NewAsyncMultiWriter()
+	defer bw.RemoveAll()
+	var a syncBuffer
+	bw.AddWriter(&a, "a")
+	p := make([]byte, 2*slotSize+10)
+	for i := range p {
+		p[i] = byte(i)
+	}
+	bw.Write(p)
+	flush(t, bw)
+	if !bytes.Equal(a.Bytes(), p) || a.Writes() != 3 {
+		t.Errorf("Expected the write in 3 parts, got %d bytes in %d", len(a.Bytes()), a.Writes())
+	}
+}
+
+func TestMultiWriterSlowWriterDrops(t *testing.T) {
+	bw := NewAsyncMultiWriter()
+	defer bw.RemoveAll()
+	var fast syncBuffer
+	slow := &gateWriter{gate: make(chan struct{})}
+	bw.AddWriter(&fast, "fast")
+	bw.AddWriter(slow, "slow")
+
+	const writes = 4 * ringSlots
+	for i := 0; i < writes; i++ {
+		bw.Write([]byte{byte(i)})
+		// Let the fast writer keep up, as a real time source would
+		for deadline := time.Now().Add(time.Second); fast.Writes() <= i && time.Now().Before(deadline); {
+			time.Sleep(10 * time.Microsecond)
+		}
+	}
+	if fast.Writes() != writes {
+		t.Errorf("Expected the fast writer to get every write while the slow one stalls, got %d", fast.Writes())
+	}
+
+	close(slow.gate)
+	flush(t, bw)
+	got := slow.Bytes()
+	if len(got) == 0 || len(got) >= writes {
+		t.Fatalf("Expected the slow writer to drop some writes, got %d\n", len(got))
+	}
+	// What it got is in order and ends with the last write
+	for i := 1; i < len(got); i++ {
+		if got[i] <= got[i-1] {
+			t.Errorf("Writes out of order: %v", got)
+			break
+		}
+	}
+	if got[len(got)-1] != byte(writes-1) {
+		t.Errorf("Expected the slow writer to catch up, it ended at %d", got[len(got)-1])
+	}
+	for _, s := range bw.Stats() {
+		if s.Name == "slow" && (s.Dropped == 0 || s.Written+s.Dropped != writes || s.Lag != 0) {
+			t.Errorf("Unexpected stats of the slow writer: %+v", s)
+		}
+	}
+}
+
+func TestMultiWriterRemoveWhileWriting(t *testing.T) {
+	bw := NewAsyncMultiWriter()
+	defer bw.RemoveAll()
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p := make([]byte, 1408)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				bw.Write(p)
+			}
+		}
+	}()
+	for i := 0; i < 200; i++ {
+		name := strconv.Itoa(i % 5)
+		bw.AddWriter(io.Discard, name)
+		if i%3 == 0 {
+			bw.RemoveWriter(name)
+		}
+	}
+	close(stop)
+	<-done
+	flush(t, bw)
+}
+
+func TestMultiWriterLappedMidCopy(t *testing.T) {
+	bw := NewAsyncMultiWriter()
+	defer bw.RemoveAll()
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	// Unpaced full slots, so the slot copied below is rewritten again and
+	// again while it is copied
+	go func() {
+		defer close(done)
+		p := make([]byte, slotSize)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			for j := range p {
+				p[j] = byte(i)
+			}
+			bw.Write(p)
+		}
+	}()
+
+	c := &consumer{buf: make([]byte, 0, slotSize)}
+	copied := 0
+	for deadline := time.Now().Add(200 * time.Millisecond); time.Now().Before(deadline); {
+		seq := atomic.LoadUint64(&bw.head)
+		if seq == 0 || !bw.copySlot(c, seq-1) {
+			continue
+		}
+		copied++
+		for _, b := range c.buf {
+			if b != byte(seq-1) {
+				t.Fatalf("Copied write %d torn by a later one, got %d\n", seq-1, b)
+			}
+		}
+	}
+	close(stop)
+	<-done
+	if copied == 0 {
+		t.Errorf("Expected some slots to be copied before they were lapped")
+	}
+}
+
+// slowWriter takes d for every write
+type slowWriter time.Duration
+
+func (d slowWriter) Write(p []byte) (int, error) {
+	time.Sleep(time.Duration(d))
+	return len(p), nil
+}
+
+// benchmarkWrite writes mixer chunks to bw and reports the longest write,
+// which is how long the writer was held up by copies of the slot it reused
+func benchmarkWrite(b *testing.B, bw *AsyncMultiWriter) {
+	// A mixer chunk of 352 stereo 16 bit frames
+	p := make([]byte, 1408)
+	b.SetBytes(int64(len(p)))
+	b.ReportAllocs()
+	var longest time.Duration
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		start := time.Now()
+		bw.Write(p)
+		if d := time.Since(start); d > longest {
+			longest = d
+		}
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(longest.Nanoseconds()), "max-ns/write")
+	bw.Flush(time.Second)
+}
+
+func BenchmarkMultiWriter(b *testing.B) {
+	for _, writers := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("%d writers", writers), func(b *testing.B) {
+			bw := NewAsyncMultiWriter()
+			defer bw.RemoveAll()
+			for i := 0; i < writers; i++ {
+				bw.AddWriter(io.Discard, strconv.Itoa(i))
+			}
+			benchmarkWrite(b, bw)
+		})
+	}
+	// Writers this slow are lapped all the time
+	b.Run("4 lapped writers", func(b *testing.B) {
+		bw := NewAsyncMultiWriter()
+		defer bw.RemoveAll()
+		for i := 0; i < 4; i++ {
+			bw.AddWriter(slowWriter(time.Millisecond), strconv.Itoa(i))
+		}
+		benchmarkWrite(b, bw)
+	})
+}
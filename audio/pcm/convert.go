@@ -0,0 +1,205 @@
+package pcm
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// Converter converts a stream from one format to another: samples are
+// decoded to floats, mixed to the target channels, resampled by linear
+// interpolation and encoded again. It keeps state between calls, so every
+// stream needs its own converter. It is not safe for concurrent use.
+type Converter struct {
+	from, to Format
+	// matrix mixes a frame of from into a frame of to, nil if the channels
+	// stay as they are
+	matrix [][]float32
+	// step is the distance between output frames in input frames
+	step float64
+	// next is the position of the next output frame relative to the first
+	// frame of the next call. It may be negative, down to -1 for prev.
+	next float64
+	// prev is the last mixed frame of the previous call
+	prev []float32
+	// rest holds bytes of a frame split between calls
+	rest   []byte
+	joined []byte
+
+	samples []float32
+	mixed   []float32
+	out     []float32
+	buf     []byte
+}
+
+// NewConverter returns a converter from one format to another
+func NewConverter(from, to Format) (*Converter, error) {
+	if err := from.Validate(); err != nil {
+		return nil, err
+	}
+	if err := to.Validate(); err != nil {
+		return nil, err
+	}
+	c := &Converter{
+		from:   from,
+		to:     to,
+		matrix: mixMatrix(from.Channels, to.Channels),
+		step:   float64(from.SampleRate) / float64(to.SampleRate),
+		prev:   make([]float32, to.Channels),
+	}
+	return c, nil
+}
+
+// From returns the format the converter takes
+func (c *Converter) From() Format { return c.from }
+
+// To returns the format the converter makes
+func (c *Converter) To() Format { return c.to }
+
+// Reset forgets the previous calls, for a new stream
+func (c *Converter) Reset() {
+	c.next = 0
+	c.rest = c.rest[:0]
+	for i := range c.prev {
+		c.prev[i] = 0
+	}
+}
+
+// Convert converts p and returns the result, which is valid until the next
+// call. Bytes of an incomplete frame at the end of p are kept for the next
+// call.
+func (c *Converter) Convert(p []byte) []byte {
+	if c.from == c.to && len(c.rest) == 0 && len(p)%c.from.FrameSize() == 0 {
+		return p
+	}
+	if len(c.rest) > 0 {
+		c.joined = append(append(c.joined[:0], c.rest...), p...)
+		p = c.joined
+	}
+	whole := len(p) - len(p)%c.from.FrameSize()
+	c.rest = append(c.rest[:0], p[whole:]...)
+	c.samples = Decode(c.samples[:0], p[:whole], c.from.Encoding)
+	return c.ConvertFloat32(c.samples)
+}
+
+// ConvertFloat32 converts interleaved samples with the channels and sample
+// rate of the source format, whatever its encoding
+func (c *Converter) ConvertFloat32(samples []float32) []byte {
+	frames := c.mix(samples)
+	if c.step != 1 {
+		frames = c.resample(frames)
+	}
+	c.buf = Encode(c.buf[:0], frames, c.to.Encoding)
+	return c.buf
+}
+
+// mix maps samples to the channels of the target format
+func (c *Converter) mix(samples []float32) []float32 {
+	if c.matrix == nil {
+		return samples
+	}
+	in, out := c.from.Channels, c.to.Channels
+	n := len(samples) / in
+	c.mixed = grow(c.mixed, n*out)
+	for f := 0; f < n; f++ {
+		frame := samples[f*in : (f+1)*in]
+		for o, row := range c.matrix {
+			var sum float32
+			for i, w := range row {
+				sum += w * frame[i]
+			}
+			c.mixed[f*out+o] = sum
+		}
+	}
+	return c.mixed
+}
+
+// resample interpolates between the frames of in, continuing the previous
+// call
+func (c *Converter) resample(in []float32) []float32 {
+	ch := c.to.Channels
+	n := len(in) / ch
+	if n == 0 {
+		return in[:0]
+	}
+	c.out = c.out[:0]
+	t := c.next
+	for ; t < float64(n-1); t += c.step {
+		i := int(math.Floor(t))
+		frac := float32(t - float64(i))
+		a := c.prev
+		if i >= 0 {
+			a = in[i*ch : (i+1)*ch]
+		}
+		b := in[(i+1)*ch : (i+2)*ch]
+		for k := 0; k < ch; k++ {
+			c.out = append(c.out, a[k]+(b[k]-a[k])*frac)
+		}
+	}
+	c.next = t - float64(n)
+	copy(c.prev, in[(n-1)*ch:])
+	return c.out
+}
+
+// Decode appends the samples of p to dst as floats from -1 to 1
+func Decode(dst []float32, p []byte, e Encoding) []float32 {
+	size := e.Bytes()
+	for i := 0; i+size <= len(p); i += size {
+		var v float32
+		switch e {
+		case Int16:
+			v = float32(int16(binary.LittleEndian.Uint16(p[i:]))) / (1 << 15)
+		case Int24:
+			s := int32(p[i]) | int32(p[i+1])<<8 | int32(int8(p[i+2]))<<16
+			v = float32(s) / (1 << 23)
+		case Int32:
+			v = float32(float64(int32(binary.LittleEndian.Uint32(p[i:]))) / (1 << 31))
+		case Float32:
+			v = math.Float32frombits(binary.LittleEndian.Uint32(p[i:]))
+		}
+		dst = append(dst, v)
+	}
+	return dst
+}
+
+// Encode appends samples to dst, clipping them to -1 to 1 for integer
+// encodings
+func Encode(dst []byte, samples []float32, e Encoding) []byte {
+	for _, v := range samples {
+		switch e {
+		case Int16:
+			s := uint16(quantize(v, 1<<15))
+			dst = append(dst, byte(s), byte(s>>8))
+		case Int24:
+			s := uint32(quantize(v, 1<<23))
+			dst = append(dst, byte(s), byte(s>>8), byte(s>>16))
+		case Int32:
+			s := uint32(quantize(v, 1<<31))
+			dst = append(dst, byte(s), byte(s>>8), byte(s>>16), byte(s>>24))
+		case Float32:
+			s := math.Float32bits(v)
+			dst = append(dst, byte(s), byte(s>>8), byte(s>>16), byte(s>>24))
+		}
+	}
+	return dst
+}
+
+// quantize scales v to an integer from -scale to scale-1
+func quantize(v float32, scale float64) int64 {
+	s := math.Round(float64(v) * scale)
+	switch {
+	case s >= scale:
+		return int64(scale) - 1
+	case s < -scale:
+		return -int64(scale)
+	case s != s: // NaN
+		return 0
+	}
+	return int64(s)
+}
+
+func grow(s []float32, n int) []float32 {
+	if cap(s) < n {
+		return make([]float32, n)
+	}
+	return s[:n]
+}
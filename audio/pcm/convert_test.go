@@ -0,0 +1,168 @@
+package pcm
+
+import (
+	"math"
+	"testing"
+)
+
+// sine returns seconds of a sine of the given frequency and amplitude in
+// every channel of f
+func sine(f Format, freq, amplitude, seconds float64) []byte {
+	n := int(float64(f.SampleRate) * seconds)
+	samples := make([]float32, 0, n*f.Channels)
+	for i := 0; i < n; i++ {
+		v := float32(amplitude * math.Sin(2*math.Pi*freq*float64(i)/float64(f.SampleRate)))
+		for ch := 0; ch < f.Channels; ch++ {
+			samples = append(samples, v)
+		}
+	}
+	return Encode(nil, samples, f.Encoding)
+}
+
+// channel returns one channel of interleaved samples
+func channel(samples []float32, channels, ch int) []float32 {
+	out := make([]float32, 0, len(samples)/channels)
+	for i := ch; i < len(samples); i += channels {
+		out = append(out, samples[i])
+	}
+	return out
+}
+
+// amplitude returns the amplitude of freq in samples, by the Goertzel
+// algorithm
+func amplitude(samples []float32, rate int, freq float64) float64 {
+	coeff := 2 * math.Cos(2*math.Pi*freq/float64(rate))
+	var s1, s2 float64
+	for _, x := range samples {
+		s0 := float64(x) + coeff*s1 - s2
+		s2, s1 = s1, s0
+	}
+	power := s1*s1 + s2*s2 - coeff*s1*s2
+	return 2 * math.Sqrt(power) / float64(len(samples))
+}
+
+// convertInChunks converts p in chunks of odd sizes, the way a stream
+// arrives
+func convertInChunks(t *testing.T, c *Converter, p []byte) []byte {
+	t.Helper()
+	var out []byte
+	for len(p) > 0 {
+		n := 1001
+		if n > len(p) {
+			n = len(p)
+		}
+		out = append(out, c.Convert(p[:n])...)
+		p = p[n:]
+	}
+	return out
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to Format
+		// gain is the amplitude expected in the first output channel
+		gain float64
+	}{
+		{"48 kHz to CD", Format{48000, 2, Int16}, CD, 1},
+		{"22.05 kHz to CD", Format{22050, 2, Int16}, CD, 1},
+		{"mono to stereo", Format{44100, 1, Int16}, CD, 1},
+		{"stereo to mono", CD, Format{44100, 1, Int16}, 1},
+		{"float to CD", Format{44100, 2, Float32}, CD, 1},
+		{"24-bit 96 kHz to CD", Format{96000, 2, Int24}, CD, 1},
+		{"32-bit to float", Format{44100, 2, Int32}, Format{48000, 2, Float32}, 1},
+		// The same signal in every channel of 5.1 sums to full scale
+		{"5.1 to stereo", Format{48000, 6, Float32}, CD, 1},
+		{"stereo to 5.1", CD, Format{48000, 6, Int16}, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := NewConverter(test.from, test.to)
+			if err != nil {
+				t.Fatalf("Error creating converter: %v\n", err)
+			}
+			const freq, level, seconds = 1000, 0.5, 0.5
+			out := convertInChunks(t, c, sine(test.from, freq, level, seconds))
+
+			frames := len(out) / test.to.FrameSize()
+			want := int(float64(test.to.SampleRate) * seconds)
+			if frames < want-2 || frames > want+2 {
+				t.Errorf("Expected about %d frames, got %d", want, frames)
+			}
+			samples := Decode(nil, out, test.to.Encoding)
+			first := channel(samples, test.to.Channels, 0)
+			if got := amplitude(first, test.to.SampleRate, freq); math.Abs(got-level*test.gain) > 0.02 {
+				t.Errorf("Expected amplitude %.3f at %d Hz, got %.3f", level*test.gain, freq, got)
+			}
+			// Resampling must not move the tone
+			for _, other := range []float64{500, 1500, 3000} {
+				if got := amplitude(first, test.to.SampleRate, other); got > 0.01 {
+					t.Errorf("Expected silence at %v Hz, got amplitude %.3f", other, got)
+				}
+			}
+			if test.to.Channels == 6 {
+				if got := amplitude(channel(samples, 6, 2), test.to.SampleRate, freq); got > 0.001 {
+					t.Errorf("Expected a silent center channel, got amplitude %.3f", got)
+				}
+			}
+		})
+	}
+}
+
+func TestDownmixWeights(t *testing.T) {
+	c, err := NewConverter(Format{44100, 6, Float32}, Format{44100, 2, Float32})
+	if err != nil {
+		t.Fatalf("Error creating converter: %v\n", err)
+	}
+	// Only the left surround channel plays
+	out := Decode(nil, c.Convert(Encode(nil, []float32{0, 0, 0, 1, 1, 0}, Float32)), Float32)
+	sum := float32(1 + math.Sqrt2)
+	if math.Abs(float64(out[0]-float32(math.Sqrt2/2)/sum)) > 1e-6 || out[1] != 0 {
+		t.Errorf("Unexpected downmix of the left surround and LFE: %v", out)
+	}
+}
+
+func TestConvertIdentity(t *testing.T) {
+	c, err := NewConverter(CD, CD)
+	if err != nil {
+		t.Fatalf("Error creating converter: %v\n", err)
+	}
+	in := sine(CD, 440, 1, 0.01)
+	out := c.Convert(in)
+	if string(out) != string(in) {
+		t.Errorf("Converting to the same format changed the audio")
+	}
+	// An odd split keeps the frame together
+	out = append(append([]byte{}, c.Convert(in[:3])...), c.Convert(in[3:])...)
+	if string(out) != string(in) {
+		t.Errorf("Converting a split frame changed the audio")
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	samples := []float32{0, 0.5, -0.5, 0.25, -1}
+	for _, e := range []Encoding{Int16, Int24, Int32, Float32} {
+		got := Decode(nil, Encode(nil, samples, e), e)
+		for i := range samples {
+			if math.Abs(float64(got[i]-samples[i])) > 1e-6 {
+				t.Errorf("%s: expected %v, got %v", e, samples, got)
+				break
+			}
+		}
+	}
+	clipped := Decode(nil, Encode(nil, []float32{2, -2, float32(math.NaN())}, Int16), Int16)
+	if clipped[0] != 32767.0/32768 || clipped[1] != -1 || clipped[2] != 0 {
+		t.Errorf("Expected clipping, got %v", clipped)
+	}
+}
+
+func TestFormatValidate(t *testing.T) {
+	for _, f := range []Format{{0, 2, Int16}, {44100, 0, Int16}, {44100, 2, "u8"}, {44100, 64, Float32}} {
+		if err := f.Validate(); err == nil {
+			t.Errorf("Expected an error for %v", f)
+		}
+	}
+	if d := CD.Duration(44100 * 4); d.Seconds() != 1 {
+		t.Errorf("Expected a second of CD audio, got %v", d)
+	}
+}
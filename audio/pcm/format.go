@@ -0,0 +1,85 @@
+// Package pcm describes raw interleaved audio and converts it between sample
+// rates, channel layouts and sample encodings.
+package pcm
+
+import (
+	"fmt"
+	"time"
+)
+
+// Encoding is how one sample is stored. All encodings are little endian.
+type Encoding string
+
+const (
+	Int16   Encoding = "s16"
+	Int24   Encoding = "s24"
+	Int32   Encoding = "s32"
+	Float32 Encoding = "f32"
+)
+
+// Bytes returns the size of one sample, 0 for unknown encodings
+func (e Encoding) Bytes() int {
+	switch e {
+	case Int16:
+		return 2
+	case Int24:
+		return 3
+	case Int32, Float32:
+		return 4
+	}
+	return 0
+}
+
+// BitDepth returns the number of bits of one sample
+func (e Encoding) BitDepth() int {
+	return e.Bytes() * 8
+}
+
+// MaxChannels is the largest channel count a format may have
+const MaxChannels = 32
+
+// Format describes a stream of interleaved frames
+type Format struct {
+	SampleRate int      `json:"sample_rate"`
+	Channels   int      `json:"channels"`
+	Encoding   Encoding `json:"encoding"`
+}
+
+// CD is 44.1 kHz 16-bit stereo, the format of everything that passes
+// through the audio bridge
+var CD = Format{SampleRate: 44100, Channels: 2, Encoding: Int16}
+
+// Validate checks that f describes audio that can be converted
+func (f Format) Validate() error {
+	switch {
+	case f.SampleRate < 1000 || f.SampleRate > 384000:
+		return fmt.Errorf("sample rate %d Hz is out of range 1000-384000", f.SampleRate)
+	case f.Channels < 1 || f.Channels > MaxChannels:
+		return fmt.Errorf("%d channels are out of range 1-%d", f.Channels, MaxChannels)
+	case f.Encoding.Bytes() == 0:
+		return fmt.Errorf("unknown encoding '%s'", f.Encoding)
+	}
+	return nil
+}
+
+// FrameSize returns the size of one frame, a sample for every channel
+func (f Format) FrameSize() int {
+	return f.Channels * f.Encoding.Bytes()
+}
+
+// BitDepth returns the number of bits of one sample
+func (f Format) BitDepth() int {
+	return f.Encoding.BitDepth()
+}
+
+// Duration returns how long n bytes of f play
+func (f Format) Duration(n int) time.Duration {
+	if f.FrameSize() == 0 || f.SampleRate == 0 {
+		return 0
+	}
+	return time.Duration(n/f.FrameSize()) * time.Second / time.Duration(f.SampleRate)
+}
+
+func (f Format) String() string {
+	return fmt.Sprintf("%d Hz, %d ch, %s", f.SampleRate, f.Channels, f.Encoding)
+}
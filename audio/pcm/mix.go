@@ -0,0 +1,97 @@
+package pcm
+
+import "math"
+
+// speaker is the position of a channel
+type speaker int8
+
+const (
+	frontLeft speaker = iota
+	frontRight
+	center
+	lfe
+	backCenter
+	surroundLeft
+	surroundRight
+)
+
+// layouts are the positions of the channels of common channel counts in
+// WAV/SMPTE order
+var layouts = map[int][]speaker{
+	1: {center},
+	2: {frontLeft, frontRight},
+	3: {frontLeft, frontRight, center},
+	4: {frontLeft, frontRight, surroundLeft, surroundRight},
+	5: {frontLeft, frontRight, center, surroundLeft, surroundRight},
+	6: {frontLeft, frontRight, center, lfe, surroundLeft, surroundRight},
+	7: {frontLeft, frontRight, center, lfe, backCenter, surroundLeft, surroundRight},
+	8: {frontLeft, frontRight, center, lfe, surroundLeft, surroundRight, surroundLeft, surroundRight},
+}
+
+// stereoWeights is how much a speaker contributes to the left and right
+// channel of a stereo downmix. Like most downmixers, the LFE is dropped.
+var stereoWeights = map[speaker][2]float32{
+	frontLeft:     {1, 0},
+	frontRight:    {0, 1},
+	center:        {math.Sqrt2 / 2, math.Sqrt2 / 2},
+	lfe:           {0, 0},
+	backCenter:    {0.5, 0.5},
+	surroundLeft:  {math.Sqrt2 / 2, 0},
+	surroundRight: {0, math.Sqrt2 / 2},
+}
+
+// layout returns the positions of n channels. Unknown layouts alternate
+// between left and right.
+func layout(n int) []speaker {
+	if l, ok := layouts[n]; ok {
+		return l
+	}
+	l := make([]speaker, n)
+	for i := range l {
+		l[i] = speaker(i % 2)
+	}
+	return l
+}
+
+// mixMatrix returns the weights of every input channel for every output
+// channel, nil if the channels stay as they are. Everything is mixed to
+// mono or stereo, rows are normalized so a full scale input can not clip.
+// Stereo is upmixed to the front channels of larger layouts.
+func mixMatrix(in, out int) [][]float32 {
+	if in == out {
+		return nil
+	}
+	var stereo [2][]float32
+	for side := range stereo {
+		stereo[side] = make([]float32, in)
+		for i, s := range layout(in) {
+			stereo[side][i] = stereoWeights[s][side]
+		}
+	}
+
+	matrix := make([][]float32, out)
+	switch out {
+	case 1:
+		matrix[0] = make([]float32, in)
+		for i := range matrix[0] {
+			matrix[0][i] = stereo[0][i] + stereo[1][i]
+		}
+	default:
+		matrix[0], matrix[1] = stereo[0], stereo[1]
+		for o := 2; o < out; o++ {
+			matrix[o] = make([]float32, in)
+		}
+	}
+	for _, row := range matrix {
+		var sum float32
+		for _, w := range row {
+			sum += w
+		}
+		for i := range row {
+			if sum > 0 {
+				row[i] /= sum
+			}
+		}
+	}
+	return matrix
+}
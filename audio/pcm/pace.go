@@ -0,0 +1,47 @@
+package pcm
+
+import (
+	"context"
+	"time"
+)
+
+// ChunkFrames is how many frames the sources of the audio bridge write at
+// once, the size of an AirPlay packet
+const ChunkFrames = 352
+
+// maxLag is how many writes a Pacer may fall behind before it starts over
+const maxLag = 4
+
+// Pacer spaces out the writes of audio so it is sent no faster than it plays.
+// It is not safe for concurrent use.
+type Pacer struct {
+	start time.Time
+	paced time.Duration
+}
+
+// NewPacer returns a pacer that starts now
+func NewPacer() *Pacer {
+	return &Pacer{start: time.Now()}
+}
+
+// Reset starts over from now, after a pause
+func (p *Pacer) Reset() {
+	p.start, p.paced = time.Now(), 0
+}
+
+// Wait blocks until the d of audio just written has played, or until ctx is
+// done and returns its error. After a stall of more than a few writes it
+// starts over instead of catching up, which would drain live inputs and
+// flood the outputs.
+func (p *Pacer) Wait(ctx context.Context, d time.Duration) error {
+	p.paced += d
+	if behind := time.Since(p.start) - p.paced; behind > maxLag*d {
+		p.Reset()
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(time.Until(p.start.Add(p.paced))):
+		return nil
+	}
+}
@@ -0,0 +1,37 @@
+package pcm
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPacer(t *testing.T) {
+	const d = 10 * time.Millisecond
+	p := NewPacer()
+	begin := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := p.Wait(context.Background(), d); err != nil {
+			t.Fatalf("Error waiting: %v\n", err)
+		}
+	}
+	if took := time.Since(begin); took < 5*d {
+		t.Errorf("Expected 5 writes to take %v, took %v", 5*d, took)
+	}
+
+	// After a stall it does not rush to catch up
+	time.Sleep(10 * d)
+	begin = time.Now()
+	for i := 0; i < 5; i++ {
+		p.Wait(context.Background(), d)
+	}
+	if took := time.Since(begin); took < 4*d {
+		t.Errorf("Expected the pacer to start over after a stall, 5 writes took %v", took)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := p.Wait(ctx, time.Hour); err == nil {
+		t.Errorf("Expected an error once ctx is done")
+	}
+}
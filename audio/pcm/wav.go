@@ -0,0 +1,121 @@
+package pcm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrUnsupported is returned for WAV files in an encoding this package can
+// not convert
+var ErrUnsupported = errors.New("unsupported encoding")
+
+const (
+	wavFormatPCM        = 0x0001
+	wavFormatFloat      = 0x0003
+	wavFormatExtensible = 0xfffe
+)
+
+// ReadWAVHeader reads the chunks of a WAV file up to its samples and
+// returns their format and size. The size is -1 if the header does not know
+// it, as in files that were still being written.
+func ReadWAVHeader(r io.Reader) (f Format, size int64, err error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return f, 0, fmt.Errorf("error reading RIFF header: %w", err)
+	}
+	if string(riff[:4]) != "RIFF" || string(riff[8:]) != "WAVE" {
+		return f, 0, errors.New("not a WAV file")
+	}
+
+	var header [8]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return f, 0, fmt.Errorf("error reading chunk header: %w", err)
+		}
+		id, n := string(header[:4]), int64(binary.LittleEndian.Uint32(header[4:]))
+		switch id {
+		case "fmt ":
+			if n < 16 || n > 1024 {
+				return f, 0, fmt.Errorf("invalid fmt chunk of %d bytes", n)
+			}
+			chunk := make([]byte, n+n%2)
+			if _, err := io.ReadFull(r, chunk); err != nil {
+				return f, 0, fmt.Errorf("error reading fmt chunk: %w", err)
+			}
+			if f, err = wavFormat(chunk); err != nil {
+				return f, 0, err
+			}
+		case "data":
+			if f.Encoding == "" {
+				return f, 0, errors.New("data chunk before fmt chunk")
+			}
+			if n == 0 || n == 0xffffffff {
+				n = -1
+			}
+			return f, n, nil
+		default:
+			if _, err := io.CopyN(io.Discard, r, n+n%2); err != nil {
+				return f, 0, fmt.Errorf("error skipping '%s' chunk: %w", id, err)
+			}
+		}
+	}
+}
+
+// wavFormat parses a fmt chunk
+func wavFormat(chunk []byte) (f Format, err error) {
+	tag := binary.LittleEndian.Uint16(chunk)
+	if tag == wavFormatExtensible && len(chunk) >= 26 {
+		// The first two bytes of the sub format GUID are the real tag
+		tag = binary.LittleEndian.Uint16(chunk[24:])
+	}
+	f.Channels = int(binary.LittleEndian.Uint16(chunk[2:]))
+	f.SampleRate = int(binary.LittleEndian.Uint32(chunk[4:]))
+	bits := binary.LittleEndian.Uint16(chunk[14:])
+	switch {
+	case tag == wavFormatPCM && bits == 16:
+		f.Encoding = Int16
+	case tag == wavFormatPCM && bits == 24:
+		f.Encoding = Int24
+	case tag == wavFormatPCM && bits == 32:
+		f.Encoding = Int32
+	case tag == wavFormatFloat && bits == 32:
+		f.Encoding = Float32
+	default:
+		return f, fmt.Errorf("WAV format %#04x with %d bits: %w", tag, bits, ErrUnsupported)
+	}
+	return f, f.Validate()
+}
+
+// WriteWAVHeader writes the header of a WAV file with size bytes of samples
+// in format f. A size of -1 writes the size that marks it as unknown, for
+// files that are still being written.
+func WriteWAVHeader(w io.Writer, f Format, size int64) error {
+	if err := f.Validate(); err != nil {
+		return err
+	}
+	riffSize, dataSize := uint32(36+size), uint32(size)
+	if size < 0 || size > 0xffffffff-36 {
+		riffSize, dataSize = 0xffffffff, 0xffffffff
+	}
+	tag := uint16(wavFormatPCM)
+	if f.Encoding == Float32 {
+		tag = wavFormatFloat
+	}
+	header := make([]byte, 44)
+	copy(header, "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], riffSize)
+	copy(header[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(header[16:], 16)
+	binary.LittleEndian.PutUint16(header[20:], tag)
+	binary.LittleEndian.PutUint16(header[22:], uint16(f.Channels))
+	binary.LittleEndian.PutUint32(header[24:], uint32(f.SampleRate))
+	binary.LittleEndian.PutUint32(header[28:], uint32(f.SampleRate*f.FrameSize()))
+	binary.LittleEndian.PutUint16(header[32:], uint16(f.FrameSize()))
+	binary.LittleEndian.PutUint16(header[34:], uint16(f.BitDepth()))
+	copy(header[36:], "data")
+	binary.LittleEndian.PutUint32(header[40:], dataSize)
+	_, err := w.Write(header)
+	return err
+}
@@ -0,0 +1,49 @@
+package pcm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func TestWAVHeader(t *testing.T) {
+	for _, f := range []Format{CD, {48000, 6, Float32}, {96000, 1, Int24}} {
+		var buf bytes.Buffer
+		if err := WriteWAVHeader(&buf, f, 1200); err != nil {
+			t.Fatalf("Error writing header: %v\n", err)
+		}
+		got, size, err := ReadWAVHeader(&buf)
+		if err != nil {
+			t.Fatalf("Error reading header of %s: %v\n", f, err)
+		}
+		if got != f || size != 1200 {
+			t.Errorf("Expected %s with 1200 bytes, got %s with %d", f, got, size)
+		}
+	}
+}
+
+func TestReadWAVHeader(t *testing.T) {
+	var buf bytes.Buffer
+	WriteWAVHeader(&buf, CD, -1)
+	header := buf.Bytes()
+
+	// A LIST chunk of odd size between fmt and data is skipped
+	list := append([]byte("LIST\x03\x00\x00\x00abc\x00"), header[36:]...)
+	_, size, err := ReadWAVHeader(bytes.NewReader(append(append([]byte{}, header[:36]...), list...)))
+	if err != nil || size != -1 {
+		t.Errorf("Expected an unknown size after a LIST chunk, got %d, %v", size, err)
+	}
+
+	unsigned := append([]byte{}, header...)
+	binary.LittleEndian.PutUint16(unsigned[34:], 8)
+	if _, _, err := ReadWAVHeader(bytes.NewReader(unsigned)); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected 8-bit audio to be unsupported, got %v", err)
+	}
+	if _, _, err := ReadWAVHeader(bytes.NewReader([]byte("RIFF\x00\x00\x00\x00AVI "))); err == nil {
+		t.Errorf("Expected an error for a file that is not WAV")
+	}
+	if _, _, err := ReadWAVHeader(bytes.NewReader(header[:30])); err == nil {
+		t.Errorf("Expected an error for a truncated header")
+	}
+}
@@ -0,0 +1,66 @@
+//go:build cgo
+// +build cgo
+
+package audio
+
+import (
+	"ledfx/config"
+	"ledfx/logger"
+
+	"github.com/gordonklaus/portaudio"
+)
+
+func GetPaDeviceInfo(ad config.AudioDevice) (d *portaudio.DeviceInfo, err error) {
+	hs, err := portaudio.HostApis()
+	if err != nil {
+		return
+	}
+	for i, h := range hs {
+		for _, d := range h.Devices {
+			if d.MaxInputChannels < 1 {
+				continue
+			}
+			if ad.Id == createId(i, d.Name) {
+				return d, nil
+			}
+		}
+	}
+	logger.Logger.Warn("Saved audio input device cannot be found. Reverting to default device.")
+	d, err = portaudio.DefaultInputDevice()
+	if err != nil {
+		return &portaudio.DeviceInfo{}, err
+	}
+	return d, err
+}
+
+func getPaDevices() (infos []config.AudioDevice, err error) {
+	err = portaudio.Initialize()
+	if err != nil {
+		logger.Logger.Error(err)
+		return
+	}
+	defer portaudio.Terminate()
+
+	hs, err := portaudio.HostApis()
+	if err != nil {
+		logger.Logger.Error(err)
+		return
+	}
+	for i, h := range hs {
+		for _, d := range h.Devices {
+			if d.MaxInputChannels < 1 {
+				continue
+			}
+			ad := config.AudioDevice{
+				Id:         createId(i, d.Name),
+				HostApi:    h.Name,
+				SampleRate: d.DefaultSampleRate,
+				Name:       d.Name,
+				Channels:   d.MaxInputChannels,
+				IsDefault:  d.Name == h.DefaultInputDevice.Name,
+			}
+			infos = append(infos, ad)
+		}
+	}
+	return infos, err
+}
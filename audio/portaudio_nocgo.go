@@ -0,0 +1,11 @@
+//go:build !cgo
+// +build !cgo
+
+package audio
+
+import "ledfx/config"
+
+// getPaDevices lists nothing in builds without cgo, which have no PortAudio
+func getPaDevices() (infos []config.AudioDevice, err error) {
+	return nil, nil
+}
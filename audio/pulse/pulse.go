@@ -0,0 +1,165 @@
+// Package pulse captures audio from PulseAudio, or from PipeWire through its
+// PulseAudio server, with the pactl and parec tools. Unlike PortAudio it
+// lists the monitor source of every sink, which records what the sink plays.
+package pulse
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	log "ledfx/logger"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// HostApi is the host API of the sources listed next to the PortAudio ones
+const HostApi = "PulseAudio"
+
+// Source is a PulseAudio source
+type Source struct {
+	Index int
+	// Name identifies the source and stays the same across restarts
+	Name        string
+	Description string
+	SampleRate  int
+	Channels    int
+	// MonitorOf is the sink a monitor source records, empty for other
+	// sources
+	MonitorOf string
+	Default   bool
+}
+
+// Monitor returns whether s records what a sink plays
+func (s Source) Monitor() bool {
+	return s.MonitorOf != ""
+}
+
+// run runs a command and returns its output, it is replaced in tests
+var run = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = cEnv()
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("error running %s %s: %w", name, strings.Join(args, " "), err)
+	}
+	return out, nil
+}
+
+// cEnv is the environment with the C locale, so pactl output is in English
+func cEnv() []string {
+	return append(os.Environ(), "LC_ALL=C")
+}
+
+// Available returns whether pactl is installed and reaches a server
+func Available(ctx context.Context) bool {
+	if _, err := exec.LookPath("pactl"); err != nil {
+		return false
+	}
+	_, err := run(ctx, "pactl", "info")
+	return err == nil
+}
+
+// Sources lists the sources of the server, sink monitors included
+func Sources(ctx context.Context) ([]Source, error) {
+	list, err := run(ctx, "pactl", "list", "sources")
+	if err != nil {
+		return nil, err
+	}
+	info, err := run(ctx, "pactl", "info")
+	if err != nil {
+		return nil, err
+	}
+	sources := parseSources(list)
+	def := parseFields(info)["Default Source"]
+	for i := range sources {
+		sources[i].Default = sources[i].Name == def
+	}
+	return sources, nil
+}
+
+// parseSources parses the output of pactl list sources. A source that can
+// not be parsed is logged and left out, so one odd device does not hide the
+// others.
+func parseSources(out []byte) []Source {
+	var sources []Source
+	for _, block := range bytes.Split(out, []byte("\n\n")) {
+		block = bytes.TrimSpace(block)
+		if !bytes.HasPrefix(block, []byte("Source #")) {
+			continue
+		}
+		s, err := parseSource(block)
+		if err != nil {
+			log.Logger.WithField("category", "PulseAudio").Warnf("Skipping source: %v", err)
+			continue
+		}
+		sources = append(sources, s)
+	}
+	return sources
+}
+
+// parseSource parses one "Source #" block of pactl list sources
+func parseSource(block []byte) (s Source, err error) {
+	header := block
+	if i := bytes.IndexByte(block, '\n'); i >= 0 {
+		header = block[:i]
+	}
+	index, err := strconv.Atoi(string(bytes.TrimPrefix(header, []byte("Source #"))))
+	if err != nil {
+		return s, fmt.Errorf("error parsing source header '%s': %w", header, err)
+	}
+	fields := parseFields(block)
+	s = Source{
+		Index:       index,
+		Name:        fields["Name"],
+		Description: fields["Description"],
+	}
+	if s.Name == "" {
+		return s, fmt.Errorf("source #%d has no name", index)
+	}
+	if s.Description == "" {
+		s.Description = s.Name
+	}
+	if sink := fields["Monitor of Sink"]; sink != "n/a" {
+		s.MonitorOf = sink
+	}
+	if s.SampleRate, s.Channels, err = parseSampleSpec(fields["Sample Specification"]); err != nil {
+		return s, fmt.Errorf("error parsing source %s: %w", s.Name, err)
+	}
+	return s, nil
+}
+
+// parseFields returns the "Key: Value" lines of out that are indented by at
+// most a tab, deeper ones are properties and continuations
+func parseFields(out []byte) map[string]string {
+	fields := make(map[string]string)
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		line := strings.TrimPrefix(sc.Text(), "\t")
+		if strings.HasPrefix(line, "\t") || strings.HasPrefix(line, " ") {
+			continue
+		}
+		if i := strings.Index(line, ": "); i > 0 {
+			fields[line[:i]] = strings.TrimSpace(line[i+2:])
+		}
+	}
+	return fields
+}
+
+// parseSampleSpec parses a sample specification like "s16le 2ch 44100Hz"
+// into its rate and channels
+func parseSampleSpec(spec string) (rate, channels int, err error) {
+	parts := strings.Fields(spec)
+	if len(parts) != 3 || !strings.HasSuffix(parts[1], "ch") || !strings.HasSuffix(parts[2], "Hz") {
+		return 0, 0, fmt.Errorf("invalid sample specification '%s'", spec)
+	}
+	if channels, err = strconv.Atoi(strings.TrimSuffix(parts[1], "ch")); err != nil {
+		return 0, 0, fmt.Errorf("invalid channels in '%s'", spec)
+	}
+	if rate, err = strconv.Atoi(strings.TrimSuffix(parts[2], "Hz")); err != nil {
+		return 0, 0, fmt.Errorf("invalid sample rate in '%s'", spec)
+	}
+	return rate, channels, nil
+}
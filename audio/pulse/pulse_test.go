@@ -0,0 +1,182 @@
+package pulse
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// listSources is pactl list sources output of PipeWire, shortened
+const listSources = `Source #52
+	State: SUSPENDED
+	Name: alsa_output.pci-0000_00_1f.3.analog-stereo.monitor
+	Description: Monitor of Built-in Audio Analog Stereo
+	Driver: PipeWire
+	Sample Specification: s32le 2ch 48000Hz
+	Channel Map: front-left,front-right
+	Owner Module: 4294967295
+	Mute: no
+	Volume: front-left: 65536 / 100% / 0.00 dB,   front-right: 65536 / 100% / 0.00 dB
+	        balance 0.00
+	Base Volume: 65536 / 100% / 0.00 dB
+	Monitor of Sink: alsa_output.pci-0000_00_1f.3.analog-stereo
+	Latency: 0 usec, configured 0 usec
+	Flags: HARDWARE DECIBEL_VOLUME LATENCY
+	Properties:
+		device.description = "Built-in Audio"
+		Name: not a field
+	Formats:
+		pcm
+
+Source #53
+	State: RUNNING
+	Name: alsa_input.usb-0d8c_USB_PnP_Sound_Device-00.mono-fallback
+	Description: USB PnP Sound Device Mono
+	Driver: PipeWire
+	Sample Specification: s16le 1ch 44100Hz
+	Channel Map: mono
+	Monitor of Sink: n/a
+	Properties:
+		alsa.card = "2"
+`
+
+const info = `Server String: /run/user/1000/pulse/native
+Server Name: PulseAudio (on PipeWire 0.3.65)
+Default Sink: alsa_output.pci-0000_00_1f.3.analog-stereo
+Default Source: alsa_input.usb-0d8c_USB_PnP_Sound_Device-00.mono-fallback
+`
+
+// fakeRun answers pactl with list and info
+func fakeRun(list, info *string) func(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		switch strings.Join(append([]string{name}, args...), " ") {
+		case "pactl list sources":
+			return []byte(*list), nil
+		case "pactl info":
+			return []byte(*info), nil
+		}
+		return nil, errors.New("unexpected command")
+	}
+}
+
+func TestSources(t *testing.T) {
+	defer func(r func(context.Context, string, ...string) ([]byte, error)) { run = r }(run)
+	list, i := listSources, info
+	run = fakeRun(&list, &i)
+
+	sources, err := Sources(context.Background())
+	if err != nil {
+		t.Fatalf("Error listing sources: %v\n", err)
+	}
+	want := []Source{
+		{
+			Index:       52,
+			Name:        "alsa_output.pci-0000_00_1f.3.analog-stereo.monitor",
+			Description: "Monitor of Built-in Audio Analog Stereo",
+			SampleRate:  48000,
+			Channels:    2,
+			MonitorOf:   "alsa_output.pci-0000_00_1f.3.analog-stereo",
+		},
+		{
+			Index:       53,
+			Name:        "alsa_input.usb-0d8c_USB_PnP_Sound_Device-00.mono-fallback",
+			Description: "USB PnP Sound Device Mono",
+			SampleRate:  44100,
+			Channels:    1,
+			Default:     true,
+		},
+	}
+	if len(sources) != len(want) {
+		t.Fatalf("Expected %d sources, got %+v\n", len(want), sources)
+	}
+	for i := range want {
+		if sources[i] != want[i] {
+			t.Errorf("Expected %+v, got %+v", want[i], sources[i])
+		}
+	}
+	if !sources[0].Monitor() || sources[1].Monitor() {
+		t.Errorf("Expected only the first source to be a monitor")
+	}
+}
+
+func TestParseSourcesSkipsOdd(t *testing.T) {
+	odd := strings.Replace(listSources, "s32le 2ch 48000Hz", "s32le 2ch", 1)
+	sources := parseSources([]byte(odd))
+	if len(sources) != 1 || sources[0].Index != 53 {
+		t.Errorf("Expected only source #53, got %+v", sources)
+	}
+}
+
+func TestParseSampleSpec(t *testing.T) {
+	if rate, channels, err := parseSampleSpec("float32le 6ch 96000Hz"); err != nil || rate != 96000 || channels != 6 {
+		t.Errorf("Unexpected %d Hz %d ch: %v", rate, channels, err)
+	}
+	for _, spec := range []string{"", "s16le 2ch", "s16le twoch 44100Hz", "s16le 2ch 44.1kHz"} {
+		if _, _, err := parseSampleSpec(spec); err == nil {
+			t.Errorf("Expected an error for '%s'", spec)
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	defer func(r func(context.Context, string, ...string) ([]byte, error)) { run = r }(run)
+	list, i := listSources, info
+	run = fakeRun(&list, &i)
+
+	// Each line of pactl subscribe moves to the next state
+	states := []struct {
+		event string
+		list  string
+		info  string
+	}{
+		{"Event 'new' on client #80", listSources, info},
+		// Unplugging the USB device, the server switches the default
+		{"Event 'remove' on source #53", listSources[:strings.Index(listSources, "Source #53")], strings.Replace(info, "alsa_input.usb-0d8c_USB_PnP_Sound_Device-00.mono-fallback", "alsa_output.pci-0000_00_1f.3.analog-stereo.monitor", 1)},
+		{"Event 'change' on server #-1", listSources, info},
+		{"Event 'new' on source-output #81", "", ""},
+	}
+	var lines []string
+	for _, s := range states {
+		lines = append(lines, s.event)
+	}
+	step := 0
+	events := &stepReader{lines: lines, before: func() {
+		list, i = states[step].list, states[step].info
+		step++
+	}}
+
+	var got []string
+	err := watch(context.Background(), events, func(e Event) {
+		got = append(got, string(e.Type)+" "+e.Source.Name)
+	})
+	if err != nil {
+		t.Fatalf("Error watching: %v\n", err)
+	}
+	want := []string{
+		"changed alsa_output.pci-0000_00_1f.3.analog-stereo.monitor",
+		"removed alsa_input.usb-0d8c_USB_PnP_Sound_Device-00.mono-fallback",
+		"changed alsa_output.pci-0000_00_1f.3.analog-stereo.monitor",
+		"added alsa_input.usb-0d8c_USB_PnP_Sound_Device-00.mono-fallback",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected events\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+// stepReader returns one line per read, calling before ahead of each
+type stepReader struct {
+	lines  []string
+	before func()
+}
+
+func (r *stepReader) Read(p []byte) (int, error) {
+	if len(r.lines) == 0 {
+		return 0, io.EOF
+	}
+	r.before()
+	n := copy(p, r.lines[0]+"\n")
+	r.lines = r.lines[1:]
+	return n, nil
+}
@@ -0,0 +1,92 @@
+package pulse
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"sync"
+)
+
+// Recorder records a source with parec
+type Recorder struct {
+	cmd  *exec.Cmd
+	done chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+// Record starts recording the source called name in 32 bit float samples at
+// rate and channels, resampled by the server if they differ from the
+// source. out is passed a sixtieth of a second of whole frames at a time.
+func Record(name string, rate, channels int, out io.Writer) (*Recorder, error) {
+	cmd := exec.Command("parec",
+		"--device="+name,
+		"--format=float32le",
+		"--rate="+strconv.Itoa(rate),
+		"--channels="+strconv.Itoa(channels),
+		"--latency-msec=20",
+		"--client-name=LedFx",
+		"--stream-name=LedFx capture",
+		"--raw",
+	)
+	cmd.Env = cEnv()
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("error running parec: %w", err)
+	}
+	r := &Recorder{cmd: cmd, done: make(chan struct{})}
+	go r.pump(stdout, make([]byte, rate/60*channels*4), out)
+	return r, nil
+}
+
+// pump copies buf sized chunks of stdout to out until parec exits
+func (r *Recorder) pump(stdout io.Reader, buf []byte, out io.Writer) {
+	defer close(r.done)
+	var err error
+	for {
+		if _, err = io.ReadFull(stdout, buf); err != nil {
+			break
+		}
+		if _, err = out.Write(buf); err != nil {
+			_ = r.cmd.Process.Kill()
+			break
+		}
+	}
+	werr := r.cmd.Wait()
+	switch {
+	case !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF):
+		// out failed
+	case werr != nil:
+		err = fmt.Errorf("parec exited: %w", werr)
+	default:
+		err = errors.New("parec exited")
+	}
+	r.mu.Lock()
+	r.err = err
+	r.mu.Unlock()
+}
+
+// Done is closed once recording stopped, because of Stop or because parec
+// exited, e.g. as the source was unplugged
+func (r *Recorder) Done() <-chan struct{} {
+	return r.done
+}
+
+// Err returns why recording stopped, nil while it goes on
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Stop stops parec and waits for it to exit
+func (r *Recorder) Stop() {
+	_ = r.cmd.Process.Kill()
+	<-r.done
+}
@@ -0,0 +1,95 @@
+package pulse
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+type EventType string
+
+const (
+	SourceAdded   EventType = "added"
+	SourceRemoved EventType = "removed"
+	// SourceChanged sources were renamed or became or stopped being the
+	// default
+	SourceChanged EventType = "changed"
+)
+
+// Event is a change to the sources of the server
+type Event struct {
+	Type   EventType
+	Source Source
+}
+
+// Watch calls fn with every source that is plugged in, unplugged or changed
+// until ctx is done or the server goes away
+func Watch(ctx context.Context, fn func(Event)) error {
+	cmd := exec.CommandContext(ctx, "pactl", "subscribe")
+	cmd.Env = cEnv()
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("error running pactl subscribe: %w", err)
+	}
+	err = watch(ctx, stdout, fn)
+	if werr := cmd.Wait(); err == nil && ctx.Err() == nil {
+		err = fmt.Errorf("pactl subscribe exited: %v", werr)
+	}
+	return err
+}
+
+// watch lists the sources again after every line of pactl subscribe output
+// in events that concerns them, and calls fn with the differences
+func watch(ctx context.Context, events io.Reader, fn func(Event)) error {
+	known, err := Sources(ctx)
+	if err != nil {
+		return err
+	}
+	sc := bufio.NewScanner(events)
+	for sc.Scan() {
+		// The default source is a setting of the server
+		line := sc.Text()
+		if !strings.Contains(line, " on source #") && !strings.Contains(line, " on server") {
+			continue
+		}
+		sources, err := Sources(ctx)
+		if err != nil {
+			return err
+		}
+		for _, e := range diff(known, sources) {
+			fn(e)
+		}
+		known = sources
+	}
+	return sc.Err()
+}
+
+// diff returns the events that turn the sources from into to
+func diff(from, to []Source) (events []Event) {
+	old := make(map[string]Source, len(from))
+	for _, s := range from {
+		old[s.Name] = s
+	}
+	for _, s := range to {
+		o, ok := old[s.Name]
+		delete(old, s.Name)
+		switch {
+		case !ok:
+			events = append(events, Event{Type: SourceAdded, Source: s})
+		case o.Description != s.Description || o.Default != s.Default:
+			events = append(events, Event{Type: SourceChanged, Source: s})
+		}
+	}
+	for _, s := range from {
+		if _, ok := old[s.Name]; ok {
+			events = append(events, Event{Type: SourceRemoved, Source: s})
+		}
+	}
+	return events
+}
@@ -0,0 +1,102 @@
+//go:build linux
+// +build linux
+
+package audio
+
+import (
+	"context"
+	"fmt"
+	"ledfx/audio/pulse"
+	"ledfx/config"
+	"ledfx/logger"
+	"time"
+)
+
+// pulseHostApi is the host API index the IDs of PulseAudio sources are
+// created with, below the PortAudio ones so they never collide
+const pulseHostApi = -1
+
+func pulseDevice(s pulse.Source) config.AudioDevice {
+	return config.AudioDevice{
+		Id:         createId(pulseHostApi, s.Name),
+		HostApi:    pulse.HostApi,
+		SampleRate: float64(s.SampleRate),
+		Name:       s.Description,
+		Channels:   s.Channels,
+		IsDefault:  s.Default,
+		Source:     s.Name,
+	}
+}
+
+// pulseDevices lists the sources of PulseAudio or PipeWire, sink monitors
+// included. There are none without a server.
+func pulseDevices() ([]config.AudioDevice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if !pulse.Available(ctx) {
+		return nil, nil
+	}
+	sources, err := pulse.Sources(ctx)
+	if err != nil {
+		return nil, err
+	}
+	devices := make([]config.AudioDevice, len(sources))
+	for i, s := range sources {
+		devices[i] = pulseDevice(s)
+	}
+	return devices, nil
+}
+
+// GetPulseSource returns the PulseAudio source of ad, found by its ID or
+// else by the source name saved with it
+func GetPulseSource(ad config.AudioDevice) (pulse.Source, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sources, err := pulse.Sources(ctx)
+	if err != nil {
+		return pulse.Source{}, err
+	}
+	for _, s := range sources {
+		if createId(pulseHostApi, s.Name) == ad.Id {
+			return s, nil
+		}
+	}
+	for _, s := range sources {
+		if s.Name == ad.Source {
+			return s, nil
+		}
+	}
+	return pulse.Source{}, fmt.Errorf("PulseAudio source '%s' is not plugged in", ad.Name)
+}
+
+// WatchDevices calls fn with every PulseAudio or PipeWire source that is
+// plugged in, unplugged or changed until ctx is done. It returns at once
+// without a server.
+func WatchDevices(ctx context.Context, fn func(DeviceEvent)) {
+	if !pulse.Available(ctx) {
+		return
+	}
+	backoff := time.Second
+	for {
+		started := time.Now()
+		err := pulse.Watch(ctx, func(e pulse.Event) {
+			fn(DeviceEvent{Type: string(e.Type), Device: pulseDevice(e.Source)})
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		// The server restarted after running for a while
+		if time.Since(started) > time.Minute {
+			backoff = time.Second
+		}
+		logger.Logger.WithField("category", "Audio Devices").Warnf("Watching PulseAudio sources stopped, retrying in %v: %v", backoff, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > time.Minute {
+			backoff = time.Minute
+		}
+	}
+}
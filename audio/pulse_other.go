@@ -0,0 +1,23 @@
+//go:build !linux
+// +build !linux
+
+package audio
+
+import (
+	"context"
+	"errors"
+	"ledfx/audio/pulse"
+	"ledfx/config"
+)
+
+func pulseDevices() ([]config.AudioDevice, error) {
+	return nil, nil
+}
+
+// GetPulseSource fails, PulseAudio sources are only captured on Linux
+func GetPulseSource(ad config.AudioDevice) (pulse.Source, error) {
+	return pulse.Source{}, errors.New("PulseAudio capture is only supported on Linux")
+}
+
+// WatchDevices returns at once, only PulseAudio sources are watched
+func WatchDevices(ctx context.Context, fn func(DeviceEvent)) {}
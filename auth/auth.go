@@ -0,0 +1,159 @@
+// Package auth protects the HTTP and websocket APIs with API tokens or a
+// password. Authentication is off until a token or password is configured.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"ledfx/config"
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type Scope string
+
+const (
+	// ScopeRead allows safe methods only (GET, HEAD, OPTIONS)
+	ScopeRead Scope = "read"
+	// ScopeControl allows every method
+	ScopeControl Scope = "control"
+)
+
+// tokenPrefix marks LedFx secrets so they are easy to spot in scripts and logs
+const tokenPrefix = "lfx_"
+
+const hashPrefix = "sha256:"
+
+var ErrInvalidScope = errors.New("scope must be 'read' or 'control'")
+
+// ParseScope validates a scope name
+func ParseScope(s string) (Scope, error) {
+	switch Scope(s) {
+	case ScopeRead, ScopeControl:
+		return Scope(s), nil
+	}
+	return "", fmt.Errorf("invalid scope '%s': %w", s, ErrInvalidScope)
+}
+
+// Allows reports whether the scope may use the given HTTP method
+func (s Scope) Allows(method string) bool {
+	switch s {
+	case ScopeControl:
+		return true
+	case ScopeRead:
+		return isSafe(method)
+	}
+	return false
+}
+
+func isSafe(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// Enabled reports whether any credential is configured
+func Enabled(conf config.AuthConfig) bool {
+	return conf.PasswordHash != "" || len(conf.Tokens) > 0
+}
+
+// NewSecret returns a random token secret
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating token: %w", err)
+	}
+	return tokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken hashes a token secret for storage. Secrets are random and long,
+// so a fast hash is enough.
+func HashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hashPrefix + hex.EncodeToString(sum[:])
+}
+
+// HashPassword hashes a user chosen password with bcrypt
+func HashPassword(password string) (string, error) {
+	if password == "" {
+		return "", errors.New("password is empty")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("error hashing password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// TokenScope returns the scope of the token matching secret
+func TokenScope(conf config.AuthConfig, secret string) (Scope, bool) {
+	hash := []byte(HashToken(secret))
+	for _, token := range conf.Tokens {
+		if subtle.ConstantTimeCompare(hash, []byte(token.Hash)) == 1 {
+			scope, err := ParseScope(token.Scope)
+			return scope, err == nil
+		}
+	}
+	return "", false
+}
+
+// passwordCache remembers the last password that matched, since bcrypt is
+// deliberately too slow to run on every request
+var passwordCache struct {
+	sync.Mutex
+	hash     string
+	password [sha256.Size]byte
+}
+
+// CheckPassword reports whether password matches the configured hash
+func CheckPassword(conf config.AuthConfig, password string) bool {
+	if conf.PasswordHash == "" || password == "" {
+		return false
+	}
+	sum := sha256.Sum256([]byte(password))
+
+	passwordCache.Lock()
+	defer passwordCache.Unlock()
+	if passwordCache.hash == conf.PasswordHash && subtle.ConstantTimeCompare(sum[:], passwordCache.password[:]) == 1 {
+		return true
+	}
+	if bcrypt.CompareHashAndPassword([]byte(conf.PasswordHash), []byte(password)) != nil {
+		return false
+	}
+	passwordCache.hash = conf.PasswordHash
+	passwordCache.password = sum
+	return true
+}
+
+// Authenticate returns the scope granted by the credentials of r. Tokens are
+// accepted as "Authorization: Bearer", in the "token" header or, for
+// websockets which can't set headers from a browser, the "token" query
+// parameter. The password is accepted through HTTP basic auth with any user
+// name and grants the control scope.
+func Authenticate(r *http.Request, conf config.AuthConfig) (Scope, bool) {
+	if secret := requestToken(r); secret != "" {
+		return TokenScope(conf, secret)
+	}
+	if _, password, ok := r.BasicAuth(); ok {
+		if CheckPassword(conf, password) {
+			return ScopeControl, true
+		}
+	}
+	return "", false
+}
+
+func requestToken(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); len(h) > 7 && strings.EqualFold(h[:7], "bearer ") {
+		return strings.TrimSpace(h[7:])
+	}
+	if h := r.Header.Get("token"); h != "" {
+		return h
+	}
+	return r.URL.Query().Get("token")
+}
@@ -0,0 +1,239 @@
+package auth
+
+import (
+	"ledfx/config"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	readToken    = "lfx_read"
+	controlToken = "lfx_control"
+	password     = "hunter2"
+)
+
+func setupTestConfig(t *testing.T, conf config.AuthConfig) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "go_config.json")
+	if err := os.WriteFile(path, []byte("{}\n"), 0644); err != nil {
+		t.Fatalf("Error writing test config: %v\n", err)
+	}
+	config.GlobalViper = viper.New()
+	config.WriteDelay = 0
+	config.GlobalViper.SetConfigFile(path)
+	if err := config.Replace(config.Config{Auth: conf}); err != nil {
+		t.Fatalf("Error setting test config: %v\n", err)
+	}
+}
+
+func enabledConfig(t *testing.T) config.AuthConfig {
+	hash, err := HashPassword(password)
+	if err != nil {
+		t.Fatalf("Error hashing password: %v\n", err)
+	}
+	return config.AuthConfig{
+		PasswordHash: hash,
+		Tokens: []config.ApiToken{
+			{Id: "r", Name: "Read", Scope: "read", Hash: HashToken(readToken)},
+			{Id: "c", Name: "Control", Scope: "control", Hash: HashToken(controlToken)},
+		},
+		AllowedOrigins: []string{"http://localhost:3000"},
+	}
+}
+
+func serve(method, path, remote string, setup func(r *http.Request)) int {
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	r := httptest.NewRequest(method, path, nil)
+	r.RemoteAddr = remote
+	if setup != nil {
+		setup(r)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+	return rec.Code
+}
+
+func TestMiddlewareDisabled(t *testing.T) {
+	setupTestConfig(t, config.AuthConfig{})
+	cases := []struct {
+		method string
+		path   string
+		remote string
+		a      int
+	}{
+		{http.MethodPost, "/api/devices", "192.168.1.20:5000", http.StatusOK},
+		{http.MethodGet, "/api/bridge/ctl/youtube/info", "192.168.1.20:5000", http.StatusOK},
+		{http.MethodPost, "/api/bridge/set/input/airplay", "127.0.0.1:5000", http.StatusOK},
+		{http.MethodPost, "/api/bridge/set/input/airplay", "[::1]:5000", http.StatusOK},
+		{http.MethodPost, "/api/bridge/set/input/airplay", "192.168.1.20:5000", http.StatusForbidden},
+		{http.MethodPost, "/api/bridge/add/output/local", "192.168.1.20:5000", http.StatusForbidden},
+	}
+	for _, c := range cases {
+		if guess := serve(c.method, c.path, c.remote, nil); guess != c.a {
+			t.Errorf("%s %s from %s: expected %d but got %d", c.method, c.path, c.remote, c.a, guess)
+		}
+	}
+}
+
+func TestMiddlewareAuthControl(t *testing.T) {
+	setupTestConfig(t, config.AuthConfig{})
+	origin := func(o string) func(r *http.Request) {
+		return func(r *http.Request) { r.Header.Set("Origin", o) }
+	}
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		remote string
+		setup  func(r *http.Request)
+		a      int
+	}{
+		{"token from this machine", http.MethodPost, "/api/auth/tokens", "127.0.0.1:5000", nil, http.StatusOK},
+		{"token from the network", http.MethodPost, "/api/auth/tokens", "192.168.1.20:5000", nil, http.StatusForbidden},
+		{"password from the network", http.MethodPut, "/api/auth/password", "192.168.1.20:5000", nil, http.StatusForbidden},
+		{"origins from the network", http.MethodPut, "/api/auth/origins", "192.168.1.20:5000", nil, http.StatusForbidden},
+		{"listing tokens from the network", http.MethodGet, "/api/auth/tokens", "192.168.1.20:5000", nil, http.StatusOK},
+		{"token from a foreign origin", http.MethodPost, "/api/auth/tokens", "127.0.0.1:5000", origin("http://evil.example"), http.StatusForbidden},
+		{"token from the same origin", http.MethodPost, "/api/auth/tokens", "127.0.0.1:5000", origin("http://example.com"), http.StatusOK},
+		{"write from a foreign origin", http.MethodPost, "/api/devices", "192.168.1.20:5000", origin("http://evil.example"), http.StatusForbidden},
+		{"read from a foreign origin", http.MethodGet, "/api/devices", "192.168.1.20:5000", origin("http://evil.example"), http.StatusOK},
+	}
+	for _, c := range cases {
+		if guess := serve(c.method, c.path, c.remote, c.setup); guess != c.a {
+			t.Errorf("%s: expected %d but got %d", c.name, c.a, guess)
+		}
+	}
+}
+
+func TestMiddlewareEnabled(t *testing.T) {
+	setupTestConfig(t, enabledConfig(t))
+	bearer := func(token string) func(r *http.Request) {
+		return func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) }
+	}
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		setup  func(r *http.Request)
+		a      int
+	}{
+		{"frontend is public", http.MethodGet, "/index.html", nil, http.StatusOK},
+		{"preflight is public", http.MethodOptions, "/api/devices", nil, http.StatusOK},
+		{"no credentials", http.MethodGet, "/api/devices", nil, http.StatusUnauthorized},
+		{"bridge input without token", http.MethodPost, "/api/bridge/set/input/youtube", nil, http.StatusUnauthorized},
+		{"websocket without token", http.MethodGet, "/ws", nil, http.StatusUnauthorized},
+		{"unknown token", http.MethodGet, "/api/devices", bearer("lfx_nope"), http.StatusUnauthorized},
+		{"read token reads", http.MethodGet, "/api/devices", bearer(readToken), http.StatusOK},
+		{"read token writes", http.MethodPost, "/api/devices", bearer(readToken), http.StatusForbidden},
+		{"read token bridge input", http.MethodPost, "/api/bridge/set/input/youtube", bearer(readToken), http.StatusForbidden},
+		{"control token writes", http.MethodPost, "/api/bridge/set/input/youtube", bearer(controlToken), http.StatusOK},
+		{"token header", http.MethodDelete, "/api/devices/couch", func(r *http.Request) { r.Header.Set("token", controlToken) }, http.StatusOK},
+		{"token query", http.MethodGet, "/api/bridge/statpoll/ws?token=" + readToken, nil, http.StatusOK},
+		{"password", http.MethodPut, "/api/devices/couch", func(r *http.Request) { r.SetBasicAuth("", password) }, http.StatusOK},
+		{"wrong password", http.MethodPut, "/api/devices/couch", func(r *http.Request) { r.SetBasicAuth("", "nope") }, http.StatusUnauthorized},
+		{"foreign origin", http.MethodPut, "/api/devices/couch", func(r *http.Request) {
+			r.SetBasicAuth("", password)
+			r.Header.Set("Origin", "http://evil.example")
+		}, http.StatusForbidden},
+		{"allowed origin", http.MethodPut, "/api/devices/couch", func(r *http.Request) {
+			r.SetBasicAuth("", password)
+			r.Header.Set("Origin", "http://localhost:3000")
+		}, http.StatusOK},
+	}
+	for _, c := range cases {
+		if guess := serve(c.method, c.path, "192.168.1.20:5000", c.setup); guess != c.a {
+			t.Errorf("%s: expected %d but got %d", c.name, c.a, guess)
+		}
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	setupTestConfig(t, enabledConfig(t))
+	cases := []struct {
+		origin string
+		a      bool
+	}{
+		{"", true},
+		{"http://ledfx.local:8080", true},
+		{"http://localhost:3000", true},
+		{"http://localhost:3000/", true},
+		{"http://evil.example", false},
+		{"::", false},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, "http://ledfx.local:8080/ws", nil)
+		if c.origin != "" {
+			r.Header.Set("Origin", c.origin)
+		}
+		if guess := CheckOrigin(r); guess != c.a {
+			t.Errorf("Origin %q: expected %v but got %v", c.origin, c.a, guess)
+		}
+	}
+
+	if err := SetAllowedOrigins([]string{"*"}); err != nil {
+		t.Fatalf("Error setting origins: %v\n", err)
+	}
+	r := httptest.NewRequest(http.MethodGet, "http://ledfx.local:8080/ws", nil)
+	r.Header.Set("Origin", "http://evil.example")
+	if !CheckOrigin(r) {
+		t.Errorf("Wildcard origin should allow every origin")
+	}
+}
+
+func TestTokens(t *testing.T) {
+	setupTestConfig(t, config.AuthConfig{})
+	token, secret, err := AddToken("script", ScopeRead)
+	if err != nil {
+		t.Fatalf("Error adding token: %v\n", err)
+	}
+	if token.Hash == secret || token.Hash != HashToken(secret) {
+		t.Errorf("Token must only store the hash of its secret: %+v", token)
+	}
+	if scope, ok := TokenScope(config.Snapshot().Auth, secret); !ok || scope != ScopeRead {
+		t.Errorf("Expected read scope, got %q %v", scope, ok)
+	}
+	if !Enabled(config.Snapshot().Auth) {
+		t.Errorf("Adding a token should enable authentication")
+	}
+	if _, _, err := AddToken("bad", Scope("admin")); err == nil {
+		t.Errorf("Expected an error for an unknown scope")
+	}
+
+	if err := RemoveToken(token.Id); err != nil {
+		t.Fatalf("Error removing token: %v\n", err)
+	}
+	if _, ok := TokenScope(config.Snapshot().Auth, secret); ok {
+		t.Errorf("Removed token still authenticates")
+	}
+	if err := RemoveToken(token.Id); err == nil {
+		t.Errorf("Expected an error removing a missing token")
+	}
+}
+
+func TestPassword(t *testing.T) {
+	setupTestConfig(t, config.AuthConfig{})
+	if err := SetPassword(password); err != nil {
+		t.Fatalf("Error setting password: %v\n", err)
+	}
+	conf := config.Snapshot().Auth
+	if conf.PasswordHash == password || !CheckPassword(conf, password) {
+		t.Errorf("Password was not hashed or does not verify")
+	}
+	// Second check is served from the cache
+	if !CheckPassword(conf, password) || CheckPassword(conf, "nope") {
+		t.Errorf("Cached password check is wrong")
+	}
+	if err := SetPassword(""); err != nil {
+		t.Fatalf("Error removing password: %v\n", err)
+	}
+	if CheckPassword(config.Snapshot().Auth, password) {
+		t.Errorf("Removed password still verifies")
+	}
+}
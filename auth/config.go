@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"ledfx/config"
+	"strings"
+	"time"
+)
+
+// AddToken creates a token and stores its hash. The returned secret is
+// the only copy and can't be recovered later.
+func AddToken(name string, scope Scope) (token config.ApiToken, secret string, err error) {
+	if name == "" {
+		return token, "", errors.New("token name is empty")
+	}
+	if _, err := ParseScope(string(scope)); err != nil {
+		return token, "", err
+	}
+	if secret, err = NewSecret(); err != nil {
+		return token, "", err
+	}
+	hash := HashToken(secret)
+	token = config.ApiToken{
+		Id:      strings.TrimPrefix(hash, hashPrefix)[:12],
+		Name:    name,
+		Scope:   string(scope),
+		Hash:    hash,
+		Created: time.Now().Unix(),
+	}
+	err = config.Update(func(c *config.Config) error {
+		c.Auth.Tokens = append(c.Auth.Tokens, token)
+		return nil
+	})
+	return token, secret, err
+}
+
+// RemoveToken revokes the token with the given id
+func RemoveToken(id string) error {
+	return config.Update(func(c *config.Config) error {
+		for index, token := range c.Auth.Tokens {
+			if token.Id == id {
+				c.Auth.Tokens = append(c.Auth.Tokens[:index], c.Auth.Tokens[index+1:]...)
+				return nil
+			}
+		}
+		return fmt.Errorf("token '%s' does not exist", id)
+	})
+}
+
+// SetPassword sets the password, or removes it when password is empty
+func SetPassword(password string) error {
+	hash := ""
+	if password != "" {
+		var err error
+		if hash, err = HashPassword(password); err != nil {
+			return err
+		}
+	}
+	return config.Update(func(c *config.Config) error {
+		c.Auth.PasswordHash = hash
+		return nil
+	})
+}
+
+// SetAllowedOrigins replaces the websocket and CORS origin allow-list
+func SetAllowedOrigins(origins []string) error {
+	return config.Update(func(c *config.Config) error {
+		c.Auth.AllowedOrigins = origins
+		return nil
+	})
+}
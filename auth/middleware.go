@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"ledfx/config"
+	log "ledfx/logger"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type contextKey struct{}
+
+// FromContext returns the scope the request was authenticated with. It is
+// empty when authentication is off.
+func FromContext(ctx context.Context) Scope {
+	scope, _ := ctx.Value(contextKey{}).(Scope)
+	return scope
+}
+
+// protected reports whether a path is part of the API
+func protected(path string) bool {
+	return strings.HasPrefix(path, "/api/") || path == "/api" || path == "/ws"
+}
+
+// bridgeControl reports whether a request changes bridge inputs or outputs.
+// These start network services and downloads, so they are never open to the
+// network, even when authentication is off.
+func bridgeControl(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/api/bridge/") && !isSafe(r.Method)
+}
+
+// authControl reports whether a request changes credentials or origins.
+// Without authentication anyone could otherwise lock the owner out or mint
+// themselves a token, so these are only open to this machine until then.
+func authControl(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/api/auth/") && !isSafe(r.Method)
+}
+
+// Middleware refuses API requests without a credential for their method once
+// authentication is enabled. The frontend files stay public. Requests that
+// change anything are refused from foreign origins, so web pages can't make
+// a browser on the LAN change settings.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !protected(r.URL.Path) || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		conf := config.Snapshot().Auth
+		if !isSafe(r.Method) && !originAccepted(r, conf) {
+			writeError(w, http.StatusForbidden, "origin '"+r.Header.Get("Origin")+"' is not allowed to change settings")
+			return
+		}
+		if !Enabled(conf) {
+			switch {
+			case bridgeControl(r) && !isLoopback(r):
+				writeError(w, http.StatusForbidden, "bridge control from the network requires an API token, create one with POST /api/auth/tokens")
+			case authControl(r) && !isLoopback(r):
+				writeError(w, http.StatusForbidden, "authentication can only be set up from this machine until it is enabled")
+			default:
+				next.ServeHTTP(w, r)
+			}
+			return
+		}
+
+		scope, ok := Authenticate(r, conf)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="LedFx"`)
+			writeError(w, http.StatusUnauthorized, "a valid API token or password is required")
+			return
+		}
+		if !scope.Allows(r.Method) {
+			writeError(w, http.StatusForbidden, "scope '"+string(scope)+"' does not allow "+r.Method)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, scope)))
+	})
+}
+
+// CheckOrigin is a websocket.Upgrader CheckOrigin function. It accepts
+// requests without an Origin header (non-browser clients), same origin
+// requests and origins in the configured allow-list, where "*" allows all.
+func CheckOrigin(r *http.Request) bool {
+	if originAccepted(r, config.Snapshot().Auth) {
+		return true
+	}
+	log.Logger.WithField("category", "Auth").Warnf("Refused websocket from origin %s", r.Header.Get("Origin"))
+	return false
+}
+
+// originAccepted reports whether r has no Origin header, comes from the same
+// origin or from one in the allow-list
+func originAccepted(r *http.Request, conf config.AuthConfig) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host) || OriginAllowed(conf, origin)
+}
+
+// OriginAllowed reports whether origin is in the allow-list
+func OriginAllowed(conf config.AuthConfig, origin string) bool {
+	origin = strings.TrimRight(origin, "/")
+	for _, allowed := range conf.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(strings.TrimRight(allowed, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func isLoopback(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": "error", "error": msg})
+}
@@ -0,0 +1,88 @@
+package bridgeapi
+
+import (
+	"fmt"
+	"ledfx/api/openapi"
+	"ledfx/audio/audiobridge"
+	"ledfx/audio/audiobridge/file"
+	"ledfx/audio/audiobridge/recorder"
+	"net/http"
+)
+
+type route struct {
+	op      openapi.Operation
+	handler func(s *Server) http.HandlerFunc
+}
+
+// routes is the single source for both the mux registrations and the
+// OpenAPI operations of the bridge API
+var routes = []route{
+	// Input setters
+	{openapi.Operation{Method: http.MethodPost, Path: "/api/bridge/set/input/airplay", Id: "setInputAirPlay", Summary: "Use an AirPlay server as the bridge input", Request: audiobridge.AirPlayInputJSON{}}, func(s *Server) http.HandlerFunc { return s.handleSetInputAirPlay }},
+	{openapi.Operation{Method: http.MethodPost, Path: "/api/bridge/set/input/youtube", Id: "setInputYouTube", Summary: "Use YouTube as the bridge input", Request: audiobridge.YouTubeInputJSON{}}, func(s *Server) http.HandlerFunc { return s.handleSetInputYouTube }},
+	{openapi.Operation{Method: http.MethodPost, Path: "/api/bridge/set/input/file", Id: "setInputFile", Summary: "Use local audio files and streams as the bridge input", Request: audiobridge.FileInputJSON{}}, func(s *Server) http.HandlerFunc { return s.handleSetInputFile }},
+	{openapi.Operation{Method: http.MethodPost, Path: "/api/bridge/set/input/synth", Id: "setInputSynth", Summary: "Use a generated test signal as the bridge input", Request: audiobridge.SynthInputJSON{}}, func(s *Server) http.HandlerFunc { return s.handleSetInputSynth }},
+	{openapi.Operation{Method: http.MethodPost, Path: "/api/bridge/set/input/capture", Id: "setInputCapture", Summary: "Use a local capture device as the bridge input", Request: audiobridge.LocalInputJSON{}}, func(s *Server) http.HandlerFunc { return s.handleSetInputCapture }},
+
+	// Output adders
+	{openapi.Operation{Method: http.MethodPost, Path: "/api/bridge/add/output/airplay", Id: "addOutputAirPlay", Summary: "Add an AirPlay output", Request: audiobridge.AirPlayOutputJSON{}}, func(s *Server) http.HandlerFunc { return s.handleAddOutputAirPlay }},
+	{openapi.Operation{Method: http.MethodPost, Path: "/api/bridge/add/output/local", Id: "addOutputLocal", Summary: "Add a local playback output", Request: audiobridge.LocalOutputJSON{}}, func(s *Server) http.HandlerFunc { return s.handleAddOutputLocal }},
+	{openapi.Operation{Method: http.MethodPost, Path: "/api/bridge/add/output/recorder", Id: "addOutputRecorder", Summary: "Add an output that records to WAV or FLAC files", Request: audiobridge.RecorderOutputJSON{}}, func(s *Server) http.HandlerFunc { return s.handleAddOutputRecorder }},
+
+	// Ctl
+	{openapi.Operation{Method: http.MethodPost, Path: "/api/bridge/ctl/youtube/set", Id: "ctlYouTubeSet", Summary: "Control YouTube playback", Request: audiobridge.YouTubeCTLJSON{}}, func(s *Server) http.HandlerFunc { return s.handleCtlYouTube }},
+	{openapi.Operation{Method: http.MethodGet, Path: "/api/bridge/ctl/youtube/info", Id: "ctlYouTubeInfo", Summary: "Get YouTube playback info", Response: audiobridge.YouTubeInfo{}}, func(s *Server) http.HandlerFunc { return s.handleCtlYouTubeGetInfo }},
+	{openapi.Operation{Method: http.MethodPost, Path: "/api/bridge/ctl/file/set", Id: "ctlFileSet", Summary: "Control file playback", Request: audiobridge.FileCTLJSON{}}, func(s *Server) http.HandlerFunc { return s.handleCtlFile }},
+	{openapi.Operation{Method: http.MethodGet, Path: "/api/bridge/ctl/file/info", Id: "ctlFileInfo", Summary: "Get file playback info", Response: file.Info{}}, func(s *Server) http.HandlerFunc { return s.handleCtlFileGetInfo }},
+	{openapi.Operation{Method: http.MethodPost, Path: "/api/bridge/ctl/recorder/set", Id: "ctlRecorderSet", Summary: "Start or stop recording", Request: audiobridge.RecorderCTLJSON{}}, func(s *Server) http.HandlerFunc { return s.handleCtlRecorder }},
+	{openapi.Operation{Method: http.MethodGet, Path: "/api/bridge/ctl/recorder/info", Id: "ctlRecorderInfo", Summary: "Get the recording state and files", Response: recorder.Info{}}, func(s *Server) http.HandlerFunc { return s.handleCtlRecorderGetInfo }},
+	{openapi.Operation{Method: http.MethodPost, Path: "/api/bridge/ctl/mixer/set", Id: "ctlMixerSet", Summary: "Set the gain, mute and priority of a mixer input", Request: audiobridge.MixerCTLJSON{}}, func(s *Server) http.HandlerFunc { return s.handleCtlMixer }},
+	{openapi.Operation{Method: http.MethodPost, Path: "/api/bridge/ctl/mixer/remove", Id: "ctlMixerRemove", Summary: "Stop an input and remove it from the mixer", Request: audiobridge.MixerRemoveJSON{}}, func(s *Server) http.HandlerFunc { return s.handleCtlMixerRemove }},
+	{openapi.Operation{Method: http.MethodGet, Path: "/api/bridge/ctl/mixer/info", Id: "ctlMixerInfo", Summary: "Get the mixer inputs and levels", Response: audiobridge.MixerInfo{}}, func(s *Server) http.HandlerFunc { return s.handleCtlMixerGetInfo }},
+	{openapi.Operation{Method: http.MethodPost, Path: "/api/bridge/ctl/output/set", Id: "ctlOutputSet", Summary: "Set the volume and mute of an output", Request: audiobridge.OutputCTLJSON{}}, func(s *Server) http.HandlerFunc { return s.handleCtlOutput }},
+	{openapi.Operation{Method: http.MethodPost, Path: "/api/bridge/ctl/output/remove", Id: "ctlOutputRemove", Summary: "Stop and remove an output", Request: audiobridge.OutputRemoveJSON{}}, func(s *Server) http.HandlerFunc { return s.handleCtlOutputRemove }},
+	{openapi.Operation{Method: http.MethodGet, Path: "/api/bridge/ctl/output/info", Id: "ctlOutputInfo", Summary: "List the outputs", Response: audiobridge.OutputList{}}, func(s *Server) http.HandlerFunc { return s.handleCtlOutputGetInfo }},
+	{openapi.Operation{Method: http.MethodPost, Path: "/api/bridge/ctl/delay/set", Id: "ctlDelaySet", Summary: "Set the delay of an output or sync them to AirPlay", Request: audiobridge.DelayCTLJSON{}}, func(s *Server) http.HandlerFunc { return s.handleCtlDelay }},
+	{openapi.Operation{Method: http.MethodGet, Path: "/api/bridge/ctl/delay/info", Id: "ctlDelayInfo", Summary: "Get the delay and latency of every output", Response: audiobridge.DelayInfoList{}}, func(s *Server) http.HandlerFunc { return s.handleCtlDelayGetInfo }},
+	{openapi.Operation{Method: http.MethodPost, Path: "/api/bridge/ctl/airplay/set", Id: "ctlAirPlaySet", Summary: "Control the AirPlay server", Request: audiobridge.AirPlayJsonCtlSet{}}, func(s *Server) http.HandlerFunc { return s.handleCtlAirPlaySet }},
+	{openapi.Operation{Method: http.MethodGet, Path: "/api/bridge/ctl/airplay/clients", Id: "ctlAirPlayClients", Summary: "List AirPlay clients", Response: audiobridge.ClientList{}}, func(s *Server) http.HandlerFunc { return s.handleCtlAirPlayGetClients }},
+	{openapi.Operation{Method: http.MethodGet, Path: "/api/bridge/ctl/airplay/info", Id: "ctlAirPlayInfo", Summary: "Get AirPlay info (not implemented yet)", Status: http.StatusServiceUnavailable}, func(s *Server) http.HandlerFunc { return s.handleCtlAirPlayGetInfo }},
+
+	// StatPoller
+	{openapi.Operation{Method: http.MethodGet, Path: "/api/bridge/statpoll/ws", Id: "statPollWebsocket", Summary: "Open a statpoll websocket", Status: http.StatusSwitchingProtocols}, func(s *Server) http.HandlerFunc { return s.handleStatPollInitWs }},
+
+	// Misc
+	{openapi.Operation{Method: http.MethodGet, Path: ArtworkURLPath, Id: "getArtwork", Summary: "Get the artwork of the current track", Response: []byte{}, ContentType: "image/png"}, func(s *Server) http.HandlerFunc { return s.handleArtwork }},
+}
+
+// Operations describes every bridge route for the OpenAPI document
+func Operations() []openapi.Operation {
+	ops := make([]openapi.Operation, 0, len(routes))
+	for _, r := range routes {
+		op := r.op
+		op.Tag = "bridge"
+		// Bridge errors are written as plain text
+		op.Error = ""
+		ops = append(ops, op)
+	}
+	return ops
+}
+
+// register adds every route to mux, rejecting methods the route doesn't document
+func (s *Server) register(mux *http.ServeMux) {
+	for _, r := range routes {
+		mux.HandleFunc(r.op.Path, allowMethod(r.op.Method, r.handler(s)))
+	}
+}
+
+func allowMethod(method string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			w.Write([]byte(fmt.Sprintf("method '%s' is not allowed", r.Method)))
+			return
+		}
+		next(w, r)
+	}
+}
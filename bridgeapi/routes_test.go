@@ -0,0 +1,31 @@
+package bridgeapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestRoutesDocumented checks that every documented route is registered and
+// refuses the methods it doesn't document, without starting a bridge
+func TestRoutesDocumented(t *testing.T) {
+	mux := http.NewServeMux()
+	(&Server{}).register(mux)
+
+	for _, op := range Operations() {
+		req := httptest.NewRequest(op.Method, op.Path, nil)
+		if _, pattern := mux.Handler(req); pattern != op.Path {
+			t.Errorf("%s %s is documented but not registered", op.Method, op.Path)
+		}
+		for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+			if method == op.Method {
+				continue
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(method, op.Path, nil))
+			if rec.Code != http.StatusMethodNotAllowed {
+				t.Errorf("%s %s is undocumented but answered with %d", method, op.Path, rec.Code)
+			}
+		}
+	}
+}
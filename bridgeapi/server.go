@@ -8,6 +8,7 @@ import (
 	"io/ioutil"
 	"ledfx/audio"
 	"ledfx/audio/audiobridge"
+	"ledfx/auth"
 	"ledfx/bridgeapi/statpoll"
 	log "ledfx/logger"
 	"net/http"
@@ -25,45 +26,30 @@ type Server struct {
 	upgrader   *websocket.Upgrader
 }
 
-func NewServer(callback func(buf audio.Buffer), mux *http.ServeMux) (err error) {
-	s := &Server{
+func NewServer(callback func(buf audio.Buffer), mux *http.ServeMux) (s *Server, err error) {
+	s = &Server{
 		mux: mux,
 		upgrader: &websocket.Upgrader{
 			ReadBufferSize:  1024,
 			WriteBufferSize: 1024,
 			WriteBufferPool: &sync.Pool{},
+			CheckOrigin:     auth.CheckOrigin,
 		},
 	}
 
 	if s.br, err = audiobridge.NewBridge(callback); err != nil {
-		return fmt.Errorf("error initializing new bridge: %w", err)
+		return nil, fmt.Errorf("error initializing new bridge: %w", err)
 	}
 
 	s.statPoller = statpoll.New(s.br)
 
-	// Input setter handlers
-	s.mux.HandleFunc("/api/bridge/set/input/airplay", s.handleSetInputAirPlay)
-	s.mux.HandleFunc("/api/bridge/set/input/youtube", s.handleSetInputYouTube)
-	s.mux.HandleFunc("/api/bridge/set/input/capture", s.handleSetInputCapture)
-
-	// Output adder handlers
-	s.mux.HandleFunc("/api/bridge/add/output/airplay", s.handleAddOutputAirPlay)
-	s.mux.HandleFunc("/api/bridge/add/output/local", s.handleAddOutputLocal)
-
-	// Ctl handlers
-	s.mux.HandleFunc("/api/bridge/ctl/youtube/set", s.handleCtlYouTube)
-	s.mux.HandleFunc("/api/bridge/ctl/youtube/info", s.handleCtlYouTubeGetInfo)
-
-	s.mux.HandleFunc("/api/bridge/ctl/airplay/set", s.handleCtlAirPlaySet)
-	s.mux.HandleFunc("/api/bridge/ctl/airplay/clients", s.handleCtlAirPlayGetClients)
-	s.mux.HandleFunc("/api/bridge/ctl/airplay/info", s.handleCtlAirPlayGetInfo)
-
-	// StatPoller handler
-	s.mux.HandleFunc("/api/bridge/statpoll/ws", s.handleStatPollInitWs)
+	s.register(s.mux)
+	return s, nil
+}
 
-	// Artwork handler
-	s.mux.HandleFunc(ArtworkURLPath, s.handleArtwork)
-	return nil
+// Stop stops the audio bridge and its inputs and outputs
+func (s *Server) Stop() {
+	s.br.Stop()
 }
 
 func (s *Server) handleStatPollInitWs(w http.ResponseWriter, r *http.Request) {
@@ -116,12 +102,6 @@ func (s *Server) handleAddOutputAirPlay(w http.ResponseWriter, r *http.Request)
 	w.WriteHeader(http.StatusOK)
 }
 func (s *Server) handleCtlAirPlaySet(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		w.Write([]byte(fmt.Sprintf("method '%s' is not allowed", r.Method)))
-		return
-	}
-
 	log.Logger.Infoln("Got AirPlay SET CTL request...")
 	bodyBytes, err := ioutil.ReadAll(r.Body)
 	if err != nil {
@@ -141,11 +121,6 @@ func (s *Server) handleCtlAirPlaySet(w http.ResponseWriter, r *http.Request) {
 }
 func (s *Server) handleCtlAirPlayGetClients(w http.ResponseWriter, r *http.Request) {
 	log.Logger.Infoln("Got AirPlay GET CTL request...")
-	if r.Method != http.MethodGet {
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		w.Write([]byte(fmt.Sprintf("method '%s' is not allowed", r.Method)))
-		return
-	}
 
 	clientBytes, err := s.br.JSONWrapper().CTL().AirPlayGetClients()
 	if err != nil {
@@ -212,6 +187,199 @@ func (s *Server) handleCtlYouTubeGetInfo(w http.ResponseWriter, r *http.Request)
 
 // ############### END YOUTUBE ###############
 
+// ############## BEGIN FILE ##############
+func (s *Server) handleSetInputFile(w http.ResponseWriter, r *http.Request) {
+	bodyBytes, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Logger.Errorf("Error reading request body: %v", err)
+		w.Write(errToBytes(err))
+		return
+	}
+	log.Logger.Infoln("Setting input source to files...")
+	if err := s.br.JSONWrapper().StartFileInput(bodyBytes); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Logger.Errorf("Error starting file input: %v", err)
+		w.Write(errToBytes(err))
+		return
+	}
+}
+
+func (s *Server) handleCtlFile(w http.ResponseWriter, r *http.Request) {
+	bodyBytes, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Logger.Errorf("Error reading request body: %v", err)
+		w.Write(errToBytes(err))
+		return
+	}
+	if err := s.br.JSONWrapper().CTL().FileSet(bodyBytes); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Logger.Errorf("Error running FileSet CTL action: %v", err)
+		w.Write(errToBytes(err))
+		return
+	}
+}
+
+func (s *Server) handleCtlFileGetInfo(w http.ResponseWriter, r *http.Request) {
+	ret, err := s.br.JSONWrapper().CTL().FileGetInfo()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Logger.Errorf("Error running FileGet CTL action: %v", err)
+		w.Write(errToBytes(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(ret)
+}
+
+// ############### END FILE ###############
+
+// ############## BEGIN SYNTH ##############
+func (s *Server) handleSetInputSynth(w http.ResponseWriter, r *http.Request) {
+	bodyBytes, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Logger.Errorf("Error reading request body: %v", err)
+		w.Write(errToBytes(err))
+		return
+	}
+	log.Logger.Infoln("Setting input source to a generated signal...")
+	if err := s.br.JSONWrapper().StartSynthInput(bodyBytes); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Logger.Errorf("Error starting synth input: %v", err)
+		w.Write(errToBytes(err))
+		return
+	}
+}
+
+// ############### END SYNTH ###############
+
+// ############## BEGIN MIXER ##############
+func (s *Server) handleCtlMixer(w http.ResponseWriter, r *http.Request) {
+	bodyBytes, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Logger.Errorf("Error reading request body: %v", err)
+		w.Write(errToBytes(err))
+		return
+	}
+	if err := s.br.JSONWrapper().CTL().MixerSet(bodyBytes); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Logger.Errorf("Error running MixerSet CTL action: %v", err)
+		w.Write(errToBytes(err))
+		return
+	}
+}
+
+func (s *Server) handleCtlMixerRemove(w http.ResponseWriter, r *http.Request) {
+	bodyBytes, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Logger.Errorf("Error reading request body: %v", err)
+		w.Write(errToBytes(err))
+		return
+	}
+	if err := s.br.JSONWrapper().CTL().MixerRemove(bodyBytes); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Logger.Errorf("Error running MixerRemove CTL action: %v", err)
+		w.Write(errToBytes(err))
+		return
+	}
+}
+
+func (s *Server) handleCtlMixerGetInfo(w http.ResponseWriter, r *http.Request) {
+	ret, err := s.br.JSONWrapper().CTL().MixerGetInfo()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Logger.Errorf("Error running MixerGet CTL action: %v", err)
+		w.Write(errToBytes(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(ret)
+}
+
+// ############### END MIXER ###############
+
+// ############## BEGIN OUTPUT ##############
+func (s *Server) handleCtlOutput(w http.ResponseWriter, r *http.Request) {
+	bodyBytes, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Logger.Errorf("Error reading request body: %v", err)
+		w.Write(errToBytes(err))
+		return
+	}
+	if err := s.br.JSONWrapper().CTL().OutputSet(bodyBytes); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Logger.Errorf("Error running OutputSet CTL action: %v", err)
+		w.Write(errToBytes(err))
+		return
+	}
+}
+
+func (s *Server) handleCtlOutputRemove(w http.ResponseWriter, r *http.Request) {
+	bodyBytes, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Logger.Errorf("Error reading request body: %v", err)
+		w.Write(errToBytes(err))
+		return
+	}
+	if err := s.br.JSONWrapper().CTL().OutputRemove(bodyBytes); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Logger.Errorf("Error running OutputRemove CTL action: %v", err)
+		w.Write(errToBytes(err))
+		return
+	}
+}
+
+func (s *Server) handleCtlOutputGetInfo(w http.ResponseWriter, r *http.Request) {
+	ret, err := s.br.JSONWrapper().CTL().OutputGetInfo()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Logger.Errorf("Error running OutputGet CTL action: %v", err)
+		w.Write(errToBytes(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(ret)
+}
+
+// ############### END OUTPUT ###############
+
+// ############## BEGIN DELAY ##############
+func (s *Server) handleCtlDelay(w http.ResponseWriter, r *http.Request) {
+	bodyBytes, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Logger.Errorf("Error reading request body: %v", err)
+		w.Write(errToBytes(err))
+		return
+	}
+	if err := s.br.JSONWrapper().CTL().DelaySet(bodyBytes); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Logger.Errorf("Error running DelaySet CTL action: %v", err)
+		w.Write(errToBytes(err))
+		return
+	}
+}
+
+func (s *Server) handleCtlDelayGetInfo(w http.ResponseWriter, r *http.Request) {
+	ret, err := s.br.JSONWrapper().CTL().DelayGetInfo()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Logger.Errorf("Error running DelayGet CTL action: %v", err)
+		w.Write(errToBytes(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(ret)
+}
+
+// ############### END DELAY ###############
+
 // ############## BEGIN LOCAL ##############
 func (s *Server) handleSetInputCapture(w http.ResponseWriter, r *http.Request) {
 	bodyBytes, err := ioutil.ReadAll(r.Body)
@@ -249,6 +417,55 @@ func (s *Server) handleAddOutputLocal(w http.ResponseWriter, r *http.Request) {
 
 // ############### END LOCAL ###############
 
+// ############## BEGIN RECORDER ##############
+func (s *Server) handleAddOutputRecorder(w http.ResponseWriter, r *http.Request) {
+	bodyBytes, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Logger.Errorf("Error reading request body: %v", err)
+		w.Write(errToBytes(err))
+		return
+	}
+	log.Logger.Infoln("Adding recorder output...")
+	if err := s.br.JSONWrapper().AddRecorderOutput(bodyBytes); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Logger.Errorf("Error adding recorder output: %v", err)
+		w.Write(errToBytes(err))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handleCtlRecorder(w http.ResponseWriter, r *http.Request) {
+	bodyBytes, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Logger.Errorf("Error reading request body: %v", err)
+		w.Write(errToBytes(err))
+		return
+	}
+	if err := s.br.JSONWrapper().CTL().RecorderSet(bodyBytes); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Logger.Errorf("Error running RecorderSet CTL action: %v", err)
+		w.Write(errToBytes(err))
+		return
+	}
+}
+
+func (s *Server) handleCtlRecorderGetInfo(w http.ResponseWriter, r *http.Request) {
+	ret, err := s.br.JSONWrapper().CTL().RecorderGetInfo()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Logger.Errorf("Error running RecorderGet CTL action: %v", err)
+		w.Write(errToBytes(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(ret)
+}
+
+// ############### END RECORDER ###############
+
 // ############## BEGIN MISC ##############
 func (s *Server) handleArtwork(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("content-type", "image/png")
@@ -11,4 +11,12 @@ const (
 
 	RqtAirPlayInfo     ReqType = "airplay_info"
 	RqtStopAirPlayInfo ReqType = "stop_airplay_info"
+
+	RqtMixerInfo     ReqType = "mixer_info"
+	RqtStopMixerInfo ReqType = "stop_mixer_info"
+
+	// RqtOutputEvents sends a change to the outputs as it happens, rather
+	// than every interval
+	RqtOutputEvents     ReqType = "output_events"
+	RqtStopOutputEvents ReqType = "stop_output_events"
 )
@@ -3,7 +3,9 @@ package statpoll
 import (
 	"github.com/gorilla/websocket"
 	"ledfx/audio/audiobridge"
+	"ledfx/audio/audiobridge/mixer"
 	"ledfx/audio/audiobridge/youtube"
+	"ledfx/audio/pcm"
 	"ledfx/integrations/airplay2"
 	log "ledfx/logger"
 	"time"
@@ -18,6 +20,7 @@ type Response struct {
 // valueBridgeInfo contains all information on audiobridge.Bridge
 type valueBridgeInfo struct {
 	InputType string                    `json:"input_type"`
+	Format    pcm.Format                `json:"format"`
 	Outputs   []*audiobridge.OutputInfo `json:"outputs"`
 }
 
@@ -40,6 +43,7 @@ func (s *StatPoller) sendBridgeInfo(n int, interval time.Duration, ws *websocket
 			Iteration: i,
 			Value: &valueBridgeInfo{
 				InputType: info.InputType(),
+				Format:    info.Format(),
 				Outputs:   info.AllOutputs(),
 			},
 		}
@@ -194,3 +198,96 @@ func (s *StatPoller) stopAirPlayInfo() {
 		s.stopSendAirPlayInfo.Store(true)
 	}
 }
+
+// valueMixerInfo contains the level meters of the mixer and its inputs
+type valueMixerInfo struct {
+	Level  mixer.Level       `json:"level"`
+	Inputs []mixer.InputInfo `json:"inputs"`
+}
+
+func (s *StatPoller) sendMixerInfo(n int, interval time.Duration, ws *websocket.Conn) {
+	if s.sendingMixerInfo.Load() {
+		s.stopMixerInfo()
+		time.Sleep(interval)
+	}
+
+	s.sendingMixerInfo.Store(true)
+	defer s.sendingMixerInfo.Store(false)
+
+	info := s.br.Info()
+	for i := 0; i != n; i++ {
+		now := time.Now()
+
+		resp := &Response{
+			Type:      RqtMixerInfo,
+			Iteration: i,
+			Value: &valueMixerInfo{
+				Level:  info.Level(),
+				Inputs: info.Inputs(),
+			},
+		}
+
+		if s.stopSendMixerInfo.Load() {
+			s.stopSendMixerInfo.Store(false)
+			return
+		}
+		if err := ws.WriteJSON(resp); err != nil {
+			log.Logger.WithField("category", "StatPoll MixerInfo").Errorf("Error writing JSON over websocket: %v", err)
+			return
+		}
+		time.Sleep(interval - time.Since(now))
+	}
+}
+
+func (s *StatPoller) stopMixerInfo() {
+	if s.sendingMixerInfo.Load() {
+		s.stopSendMixerInfo.Store(true)
+	}
+}
+
+// sendOutputEvents sends n changes to the outputs as they happen. The stop
+// request is checked every interval while no change happens.
+func (s *StatPoller) sendOutputEvents(n int, interval time.Duration, ws *websocket.Conn) {
+	if interval <= 0 {
+		interval = 250 * time.Millisecond
+	}
+	if s.sendingOutputEvents.Load() {
+		s.stopOutputEvents()
+		time.Sleep(interval)
+	}
+
+	s.sendingOutputEvents.Store(true)
+	defer s.sendingOutputEvents.Store(false)
+
+	events, cancel := s.br.SubscribeOutputs()
+	defer cancel()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for i := 0; i != n; {
+		select {
+		case e := <-events:
+			resp := &Response{
+				Type:      RqtOutputEvents,
+				Iteration: i,
+				Value:     e,
+			}
+			if err := ws.WriteJSON(resp); err != nil {
+				log.Logger.WithField("category", "StatPoll OutputEvents").Errorf("Error writing JSON over websocket: %v", err)
+				return
+			}
+			i++
+		case <-ticker.C:
+		}
+
+		if s.stopSendOutputEvents.Load() {
+			s.stopSendOutputEvents.Store(false)
+			return
+		}
+	}
+}
+
+func (s *StatPoller) stopOutputEvents() {
+	if s.sendingOutputEvents.Load() {
+		s.stopSendOutputEvents.Store(true)
+	}
+}
@@ -23,6 +23,12 @@ type StatPoller struct {
 
 	sendingAirPlayInfo  *atomic.Bool
 	stopSendAirPlayInfo *atomic.Bool
+
+	sendingMixerInfo  *atomic.Bool
+	stopSendMixerInfo *atomic.Bool
+
+	sendingOutputEvents  *atomic.Bool
+	stopSendOutputEvents *atomic.Bool
 }
 
 func New(br *audiobridge.Bridge) (s *StatPoller) {
@@ -34,6 +40,11 @@ func New(br *audiobridge.Bridge) (s *StatPoller) {
 		stopSendYoutubeInfo: atomic.NewBool(false),
 		sendingAirPlayInfo:  atomic.NewBool(false),
 		stopSendAirPlayInfo: atomic.NewBool(false),
+		sendingMixerInfo:    atomic.NewBool(false),
+		stopSendMixerInfo:   atomic.NewBool(false),
+
+		sendingOutputEvents:  atomic.NewBool(false),
+		stopSendOutputEvents: atomic.NewBool(false),
 	}
 }
 
@@ -103,6 +114,14 @@ Check:
 		go s.sendAirPlayInfo(r.Iterations, time.Duration(r.IntervalMs)*time.Millisecond, ws)
 	case RqtStopAirPlayInfo:
 		go s.stopAirPlayInfo()
+	case RqtMixerInfo:
+		go s.sendMixerInfo(r.Iterations, time.Duration(r.IntervalMs)*time.Millisecond, ws)
+	case RqtStopMixerInfo:
+		go s.stopMixerInfo()
+	case RqtOutputEvents:
+		go s.sendOutputEvents(r.Iterations, time.Duration(r.IntervalMs)*time.Millisecond, ws)
+	case RqtStopOutputEvents:
+		go s.stopOutputEvents()
 	default:
 		return fmt.Errorf("unknown request type '%s'", r.Type)
 	}
@@ -3,7 +3,9 @@ package color
 
 import (
 	"errors"
+	"fmt"
 	"image/color"
+	"math"
 	"strconv"
 	"strings"
 )
@@ -19,44 +21,168 @@ type Color [3]float64
 var errInvalidColor = errors.New("invalid color")
 
 // NewColor Parses string to ledfx color. "#ff00ff" / "rgb(255,0,255)" / "red" -> [1., 0., 1.]
+// Names of user colors are resolved too. Also understood are "#f0f",
+// "#ff00ff80" and "rgba(255, 0, 255, 0.5)", where alpha darkens the color as
+// if it were drawn over black, "hsv(300, 100%, 100%)", "hsl(300, 100%, 50%)"
+// and color temperatures like "3000K".
 func NewColor(c string) (col Color, err error) {
-	c = strings.ToLower(c)
+	if user, ok := userColor(c); ok {
+		c = user
+	}
+	c = strings.ToLower(strings.TrimSpace(c))
 	predef, isPredef := LedFxColors[c]
 	switch {
 	case isPredef: // Color is predefined
 		col, err = parseHex(predef)
-	case c[0:4] == "rgb(": // "rgb(0, 127, 255)"
-		col, err = parseRGB(c)
-	case c[0:1] == "#": // "#0088ff"
+	case strings.HasPrefix(c, "#"): // "#0088ff"
 		col, err = parseHex(c)
+	case strings.HasPrefix(c, "rgb(") || strings.HasPrefix(c, "rgba("): // "rgb(0, 127, 255)"
+		col, err = parseRGB(c)
+	case strings.HasPrefix(c, "hsv(") || strings.HasPrefix(c, "hsl("): // "hsv(210, 100%, 100%)"
+		col, err = parseHue(c)
+	case strings.HasSuffix(c, "k"): // "3000k"
+		col, err = parseKelvin(c)
 	default:
 		err = errInvalidColor
 	}
 	if err != nil {
 		col = Color{}
+		if !errors.Is(err, errInvalidColor) {
+			err = fmt.Errorf("%w '%s': %v", errInvalidColor, c, err)
+		}
 	}
 	return col, err
 }
 
+// parseArgs splits "name(a, b, c)" into its arguments
+func parseArgs(c string) (name string, args []string, err error) {
+	open := strings.IndexByte(c, '(')
+	if open < 0 || !strings.HasSuffix(c, ")") {
+		return "", nil, errInvalidColor
+	}
+	args = strings.Split(c[open+1:len(c)-1], ",")
+	for i := range args {
+		args[i] = strings.TrimSpace(args[i])
+	}
+	return c[:open], args, nil
+}
+
+// parseFraction parses "50%" or a number up to max to a value in 0-1
+func parseFraction(s string, max float64) (float64, error) {
+	if strings.HasSuffix(s, "%") {
+		s, max = strings.TrimSuffix(s, "%"), 100
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("'%s' is not a number", s)
+	}
+	if !(f >= 0 && f <= max) { // also catches NaN
+		return 0, fmt.Errorf("%s is out of range 0-%g", s, max)
+	}
+	return f / max, nil
+}
+
 func parseRGB(c string) (col Color, err error) {
-	c = strings.ReplaceAll(c, " ", "")
-	c = strings.TrimLeft(c, "rgb(")
-	c = strings.TrimRight(c, ")")
-	for i, val := range strings.Split(c, ",") {
-		col[i], err = strconv.ParseFloat(val, 64)
-		col[i] /= 255
-		if col[i] < 0 || col[i] > 1 {
-			err = errInvalidColor
+	name, args, err := parseArgs(c)
+	if err != nil {
+		return col, err
+	}
+	n := 3
+	if name == "rgba" {
+		n = 4
+	}
+	if len(args) != n {
+		return col, fmt.Errorf("%s takes %d values, got %d", name, n, len(args))
+	}
+	for i := range col {
+		if col[i], err = parseFraction(args[i], 255); err != nil {
+			return col, err
 		}
 	}
-	return col, err
+	if n == 4 {
+		alpha, err := parseFraction(args[3], 1)
+		if err != nil {
+			return col, err
+		}
+		col = col.scale(alpha)
+	}
+	return col, nil
 }
 
-func parseHex(c string) (col Color, err error) {
-	if len(c) != 7 {
-		err = errInvalidColor
+// parseHue parses "hsv(h, s, v)" and "hsl(h, s, l)". The hue is in degrees,
+// saturation, value and lightness are percentages or fractions of 1.
+func parseHue(c string) (col Color, err error) {
+	name, args, err := parseArgs(c)
+	if err != nil {
 		return col, err
 	}
+	if len(args) != 3 {
+		return col, fmt.Errorf("%s takes 3 values, got %d", name, len(args))
+	}
+	h, err := strconv.ParseFloat(strings.TrimSuffix(args[0], "deg"), 64)
+	if err != nil || math.IsNaN(h) || math.IsInf(h, 0) {
+		return col, fmt.Errorf("'%s' is not a hue", args[0])
+	}
+	var sv [2]float64
+	for i := range sv {
+		if sv[i], err = parseFraction(args[i+1], 1); err != nil {
+			return col, err
+		}
+	}
+	if name == "hsl" {
+		return FromHSL(h, sv[0], sv[1]), nil
+	}
+	return FromHSV(h, sv[0], sv[1]), nil
+}
+
+const (
+	minKelvin = 1000
+	maxKelvin = 40000
+)
+
+// parseKelvin approximates the color of a black body at a temperature in
+// Kelvin, after Tanner Helland's fit of the CIE 1964 color matching data
+func parseKelvin(c string) (col Color, err error) {
+	k, err := strconv.ParseFloat(strings.TrimSuffix(c, "k"), 64)
+	if err != nil {
+		return col, errInvalidColor
+	}
+	if !(k >= minKelvin && k <= maxKelvin) {
+		return col, fmt.Errorf("temperature %gK is out of range %dK-%dK", k, minKelvin, maxKelvin)
+	}
+	t := k / 100
+	var r, g, b float64
+	if t <= 66 {
+		r = 255
+		g = 99.4708025861*math.Log(t) - 161.1195681661
+	} else {
+		r = 329.698727446 * math.Pow(t-60, -0.1332047592)
+		g = 288.1221695283 * math.Pow(t-60, -0.0755148492)
+	}
+	switch {
+	case t >= 66:
+		b = 255
+	case t <= 19:
+		b = 0
+	default:
+		b = 138.5177312231*math.Log(t-10) - 305.0447927307
+	}
+	for i, v := range []float64{r, g, b} {
+		col[i] = math.Min(math.Max(v, 0), 255) / 255
+	}
+	return col, nil
+}
+
+// parseHex parses "#rgb", "#rrggbb" and "#rrggbbaa"
+func parseHex(c string) (col Color, err error) {
+	digits := c[1:]
+	switch len(digits) {
+	case 3:
+		digits = string([]byte{digits[0], digits[0], digits[1], digits[1], digits[2], digits[2]})
+	case 6, 8:
+	default:
+		return col, errInvalidColor
+	}
 	hexToByte := func(b byte) byte {
 		switch {
 		case b >= '0' && b <= '9':
@@ -67,12 +193,110 @@ func parseHex(c string) (col Color, err error) {
 		err = errInvalidColor
 		return 0
 	}
-	col[0] = float64(hexToByte(c[1])<<4+hexToByte(c[2])) / 255
-	col[1] = float64(hexToByte(c[3])<<4+hexToByte(c[4])) / 255
-	col[2] = float64(hexToByte(c[5])<<4+hexToByte(c[6])) / 255
+	channel := func(i int) float64 {
+		return float64(hexToByte(digits[2*i])<<4+hexToByte(digits[2*i+1])) / 255
+	}
+	for i := range col {
+		col[i] = channel(i)
+	}
+	if len(digits) == 8 {
+		col = col.scale(channel(3))
+	}
 	return col, err
 }
 
+// scale darkens col by alpha
+func (col Color) scale(alpha float64) Color {
+	for i := range col {
+		col[i] *= alpha
+	}
+	return col
+}
+
+// HSV returns the hue of col in degrees 0-360 and its saturation and value in 0-1
+func (col Color) HSV() (h, s, v float64) {
+	max, min := col.maxMin()
+	if max > 0 {
+		s = (max - min) / max
+	}
+	return col.hue(), s, max
+}
+
+// HSL returns the hue of col in degrees 0-360 and its saturation and lightness in 0-1
+func (col Color) HSL() (h, s, l float64) {
+	max, min := col.maxMin()
+	l = (max + min) / 2
+	if d := max - min; d > 0 {
+		s = d / (1 - math.Abs(2*l-1))
+	}
+	return col.hue(), s, l
+}
+
+// FromHSV returns the color of a hue in degrees and saturation and value in 0-1
+func FromHSV(h, s, v float64) Color {
+	c := v * s
+	return fromHue(h, c, v-c)
+}
+
+// FromHSL returns the color of a hue in degrees and saturation and lightness in 0-1
+func FromHSL(h, s, l float64) Color {
+	c := (1 - math.Abs(2*l-1)) * s
+	return fromHue(h, c, l-c/2)
+}
+
+func (col Color) maxMin() (max, min float64) {
+	return math.Max(col[0], math.Max(col[1], col[2])), math.Min(col[0], math.Min(col[1], col[2]))
+}
+
+func (col Color) hue() (h float64) {
+	max, min := col.maxMin()
+	d := max - min
+	switch {
+	case d == 0:
+		return 0
+	case max == col[0]:
+		h = math.Mod((col[1]-col[2])/d, 6)
+	case max == col[1]:
+		h = (col[2]-col[0])/d + 2
+	default:
+		h = (col[0]-col[1])/d + 4
+	}
+	h *= 60
+	if h < 0 {
+		h += 360
+	}
+	return h
+}
+
+// fromHue builds a color from a hue in degrees, its chroma c and the amount
+// m added to every channel
+func fromHue(h, c, m float64) Color {
+	h = math.Mod(h, 360)
+	if h < 0 {
+		h += 360
+	}
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	var col Color
+	switch {
+	case h < 60:
+		col = Color{c, x, 0}
+	case h < 120:
+		col = Color{x, c, 0}
+	case h < 180:
+		col = Color{0, c, x}
+	case h < 240:
+		col = Color{0, x, c}
+	case h < 300:
+		col = Color{x, 0, c}
+	default:
+		col = Color{c, 0, x}
+	}
+	for i := range col {
+		col[i] += m
+	}
+	return col
+}
+
 func (col Color) NRGBA() color.NRGBA {
 	return color.NRGBA{
 		R: NormalizeFloat(col[0]),
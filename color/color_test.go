@@ -1,6 +1,7 @@
 package color
 
 import (
+	"math"
 	"testing"
 )
 
@@ -17,15 +18,32 @@ func TestNewColor(t *testing.T) {
 	}{
 		{"#ffFf00", Color{1, 1, 0}, false},
 		{"RGB(0,255, 0)", Color{0, 1, 0}, false},
-		{"#fF0", Color{0, 0, 0}, true},
+		{"#fF0", Color{1, 1, 0}, false},
+		{"#ff000080", Color{float64(0x80) / 255, 0, 0}, false},
+		{"rgba(0, 255, 0, 0.5)", Color{0, 0.5, 0}, false},
+		{"rgb(100%, 0, 50%)", Color{1, 0, 0.5}, false},
+		{"hsv(120, 100%, 50%)", Color{0, 0.5, 0}, false},
+		{"HSL(240deg, 1, 0.5)", Color{0, 0, 1}, false},
+		{"6600K", Color{1, 1, 1}, false},
 		{"rgb(-1,0,256)", Color{}, true},
+		{"rgb(0,0)", Color{}, true},
+		{"rgba(0,0,0)", Color{}, true},
+		{"rgb(0,0,0", Color{}, true},
+		{"rgb(nan,0,0)", Color{}, true},
+		{"hsv(inf, 1, 1)", Color{}, true},
+		{"hsl(0, 101%, 0)", Color{}, true},
+		{"500K", Color{}, true},
 		{"#efghij", Color{}, true},
+		{"#ff80", Color{}, true},
+		{"#", Color{}, true},
+		{"", Color{}, true},
+		{"rgb", Color{}, true},
 		{"nonsense color", Color{}, true},
 		{"red", Color{1, 0, 0}, false},
 	}
 	for _, c := range cases {
 		guess, err := NewColor(c.q)
-		if (c.a != guess) || (err == nil == c.e) { // if the answer is wrong, or the error value is unexpected
+		if !nearly(c.a, guess) || (err == nil == c.e) { // if the answer is wrong, or the error value is unexpected
 			t.Errorf("Failed to parse %s: expected (%v, %v) but got (%v, %v)", c.q, c.a, c.e, guess, err)
 		}
 	}
@@ -41,6 +59,11 @@ func TestNewGradient(t *testing.T) {
 		{"linear-gradient(180deg, #ffgh00 10%)", Gradient{}, true},
 		{"linear-gradient(180deg, rgb(299,0,299) 10%)", Gradient{}, true},
 		{"linear-gradient(180deg, useless color 10%)", Gradient{}, true},
+		{"linear-gradient(180deg, rgb(0,0,0 10%)", Gradient{}, true},
+		{"linear-gradient(180deg, #ff 10%)", Gradient{}, true},
+		{"linear-gradient(180deg, , )", Gradient{}, true},
+		{"linear", Gradient{}, true},
+		{"linear-gradient(45deg, hsl(0, 100%, 50%) 0%, rgba(0, 0, 255, 0.5) 100%)", Gradient{mode: "linear", angle: 45}, false},
 		{
 			"linear-gradient(90deg, #ffFf00 10%, rgb(255, 0, 255) 30%)",
 			Gradient{
@@ -62,8 +85,51 @@ func TestNewGradient(t *testing.T) {
 	}
 	for _, c := range cases {
 		guess, err := NewGradient(c.q)
+		if guess == nil {
+			guess = &Gradient{}
+		}
 		if (c.a.mode != guess.mode) || (c.a.angle != guess.angle) || (err == nil == c.e) { // if the answer is wrong, or the error value is unexpected
 			t.Errorf("Failed to parse %s: expected (%v, %v) but got (%v, %v)", c.q, c.a, c.e, guess, err)
 		}
 	}
 }
+
+func TestUserNames(t *testing.T) {
+	SetUser(map[string]string{"Sunset": "#ff8000"}, map[string]string{"Dusk": "linear-gradient(90deg, #ff8000 0%, #000080 100%)"})
+	defer SetUser(nil, nil)
+
+	if c, err := NewColor("Sunset"); err != nil || c != (Color{1, float64(0x80) / 255, 0}) {
+		t.Errorf("Failed to resolve user color: (%v, %v)", c, err)
+	}
+	if _, err := NewGradient("Dusk"); err != nil {
+		t.Errorf("Failed to resolve user gradient: %v", err)
+	}
+	SetUser(nil, nil)
+	if _, err := NewColor("Sunset"); err == nil {
+		t.Errorf("Removed user color still resolves")
+	}
+}
+
+func TestHSV(t *testing.T) {
+	for _, c := range []Color{{1, 0, 0}, {0.2, 0.4, 0.6}, {0.5, 0.5, 0.5}, {0, 0, 0}, {1, 1, 1}, {0.9, 0.1, 0.7}} {
+		if h, s, v := c.HSV(); !nearly(FromHSV(h, s, v), c) {
+			t.Errorf("HSV of %v does not round trip: (%v, %v, %v) -> %v", c, h, s, v, FromHSV(h, s, v))
+		}
+		if h, s, l := c.HSL(); !nearly(FromHSL(h, s, l), c) {
+			t.Errorf("HSL of %v does not round trip: (%v, %v, %v) -> %v", c, h, s, l, FromHSL(h, s, l))
+		}
+	}
+	if h, s, v := (Color{0, 0.5, 1}).HSV(); !nearly(Color{h, s, v}, Color{210, 1, 1}) {
+		t.Errorf("Expected HSV (210, 1, 1) but got (%v, %v, %v)", h, s, v)
+	}
+}
+
+// nearly compares colors allowing for rounding errors
+func nearly(a, b Color) bool {
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
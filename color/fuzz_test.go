@@ -0,0 +1,37 @@
+//go:build go1.18
+
+package color
+
+import (
+	"math"
+	"testing"
+)
+
+func FuzzNewColor(f *testing.F) {
+	for _, seed := range []string{"#ff00ff", "#f0f", "#ff00ff80", "rgb(255, 0, 255)", "rgba(255, 0, 255, 50%)", "hsv(300, 100%, 100%)", "hsl(300, 1, 0.5)", "3000K", "red", "rgb(", ""} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		col, err := NewColor(s)
+		if err != nil {
+			if col != (Color{}) {
+				t.Errorf("Got %v with error %v", col, err)
+			}
+			return
+		}
+		for _, v := range col {
+			if math.IsNaN(v) || v < 0 || v > 1 {
+				t.Errorf("Parsed %q to out of range color %v", s, col)
+			}
+		}
+	})
+}
+
+func FuzzNewGradient(f *testing.F) {
+	for _, seed := range []string{"Rainbow", "linear-gradient(90deg, rgb(255, 0, 0) 0%, #800000 50%, hsl(0, 100%, 50%) 100%)", "linear-gradient(", "linear"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		NewGradient(s)
+	})
+}
@@ -185,6 +185,9 @@ func NewGradient(gs string) (g *Gradient, err error) {
 	if isPredef {
 		return parseGradient(predef)
 	}
+	if user, ok := userGradient(gs); ok {
+		return parseGradient(user)
+	}
 	return parseGradient(gs)
 }
 
@@ -214,7 +217,7 @@ func GradientFromPNG(data []byte, resolution int, angle int) (g *Gradient, err e
 Parses gradient from string of format eg.
 "linear-gradient(90deg, rgb(100, 0, 255) 0%, #800000 50%, #ec77ab 100%)"
 where each color is associated with a % value for its position in the gradient
-each color can be hex or rgb format
+each color can be #rrggbb hex or rgb, rgba, hsv or hsl format
 */
 func parseGradient(gs string) (g *Gradient, err error) {
 	g = &Gradient{
@@ -225,6 +228,9 @@ func parseGradient(gs string) (g *Gradient, err error) {
 	gs = strings.ToLower(gs)
 	gs = strings.ReplaceAll(gs, " ", "")
 	splits = strings.SplitN(gs, "(", 2)
+	if len(splits) != 2 {
+		return nil, errInvalidGradient
+	}
 	mode := splits[0]
 	g.mode = strings.TrimSuffix(mode, "-gradient")
 	angleColorPos := splits[1]
@@ -239,7 +245,7 @@ func parseGradient(gs string) (g *Gradient, err error) {
 		return nil, fmt.Errorf("error parsing angle string: %w", err)
 	}
 	colorPos := splits[1]
-	splits = strings.SplitAfter(colorPos, "%,")
+	splits = splitStops(colorPos)
 
 	g.colors = make([]Color, len(splits))
 	g.positions = make([]float64, len(splits))
@@ -247,17 +253,21 @@ func parseGradient(gs string) (g *Gradient, err error) {
 	var c Color
 	var p float64
 	for i, cp := range splits {
-		cp = strings.TrimRight(cp, "%,")
-		switch cp[0:1] {
-		case "r": // rgb style
+		cp = strings.TrimSuffix(cp, "%")
+		switch {
+		case strings.HasPrefix(cp, "rgb") || strings.HasPrefix(cp, "hs"): // rgb, rgba, hsv or hsl style
 			cpSplit = strings.SplitAfter(cp, ")")
+			if len(cpSplit) != 2 {
+				err = errInvalidGradient
+				break
+			}
 			c, err = NewColor(cpSplit[0])
 			if err != nil {
 				break
 			}
 			p, err = strconv.ParseFloat(cpSplit[1], 64)
 			p /= 100
-		case "#": // hex style
+		case strings.HasPrefix(cp, "#") && len(cp) > 7: // hex style
 			c, err = NewColor(cp[0:7])
 			if err != nil {
 				break
@@ -279,6 +289,26 @@ func parseGradient(gs string) (g *Gradient, err error) {
 	return g, err
 }
 
+// splitStops splits color stops at the commas between them, leaving those
+// inside of color functions alone
+func splitStops(s string) (stops []string) {
+	depth, start := 0, 0
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				stops = append(stops, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(stops, s[start:])
+}
+
 func minMax(a, b int) (min, max int) {
 	if a > b {
 		return b, a
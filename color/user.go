@@ -0,0 +1,41 @@
+package color
+
+import "sync"
+
+// User defined colors and gradients by name, next to the builtin LedFxColors
+// and LedFxGradients. They live in the config, which keeps them in sync here.
+var (
+	userMu        sync.RWMutex
+	userColors    = map[string]string{}
+	userGradients = map[string]string{}
+)
+
+// SetUser replaces the user colors and gradients NewColor and NewGradient
+// resolve by name
+func SetUser(colors, gradients map[string]string) {
+	c := make(map[string]string, len(colors))
+	for name, value := range colors {
+		c[name] = value
+	}
+	g := make(map[string]string, len(gradients))
+	for name, value := range gradients {
+		g[name] = value
+	}
+	userMu.Lock()
+	defer userMu.Unlock()
+	userColors, userGradients = c, g
+}
+
+func userColor(name string) (string, bool) {
+	userMu.RLock()
+	defer userMu.RUnlock()
+	value, ok := userColors[name]
+	return value, ok
+}
+
+func userGradient(name string) (string, bool) {
+	userMu.RLock()
+	defer userMu.RUnlock()
+	value, ok := userGradients[name]
+	return value, ok
+}
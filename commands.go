@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"ledfx/config"
+	"ledfx/config/legacy"
+	"os"
+)
+
+// runCommand runs the subcommand given after the flags and returns the exit
+// code. Subcommands work on the config and exit without starting LedFx.
+func runCommand(args []string) int {
+	switch args[0] {
+	case "import-legacy":
+		if len(args) != 2 {
+			fmt.Fprintln(os.Stderr, "Usage: ledfx import-legacy <path to Python LedFx config.json>")
+			return 2
+		}
+		return importLegacy(args[1])
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command '%s', known commands: import-legacy\n", args[0])
+		return 2
+	}
+}
+
+// importLegacy merges a Python LedFx config.json into the config
+func importLegacy(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	report, err := legacy.Import(data)
+	if err == nil {
+		err = config.Flush()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error importing %s: %v\n", path, err)
+		return 1
+	}
+
+	fmt.Printf("Imported %d devices, %d virtuals, %d presets, %d colors and %d gradients from %s\n",
+		report.Devices, report.Virtuals, report.Presets, report.Colors, report.Gradients, path)
+	if len(report.Untranslated) > 0 {
+		fmt.Println("Not imported:")
+		for _, line := range report.Untranslated {
+			fmt.Println("  " + line)
+		}
+	}
+	return 0
+}
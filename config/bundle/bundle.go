@@ -0,0 +1,395 @@
+// Package bundle copies a LedFx setup between installations. A bundle holds
+// the devices, virtuals, presets and user colors and gradients of a config,
+// but no server settings or credentials.
+package bundle
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ledfx/color"
+	"ledfx/config"
+	"ledfx/constants"
+	"ledfx/device"
+	"ledfx/effect"
+	"ledfx/virtual"
+	"sort"
+	"strings"
+)
+
+// Version is the bundle format written by Export
+const Version = 1
+
+// ErrChanged is returned by ImportExpecting when the config changed since
+// the dry run its expected changes came from
+var ErrChanged = errors.New("the config changed since the dry run, import again")
+
+// Bundle is the part of a config that describes a setup. LedFx is the
+// version that exported it.
+type Bundle struct {
+	Version       int               `json:"version"`
+	LedFx         string            `json:"ledfx"`
+	Devices       []config.Device   `json:"devices"`
+	Virtuals      []config.Virtual  `json:"virtuals"`
+	Presets       []config.Preset   `json:"presets"`
+	UserColors    map[string]string `json:"user_colors"`
+	UserGradients map[string]string `json:"user_gradients"`
+}
+
+// Mode decides what happens to configured entries that are not in a bundle
+type Mode string
+
+const (
+	// Merge keeps them, bundle entries replace those with the same id or name
+	Merge Mode = "merge"
+	// Replace removes them
+	Replace Mode = "replace"
+)
+
+// ParseMode validates a mode name, the empty name is Merge
+func ParseMode(s string) (Mode, error) {
+	switch Mode(s) {
+	case "":
+		return Merge, nil
+	case Merge, Replace:
+		return Mode(s), nil
+	}
+	return "", fmt.Errorf("invalid mode '%s', must be 'merge' or 'replace'", s)
+}
+
+// Change is one entry an import adds, updates or removes
+type Change struct {
+	Kind string `json:"kind"`
+	Id   string `json:"id"`
+	Op   string `json:"op"`
+}
+
+// ValidationError lists everything wrong with a bundle
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("bundle has %d problems: %s", len(e.Problems), strings.Join(e.Problems, "; "))
+}
+
+// Export returns the bundle of conf. Its lists are copies, but the entries
+// in them share their segments and effect settings with conf.
+func Export(conf config.Config) Bundle {
+	return Bundle{
+		Version:       Version,
+		LedFx:         constants.VERSION,
+		Devices:       append([]config.Device{}, conf.Devices...),
+		Virtuals:      append([]config.Virtual{}, conf.Virtuals...),
+		Presets:       append([]config.Preset{}, conf.Presets...),
+		UserColors:    copyNamed(conf.UserColors),
+		UserGradients: copyNamed(conf.UserGradients),
+	}
+}
+
+// Apply puts the entries of b into c
+func Apply(c *config.Config, b Bundle, mode Mode) {
+	if mode == Replace {
+		c.Devices = append([]config.Device{}, b.Devices...)
+		c.Virtuals = append([]config.Virtual{}, b.Virtuals...)
+		c.Presets = append([]config.Preset{}, b.Presets...)
+		c.UserColors = copyNamed(b.UserColors)
+		c.UserGradients = copyNamed(b.UserGradients)
+		return
+	}
+	for _, dev := range b.Devices {
+		if i := deviceIndex(c.Devices, dev.Id); i >= 0 {
+			c.Devices[i] = dev
+		} else {
+			c.Devices = append(c.Devices, dev)
+		}
+	}
+	for _, virt := range b.Virtuals {
+		if i := virtualIndex(c.Virtuals, virt.Id); i >= 0 {
+			c.Virtuals[i] = virt
+		} else {
+			c.Virtuals = append(c.Virtuals, virt)
+		}
+	}
+	for _, preset := range b.Presets {
+		if i := presetIndex(c.Presets, preset.Id); i >= 0 {
+			c.Presets[i] = preset
+		} else {
+			c.Presets = append(c.Presets, preset)
+		}
+	}
+	c.UserColors = mergeNamed(c.UserColors, b.UserColors)
+	c.UserGradients = mergeNamed(c.UserGradients, b.UserGradients)
+}
+
+// Validate checks every entry of b, and that the virtuals of b fit on the
+// devices conf has once b is applied with mode
+func Validate(conf config.Config, b Bundle, mode Mode) error {
+	var problems []string
+	fail := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	if b.Version > Version {
+		fail("bundle version %d is newer than version %d supported by this LedFx", b.Version, Version)
+	}
+
+	seen := make(map[string]bool)
+	for i, dev := range b.Devices {
+		if err := device.ValidateDevice(dev); err != nil {
+			fail("devices[%d]: %v", i, err)
+		} else if seen[dev.Id] {
+			fail("devices[%d]: duplicate id '%s'", i, dev.Id)
+		}
+		seen[dev.Id] = true
+	}
+	devices := b.Devices
+	if mode == Merge {
+		for _, dev := range conf.Devices {
+			if !seen[dev.Id] {
+				devices = append(devices[:len(devices):len(devices)], dev)
+			}
+		}
+	}
+
+	// Effects may name user colors of the bundle, which are not known yet
+	resolve := func(ec *config.EffectConfig) {
+		for _, field := range []*string{&ec.Color, &ec.BackgroundColor} {
+			if value, ok := b.UserColors[*field]; ok {
+				*field = value
+			}
+		}
+	}
+
+	seen = make(map[string]bool)
+	for i, virt := range b.Virtuals {
+		resolve(&virt.Effect.Config)
+		if err := virtual.ValidateVirtualOn(virt, devices); err != nil {
+			fail("virtuals[%d]: %v", i, err)
+		} else if seen[virt.Id] {
+			fail("virtuals[%d]: duplicate id '%s'", i, virt.Id)
+		}
+		seen[virt.Id] = true
+	}
+
+	seen = make(map[string]bool)
+	for i, preset := range b.Presets {
+		resolve(&preset.Config)
+		if err := effect.ValidatePreset(preset); err != nil {
+			fail("presets[%d]: %v", i, err)
+		} else if seen[preset.Id] {
+			fail("presets[%d]: duplicate id '%s'", i, preset.Id)
+		}
+		seen[preset.Id] = true
+	}
+
+	for _, name := range sortedNames(b.UserColors) {
+		if err := CheckColor(b.UserColors[name]); err != nil {
+			fail("user_colors[%s]: %v", name, err)
+		}
+	}
+	for _, name := range sortedNames(b.UserGradients) {
+		if err := CheckGradient(b.UserGradients[name]); err != nil {
+			fail("user_gradients[%s]: %v", name, err)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// Import validates b and applies it to the config. With dryRun the config is
+// left as it is. It returns the changes the import makes.
+func Import(b Bundle, mode Mode, dryRun bool) ([]Change, error) {
+	return importBundle(b, mode, dryRun, nil)
+}
+
+// ImportExpecting is Import that only applies b if it makes exactly the
+// expected changes, those of an earlier dry run. Otherwise it leaves the
+// config as it is and returns ErrChanged with the changes it would make now.
+func ImportExpecting(b Bundle, mode Mode, expected []Change) ([]Change, error) {
+	if expected == nil {
+		expected = []Change{}
+	}
+	return importBundle(b, mode, false, expected)
+}
+
+// importBundle is Import, checking the changes against expected unless it is
+// nil
+func importBundle(b Bundle, mode Mode, dryRun bool, expected []Change) ([]Change, error) {
+	var changes []Change
+	err := config.Update(func(c *config.Config) error {
+		if err := Validate(*c, b, mode); err != nil {
+			return err
+		}
+		before := Export(*c)
+		Apply(c, b, mode)
+		changes = Diff(before, Export(*c))
+		if expected != nil && !sameChanges(changes, expected) {
+			return ErrChanged
+		}
+		if dryRun || len(changes) == 0 {
+			return config.ErrUnchanged
+		}
+		return nil
+	})
+	return changes, err
+}
+
+func sameChanges(a, b []Change) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Diff lists the entries that were added, updated or removed from before to
+// after, by kind and then id
+func Diff(before, after Bundle) []Change {
+	changes := []Change{}
+	diff := func(kind string, old, new map[string]interface{}) {
+		ids := make([]string, 0, len(old)+len(new))
+		for id := range old {
+			ids = append(ids, id)
+		}
+		for id := range new {
+			if _, ok := old[id]; !ok {
+				ids = append(ids, id)
+			}
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			o, inOld := old[id]
+			n, inNew := new[id]
+			switch {
+			case !inOld:
+				changes = append(changes, Change{Kind: kind, Id: id, Op: "add"})
+			case !inNew:
+				changes = append(changes, Change{Kind: kind, Id: id, Op: "remove"})
+			case !sameJSON(o, n):
+				changes = append(changes, Change{Kind: kind, Id: id, Op: "update"})
+			}
+		}
+	}
+	diff("device", devicesById(before.Devices), devicesById(after.Devices))
+	diff("virtual", virtualsById(before.Virtuals), virtualsById(after.Virtuals))
+	diff("preset", presetsById(before.Presets), presetsById(after.Presets))
+	diff("color", byName(before.UserColors), byName(after.UserColors))
+	diff("gradient", byName(before.UserGradients), byName(after.UserGradients))
+	return changes
+}
+
+// CheckColor and CheckGradient report whether a user color or gradient parses
+func CheckColor(s string) error {
+	_, err := color.NewColor(s)
+	return err
+}
+
+func CheckGradient(s string) error {
+	_, err := color.NewGradient(s)
+	return err
+}
+
+// sameJSON compares entries by their encoding, so segment indexes compare
+// equal whether they were decoded as float64 or set as int
+func sameJSON(a, b interface{}) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(ja, jb)
+}
+
+func devicesById(devices []config.Device) map[string]interface{} {
+	m := make(map[string]interface{}, len(devices))
+	for _, dev := range devices {
+		m[dev.Id] = dev
+	}
+	return m
+}
+
+func virtualsById(virtuals []config.Virtual) map[string]interface{} {
+	m := make(map[string]interface{}, len(virtuals))
+	for _, virt := range virtuals {
+		m[virt.Id] = virt
+	}
+	return m
+}
+
+func presetsById(presets []config.Preset) map[string]interface{} {
+	m := make(map[string]interface{}, len(presets))
+	for _, p := range presets {
+		m[p.Id] = p
+	}
+	return m
+}
+
+func byName(named map[string]string) map[string]interface{} {
+	m := make(map[string]interface{}, len(named))
+	for name, value := range named {
+		m[name] = value
+	}
+	return m
+}
+
+func sortedNames(named map[string]string) []string {
+	names := make([]string, 0, len(named))
+	for name := range named {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func copyNamed(named map[string]string) map[string]string {
+	cp := make(map[string]string, len(named))
+	for name, value := range named {
+		cp[name] = value
+	}
+	return cp
+}
+
+func mergeNamed(dst, src map[string]string) map[string]string {
+	if len(src) == 0 {
+		return dst
+	}
+	if dst == nil {
+		dst = make(map[string]string, len(src))
+	}
+	for name, value := range src {
+		dst[name] = value
+	}
+	return dst
+}
+
+func deviceIndex(devices []config.Device, id string) int {
+	for i, dev := range devices {
+		if dev.Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func virtualIndex(virtuals []config.Virtual, id string) int {
+	for i, virt := range virtuals {
+		if virt.Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func presetIndex(presets []config.Preset, id string) int {
+	for i, p := range presets {
+		if p.Id == id {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,182 @@
+package bundle
+
+import (
+	"errors"
+	"ledfx/config"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func testConfig() config.Config {
+	return config.Config{
+		Port: 8080,
+		Devices: []config.Device{{
+			Id:     "couch",
+			Type:   "wled",
+			Config: config.DeviceConfig{Name: "Couch", IpAddress: "127.0.0.1", PixelCount: 36},
+		}},
+		Virtuals: []config.Virtual{{
+			Id:       "couch",
+			IsDevice: "couch",
+			Config:   config.VirtualConfig{Name: "Couch"},
+			Segments: [][]interface{}{{"couch", 0, 35, false}},
+		}},
+		Presets:    []config.Preset{{Id: "blue", Name: "Blue", Type: "singleColor", Config: config.EffectConfig{Color: "#0000ff"}}},
+		UserColors: map[string]string{"Mint": "#00ff80"},
+	}
+}
+
+func testBundle() Bundle {
+	return Bundle{
+		Version: Version,
+		Devices: []config.Device{{
+			Id:     "desk",
+			Type:   "wled",
+			Config: config.DeviceConfig{Name: "Desk", IpAddress: "127.0.0.2", PixelCount: 60},
+		}},
+		Virtuals: []config.Virtual{{
+			Id:       "room",
+			Config:   config.VirtualConfig{Name: "Room"},
+			Segments: [][]interface{}{{"desk", 0, 59, false}, {"couch", 0, 35, true}},
+			// Named after a user color in the bundle
+			Effect: config.Effect{Type: "singleColor", Config: config.EffectConfig{Color: "Sunset"}},
+		}},
+		Presets:    []config.Preset{{Id: "blue", Name: "Navy", Type: "singleColor", Config: config.EffectConfig{Color: "#000080"}}},
+		UserColors: map[string]string{"Sunset": "#ff8000"},
+	}
+}
+
+func TestApply(t *testing.T) {
+	c := testConfig()
+	Apply(&c, testBundle(), Merge)
+	if len(c.Devices) != 2 || len(c.Virtuals) != 2 || len(c.Presets) != 1 || c.Presets[0].Name != "Navy" || len(c.UserColors) != 2 {
+		t.Errorf("Unexpected merge result: %+v", c)
+	}
+
+	c = testConfig()
+	Apply(&c, testBundle(), Replace)
+	if len(c.Devices) != 1 || c.Devices[0].Id != "desk" || len(c.UserColors) != 1 || c.Port != 8080 {
+		t.Errorf("Unexpected replace result: %+v", c)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate(testConfig(), testBundle(), Merge); err != nil {
+		t.Errorf("Unexpected error merging a valid bundle: %v", err)
+	}
+
+	// The room virtual spans the couch device, which replace removes
+	var invalid *ValidationError
+	if err := Validate(testConfig(), testBundle(), Replace); !errors.As(err, &invalid) || len(invalid.Problems) != 1 {
+		t.Errorf("Expected one problem replacing, got %v", err)
+	}
+
+	b := testBundle()
+	b.Version = Version + 1
+	b.Devices = append(b.Devices, b.Devices[0])
+	b.Presets[0].Type = "fireworks"
+	b.UserColors["Broken"] = "#ff80"
+	b.UserGradients = map[string]string{"Broken": "linear"}
+	if err := Validate(testConfig(), b, Merge); !errors.As(err, &invalid) || len(invalid.Problems) != 5 {
+		t.Errorf("Expected 5 problems, got %v", err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	before := Export(testConfig())
+	c := testConfig()
+	c.Virtuals[0].Segments = [][]interface{}{{"couch", float64(0), float64(35), false}}
+	Apply(&c, testBundle(), Replace)
+
+	expected := []Change{
+		{Kind: "device", Id: "couch", Op: "remove"},
+		{Kind: "device", Id: "desk", Op: "add"},
+		{Kind: "virtual", Id: "couch", Op: "remove"},
+		{Kind: "virtual", Id: "room", Op: "add"},
+		{Kind: "preset", Id: "blue", Op: "update"},
+		{Kind: "color", Id: "Mint", Op: "remove"},
+		{Kind: "color", Id: "Sunset", Op: "add"},
+	}
+	if changes := Diff(before, Export(c)); !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Expected changes %+v but got %+v", expected, changes)
+	}
+
+	// Segment indexes decoded from JSON are the same as ints
+	c = testConfig()
+	c.Virtuals[0].Segments = [][]interface{}{{"couch", float64(0), float64(35), false}}
+	if changes := Diff(before, Export(c)); len(changes) != 0 {
+		t.Errorf("Expected no changes, got %+v", changes)
+	}
+}
+
+func TestImport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "go_config.json")
+	if err := os.WriteFile(path, []byte("{}\n"), 0644); err != nil {
+		t.Fatalf("Error writing test config: %v\n", err)
+	}
+	config.GlobalViper = viper.New()
+	config.WriteDelay = 0
+	config.GlobalViper.SetConfigFile(path)
+	if err := config.Replace(testConfig()); err != nil {
+		t.Fatalf("Error setting test config: %v\n", err)
+	}
+
+	changes, err := Import(testBundle(), Merge, true)
+	if err != nil || len(changes) != 4 {
+		t.Fatalf("Unexpected dry run result %+v: %v", changes, err)
+	}
+	if len(config.Snapshot().Devices) != 1 {
+		t.Errorf("Dry run changed the config")
+	}
+
+	if _, err := Import(testBundle(), Merge, false); err != nil {
+		t.Fatalf("Error importing: %v\n", err)
+	}
+	if len(config.Snapshot().Devices) != 2 {
+		t.Errorf("Import did not change the config")
+	}
+	if changes, err := Import(testBundle(), Merge, false); err != nil || len(changes) != 0 {
+		t.Errorf("Importing twice should change nothing, got %+v: %v", changes, err)
+	}
+}
+
+func TestImportExpecting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "go_config.json")
+	if err := os.WriteFile(path, []byte("{}\n"), 0644); err != nil {
+		t.Fatalf("Error writing test config: %v\n", err)
+	}
+	config.GlobalViper = viper.New()
+	config.WriteDelay = 0
+	config.GlobalViper.SetConfigFile(path)
+	if err := config.Replace(testConfig()); err != nil {
+		t.Fatalf("Error setting test config: %v\n", err)
+	}
+
+	expected, err := Import(testBundle(), Merge, true)
+	if err != nil {
+		t.Fatalf("Error in dry run: %v\n", err)
+	}
+	// Someone else makes the preset what the bundle has
+	config.Update(func(c *config.Config) error {
+		c.Presets[0] = testBundle().Presets[0]
+		return nil
+	})
+
+	changes, err := ImportExpecting(testBundle(), Merge, expected)
+	if !errors.Is(err, ErrChanged) || len(changes) != len(expected)-1 {
+		t.Fatalf("Expected ErrChanged and one change less, got %+v: %v", changes, err)
+	}
+	if len(config.Snapshot().Devices) != 1 {
+		t.Errorf("A failed import changed the config")
+	}
+	if _, err := ImportExpecting(testBundle(), Merge, changes); err != nil {
+		t.Errorf("Error importing the changes of the new dry run: %v", err)
+	}
+	if len(config.Snapshot().Devices) != 2 {
+		t.Errorf("Import did not change the config")
+	}
+}
@@ -26,6 +26,47 @@ type DeviceConfig struct {
 	Timeout     int `mapstructure:"timeout" json:"timeout"`
 	// Type            string `mapstructure:"type" json:"type"` // not in old api when devicetype UDP
 	UdpPacketType string `mapstructure:"udp_packet_type" json:"udp_packet_type"`
+	// Calibration corrects the colors for the strip, see device.Output
+	Calibration Calibration `mapstructure:"calibration" json:"calibration"`
+	// Power limits the current the strip draws, see device.Limiter
+	Power Power `mapstructure:"power" json:"power"`
+}
+
+// Power describes the current a strip draws and may draw. The zero value
+// estimates the draw of WS2812 LEDs and limits nothing.
+type Power struct {
+	// MilliampsPerChannel is the current of one channel at full brightness,
+	// 0 means 20
+	MilliampsPerChannel float64 `mapstructure:"milliamps_per_channel" json:"milliamps_per_channel"`
+	// IdleMilliamps is the current of one dark pixel
+	IdleMilliamps float64 `mapstructure:"idle_milliamps" json:"idle_milliamps"`
+	// MaxMilliamps is the budget of the device, 0 means no limit
+	MaxMilliamps int `mapstructure:"max_milliamps" json:"max_milliamps"`
+	// Supply is the id of a power supply the device shares with others
+	Supply string `mapstructure:"supply" json:"supply"`
+}
+
+// PowerSupply is a budget shared by the devices that name it
+type PowerSupply struct {
+	Id           string `mapstructure:"id" json:"id"`
+	Name         string `mapstructure:"name" json:"name"`
+	MaxMilliamps int    `mapstructure:"max_milliamps" json:"max_milliamps"`
+}
+
+// Calibration describes how a strip shows colors. The zero value sends
+// colors unchanged in RGB order.
+type Calibration struct {
+	// ChannelOrder is the order the strip takes channels in, like "GRB".
+	// A W adds a white channel that is extracted from the color.
+	ChannelOrder string `mapstructure:"channel_order" json:"channel_order"`
+	// Gamma raises channels to a power, 0 means 1
+	Gamma float64 `mapstructure:"gamma" json:"gamma"`
+	// WhitePoint is the color that full white is scaled to, like "#ffe0c0"
+	// or "5000K"
+	WhitePoint string `mapstructure:"white_point" json:"white_point"`
+	// Dither carries rounding errors over to the next frame, which smooths
+	// fades at low brightness
+	Dither bool `mapstructure:"dither" json:"dither"`
 }
 
 type VirtualConfig struct {
@@ -65,6 +106,13 @@ type Effect struct {
 	Type   string       `mapstructure:"type" json:"type"`
 }
 
+type Preset struct {
+	Id     string       `mapstructure:"id" json:"id"`
+	Name   string       `mapstructure:"name" json:"name"`
+	Type   string       `mapstructure:"type" json:"type"`
+	Config EffectConfig `mapstructure:"config" json:"config"`
+}
+
 type Device struct {
 	Config DeviceConfig `mapstructure:"config" json:"config"`
 	// Effect Effect       `mapstructure:"effect" json:"effect"` // not in old api when devicetype UDP
@@ -82,6 +130,23 @@ type Virtual struct {
 	// Segments []Segment     `mapstructure:"segments" json:"segments"`
 }
 
+// ApiToken grants a scope to whoever presents the secret it was created
+// with. Only a hash of the secret is stored.
+type ApiToken struct {
+	Id      string `mapstructure:"id" json:"id"`
+	Name    string `mapstructure:"name" json:"name"`
+	Scope   string `mapstructure:"scope" json:"scope"`
+	Hash    string `mapstructure:"hash" json:"hash"`
+	Created int64  `mapstructure:"created" json:"created"`
+}
+
+// AuthConfig enables authentication as soon as a token or password is set
+type AuthConfig struct {
+	PasswordHash   string     `mapstructure:"password_hash" json:"password_hash"`
+	Tokens         []ApiToken `mapstructure:"tokens" json:"tokens"`
+	AllowedOrigins []string   `mapstructure:"allowed_origins" json:"allowed_origins"`
+}
+
 type AudioDevice struct {
 	Id         string  `mapstructure:"id" json:"id"`
 	HostApi    string  `mapstructure:"hostapi" json:"hostapi"`
@@ -96,25 +161,39 @@ type AudioConfig struct {
 	Device    AudioDevice `mapstructure:"device" json:"device"`
 	FftSize   int         `mapstructure:"fft_size" json:"fft_size"`
 	FrameRate int         `mapstructure:"frame_rate" json:"frame_rate"`
+	// Analysis is the analysis backend, "aubio" or "go". Empty picks aubio
+	// if this build has it.
+	Analysis string `mapstructure:"analysis" json:"analysis,omitempty"`
 }
 
 type Config struct {
-	Config      string      `mapstructure:"config" json:"config"`
-	Port        int         `mapstructure:"port" json:"port"`
-	Version     bool        `mapstructure:"version" json:"version"`
-	OpenUi      bool        `mapstructure:"open-ui" json:"open-ui"`
-	Verbose     bool        `mapstructure:"verbose" json:"verbose"`
-	VeryVerbose bool        `mapstructure:"very-verbose" json:"very-verbose"`
-	Host        string      `mapstructure:"host" json:"host"`
-	Offline     bool        `mapstructure:"offline" json:"offline"`
-	SentryCrash bool        `mapstructure:"sentry-crash-test" json:"sentry-crash-test"`
-	Devices     []Device    `mapstructure:"devices" json:"devices"`
-	Virtuals    []Virtual   `mapstructure:"virtuals" json:"virtuals"`
-	Audio       AudioConfig `mapstructure:"audio" json:"audio"`
+	SchemaVersion int         `mapstructure:"schema_version" json:"schema_version"`
+	Config        string      `mapstructure:"config" json:"config"`
+	Port          int         `mapstructure:"port" json:"port"`
+	Version       bool        `mapstructure:"version" json:"version"`
+	OpenUi        bool        `mapstructure:"open-ui" json:"open-ui"`
+	Verbose       bool        `mapstructure:"verbose" json:"verbose"`
+	VeryVerbose   bool        `mapstructure:"very-verbose" json:"very-verbose"`
+	Host          string      `mapstructure:"host" json:"host"`
+	Offline       bool        `mapstructure:"offline" json:"offline"`
+	SentryCrash   bool        `mapstructure:"sentry-crash-test" json:"sentry-crash-test"`
+	Headless      bool        `mapstructure:"headless" json:"headless"`
+	LogFile       string      `mapstructure:"log-file" json:"log-file"`
+	Devices       []Device    `mapstructure:"devices" json:"devices"`
+	Virtuals      []Virtual   `mapstructure:"virtuals" json:"virtuals"`
+	Presets       []Preset    `mapstructure:"presets" json:"presets"`
+	Audio         AudioConfig `mapstructure:"audio" json:"audio"`
+	Auth          AuthConfig  `mapstructure:"auth" json:"auth"`
+
+	// UserColors and UserGradients map names to CSS values, next to the
+	// builtins in the color package
+	UserColors    map[string]string `mapstructure:"user_colors" json:"user_colors"`
+	UserGradients map[string]string `mapstructure:"user_gradients" json:"user_gradients"`
+
+	PowerSupplies []PowerSupply `mapstructure:"power_supplies" json:"power_supplies"`
 }
 
 var configPath string
-var GlobalConfig *Config
 
 var GlobalViper *viper.Viper
 
@@ -127,10 +206,19 @@ func InitConfig() error {
 	pflag.BoolP("open-ui", "u", false, "Automatically open the web interface")
 	pflag.BoolP("verbose", "i", false, "Set log level to INFO")
 	pflag.BoolP("very-verbose", "d", false, "Set log level to DEBUG")
-	pflag.String("host", "", "The hostname of the web interface")
-	pflag.BoolP("offline", "o", false, "Disable automated updates and sentry crash logger")
+	pflag.String("host", "0.0.0.0", "The address the web interface listens on")
+	pflag.BoolP("offline", "o", false, "Never reach the internet: no frontend download, updates or sentry crash logger")
 	pflag.BoolP("sentry-crash-test", "s", false, "This crashes LedFx to test the sentry crash logger")
+	pflag.Bool("headless", false, "Run without the system tray, e.g. as a daemon on a server")
+	pflag.String("log-file", "", "Append log output to this file instead of the terminal")
 
+	// --ip is the old name of --host
+	pflag.CommandLine.SetNormalizeFunc(func(f *pflag.FlagSet, name string) pflag.NormalizedName {
+		if name == "ip" {
+			name = "host"
+		}
+		return pflag.NormalizedName(name)
+	})
 	pflag.Parse()
 	err := GlobalViper.BindPFlags(pflag.CommandLine)
 	if err != nil {
@@ -182,23 +270,17 @@ func loadConfig(configName string) (err error) {
 	}
 
 	v := GlobalViper
-
-	if err != nil {
-		return err
-	}
-
-	v.SetConfigName(configName)
 	v.AutomaticEnv()
-	v.AddConfigPath(configPath)
-	err = v.ReadInConfig()
-	if err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-			log.Println("Config file not found; using defaults")
+
+	conf, migrated, err := load(v, filepath.Join(configPath, configName+".json"))
+	set(conf)
+	if migrated {
+		// Write the migrated file now, keeping the old one as backup
+		if saveErr := save(conf); saveErr != nil {
+			log.Println(saveErr)
+		} else if saveErr = Flush(); saveErr != nil {
+			log.Println(saveErr)
 		}
-		return nil
 	}
-
-	err = v.Unmarshal(&GlobalConfig)
-
-	return
+	return err
 }
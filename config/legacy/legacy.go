@@ -0,0 +1,361 @@
+// Package legacy imports the config.json written by the Python version of
+// LedFx. Everything that has no counterpart in this version is listed in
+// the Report instead of being dropped silently.
+package legacy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ledfx/config"
+	"ledfx/config/bundle"
+	"ledfx/device"
+	"ledfx/effect"
+	"math"
+	"sort"
+)
+
+// Report counts the imported entries and describes everything that could
+// not be translated
+type Report struct {
+	Devices      int      `json:"devices"`
+	Virtuals     int      `json:"virtuals"`
+	Presets      int      `json:"presets"`
+	Colors       int      `json:"colors"`
+	Gradients    int      `json:"gradients"`
+	Untranslated []string `json:"untranslated"`
+}
+
+func (r *Report) skip(format string, args ...interface{}) {
+	r.Untranslated = append(r.Untranslated, fmt.Sprintf(format, args...))
+}
+
+// ErrNotLegacy is returned for data that is not a Python LedFx config
+var ErrNotLegacy = errors.New("not a Python LedFx config")
+
+// deviceTypes are the Python device types driven by the WLED UDP protocols
+var deviceTypes = map[string]bool{"wled": true, "udp": true}
+
+// Convert translates a Python LedFx config.json. The returned config only
+// holds the translated devices, virtuals, presets, user colors and gradients
+// and audio settings.
+func Convert(data []byte) (config.Config, Report, error) {
+	report := Report{Untranslated: []string{}}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return config.Config{}, report, fmt.Errorf("%w: %v", ErrNotLegacy, err)
+	}
+	if _, ok := raw["schema_version"]; ok {
+		return config.Config{}, report, fmt.Errorf("%w: this is a config of the Go version", ErrNotLegacy)
+	}
+
+	var conf config.Config
+	root := &object{fields: raw, read: make(map[string]bool), report: &report}
+	// Presets shipped with Python LedFx, and its own bookkeeping
+	root.ignore("ledfx_presets", "configuration_version")
+
+	pixels := make(map[string]int)
+	if list, ok := root.get("devices"); ok {
+		for i, v := range asList(list) {
+			if dev, ok := convertDevice(label("devices", i, v), v, &report); ok {
+				conf.Devices = append(conf.Devices, dev)
+				pixels[dev.Id] = dev.Config.PixelCount
+			}
+		}
+	}
+	if list, ok := root.get("virtuals"); ok {
+		for i, v := range asList(list) {
+			if virt, ok := convertVirtual(label("virtuals", i, v), v, pixels, &report); ok {
+				conf.Virtuals = append(conf.Virtuals, virt)
+			}
+		}
+	}
+	if presets, ok := root.object("user_presets"); ok {
+		conf.Presets = convertPresets(presets)
+	}
+	if colors, ok := root.object("user_colors"); ok {
+		conf.UserColors = convertNamed(colors, bundle.CheckColor)
+	}
+	if gradients, ok := root.object("user_gradients"); ok {
+		conf.UserGradients = convertNamed(gradients, bundle.CheckGradient)
+	}
+	if audio, ok := root.object("audio"); ok {
+		conf.Audio = convertAudio(audio)
+	}
+	if scenes, ok := root.get("scenes"); ok {
+		if m, _ := scenes.(map[string]interface{}); len(m) > 0 {
+			report.skip("scenes: %d scenes not imported, scenes are not supported", len(m))
+		}
+	}
+	if integrations, ok := root.get("integrations"); ok {
+		if l, _ := integrations.([]interface{}); len(l) > 0 {
+			report.skip("integrations: %d integrations not imported, integrations are not supported", len(l))
+		}
+	}
+	root.rest()
+
+	report.Devices = len(conf.Devices)
+	report.Virtuals = len(conf.Virtuals)
+	report.Presets = len(conf.Presets)
+	report.Colors = len(conf.UserColors)
+	report.Gradients = len(conf.UserGradients)
+	return conf, report, nil
+}
+
+// Import converts a Python LedFx config and merges it into the running
+// config. Imported entries replace existing ones with the same id or name.
+func Import(data []byte) (Report, error) {
+	imported, report, err := Convert(data)
+	if err != nil {
+		return report, err
+	}
+	err = config.Update(func(c *config.Config) error {
+		bundle.Apply(c, bundle.Export(imported), bundle.Merge)
+		if imported.Audio.FftSize != 0 {
+			c.Audio.FftSize = imported.Audio.FftSize
+		}
+		if imported.Audio.FrameRate != 0 {
+			c.Audio.FrameRate = imported.Audio.FrameRate
+		}
+		return nil
+	})
+	return report, err
+}
+
+func convertDevice(name string, v interface{}, r *Report) (config.Device, bool) {
+	o, ok := newObject(name, v, r)
+	if !ok {
+		return config.Device{}, false
+	}
+	dev := config.Device{Id: o.str("id"), Type: o.str("type")}
+	if !deviceTypes[dev.Type] {
+		r.skip("%s: device type '%s' is not supported", name, dev.Type)
+		return config.Device{}, false
+	}
+	if c, ok := o.object("config"); ok {
+		dev.Config = config.DeviceConfig{
+			Name:          c.str("name"),
+			IpAddress:     c.str("ip_address"),
+			PixelCount:    c.integer("pixel_count"),
+			Port:          c.integer("port"),
+			RefreshRate:   c.integer("refresh_rate"),
+			Timeout:       c.integer("timeout"),
+			CenterOffset:  c.integer("center_offset"),
+			UdpPacketType: c.str("udp_packet_type"),
+		}
+		if mode := c.str("sync_mode"); mode != "" && mode != "UDP" {
+			r.skip("%s: sync_mode %s is not supported, using UDP", c.name, mode)
+		}
+		if _, ok := device.UDPProtocols[dev.Config.UdpPacketType]; dev.Config.UdpPacketType != "" && !ok {
+			r.skip("%s: udp_packet_type %s is not supported, using the default", c.name, dev.Config.UdpPacketType)
+			dev.Config.UdpPacketType = ""
+		}
+		c.rest()
+	}
+	o.rest()
+	if err := device.ValidateDevice(dev); err != nil {
+		r.skip("%s: %v", name, err)
+		return config.Device{}, false
+	}
+	return dev, true
+}
+
+// convertVirtual keeps the segments on imported devices, pixels maps their
+// ids to their pixel counts
+func convertVirtual(name string, v interface{}, pixels map[string]int, r *Report) (config.Virtual, bool) {
+	o, ok := newObject(name, v, r)
+	if !ok {
+		return config.Virtual{}, false
+	}
+	virt := config.Virtual{Id: o.str("id"), IsDevice: o.str("is_device"), Active: o.boolean("active")}
+	o.ignore("auto_generated")
+	if virt.Id == "" {
+		r.skip("%s: missing id", name)
+		return config.Virtual{}, false
+	}
+	if _, ok := pixels[virt.IsDevice]; virt.IsDevice != "" && !ok {
+		r.skip("%s: device '%s' was not imported", name, virt.IsDevice)
+		return config.Virtual{}, false
+	}
+
+	virt.Config.MaxBrightness = 1
+	if c, ok := o.object("config"); ok {
+		virt.Config.Name = c.str("name")
+		virt.Config.IconName = c.str("icon_name")
+		virt.Config.Mapping = c.str("mapping")
+		virt.Config.CenterOffset = c.integer("center_offset")
+		virt.Config.FrequencyMin = c.integer("frequency_min")
+		virt.Config.FrequencyMax = c.integer("frequency_max")
+		virt.Config.PreviewOnly = c.boolean("preview_only")
+		virt.Config.TransitionMode = c.str("transition_mode")
+		if t, ok := c.number("transition_time"); ok {
+			virt.Config.TransitionTime = float32(t)
+		}
+		// Python stores brightness as 0 to 1, we only switch it on or off
+		if b, ok := c.number("max_brightness"); ok {
+			if b != 0 && b != 1 {
+				r.skip("%s: max_brightness %v is not supported, using 1", c.name, b)
+				b = 1
+			}
+			virt.Config.MaxBrightness = int(b)
+		}
+		c.rest()
+	}
+	if virt.Config.Name == "" {
+		virt.Config.Name = virt.Id
+	}
+
+	if list, ok := o.get("segments"); ok {
+		virt.Segments = [][]interface{}{}
+		for i, seg := range asList(list) {
+			if s, ok := convertSegment(fmt.Sprintf("%s.segments[%d]", name, i), seg, pixels, r); ok {
+				virt.Segments = append(virt.Segments, s)
+			}
+		}
+	}
+
+	if e, ok := o.object("effect"); ok && len(e.fields) > 0 {
+		virt.Effect, ok = convertEffect(e)
+		if !ok {
+			virt.Active = false
+		}
+	} else {
+		virt.Active = false
+	}
+	o.rest()
+	return virt, true
+}
+
+func convertSegment(name string, v interface{}, pixels map[string]int, r *Report) ([]interface{}, bool) {
+	seg, _ := v.([]interface{})
+	if len(seg) != 4 {
+		r.skip("%s: expected [device_id, start, end, reverse], got %v", name, v)
+		return nil, false
+	}
+	id, _ := seg[0].(string)
+	count, ok := pixels[id]
+	if !ok {
+		r.skip("%s: device '%v' was not imported", name, seg[0])
+		return nil, false
+	}
+	start, ok1 := seg[1].(float64)
+	end, ok2 := seg[2].(float64)
+	reverse, ok3 := seg[3].(bool)
+	if !ok1 || !ok2 || !ok3 || start != math.Trunc(start) || end != math.Trunc(end) ||
+		start < 0 || end < start || int(end) >= count {
+		r.skip("%s: invalid segment %v for %d pixels", name, v, count)
+		return nil, false
+	}
+	return []interface{}{id, int(start), int(end), reverse}, true
+}
+
+func convertEffect(o *object) (config.Effect, bool) {
+	typ := o.str("type")
+	displayName, ok := effect.Types[typ]
+	if !ok {
+		o.report.skip("%s: effect type '%s' is not supported", o.name, typ)
+		return config.Effect{}, false
+	}
+	e := config.Effect{Type: typ, Name: displayName}
+	if c, ok := o.object("config"); ok {
+		e.Config = convertEffectConfig(c)
+	}
+	o.rest()
+	if err := effect.ValidateEffect(e); err != nil {
+		o.report.skip("%s: %v", o.name, err)
+		return config.Effect{}, false
+	}
+	return e, true
+}
+
+func convertEffectConfig(c *object) config.EffectConfig {
+	ec := config.EffectConfig{
+		Color:           c.str("color"),
+		BackgroundColor: c.str("background_color"),
+		GradientName:    c.str("gradient"),
+	}
+	c.rest()
+	return ec
+}
+
+// convertPresets flattens the presets Python keeps per effect type. Preset
+// ids are unique across types here, so a repeated id gets the type prepended.
+func convertPresets(o *object) []config.Preset {
+	var presets []config.Preset
+	used := make(map[string]bool)
+	for _, typ := range sortedKeys(o.fields) {
+		byId, ok := o.object(typ)
+		if !ok {
+			continue
+		}
+		if _, ok := effect.Types[typ]; !ok {
+			o.report.skip("%s: %d presets not imported, effect type '%s' is not supported", byId.name, len(byId.fields), typ)
+			continue
+		}
+		for _, id := range sortedKeys(byId.fields) {
+			p, ok := byId.object(id)
+			if !ok {
+				continue
+			}
+			preset := config.Preset{Id: id, Name: p.str("name"), Type: typ}
+			if used[preset.Id] {
+				preset.Id = typ + "-" + id
+			}
+			if preset.Name == "" {
+				preset.Name = id
+			}
+			if c, ok := p.object("config"); ok {
+				preset.Config = convertEffectConfig(c)
+			}
+			p.rest()
+			if err := effect.ValidatePreset(preset); err != nil {
+				o.report.skip("%s: %v", p.name, err)
+				continue
+			}
+			used[preset.Id] = true
+			presets = append(presets, preset)
+		}
+	}
+	return presets
+}
+
+// convertNamed keeps the user colors or gradients that parse
+func convertNamed(o *object, parse func(string) error) map[string]string {
+	named := make(map[string]string)
+	for _, name := range sortedKeys(o.fields) {
+		value := o.str(name)
+		if err := parse(value); err != nil {
+			o.report.skip("%s: %s '%s' does not parse: %v", o.name, name, value, err)
+			continue
+		}
+		named[name] = value
+	}
+	return named
+}
+
+func convertAudio(o *object) config.AudioConfig {
+	audio := config.AudioConfig{
+		FftSize: o.integer("fft_size"),
+		// Python calls the analysis rate sample_rate and the input rate mic_rate
+		FrameRate: o.integer("sample_rate"),
+	}
+	if _, ok := o.get("audio_device"); ok {
+		o.report.skip("%s: audio_device is a Python device index, choose the audio device again", o.name)
+	}
+	o.rest()
+	return audio
+}
+
+func asList(v interface{}) []interface{} {
+	list, _ := v.([]interface{})
+	return list
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,159 @@
+package legacy
+
+import (
+	"errors"
+	"ledfx/config"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// pythonConfig is trimmed from a config.json written by Python LedFx 2.0
+const pythonConfig = `{
+	"configuration_version": "2.2.0",
+	"host": "0.0.0.0",
+	"port": 8888,
+	"devices": [
+		{"id": "couch", "type": "wled", "config": {
+			"name": "Couch", "ip_address": "192.168.1.20", "pixel_count": 36,
+			"refresh_rate": 62, "timeout": 1, "sync_mode": "DDP", "icon_name": "wled"
+		}},
+		{"id": "desk", "type": "udp", "config": {
+			"name": "Desk", "ip_address": "192.168.1.21", "pixel_count": 60,
+			"port": 21324, "udp_packet_type": "adaptive_smallest"
+		}},
+		{"id": "tv", "type": "e131", "config": {"name": "TV", "ip_address": "192.168.1.22", "pixel_count": 100}}
+	],
+	"virtuals": [
+		{"id": "couch", "is_device": "couch", "auto_generated": false, "active": true,
+			"config": {"name": "Couch", "max_brightness": 1.0, "transition_time": 0.4, "mapping": "span"},
+			"segments": [["couch", 0, 35, false]],
+			"effect": {"type": "singleColor", "config": {"color": "#ff0000", "modulate": false}}},
+		{"id": "room", "active": true,
+			"config": {"name": "Room", "max_brightness": 0.5},
+			"segments": [["desk", 0, 59, true], ["tv", 0, 99, false], ["couch", 30, 40, false]],
+			"effect": {"type": "energy", "config": {"gradient": "Rainbow"}}},
+		{"id": "tv", "is_device": "tv", "config": {"name": "TV"}, "segments": [["tv", 0, 99, false]], "effect": {}}
+	],
+	"user_presets": {
+		"singleColor": {
+			"reset": {"name": "Reset", "config": {"color": "#000000"}},
+			"warm": {"name": "Warm", "config": {"color": "rgb(255, 120, 0)"}}
+		},
+		"energy": {"reset": {"name": "Reset", "config": {}}}
+	},
+	"ledfx_presets": {"singleColor": {"red": {"name": "Red", "config": {"color": "red"}}}},
+	"user_colors": {"Sunset": "#ff8000", "Broken": "#ff80"},
+	"user_gradients": {"Dusk": "linear-gradient(90deg, #ff8000 0%, #000080 100%)", "Broken": "linear"},
+	"audio": {"audio_device": 3, "fft_size": 4096, "mic_rate": 44100, "sample_rate": 60},
+	"scenes": {"party": {"name": "Party", "virtuals": {}}},
+	"integrations": []
+}`
+
+func TestConvert(t *testing.T) {
+	conf, report, err := Convert([]byte(pythonConfig))
+	if err != nil {
+		t.Fatalf("Error converting: %v\n", err)
+	}
+
+	expected := Report{Devices: 2, Virtuals: 2, Presets: 2, Colors: 1, Gradients: 1}
+	report.Untranslated, expected.Untranslated = nil, nil
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf("Expected counts %+v but got %+v", expected, report)
+	}
+
+	if dev := conf.Devices[1]; dev.Config.UdpPacketType != "" || dev.Config.Port != 21324 {
+		t.Errorf("Unexpected desk device: %+v", dev)
+	}
+	couch := conf.Virtuals[0]
+	if !couch.Active || couch.Effect.Name != "Single Color" || couch.Effect.Config.Color != "#ff0000" || couch.Config.TransitionTime != 0.4 {
+		t.Errorf("Unexpected couch virtual: %+v", couch)
+	}
+	room := conf.Virtuals[1]
+	expectedSegments := [][]interface{}{{"desk", 0, 59, true}}
+	if room.Active || room.Effect.Type != "" || room.Config.MaxBrightness != 1 || !reflect.DeepEqual(room.Segments, expectedSegments) {
+		t.Errorf("Unexpected room virtual: %+v", room)
+	}
+	if conf.Presets[0].Id != "reset" || conf.Presets[1].Id != "warm" {
+		t.Errorf("Unexpected presets: %+v", conf.Presets)
+	}
+	if conf.UserColors["Sunset"] != "#ff8000" || conf.UserGradients["Dusk"] == "" {
+		t.Errorf("Unexpected user colors %v and gradients %v", conf.UserColors, conf.UserGradients)
+	}
+	if conf.Audio.FftSize != 4096 || conf.Audio.FrameRate != 60 {
+		t.Errorf("Unexpected audio config: %+v", conf.Audio)
+	}
+}
+
+func TestConvertReport(t *testing.T) {
+	_, report, err := Convert([]byte(pythonConfig))
+	if err != nil {
+		t.Fatalf("Error converting: %v\n", err)
+	}
+	untranslated := strings.Join(report.Untranslated, "\n")
+	for _, expected := range []string{
+		"settings: ignored host, port",
+		"devices[couch].config: sync_mode DDP",
+		"devices[couch].config: ignored icon_name",
+		"devices[desk].config: udp_packet_type adaptive_smallest",
+		"devices[tv]: device type 'e131'",
+		"virtuals[couch].effect.config: ignored modulate",
+		"virtuals[room].config: max_brightness 0.5",
+		"virtuals[room].segments[1]: device 'tv' was not imported",
+		"virtuals[room].segments[2]: invalid segment",
+		"virtuals[room].effect: effect type 'energy'",
+		"virtuals[tv]: device 'tv' was not imported",
+		"user_presets.energy: 1 presets not imported",
+		"user_colors: Broken",
+		"user_gradients: Broken",
+		"audio: audio_device",
+		"audio: ignored mic_rate",
+		"scenes: 1 scenes",
+	} {
+		if !strings.Contains(untranslated, expected) {
+			t.Errorf("Report is missing '%s':\n%s", expected, untranslated)
+		}
+	}
+	if strings.Contains(untranslated, "ledfx_presets") || strings.Contains(untranslated, "integrations") {
+		t.Errorf("Report lists entries that need no translation:\n%s", untranslated)
+	}
+}
+
+func TestConvertInvalid(t *testing.T) {
+	for _, data := range []string{`[1, 2]`, `{"devices": `, `{"schema_version": 1}`} {
+		if _, _, err := Convert([]byte(data)); !errors.Is(err, ErrNotLegacy) {
+			t.Errorf("Expected ErrNotLegacy for %s, got %v", data, err)
+		}
+	}
+}
+
+func TestImport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "go_config.json")
+	if err := os.WriteFile(path, []byte("{}\n"), 0644); err != nil {
+		t.Fatalf("Error writing test config: %v\n", err)
+	}
+	config.GlobalViper = viper.New()
+	config.WriteDelay = 0
+	config.GlobalViper.SetConfigFile(path)
+	if err := config.Replace(config.Config{
+		Devices:    []config.Device{{Id: "couch", Type: "wled", Config: config.DeviceConfig{Name: "Old"}}, {Id: "shelf"}},
+		UserColors: map[string]string{"Mint": "#00ff80"},
+	}); err != nil {
+		t.Fatalf("Error setting test config: %v\n", err)
+	}
+
+	if _, err := Import([]byte(pythonConfig)); err != nil {
+		t.Fatalf("Error importing: %v\n", err)
+	}
+	conf := config.Snapshot()
+	if len(conf.Devices) != 3 || conf.Devices[0].Config.Name != "Couch" || conf.Devices[1].Id != "shelf" {
+		t.Errorf("Imported devices were not merged: %+v", conf.Devices)
+	}
+	if len(conf.UserColors) != 2 || conf.UserColors["Mint"] == "" {
+		t.Errorf("Imported colors were not merged: %v", conf.UserColors)
+	}
+}
@@ -0,0 +1,127 @@
+package legacy
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// object reads the fields of a JSON object and remembers which ones were
+// read, so the rest can be reported as untranslated
+type object struct {
+	name   string
+	fields map[string]interface{}
+	read   map[string]bool
+	report *Report
+}
+
+func newObject(name string, v interface{}, r *Report) (*object, bool) {
+	fields, ok := v.(map[string]interface{})
+	if !ok {
+		r.skip("%s: expected an object, got %v", name, v)
+		return nil, false
+	}
+	return &object{name: name, fields: fields, read: make(map[string]bool), report: r}, true
+}
+
+func (o *object) get(key string) (interface{}, bool) {
+	o.read[key] = true
+	v, ok := o.fields[key]
+	return v, ok && v != nil
+}
+
+// ignore marks fields as read that have no meaning outside of Python LedFx
+func (o *object) ignore(keys ...string) {
+	for _, key := range keys {
+		o.read[key] = true
+	}
+}
+
+func (o *object) str(key string) string {
+	v, ok := o.get(key)
+	if !ok {
+		return ""
+	}
+	s, ok := v.(string)
+	if !ok {
+		o.report.skip("%s: %s %v is not a string", o.name, key, v)
+	}
+	return s
+}
+
+func (o *object) number(key string) (float64, bool) {
+	v, ok := o.get(key)
+	if !ok {
+		return 0, false
+	}
+	f, ok := v.(float64)
+	if !ok {
+		o.report.skip("%s: %s %v is not a number", o.name, key, v)
+	}
+	return f, ok
+}
+
+// integer rounds numbers the Go config stores as integers
+func (o *object) integer(key string) int {
+	f, ok := o.number(key)
+	if !ok {
+		return 0
+	}
+	if f != math.Trunc(f) {
+		o.report.skip("%s: %s %v rounded to %d", o.name, key, f, int(math.Round(f)))
+	}
+	return int(math.Round(f))
+}
+
+func (o *object) boolean(key string) bool {
+	v, ok := o.get(key)
+	if !ok {
+		return false
+	}
+	b, ok := v.(bool)
+	if !ok {
+		o.report.skip("%s: %s %v is not a boolean", o.name, key, v)
+	}
+	return b
+}
+
+func (o *object) object(key string) (*object, bool) {
+	v, ok := o.get(key)
+	if !ok {
+		return nil, false
+	}
+	name := key
+	if o.name != "" {
+		name = o.name + "." + key
+	}
+	return newObject(name, v, o.report)
+}
+
+// rest reports the fields that were never read
+func (o *object) rest() {
+	var keys []string
+	for key := range o.fields {
+		if !o.read[key] {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+	sort.Strings(keys)
+	name := o.name
+	if name == "" {
+		name = "settings"
+	}
+	o.report.skip("%s: ignored %s", name, strings.Join(keys, ", "))
+}
+
+func label(section string, i int, v interface{}) string {
+	if m, ok := v.(map[string]interface{}); ok {
+		if id, ok := m["id"].(string); ok && id != "" {
+			return fmt.Sprintf("%s[%s]", section, id)
+		}
+	}
+	return fmt.Sprintf("%s[%d]", section, i)
+}
@@ -0,0 +1,210 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// migrations[i] upgrades a raw config from schema version i to i+1. Append
+// new migrations at the end, never change released ones.
+var migrations = []func(raw map[string]interface{}) error{
+	// 0 -> 1: viper used to persist flags that only make sense on the command line
+	func(raw map[string]interface{}) error {
+		for _, key := range commandLineKeys {
+			delete(raw, key)
+		}
+		return nil
+	},
+}
+
+// SchemaVersion is the config schema version this build reads and writes
+var SchemaVersion = len(migrations)
+
+// commandLineKeys are settings that are never written to the config file,
+// they only apply to the run they are passed to
+var commandLineKeys = []string{
+	"config", "version", "sentry-crash-test",
+	"headless", "offline", "log-file", "open-ui", "verbose", "very-verbose",
+}
+
+// ErrInvalid is returned by Update for a config a registered check rejects
+var ErrInvalid = errors.New("invalid config")
+
+// checks validate string settings by their dotted JSON path, for settings
+// whose valid values are only known to the package that uses them
+var checks = make(map[string]func(value string) error)
+
+// RegisterCheck makes loading drop, and Update reject, values of the string
+// setting at path, e.g. "audio.analysis", that check returns an error for.
+// Empty values are left to their default and not checked. It must be called
+// from init, before the config is loaded.
+func RegisterCheck(path string, check func(value string) error) {
+	checks[path] = check
+}
+
+// LoadError lists the entries that were left out while loading the config
+type LoadError struct {
+	Path     string
+	Problems []string
+}
+
+func (e *LoadError) Error() string {
+	return fmt.Sprintf("config %s has %d bad entries: %s", e.Path, len(e.Problems), strings.Join(e.Problems, "; "))
+}
+
+// migrate upgrades raw to SchemaVersion in place and returns the version it
+// was at before
+func migrate(raw map[string]interface{}) (from int, err error) {
+	if v, ok := raw["schema_version"]; ok {
+		f, isNumber := v.(float64)
+		if !isNumber || f < 0 || f != float64(int(f)) {
+			return 0, fmt.Errorf("invalid schema_version %v", v)
+		}
+		from = int(f)
+	}
+	if from > SchemaVersion {
+		return from, fmt.Errorf("config schema version %d is newer than version %d supported by this LedFx", from, SchemaVersion)
+	}
+	for version := from; version < SchemaVersion; version++ {
+		if err := migrations[version](raw); err != nil {
+			return from, fmt.Errorf("error migrating config from schema version %d to %d: %w", version, version+1, err)
+		}
+	}
+	raw["schema_version"] = SchemaVersion
+	return from, nil
+}
+
+// validate decodes every section of raw into its Config field. Sections that
+// do not decode are removed, as are list entries that do not decode, have no
+// id or repeat an id. It returns a description of everything removed.
+func validate(raw map[string]interface{}) (problems []string) {
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := strings.Split(field.Tag.Get("json"), ",")[0]
+		value, ok := raw[key]
+		if !ok || value == nil {
+			continue
+		}
+
+		list, isList := value.([]interface{})
+		if field.Type.Kind() != reflect.Slice || !isList {
+			if err := decodeAs(value, field.Type); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", key, err))
+				delete(raw, key)
+			}
+			continue
+		}
+
+		kept := make([]interface{}, 0, len(list))
+		seen := make(map[string]bool)
+		for j, entry := range list {
+			if err := decodeAs(entry, field.Type.Elem()); err != nil {
+				problems = append(problems, fmt.Sprintf("%s[%d]: %v", key, j, err))
+				continue
+			}
+			if id, hasId := entryId(entry, field.Type.Elem()); hasId {
+				switch {
+				case id == "":
+					problems = append(problems, fmt.Sprintf("%s[%d]: missing id", key, j))
+					continue
+				case seen[id]:
+					problems = append(problems, fmt.Sprintf("%s[%d]: duplicate id '%s'", key, j, id))
+					continue
+				}
+				seen[id] = true
+			}
+			kept = append(kept, entry)
+		}
+		raw[key] = kept
+	}
+	return append(problems, runChecks(raw)...)
+}
+
+// runChecks removes the values in raw that a registered check rejects and
+// returns why
+func runChecks(raw map[string]interface{}) (problems []string) {
+	paths := make([]string, 0, len(checks))
+	for path := range checks {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		keys := strings.Split(path, ".")
+		section := raw
+		for _, key := range keys[:len(keys)-1] {
+			section, _ = section[key].(map[string]interface{})
+		}
+		last := keys[len(keys)-1]
+		value, ok := section[last].(string)
+		if !ok || value == "" {
+			continue
+		}
+		if err := checks[path](value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", path, err))
+			delete(section, last)
+		}
+	}
+	return problems
+}
+
+// check returns ErrInvalid if a registered check rejects a value of conf
+func check(conf *Config) error {
+	if len(checks) == 0 {
+		return nil
+	}
+	b, err := json.Marshal(conf)
+	if err != nil {
+		return err
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	if problems := runChecks(raw); len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalid, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func decodeAs(value interface{}, t reflect.Type) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, reflect.New(t).Interface())
+}
+
+// entryId returns the id of a list entry whose type has an Id field
+func entryId(entry interface{}, t reflect.Type) (id string, ok bool) {
+	if t.Kind() != reflect.Struct {
+		return "", false
+	}
+	if _, ok := t.FieldByName("Id"); !ok {
+		return "", false
+	}
+	m, _ := entry.(map[string]interface{})
+	id, _ = m["id"].(string)
+	return id, true
+}
+
+// encode returns the JSON written to the config file for conf
+func encode(conf *Config) ([]byte, error) {
+	b, err := json.Marshal(conf)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, err
+	}
+	for _, key := range commandLineKeys {
+		delete(raw, key)
+	}
+	raw["schema_version"] = SchemaVersion
+	return json.MarshalIndent(raw, "", "  ")
+}
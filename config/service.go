@@ -0,0 +1,164 @@
+package config
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrUnchanged may be returned by an Update func that changed nothing. Update
+// then skips saving and notifying and returns nil.
+var ErrUnchanged = errors.New("config unchanged")
+
+var (
+	mu          sync.RWMutex
+	current     = &Config{}
+	subscribers = make(map[chan Config]struct{})
+	watchers    []func(c *Config)
+)
+
+// Snapshot returns a deep copy of the config. It stays the same while the
+// config changes and may be modified freely.
+func Snapshot() Config {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current.clone()
+}
+
+// Update runs fn with the config locked for writing. If fn returns nil the
+// config is checked and encoded, and only once that succeeded swapped in and
+// published to subscribers. If fn, a registered check or encoding fails the
+// changes fn made are discarded. With a WriteDelay the file is only written
+// that long after, so a write error is logged rather than returned; call
+// Flush to write it right away. fn must not keep references into the config
+// or call back into this package.
+func Update(fn func(c *Config) error) error {
+	mu.Lock()
+	defer mu.Unlock()
+	next := current.clone()
+	if err := fn(&next); err != nil {
+		if errors.Is(err, ErrUnchanged) {
+			return nil
+		}
+		return err
+	}
+	if err := check(&next); err != nil {
+		return err
+	}
+	if err := save(&next); err != nil {
+		return err
+	}
+	current = &next
+	notify()
+	return nil
+}
+
+// Replace swaps the whole config for conf, saves it and notifies subscribers
+func Replace(conf Config) error {
+	return Update(func(c *Config) error {
+		*c = conf.clone()
+		return nil
+	})
+}
+
+// Subscribe returns a channel that receives a snapshot after every change.
+// A subscriber that falls behind only gets the latest snapshot. cancel stops
+// the subscription and closes the channel.
+func Subscribe() (changes <-chan Config, cancel func()) {
+	ch := make(chan Config, 1)
+	mu.Lock()
+	subscribers[ch] = struct{}{}
+	mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			mu.Lock()
+			delete(subscribers, ch)
+			mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Watch calls fn with the config now and after every change, before Update
+// returns. fn runs with the config locked: it must be quick, must not modify
+// or keep c and must not call back into this package.
+func Watch(fn func(c *Config)) {
+	mu.Lock()
+	defer mu.Unlock()
+	watchers = append(watchers, fn)
+	fn(current)
+}
+
+// notify must be called with mu held
+func notify() {
+	for _, fn := range watchers {
+		fn(current)
+	}
+	for ch := range subscribers {
+		// Drop a snapshot the subscriber has not picked up yet
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- current.clone()
+	}
+}
+
+// set replaces the config without saving, used when loading it
+func set(conf *Config) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = conf
+	notify()
+}
+
+// clone copies every slice and map so the copy shares no memory with c. Nil
+// slices stay nil and empty slices stay empty, so both encode as before.
+func (c *Config) clone() Config {
+	cp := *c
+	if c.Devices != nil {
+		cp.Devices = append(make([]Device, 0, len(c.Devices)), c.Devices...)
+	}
+	if c.Presets != nil {
+		cp.Presets = append(make([]Preset, 0, len(c.Presets)), c.Presets...)
+	}
+	if c.Virtuals != nil {
+		cp.Virtuals = make([]Virtual, len(c.Virtuals))
+		for i, virt := range c.Virtuals {
+			cp.Virtuals[i] = virt
+			if virt.Segments == nil {
+				continue
+			}
+			cp.Virtuals[i].Segments = make([][]interface{}, len(virt.Segments))
+			for j, seg := range virt.Segments {
+				if seg != nil {
+					cp.Virtuals[i].Segments[j] = append(make([]interface{}, 0, len(seg)), seg...)
+				}
+			}
+		}
+	}
+	if c.PowerSupplies != nil {
+		cp.PowerSupplies = append(make([]PowerSupply, 0, len(c.PowerSupplies)), c.PowerSupplies...)
+	}
+	cp.UserColors = cloneStrings(c.UserColors)
+	cp.UserGradients = cloneStrings(c.UserGradients)
+	if c.Auth.Tokens != nil {
+		cp.Auth.Tokens = append(make([]ApiToken, 0, len(c.Auth.Tokens)), c.Auth.Tokens...)
+	}
+	if c.Auth.AllowedOrigins != nil {
+		cp.Auth.AllowedOrigins = append(make([]string, 0, len(c.Auth.AllowedOrigins)), c.Auth.AllowedOrigins...)
+	}
+	return cp
+}
+
+func cloneStrings(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	cp := make(map[string]string, len(m))
+	for k, v := range m {
+		cp[k] = v
+	}
+	return cp
+}
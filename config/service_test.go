@@ -0,0 +1,144 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func setupTestService(t *testing.T, conf Config) {
+	t.Helper()
+	path := writeTestFile(t, "{}\n")
+	GlobalViper = viper.New()
+	GlobalViper.SetConfigFile(path)
+	delay := WriteDelay
+	t.Cleanup(func() { WriteDelay = delay })
+	WriteDelay = 0
+	if err := Replace(conf); err != nil {
+		t.Fatalf("Error setting test config: %v\n", err)
+	}
+}
+
+func TestSnapshotIsolation(t *testing.T) {
+	setupTestService(t, Config{
+		Virtuals: []Virtual{{Id: "couch", Segments: [][]interface{}{{"couch", 0, 35, false}}}},
+	})
+	snap := Snapshot()
+	snap.Virtuals[0].Id = "desk"
+	snap.Virtuals[0].Segments[0][0] = "desk"
+	if got := Snapshot().Virtuals[0]; got.Id != "couch" || got.Segments[0][0] != "couch" {
+		t.Errorf("Modifying a snapshot changed the config: %+v", got)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	setupTestService(t, Config{Port: 8080})
+	changes, cancel := Subscribe()
+	defer cancel()
+
+	err := Update(func(c *Config) error {
+		c.Port = 1
+		return errors.New("nope")
+	})
+	if err == nil || Snapshot().Port != 8080 {
+		t.Errorf("Failed update was applied: %v, port %d", err, Snapshot().Port)
+	}
+	if err := Update(func(c *Config) error { return ErrUnchanged }); err != nil {
+		t.Errorf("ErrUnchanged should not be returned, got %v", err)
+	}
+	select {
+	case conf := <-changes:
+		t.Errorf("Unexpected notification: %+v", conf)
+	default:
+	}
+
+	for _, port := range []int{1, 2, 3} {
+		port := port
+		if err := Update(func(c *Config) error {
+			c.Port = port
+			return nil
+		}); err != nil {
+			t.Fatalf("Error updating: %v\n", err)
+		}
+	}
+	// A subscriber that falls behind gets the latest config only
+	if conf := <-changes; conf.Port != 3 {
+		t.Errorf("Expected the latest port 3 but got %d", conf.Port)
+	}
+
+	cancel()
+	if _, ok := <-changes; ok {
+		t.Errorf("Channel not closed after cancel")
+	}
+}
+
+func TestUpdateNotSaved(t *testing.T) {
+	setupTestService(t, Config{Port: 8080})
+	changes, cancel := Subscribe()
+	defer cancel()
+	configWriter.mu.Lock()
+	configWriter.disabled = errors.New("newer schema")
+	configWriter.mu.Unlock()
+
+	err := Update(func(c *Config) error {
+		c.Port = 1
+		return nil
+	})
+	if err == nil || Snapshot().Port != 8080 {
+		t.Errorf("Update that was not saved was applied: %v, port %d", err, Snapshot().Port)
+	}
+	select {
+	case conf := <-changes:
+		t.Errorf("Unexpected notification of an update that was not saved: %+v", conf)
+	default:
+	}
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	setupTestService(t, Config{})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			_ = Update(func(c *Config) error {
+				c.Devices = append(c.Devices, Device{Id: fmt.Sprint(i)})
+				return nil
+			})
+		}(i)
+		go func() {
+			defer wg.Done()
+			for _, dev := range Snapshot().Devices {
+				_ = dev.Id
+			}
+		}()
+	}
+	wg.Wait()
+	if n := len(Snapshot().Devices); n != 8 {
+		t.Errorf("Expected 8 devices but got %d", n)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	setupTestService(t, Config{Port: 8080})
+	var ports []int
+	Watch(func(c *Config) { ports = append(ports, c.Port) })
+	t.Cleanup(func() {
+		mu.Lock()
+		watchers = watchers[:len(watchers)-1]
+		mu.Unlock()
+	})
+	if err := Update(func(c *Config) error {
+		c.Port = 1
+		return nil
+	}); err != nil {
+		t.Fatalf("Error updating: %v\n", err)
+	}
+	// Called right away and before Update returns
+	if len(ports) != 2 || ports[0] != 8080 || ports[1] != 1 {
+		t.Errorf("Expected ports [8080 1] but got %v", ports)
+	}
+}
@@ -0,0 +1,186 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"ledfx/logger"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// WriteDelay is how long Save collects further changes before writing them
+// to disk. Zero or less writes right away.
+var WriteDelay = 500 * time.Millisecond
+
+type writer struct {
+	mu    sync.Mutex
+	path  string
+	data  []byte
+	timer *time.Timer
+	// disabled keeps us from overwriting a file we could not fully read
+	disabled error
+}
+
+var configWriter writer
+
+// save schedules writing conf to the config file. Saves within WriteDelay
+// of each other are written once.
+func save(conf *Config) error {
+	data, err := encode(conf)
+	if err != nil {
+		return fmt.Errorf("error encoding config: %w", err)
+	}
+
+	w := &configWriter
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.disabled != nil {
+		return fmt.Errorf("not saving config: %w", w.disabled)
+	}
+	w.path = GlobalViper.ConfigFileUsed()
+	w.data = data
+	if WriteDelay <= 0 {
+		return w.flushLocked()
+	}
+	if w.timer == nil {
+		w.timer = time.AfterFunc(WriteDelay, func() {
+			if err := Flush(); err != nil {
+				logger.Logger.WithField("category", "Config").Errorf("Error writing config: %v", err)
+			}
+		})
+	}
+	return nil
+}
+
+// Flush writes a pending Save right away
+func Flush() error {
+	configWriter.mu.Lock()
+	defer configWriter.mu.Unlock()
+	return configWriter.flushLocked()
+}
+
+func (w *writer) flushLocked() error {
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	if w.data == nil {
+		return nil
+	}
+	data := w.data
+	w.data = nil
+	return writeAtomic(w.path, data)
+}
+
+// BackupPath is where the previous version of the config file is kept
+func BackupPath(path string) string {
+	return path + ".bak"
+}
+
+// RejectedPath is where a config file with bad entries is kept as it was
+func RejectedPath(path string) string {
+	return path + ".rejected"
+}
+
+// writeAtomic replaces the file at path with data. It writes a temp file and
+// renames it over path, so a crash never leaves a half written config. The
+// previous file is kept as a backup unless it is not valid JSON.
+func writeAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("error creating temp config file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing temp config file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error syncing temp config file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error closing temp config file: %w", err)
+	}
+
+	if previous, err := os.ReadFile(path); err == nil && json.Valid(previous) {
+		if err := os.WriteFile(BackupPath(path), previous, 0644); err != nil {
+			return fmt.Errorf("error writing config backup: %w", err)
+		}
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error replacing config file: %w", err)
+	}
+	return nil
+}
+
+// load reads, migrates and validates the config file at path into v and
+// returns the result. Entries that fail validation are left out and returned
+// as a *LoadError; the file as it was is kept at RejectedPath. migrated
+// reports whether the file was written by an older schema version.
+func load(v *viper.Viper, path string) (conf *Config, migrated bool, err error) {
+	var problems []string
+	original, err := os.ReadFile(path)
+	if err != nil {
+		return &Config{}, false, err
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(original, &raw); err != nil {
+		problems = append(problems, fmt.Sprintf("file is not valid JSON: %v", err))
+		raw = make(map[string]interface{})
+		if backup, bakErr := os.ReadFile(BackupPath(path)); bakErr == nil && json.Unmarshal(backup, &raw) == nil {
+			problems = append(problems, "loaded "+filepath.Base(BackupPath(path))+" instead")
+		}
+	}
+	if raw == nil {
+		raw = make(map[string]interface{})
+	}
+
+	from, err := migrate(raw)
+	if err != nil {
+		// Load what we understand, but leave the file alone
+		configWriter.mu.Lock()
+		configWriter.disabled = err
+		configWriter.mu.Unlock()
+		problems = append(problems, err.Error())
+	}
+	problems = append(problems, validate(raw)...)
+
+	clean, err := json.Marshal(raw)
+	if err != nil {
+		return &Config{}, false, err
+	}
+	v.SetConfigFile(path)
+	v.SetConfigType("json")
+	if err := v.ReadConfig(bytes.NewReader(clean)); err != nil {
+		return &Config{}, false, err
+	}
+	conf = &Config{}
+	if err := v.Unmarshal(conf); err != nil {
+		return &Config{}, false, err
+	}
+	// viper lowercases map keys, but color and gradient names keep their case
+	var names struct {
+		UserColors    map[string]string `json:"user_colors"`
+		UserGradients map[string]string `json:"user_gradients"`
+	}
+	if err := json.Unmarshal(clean, &names); err != nil {
+		return &Config{}, false, err
+	}
+	conf.UserColors, conf.UserGradients = names.UserColors, names.UserGradients
+
+	if len(problems) > 0 {
+		if err := os.WriteFile(RejectedPath(path), original, 0644); err != nil {
+			problems = append(problems, fmt.Sprintf("error keeping rejected config: %v", err))
+		}
+		return conf, from < SchemaVersion, &LoadError{Path: path, Problems: problems}
+	}
+	return conf, from < SchemaVersion, nil
+}
@@ -0,0 +1,225 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func writeTestFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "go_config.json")
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("Error writing test config: %v\n", err)
+	}
+	t.Cleanup(func() {
+		configWriter.mu.Lock()
+		defer configWriter.mu.Unlock()
+		if configWriter.timer != nil {
+			configWriter.timer.Stop()
+		}
+		configWriter.timer, configWriter.data, configWriter.disabled = nil, nil, nil
+	})
+	return path
+}
+
+func readTestFile(t *testing.T, path string) map[string]interface{} {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Error reading %s: %v\n", path, err)
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		t.Fatalf("Error decoding %s: %v\n", path, err)
+	}
+	return raw
+}
+
+func TestMigrate(t *testing.T) {
+	raw := map[string]interface{}{"version": false, "config": "", "headless": true, "log-file": "ledfx.log", "verbose": true, "port": float64(8080)}
+	from, err := migrate(raw)
+	if err != nil {
+		t.Fatalf("Error migrating: %v\n", err)
+	}
+	expected := map[string]interface{}{"port": float64(8080), "schema_version": SchemaVersion}
+	if from != 0 || !reflect.DeepEqual(raw, expected) {
+		t.Errorf("Expected %v from version 0 but got %v from version %d", expected, raw, from)
+	}
+
+	if SchemaVersion != 1 {
+		t.Errorf("Expected schema version 1, got %d", SchemaVersion)
+	}
+
+	if _, err := migrate(map[string]interface{}{"schema_version": float64(SchemaVersion + 1)}); err == nil {
+		t.Errorf("Expected an error for a newer schema version")
+	}
+	if _, err := migrate(map[string]interface{}{"schema_version": "one"}); err == nil {
+		t.Errorf("Expected an error for an invalid schema version")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	raw := map[string]interface{}{}
+	err := json.Unmarshal([]byte(`{
+		"port": 8080,
+		"audio": "loud",
+		"devices": [
+			{"id": "couch", "config": {"pixel_count": 60}},
+			{"id": "desk", "config": {"pixel_count": "sixty"}},
+			{"config": {"pixel_count": 30}},
+			{"id": "couch", "config": {"pixel_count": 10}}
+		],
+		"presets": [{"id": "blue", "name": "Blue"}]
+	}`), &raw)
+	if err != nil {
+		t.Fatalf("Error decoding test config: %v\n", err)
+	}
+
+	problems := validate(raw)
+	if len(problems) != 4 {
+		t.Errorf("Expected 4 problems but got %d: %v", len(problems), problems)
+	}
+	if _, ok := raw["audio"]; ok {
+		t.Errorf("Invalid section was not removed")
+	}
+	if devices := raw["devices"].([]interface{}); len(devices) != 1 {
+		t.Errorf("Expected only the first device to remain, got %v", devices)
+	}
+	if presets := raw["presets"].([]interface{}); len(presets) != 1 {
+		t.Errorf("Valid presets were removed: %v", presets)
+	}
+}
+
+func TestChecks(t *testing.T) {
+	RegisterCheck("audio.analysis", func(value string) error {
+		if value != "go" {
+			return errors.New("unknown backend")
+		}
+		return nil
+	})
+	defer delete(checks, "audio.analysis")
+
+	raw := map[string]interface{}{"audio": map[string]interface{}{"analysis": "fft", "fft_size": float64(1024)}}
+	if problems := validate(raw); len(problems) != 1 {
+		t.Errorf("Expected 1 problem but got %v", problems)
+	}
+	if audio := raw["audio"].(map[string]interface{}); len(audio) != 1 || audio["fft_size"] != float64(1024) {
+		t.Errorf("Expected only the analysis backend to be removed, got %v", audio)
+	}
+
+	setupTestService(t, Config{})
+	err := Update(func(c *Config) error {
+		c.Audio.Analysis = "fft"
+		return nil
+	})
+	if !errors.Is(err, ErrInvalid) || Snapshot().Audio.Analysis != "" {
+		t.Errorf("Expected the backend to be rejected, got %v", err)
+	}
+	if err := Update(func(c *Config) error {
+		c.Audio.Analysis = "go"
+		return nil
+	}); err != nil {
+		t.Errorf("Error updating to a valid backend: %v", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := writeTestFile(t, `{"version": false, "devices": [{"id": "couch"}, {"id": "couch"}]}`)
+	conf, migrated, err := load(viper.New(), path)
+	var loadErr *LoadError
+	if !errors.As(err, &loadErr) || len(loadErr.Problems) != 1 {
+		t.Fatalf("Expected one bad entry, got %v", err)
+	}
+	if !migrated || len(conf.Devices) != 1 || conf.SchemaVersion != SchemaVersion {
+		t.Errorf("Unexpected config: migrated %v, %+v", migrated, conf)
+	}
+	if _, err := os.Stat(RejectedPath(path)); err != nil {
+		t.Errorf("Rejected file was not kept: %v", err)
+	}
+}
+
+func TestLoadCorrupt(t *testing.T) {
+	path := writeTestFile(t, `{"devices": [`)
+	if err := os.WriteFile(BackupPath(path), []byte(`{"schema_version": 1, "port": 1234}`), 0644); err != nil {
+		t.Fatalf("Error writing backup: %v\n", err)
+	}
+	conf, _, err := load(viper.New(), path)
+	if err == nil {
+		t.Errorf("Expected an error for a corrupt config")
+	}
+	if conf.Port != 1234 {
+		t.Errorf("Expected the backup to be loaded, got %+v", conf)
+	}
+}
+
+func TestLoadNewerVersion(t *testing.T) {
+	path := writeTestFile(t, `{"schema_version": 99}`)
+	GlobalViper = viper.New()
+	conf, _, err := load(GlobalViper, path)
+	if err == nil {
+		t.Fatalf("Expected an error for a newer schema version")
+	}
+	set(conf)
+	if err := Replace(*conf); err == nil {
+		t.Errorf("Saving must not overwrite a config from a newer version")
+	}
+}
+
+func TestSave(t *testing.T) {
+	path := writeTestFile(t, `{"schema_version": 1, "port": 1}`)
+	GlobalViper = viper.New()
+	GlobalViper.SetConfigFile(path)
+	defer func(delay time.Duration) { WriteDelay = delay }(WriteDelay)
+	WriteDelay = time.Hour
+
+	if err := Replace(Config{Port: 2, Version: true, Headless: true, LogFile: "ledfx.log"}); err != nil {
+		t.Fatalf("Error saving: %v\n", err)
+	}
+	if err := Update(func(c *Config) error {
+		c.Port = 3
+		c.Version = false
+		return nil
+	}); err != nil {
+		t.Fatalf("Error saving: %v\n", err)
+	}
+	if port := readTestFile(t, path)["port"]; port != float64(1) {
+		t.Errorf("Save wrote before the write delay, port is %v", port)
+	}
+
+	if err := Flush(); err != nil {
+		t.Fatalf("Error flushing: %v\n", err)
+	}
+	written := readTestFile(t, path)
+	if written["port"] != float64(3) || written["schema_version"] != float64(SchemaVersion) {
+		t.Errorf("Unexpected config written: %v", written)
+	}
+	for _, key := range commandLineKeys {
+		if _, ok := written[key]; ok {
+			t.Errorf("Command line only key %s was written: %v", key, written)
+		}
+	}
+	if port := readTestFile(t, BackupPath(path))["port"]; port != float64(1) {
+		t.Errorf("Expected the previous file as backup, port is %v", port)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(filepath.Dir(path), ".*tmp*")); len(matches) != 0 {
+		t.Errorf("Temp files left behind: %v", matches)
+	}
+}
+
+func TestLoadUserColors(t *testing.T) {
+	path := writeTestFile(t, `{"schema_version": 1, "user_colors": {"Sunset": "#ff8000"}, "user_gradients": {"Dusk": "linear-gradient(90deg, #ff8000 0%, #000080 100%)"}}`)
+	conf, _, err := load(viper.New(), path)
+	if err != nil {
+		t.Fatalf("Error loading: %v\n", err)
+	}
+	if conf.UserColors["Sunset"] != "#ff8000" || conf.UserGradients["Dusk"] == "" {
+		t.Errorf("Color and gradient names lost their case: %v %v", conf.UserColors, conf.UserGradients)
+	}
+}
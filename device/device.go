@@ -2,6 +2,7 @@ package device
 
 import (
 	"errors"
+	"fmt"
 	"ledfx/color"
 	"ledfx/config"
 )
@@ -18,43 +19,84 @@ func AddDeviceToConfig(device config.Device) (err error) {
 		return
 	}
 
-	if config.GlobalConfig.Devices == nil {
-		config.GlobalConfig.Devices = make([]config.Device, 0)
+	return config.Update(func(c *config.Config) error {
+		for index, dev := range c.Devices {
+			if dev.Id == device.Id {
+				c.Devices[index] = device
+				return nil
+			}
+		}
+		c.Devices = append(c.Devices, device)
+		return nil
+	})
+}
+
+// RemoveDeviceFromConfig removes the device with the given id, the virtual
+// that represents it and any segments other virtuals have on it.
+func RemoveDeviceFromConfig(id string) (err error) {
+	if id == "" {
+		return errors.New("device id is empty. Please provide Id to remove device from config")
 	}
 
-	for index, dev := range config.GlobalConfig.Devices {
-		if dev.Id == device.Id {
-			config.GlobalConfig.Devices[index] = device
-			return config.GlobalViper.WriteConfig()
+	return config.Update(func(c *config.Config) error {
+		index := -1
+		for i, dev := range c.Devices {
+			if dev.Id == id {
+				index = i
+				break
+			}
 		}
-	}
+		if index < 0 {
+			return fmt.Errorf("device '%s' does not exist", id)
+		}
+		c.Devices = append(c.Devices[:index], c.Devices[index+1:]...)
 
-	config.GlobalConfig.Devices = append(config.GlobalConfig.Devices, device)
-	config.GlobalViper.Set("devices", config.GlobalConfig.Devices)
-	return config.GlobalViper.WriteConfig()
+		virtuals := c.Virtuals[:0]
+		for _, virt := range c.Virtuals {
+			if virt.IsDevice == id {
+				continue
+			}
+			segments := virt.Segments[:0]
+			for _, seg := range virt.Segments {
+				if len(seg) > 0 && seg[0] == id {
+					continue
+				}
+				segments = append(segments, seg)
+			}
+			virt.Segments = segments
+			virtuals = append(virtuals, virt)
+		}
+		c.Virtuals = virtuals
+		return nil
+	})
 }
 
-func AddDeviceAsVirtualToConfig(virtual config.Virtual) (exists bool, err error) {
-	if virtual.Id == "" {
-		return exists, errors.New("virtual id is empty. Please provide Id to add virtual to config")
+// ValidateDevice checks that a device config can be used to drive a strip
+func ValidateDevice(device config.Device) error {
+	switch {
+	case device.Id == "":
+		return errors.New("device id is empty")
+	case device.Type == "":
+		return errors.New("device type is empty")
+	case device.Config.Name == "":
+		return errors.New("device name is empty")
+	case device.Config.IpAddress == "":
+		return errors.New("device ip_address is empty")
+	case device.Config.PixelCount <= 0:
+		return fmt.Errorf("device pixel_count must be positive, got %d", device.Config.PixelCount)
+	case device.Config.Port < 0 || device.Config.Port > 65535:
+		return fmt.Errorf("device port %d is out of range", device.Config.Port)
 	}
-	/*v = config.GlobalViper
-	c = config.GlobalConfig*/
-
-	if config.GlobalConfig.Virtuals == nil {
-		config.GlobalConfig.Virtuals = make([]config.Virtual, 0)
-	}
-
-	for index, virt := range config.GlobalConfig.Virtuals {
-		if virt.Id == virtual.Id {
-			virtual.Active = virt.Active
-			config.GlobalConfig.Virtuals[index] = virtual
-			config.GlobalViper.Set("virtuals", config.GlobalConfig.Virtuals)
-			return true, config.GlobalViper.WriteConfig()
+	if device.Config.UdpPacketType != "" {
+		if _, ok := UDPProtocols[device.Config.UdpPacketType]; !ok {
+			return fmt.Errorf("unknown udp_packet_type '%s'", device.Config.UdpPacketType)
 		}
 	}
-
-	config.GlobalConfig.Virtuals = append(config.GlobalConfig.Virtuals, virtual)
-	config.GlobalViper.Set("virtuals", config.GlobalConfig.Virtuals)
-	return false, config.GlobalViper.WriteConfig()
+	if _, _, err := udpOutput(device); err != nil {
+		return fmt.Errorf("invalid calibration: %w", err)
+	}
+	if err := ValidatePower(device.Config.Power); err != nil {
+		return fmt.Errorf("invalid power: %w", err)
+	}
+	return nil
 }
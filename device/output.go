@@ -0,0 +1,122 @@
+package device
+
+import (
+	"fmt"
+	"ledfx/color"
+	"ledfx/config"
+	"math"
+	"strings"
+)
+
+// Output turns frames into the bytes a strip takes. It applies the
+// calibration of a device, so every device type corrects colors alike.
+// An Output that dithers keeps the rounding errors of the last frame and
+// must not be shared between devices.
+type Output struct {
+	order  []int // index into red, green, blue and white of each channel sent
+	white  bool
+	gamma  float64
+	scale  color.Color
+	dither bool
+	// residual is the rounding error of every byte of the last frame
+	residual []float64
+	limiter  *Limiter
+}
+
+var channelIndex = map[rune]int{'r': 0, 'g': 1, 'b': 2, 'w': 3}
+
+// plain sends colors as they are in RGB order
+var plain, _ = NewOutput(config.Calibration{})
+
+// NewOutput returns the output stage for cal
+func NewOutput(cal config.Calibration) (*Output, error) {
+	o := &Output{gamma: 1, scale: color.Color{1, 1, 1}, dither: cal.Dither}
+
+	order := strings.ToLower(cal.ChannelOrder)
+	if order == "" {
+		order = "rgb"
+	}
+	seen := make(map[rune]bool)
+	for _, ch := range order {
+		i, ok := channelIndex[ch]
+		if !ok || seen[ch] {
+			break
+		}
+		seen[ch] = true
+		o.order = append(o.order, i)
+	}
+	o.white = seen['w']
+	if len(o.order) != len(order) || len(seen) < 3 || (len(seen) == 3 && o.white) {
+		return nil, fmt.Errorf("invalid channel_order '%s', must name R, G and B and optionally W once each", cal.ChannelOrder)
+	}
+
+	switch {
+	case !(cal.Gamma >= 0 && cal.Gamma <= 5): // also catches NaN
+		return nil, fmt.Errorf("gamma %v is out of range 0-5", cal.Gamma)
+	case cal.Gamma > 0:
+		o.gamma = cal.Gamma
+	}
+
+	if cal.WhitePoint != "" {
+		wp, err := color.NewColor(cal.WhitePoint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid white_point: %w", err)
+		}
+		o.scale = wp
+	}
+	return o, nil
+}
+
+// Channels is the number of bytes sent per pixel
+func (o *Output) Channels() int {
+	return len(o.order)
+}
+
+// Bytes scales colors to the white point, extracts white, applies gamma and
+// rounds every channel to a byte in channel order. Then the limiter, if any,
+// dims the frame to the power budget.
+func (o *Output) Bytes(colors []color.Color) []byte {
+	n := len(o.order)
+	data := make([]byte, len(colors)*n)
+	if o.dither && len(o.residual) != len(data) {
+		o.residual = make([]float64, len(data))
+	}
+	var px [4]float64
+	for i, c := range colors {
+		for ch := range c {
+			px[ch] = clamp(c[ch]) * o.scale[ch]
+		}
+		if o.white {
+			w := math.Min(px[0], math.Min(px[1], px[2]))
+			px[0], px[1], px[2], px[3] = px[0]-w, px[1]-w, px[2]-w, w
+		}
+		for j, ch := range o.order {
+			v := px[ch]
+			if o.gamma != 1 {
+				v = math.Pow(v, o.gamma)
+			}
+			v *= 255
+			k := i*n + j
+			if o.dither {
+				v += o.residual[k]
+			}
+			b := math.Round(math.Min(math.Max(v, 0), 255))
+			if o.dither {
+				o.residual[k] = v - b
+			}
+			data[k] = byte(b)
+		}
+	}
+	if o.limiter != nil {
+		o.limiter.Limit(data, len(colors))
+	}
+	return data
+}
+
+// clamp limits v to 0-1, NaN becomes 0
+func clamp(v float64) float64 {
+	if !(v > 0) {
+		return 0
+	}
+	return math.Min(v, 1)
+}
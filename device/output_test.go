@@ -0,0 +1,83 @@
+package device
+
+import (
+	"bytes"
+	"ledfx/color"
+	"ledfx/config"
+	"testing"
+)
+
+func TestOutput(t *testing.T) {
+	cases := []struct {
+		name string
+		cal  config.Calibration
+		in   []color.Color
+		out  []byte
+	}{
+		{"plain", config.Calibration{}, []color.Color{{1, 0.5, 0}, {2, -1, 0.999}}, []byte{255, 128, 0, 255, 0, 255}},
+		{"grb", config.Calibration{ChannelOrder: "GRB"}, []color.Color{{1, 0.5, 0}}, []byte{128, 255, 0}},
+		{"gamma", config.Calibration{Gamma: 2}, []color.Color{{0.5, 1, 0}}, []byte{64, 255, 0}},
+		{"white point", config.Calibration{WhitePoint: "#ff8000"}, []color.Color{{1, 1, 1}}, []byte{255, 128, 0}},
+		{"rgbw", config.Calibration{ChannelOrder: "grbw"}, []color.Color{{1, 0.5, 0.25}}, []byte{64, 191, 0, 64}},
+	}
+	for _, c := range cases {
+		o, err := NewOutput(c.cal)
+		if err != nil {
+			t.Fatalf("Error creating %s output: %v\n", c.name, err)
+		}
+		if got := o.Bytes(c.in); !bytes.Equal(got, c.out) {
+			t.Errorf("Failed %s output: expected %v but got %v", c.name, c.out, got)
+		}
+	}
+}
+
+func TestOutputDither(t *testing.T) {
+	o, err := NewOutput(config.Calibration{Dither: true})
+	if err != nil {
+		t.Fatalf("Error creating output: %v\n", err)
+	}
+	// A quarter step rounds to 0 without dithering, dithered it lights every
+	// fourth frame
+	var sum int
+	for i := 0; i < 100; i++ {
+		sum += int(o.Bytes([]color.Color{{0.25 / 255, 0, 0}})[0])
+	}
+	if sum != 25 {
+		t.Errorf("Expected dithered sum 25 but got %d", sum)
+	}
+}
+
+func TestOutputInvalid(t *testing.T) {
+	for _, cal := range []config.Calibration{
+		{ChannelOrder: "RG"},
+		{ChannelOrder: "RGBB"},
+		{ChannelOrder: "RGW"},
+		{ChannelOrder: "RGBX"},
+		{Gamma: -1},
+		{WhitePoint: "warm"},
+	} {
+		if _, err := NewOutput(cal); err == nil {
+			t.Errorf("Expected error for %+v", cal)
+		}
+	}
+}
+
+func TestUDPOutput(t *testing.T) {
+	cases := []struct {
+		conf     config.DeviceConfig
+		protocol byte
+		e        bool
+	}{
+		{config.DeviceConfig{}, DNRGB, false},
+		{config.DeviceConfig{Calibration: config.Calibration{ChannelOrder: "RGBW"}}, DRGBW, false},
+		{config.DeviceConfig{UdpPacketType: "DRGBW"}, DRGBW, false},
+		{config.DeviceConfig{UdpPacketType: "DRGB", Calibration: config.Calibration{ChannelOrder: "GRBW"}}, 0, true},
+		{config.DeviceConfig{UdpPacketType: "DRGBW", Calibration: config.Calibration{ChannelOrder: "GRB"}}, 0, true},
+	}
+	for _, c := range cases {
+		_, protocol, err := udpOutput(config.Device{Config: c.conf})
+		if protocol != c.protocol || (err == nil == c.e) {
+			t.Errorf("Failed for %+v: expected (%v, %v) but got (%v, %v)", c.conf, c.protocol, c.e, protocol, err)
+		}
+	}
+}
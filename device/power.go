@@ -0,0 +1,242 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"ledfx/config"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultMilliampsPerChannel is the current of one WS2812 channel at full
+// brightness
+const DefaultMilliampsPerChannel = 20
+
+const (
+	// release is how long a limited device takes to get most of its
+	// brightness back once it is within its budget again
+	release = 500 * time.Millisecond
+	// staleAfter is how long the draw of a device that sends no frames
+	// still counts against its supply
+	staleAfter = time.Second
+)
+
+// PowerDraw is the current a device draws
+type PowerDraw struct {
+	// EstimatedMilliamps is the draw of the last frame before limiting
+	EstimatedMilliamps float64 `json:"estimated_milliamps"`
+	// Milliamps is the draw of the last frame after limiting
+	Milliamps float64 `json:"milliamps"`
+	// BudgetMilliamps is what the device may draw, 0 if it is not limited
+	BudgetMilliamps float64 `json:"budget_milliamps"`
+	// Scale is the brightness the device is dimmed to, 1 if it is not
+	Scale  float64   `json:"scale"`
+	Supply string    `json:"supply,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+// SupplyDraw is the current the devices on a power supply draw
+type SupplyDraw struct {
+	MaxMilliamps int      `json:"max_milliamps"`
+	Milliamps    float64  `json:"milliamps"`
+	Devices      []string `json:"devices"`
+}
+
+// PowerReport is the draw of every device that sent a frame lately, by id,
+// and of every power supply
+type PowerReport struct {
+	Devices  map[string]PowerDraw  `json:"devices"`
+	Supplies map[string]SupplyDraw `json:"supplies"`
+}
+
+var (
+	powerMu sync.Mutex
+	// supplies holds the budgets of the configured power supplies by id
+	supplies = make(map[string]int)
+	// draws holds the last draw of every device by id
+	draws = make(map[string]PowerDraw)
+)
+
+func init() {
+	config.Watch(func(c *config.Config) {
+		powerMu.Lock()
+		defer powerMu.Unlock()
+		supplies = make(map[string]int, len(c.PowerSupplies))
+		for _, supply := range c.PowerSupplies {
+			supplies[supply.Id] = supply.MaxMilliamps
+		}
+	})
+}
+
+// Limiter estimates the current a device draws from its frames and dims them
+// to stay within the budget of the device and its share of its power supply.
+// It dims at once when a frame is over budget but brightens again slowly, so
+// limiting does not flicker.
+type Limiter struct {
+	id    string
+	conf  config.Power
+	scale float64
+	last  time.Time
+	now   func() time.Time
+}
+
+// NewLimiter returns the limiter of the device with the given id
+func NewLimiter(id string, conf config.Power) *Limiter {
+	if conf.MilliampsPerChannel == 0 {
+		conf.MilliampsPerChannel = DefaultMilliampsPerChannel
+	}
+	return &Limiter{id: id, conf: conf, scale: 1, now: time.Now}
+}
+
+// ValidatePower checks the power settings of a device
+func ValidatePower(conf config.Power) error {
+	switch {
+	case !(conf.MilliampsPerChannel >= 0 && conf.MilliampsPerChannel <= 1000): // also catches NaN
+		return fmt.Errorf("milliamps_per_channel %v is out of range 0-1000", conf.MilliampsPerChannel)
+	case !(conf.IdleMilliamps >= 0 && conf.IdleMilliamps <= 100):
+		return fmt.Errorf("idle_milliamps %v is out of range 0-100", conf.IdleMilliamps)
+	case conf.MaxMilliamps < 0:
+		return fmt.Errorf("max_milliamps must not be negative, got %d", conf.MaxMilliamps)
+	}
+	return nil
+}
+
+// ValidateSupplies checks that power supplies have unique ids and a budget
+func ValidateSupplies(list []config.PowerSupply) error {
+	seen := make(map[string]bool)
+	for i, supply := range list {
+		switch {
+		case supply.Id == "":
+			return fmt.Errorf("power supply %d has no id", i)
+		case seen[supply.Id]:
+			return fmt.Errorf("duplicate power supply id '%s'", supply.Id)
+		case supply.MaxMilliamps <= 0:
+			return fmt.Errorf("power supply '%s' max_milliamps must be positive, got %d", supply.Id, supply.MaxMilliamps)
+		}
+		seen[supply.Id] = true
+	}
+	return nil
+}
+
+var errNoSupply = errors.New("does not exist")
+
+// CheckSupply returns an error if dev names a power supply that is not in list
+func CheckSupply(dev config.Device, list []config.PowerSupply) error {
+	if dev.Config.Power.Supply == "" {
+		return nil
+	}
+	for _, supply := range list {
+		if supply.Id == dev.Config.Power.Supply {
+			return nil
+		}
+	}
+	return fmt.Errorf("power supply '%s' %w", dev.Config.Power.Supply, errNoSupply)
+}
+
+// Limit dims a frame of the given number of pixels as needed and records its draw
+func (l *Limiter) Limit(data []byte, pixels int) {
+	now := l.now()
+	idle := l.conf.IdleMilliamps * float64(pixels)
+	estimated := idle + l.channels(data)
+
+	powerMu.Lock()
+	defer powerMu.Unlock()
+	budget := l.budget(estimated, now)
+	target := 1.0
+	if budget > 0 && estimated > budget {
+		target = math.Max(budget-idle, 0) / (estimated - idle)
+	}
+	if target < l.scale || l.last.IsZero() {
+		l.scale = target
+	} else {
+		l.scale += (target - l.scale) * (1 - math.Exp(-float64(now.Sub(l.last))/float64(release)))
+		// Within a step of a byte the difference can not be seen
+		if target-l.scale < 1.0/255 {
+			l.scale = target
+		}
+	}
+	l.last = now
+
+	drawn := estimated
+	if l.scale < 1 {
+		for i := range data {
+			// Round down so the frame never ends up over budget
+			data[i] = byte(float64(data[i]) * l.scale)
+		}
+		drawn = idle + l.channels(data)
+	}
+	draws[l.id] = PowerDraw{
+		EstimatedMilliamps: estimated,
+		Milliamps:          drawn,
+		BudgetMilliamps:    budget,
+		Scale:              l.scale,
+		Supply:             l.conf.Supply,
+		At:                 now,
+	}
+}
+
+// channels is the current all channels of a frame draw
+func (l *Limiter) channels(data []byte) float64 {
+	var sum int
+	for _, b := range data {
+		sum += int(b)
+	}
+	return float64(sum) / 255 * l.conf.MilliampsPerChannel
+}
+
+// budget is the budget of the device or its share of its supply, whichever
+// is lower. The supply is shared in proportion to what the devices on it
+// would draw. powerMu must be held.
+func (l *Limiter) budget(estimated float64, now time.Time) float64 {
+	budget := float64(l.conf.MaxMilliamps)
+	max, ok := supplies[l.conf.Supply]
+	if l.conf.Supply == "" || !ok {
+		return budget
+	}
+	total := estimated
+	for id, draw := range draws {
+		if id != l.id && draw.Supply == l.conf.Supply && now.Sub(draw.At) < staleAfter {
+			total += draw.EstimatedMilliamps
+		}
+	}
+	share := float64(max)
+	if total > share {
+		share *= estimated / total
+	}
+	if budget == 0 || share < budget {
+		budget = share
+	}
+	return budget
+}
+
+// Power returns the draw of every device that sent a frame lately and of
+// every power supply
+func Power() PowerReport {
+	powerMu.Lock()
+	defer powerMu.Unlock()
+	now := time.Now()
+	report := PowerReport{
+		Devices:  make(map[string]PowerDraw),
+		Supplies: make(map[string]SupplyDraw, len(supplies)),
+	}
+	for id, max := range supplies {
+		report.Supplies[id] = SupplyDraw{MaxMilliamps: max, Devices: []string{}}
+	}
+	for id, draw := range draws {
+		if now.Sub(draw.At) >= staleAfter {
+			continue
+		}
+		report.Devices[id] = draw
+		if supply, ok := report.Supplies[draw.Supply]; ok {
+			supply.Milliamps += draw.Milliamps
+			supply.Devices = append(supply.Devices, id)
+			report.Supplies[draw.Supply] = supply
+		}
+	}
+	for _, supply := range report.Supplies {
+		sort.Strings(supply.Devices)
+	}
+	return report
+}
@@ -0,0 +1,95 @@
+package device
+
+import (
+	"ledfx/config"
+	"testing"
+	"time"
+)
+
+// fakeClock returns a limiter clock that advances by step on every frame
+func fakeClock(step time.Duration) func() time.Time {
+	now := time.Now()
+	return func() time.Time {
+		now = now.Add(step)
+		return now
+	}
+}
+
+// forgetDraws removes the draws of the devices with the given ids once the
+// test is done
+func forgetDraws(t *testing.T, ids ...string) {
+	t.Cleanup(func() {
+		powerMu.Lock()
+		defer powerMu.Unlock()
+		for _, id := range ids {
+			delete(draws, id)
+		}
+	})
+}
+
+func TestLimiter(t *testing.T) {
+	l := NewLimiter("strip", config.Power{MaxMilliamps: 600})
+	l.now = fakeClock(10 * time.Millisecond)
+	forgetDraws(t, "strip")
+
+	// 10 white pixels would draw 600 mA at 20 mA per channel
+	white := func() []byte {
+		data := make([]byte, 60)
+		for i := range data {
+			data[i] = 255
+		}
+		return data
+	}
+	data := white()
+	l.Limit(data, 10)
+	draw := Power().Devices["strip"]
+	if draw.EstimatedMilliamps != 1200 || draw.Milliamps > 600 || data[0] != 127 {
+		t.Errorf("Frame was not limited: %+v, %v", draw, data[0])
+	}
+
+	// Once within budget the brightness comes back over the release time
+	dark := make([]byte, 60)
+	l.Limit(dark, 10)
+	l.Limit(white()[:30], 10)
+	if l.scale <= 0.5 || l.scale >= 1 {
+		t.Errorf("Expected brightness between 0.5 and 1 after one frame, got %v", l.scale)
+	}
+	for i := 0; i < 300; i++ {
+		l.Limit(white()[:30], 10)
+	}
+	if l.scale != 1 {
+		t.Errorf("Expected full brightness after the release time, got %v", l.scale)
+	}
+}
+
+func TestLimiterSupply(t *testing.T) {
+	powerMu.Lock()
+	supplies = map[string]int{"psu": 900}
+	powerMu.Unlock()
+	defer func() {
+		powerMu.Lock()
+		supplies = map[string]int{}
+		powerMu.Unlock()
+	}()
+
+	a := NewLimiter("a", config.Power{Supply: "psu"})
+	b := NewLimiter("b", config.Power{Supply: "psu", MaxMilliamps: 200})
+	forgetDraws(t, "a", "b")
+
+	full := func(n int) []byte {
+		data := make([]byte, n)
+		for i := range data {
+			data[i] = 255
+		}
+		return data
+	}
+	b.Limit(full(30), 10) // would draw 600 mA, limited to 200
+	a.Limit(full(60), 20) // would draw 1200 mA, shares 900 mA with b
+	report := Power()
+	if draw := report.Devices["a"]; draw.BudgetMilliamps != 600 || draw.Milliamps > 600 {
+		t.Errorf("Unexpected draw of a: %+v", draw)
+	}
+	if supply := report.Supplies["psu"]; len(supply.Devices) != 2 || supply.Milliamps > 900 {
+		t.Errorf("Unexpected draw of supply: %+v", supply)
+	}
+}
@@ -2,10 +2,14 @@ package device
 
 import (
 	"errors"
+	"fmt"
 	"ledfx/color"
 	"ledfx/config"
 	"ledfx/logger"
 	"net"
+	"reflect"
+	"sync"
+	"syscall"
 )
 
 const (
@@ -24,31 +28,121 @@ var UDPProtocols = map[string]byte{
 }
 
 type UDPDevice struct {
+	Id         string
 	Name       string
 	Port       int
 	Connection net.Conn
 	Protocol   byte
 	Config     config.DeviceConfig
 	pb         *PacketBuilder
+	output     *Output
+	// mu orders the frames of senders sharing the device
+	mu sync.Mutex
 }
 
-// Flatten array and convert to bytes
+var (
+	openMu sync.Mutex
+	// open holds the devices shared through OpenUDPDevice by id
+	open = make(map[string]*UDPDevice)
+)
+
+// OpenUDPDevice returns the initialized device of dev, shared by every sender
+// until CloseUDPDevice. Its frames go through one output and limiter, so
+// dithering and power smoothing carry over from frame to frame. A device
+// whose config changed is opened again.
+func OpenUDPDevice(dev config.Device) (*UDPDevice, error) {
+	openMu.Lock()
+	defer openMu.Unlock()
+	if d, ok := open[dev.Id]; ok {
+		if reflect.DeepEqual(d.Config, dev.Config) {
+			return d, nil
+		}
+		_ = d.Close()
+		delete(open, dev.Id)
+	}
+	d := NewUDPDevice(dev)
+	if err := d.Init(); err != nil {
+		return nil, err
+	}
+	open[dev.Id] = d
+	return d, nil
+}
+
+// CloseUDPDevice closes the shared device with the given id, if it is open
+func CloseUDPDevice(id string) {
+	openMu.Lock()
+	defer openMu.Unlock()
+	if d, ok := open[id]; ok {
+		_ = d.Close()
+		delete(open, id)
+	}
+}
+
+// CloseUDPDevices closes every shared device
+func CloseUDPDevices() {
+	openMu.Lock()
+	defer openMu.Unlock()
+	for id, d := range open {
+		_ = d.Close()
+		delete(open, id)
+	}
+}
+
+// NewUDPDevice returns a device for dev, call Init before sending
+func NewUDPDevice(dev config.Device) *UDPDevice {
+	conf := dev.Config
+	return &UDPDevice{
+		Id:       dev.Id,
+		Name:     conf.Name,
+		Port:     conf.Port,
+		Protocol: UDPProtocols[conf.UdpPacketType],
+		Config:   conf,
+	}
+}
+
+// Flatten array and convert to bytes, without calibration
 func ColorsToBytes(colors []color.Color) []byte {
-	bytes := make([]byte, len(colors)*3)
-	for i, c := range colors {
-		bytes[i*3] = byte(c[0] * 255)
-		bytes[i*3+1] = byte(c[1] * 255)
-		bytes[i*3+2] = byte(c[2] * 255)
+	return plain.Bytes(colors)
+}
+
+// udpOutput returns the output stage and protocol of a UDP device. Unless
+// the config names a protocol it follows the channel order.
+func udpOutput(dev config.Device) (*Output, byte, error) {
+	conf := dev.Config
+	protocol := UDPProtocols[conf.UdpPacketType]
+	cal := conf.Calibration
+	if cal.ChannelOrder == "" && protocol == DRGBW {
+		cal.ChannelOrder = "RGBW"
+	}
+	out, err := NewOutput(cal)
+	if err != nil {
+		return nil, 0, err
+	}
+	if protocol == 0 {
+		protocol = DNRGB
+		if out.Channels() == 4 {
+			protocol = DRGBW
+		}
 	}
-	return bytes
+	if (protocol == DRGBW) != (out.Channels() == 4) {
+		return nil, 0, fmt.Errorf("channel_order '%s' does not fit udp_packet_type '%s', only DRGBW has a white channel", cal.ChannelOrder, conf.UdpPacketType)
+	}
+	out.limiter = NewLimiter(dev.Id, conf.Power)
+	return out, protocol, nil
 }
 
 // Need to store the connection on the device struct
 func (d *UDPDevice) Init() error {
+	output, protocol, err := udpOutput(config.Device{Id: d.Id, Config: d.Config})
+	if err != nil {
+		return err
+	}
+	d.output, d.Protocol = output, protocol
+
 	// hostName := d.Config.IpAddress
 
 	// service := hostName + ":" + strconv.Itoa(d.Port)
-	service := d.Config.IpAddress + ":21324"
+	service := net.JoinHostPort(d.Config.IpAddress, "21324")
 
 	RemoteAddr, err := net.ResolveUDPAddr("udp", service)
 	if err != nil {
@@ -82,14 +176,29 @@ func (d *UDPDevice) SendData(colors []color.Color, timeout byte) error {
 		return errors.New("device must first be initialized")
 	}
 
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	packet := d.BuildPacket(colors, timeout)
 
 	// logger.Logger.Debug("Sending Data: ", packet)
 	_, err := d.Connection.Write(packet)
-	if err != nil {
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		// An earlier frame was refused, e.g. while WLED restarted. The socket
+		// reports that on this write instead of sending, so send again.
+		_, err = d.Connection.Write(packet)
+	}
+	return err
+}
+
+// Blackout sends a black frame with timeout 0 to the device, which makes WLED
+// leave realtime mode right away instead of waiting for its timeout
+func Blackout(device config.Device) error {
+	dev := NewUDPDevice(device)
+	if err := dev.Init(); err != nil {
 		return err
 	}
-	return nil
+	defer dev.Close()
+	return dev.SendData(make([]color.Color, device.Config.PixelCount), 0x00)
 }
 
 func (d *UDPDevice) BuildPacket(colors []color.Color, timeout byte) []byte {
@@ -112,8 +221,11 @@ func (d *UDPDevice) BuildPacket(colors []color.Color, timeout byte) []byte {
 
 	packet = append(packet, ledOffset...)
 
-	data := ColorsToBytes(colors)
-	packet = append(packet, data...)
+	output := d.output
+	if output == nil {
+		output = plain
+	}
+	packet = append(packet, output.Bytes(colors)...)
 	return packet
 }
 
@@ -1,114 +1,39 @@
 package device
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io/ioutil"
 	"ledfx/config"
-	"ledfx/logger"
-	"log"
-	"net"
-	"net/http"
-	"time"
+	"ledfx/device/wled"
+	"sync"
 )
 
-type WledInfo struct {
-	Ver  string `json:"ver"`
-	Vid  int    `json:"vid"`
-	Leds struct {
-		Count  int  `json:"count"`
-		Rgbw   bool `json:"rgbw"`
-		Wv     bool `json:"wv"`
-		Cct    bool `json:"cct"`
-		Pwr    int  `json:"pwr"`
-		Fps    int  `json:"fps"`
-		Maxpwr int  `json:"maxpwr"`
-		Maxseg int  `json:"maxseg"`
-	} `json:"leds"`
-	Str      bool   `json:"str"`
-	Name     string `json:"name"`
-	Udpport  int    `json:"udpport"`
-	Live     bool   `json:"live"`
-	Lm       string `json:"lm"`
-	Lip      string `json:"lip"`
-	Ws       int    `json:"ws"`
-	Fxcount  int    `json:"fxcount"`
-	Palcount int    `json:"palcount"`
-	Wifi     struct {
-		Bssid   string `json:"bssid"`
-		Rssi    int    `json:"rssi"`
-		Signal  int    `json:"signal"`
-		Channel int    `json:"channel"`
-	} `json:"wifi"`
-	Fs struct {
-		U   int `json:"u"`
-		T   int `json:"t"`
-		Pmt int `json:"pmt"`
-	} `json:"fs"`
-	Ndc      int    `json:"ndc"`
-	Arch     string `json:"arch"`
-	Core     string `json:"core"`
-	Lwip     int    `json:"lwip"`
-	Freeheap int    `json:"freeheap"`
-	Uptime   int    `json:"uptime"`
-	Opt      int    `json:"opt"`
-	Brand    string `json:"brand"`
-	Product  string `json:"product"`
-	Mac      string `json:"mac"`
-	IP       string `json:"ip"`
-}
-
-func DetectWled(ip net.IP, id string) bool {
-	// Resolve additional WLED-info
-	url := "http://" + ip.String() + "/json/info"
-	spaceClient := http.Client{
-		Timeout: time.Second * 2, // Timeout after 2 seconds
-	}
-	req, err := http.NewRequest(http.MethodGet, url, nil)
-	if err != nil {
-		log.Fatal(err)
-	}
-	req.Header.Set("User-Agent", "spacecount-tutorial")
-	res, getErr := spaceClient.Do(req)
-	if getErr != nil {
-		log.Fatal(getErr)
-	}
-	if res.Body != nil {
-		defer res.Body.Close()
-	}
-	body, readErr := ioutil.ReadAll(res.Body)
-	if readErr != nil {
-		log.Fatal(readErr)
-	}
-	wledInfo1 := WledInfo{}
-	fmt.Println(string(body) + "\n")
-	jsonErr := json.Unmarshal(body, &wledInfo1)
-	if jsonErr != nil {
-		log.Fatal(jsonErr)
-	}
-	// Resolved
-
-	fmt.Print(wledInfo1.Name)
-	logger.Logger.Debug("New WLED found: ")
-
-	// Adding Device
-	err = AddDeviceToConfig(config.Device{
-		// TODO: fill in details
+// WledDevice returns the device for a WLED controller reachable at host,
+// with a virtual for the whole strip and one for every WLED segment if there
+// are several. RGBW strips are sent DRGBW.
+func WledDevice(id, host string, info wled.Info, segments []wled.Segment) (config.Device, []config.Virtual) {
+	dev := config.Device{
 		Config: config.DeviceConfig{
-			Name:       wledInfo1.Name,
-			PixelCount: wledInfo1.Leds.Count,
-			IpAddress:  wledInfo1.IP,
+			Name:       info.Name,
+			PixelCount: info.Leds.Count,
+			IpAddress:  host,
 		},
 		Type: "wled",
 		Id:   id,
-	})
-	if err != nil {
-		logger.Logger.Warn(err)
 	}
+	if info.Leds.Rgbw {
+		dev.Config.UdpPacketType = "DRGBW"
+	}
+	virtuals := []config.Virtual{wledVirtual(id, info.Name, [][]interface{}{{id, 0, info.Leds.Count - 1, false}})}
+	virtuals[0].IsDevice = id
+	if len(segments) > 1 {
+		virtuals = append(virtuals, segmentVirtuals(id, info.Name, segments)...)
+	}
+	return dev, virtuals
+}
 
-	// Adding Virtual
-	var exists bool
-	exists, err = AddDeviceAsVirtualToConfig(config.Virtual{
+func wledVirtual(id, name string, segments [][]interface{}) config.Virtual {
+	return config.Virtual{
 		Config: config.VirtualConfig{
 			CenterOffset:   0,
 			FrequencyMax:   15000,
@@ -116,7 +41,7 @@ func DetectWled(ip net.IP, id string) bool {
 			IconName:       "wled",
 			Mapping:        "span",
 			MaxBrightness:  1,
-			Name:           wledInfo1.Name,
+			Name:           name,
 			PreviewOnly:    false,
 			TransitionMode: "Add",
 			TransitionTime: 0.4,
@@ -130,11 +55,96 @@ func DetectWled(ip net.IP, id string) bool {
 			Type: "singleColor",
 		},
 		Id:       id,
-		IsDevice: id,
-		Segments: [][]interface{}{{id, 0, wledInfo1.Leds.Count - 1, false}},
-	})
+		Segments: segments,
+	}
+}
+
+// segmentVirtuals returns a virtual for every WLED segment of the device
+// with the given id. WLED stops are exclusive, LedFx segment ends are not.
+func segmentVirtuals(id, name string, segments []wled.Segment) []config.Virtual {
+	virtuals := make([]config.Virtual, 0, len(segments))
+	for _, seg := range segments {
+		segName := seg.Name
+		if segName == "" {
+			segName = fmt.Sprintf("%s Segment %d", name, seg.Id)
+		}
+		virtuals = append(virtuals, wledVirtual(
+			fmt.Sprintf("%s-segment-%d", id, seg.Id),
+			segName,
+			[][]interface{}{{id, seg.Start, seg.Stop - 1, seg.Reverse}},
+		))
+	}
+	return virtuals
+}
+
+// stream is the streaming state of one WLED device. Its mu is held across
+// the HTTP calls to the device, which keeps starting and stopping in order
+// without holding up other devices.
+type stream struct {
+	mu sync.Mutex
+	// snapshot is the state from before LedFx started streaming, nil while
+	// it is not
+	snapshot *wled.Snapshot
+}
+
+var (
+	streamsMu sync.Mutex
+	// streams holds the stream of every device id seen. Entries are never
+	// removed, so everyone streaming to an id shares one.
+	streams = make(map[string]*stream)
+)
+
+// deviceStream returns the stream of the device with the given id
+func deviceStream(id string) *stream {
+	streamsMu.Lock()
+	defer streamsMu.Unlock()
+	st, ok := streams[id]
+	if !ok {
+		st = &stream{}
+		streams[id] = st
+	}
+	return st
+}
+
+// StartStreaming remembers the state of a WLED device and turns it on with
+// realtime data allowed, unless it is streaming already. Other device types
+// are left alone.
+func StartStreaming(ctx context.Context, dev config.Device) error {
+	if dev.Type != "wled" {
+		return nil
+	}
+	st := deviceStream(dev.Id)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.snapshot != nil {
+		return nil
+	}
+	client := wled.New(dev.Config.IpAddress)
+	snapshot, err := client.Snapshot(ctx)
 	if err != nil {
-		logger.Logger.Warn(err)
+		return fmt.Errorf("error saving WLED state of '%s': %w", dev.Id, err)
+	}
+	on, lor := true, wled.LiveOverrideOff
+	if err := client.SetState(ctx, wled.Update{On: &on, LiveOverride: &lor}); err != nil {
+		return fmt.Errorf("error preparing WLED '%s' for streaming: %w", dev.Id, err)
+	}
+	st.snapshot = &snapshot
+	return nil
+}
+
+// StopStreaming puts a WLED device back into the state it had before
+// StartStreaming
+func StopStreaming(ctx context.Context, dev config.Device) error {
+	st := deviceStream(dev.Id)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.snapshot == nil {
+		return nil
+	}
+	snapshot := *st.snapshot
+	st.snapshot = nil
+	if err := wled.New(dev.Config.IpAddress).Restore(ctx, snapshot); err != nil {
+		return fmt.Errorf("error restoring WLED state of '%s': %w", dev.Id, err)
 	}
-	return exists
+	return nil
 }
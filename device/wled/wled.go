@@ -0,0 +1,239 @@
+// Package wled talks to WLED controllers over their JSON API, see
+// https://kno.wled.ge/interfaces/json-api/
+package wled
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Timeout limits every request to a controller
+const Timeout = 2 * time.Second
+
+// Live override values of State.LiveOverride
+const (
+	// LiveOverrideOff lets realtime data override the effect of WLED
+	LiveOverrideOff = 0
+	// LiveOverrideOnce ignores realtime data until the stream stops
+	LiveOverrideOnce = 1
+	// LiveOverrideOn ignores realtime data until it is turned off again
+	LiveOverrideOn = 2
+)
+
+// Info is /json/info, the read-only description of a controller
+type Info struct {
+	Ver  string `json:"ver"`
+	Vid  int    `json:"vid"`
+	Leds struct {
+		Count  int  `json:"count"`
+		Rgbw   bool `json:"rgbw"`
+		Wv     bool `json:"wv"`
+		Cct    bool `json:"cct"`
+		Pwr    int  `json:"pwr"`
+		Fps    int  `json:"fps"`
+		Maxpwr int  `json:"maxpwr"`
+		Maxseg int  `json:"maxseg"`
+	} `json:"leds"`
+	Str      bool   `json:"str"`
+	Name     string `json:"name"`
+	Udpport  int    `json:"udpport"`
+	Live     bool   `json:"live"`
+	Lm       string `json:"lm"`
+	Lip      string `json:"lip"`
+	Ws       int    `json:"ws"`
+	Fxcount  int    `json:"fxcount"`
+	Palcount int    `json:"palcount"`
+	Wifi     struct {
+		Bssid   string `json:"bssid"`
+		Rssi    int    `json:"rssi"`
+		Signal  int    `json:"signal"`
+		Channel int    `json:"channel"`
+	} `json:"wifi"`
+	Fs struct {
+		U   int `json:"u"`
+		T   int `json:"t"`
+		Pmt int `json:"pmt"`
+	} `json:"fs"`
+	Ndc      int    `json:"ndc"`
+	Arch     string `json:"arch"`
+	Core     string `json:"core"`
+	Lwip     int    `json:"lwip"`
+	Freeheap int    `json:"freeheap"`
+	Uptime   int    `json:"uptime"`
+	Opt      int    `json:"opt"`
+	Brand    string `json:"brand"`
+	Product  string `json:"product"`
+	Mac      string `json:"mac"`
+	IP       string `json:"ip"`
+}
+
+// State is /json/state, what a controller shows
+type State struct {
+	On           bool      `json:"on"`
+	Brightness   int       `json:"bri"`
+	Transition   int       `json:"transition"`
+	Preset       int       `json:"ps"`
+	Playlist     int       `json:"pl"`
+	LiveOverride int       `json:"lor"`
+	MainSegment  int       `json:"mainseg"`
+	Segments     []Segment `json:"seg"`
+}
+
+// Segment is a range of LEDs WLED runs an effect on. Stop is exclusive.
+type Segment struct {
+	Id         int    `json:"id"`
+	Name       string `json:"n,omitempty"`
+	Start      int    `json:"start"`
+	Stop       int    `json:"stop"`
+	On         bool   `json:"on"`
+	Brightness int    `json:"bri"`
+	Reverse    bool   `json:"rev"`
+	Mirror     bool   `json:"mi"`
+}
+
+// Update changes a state, fields that are nil stay as they are
+type Update struct {
+	On           *bool `json:"on,omitempty"`
+	Brightness   *int  `json:"bri,omitempty"`
+	Preset       *int  `json:"ps,omitempty"`
+	LiveOverride *int  `json:"lor,omitempty"`
+	// Live enters or leaves realtime mode
+	Live *bool `json:"live,omitempty"`
+	// Segments replaces segments by id
+	Segments []Segment `json:"seg,omitempty"`
+}
+
+// Snapshot is a state as the controller sent it, including fields State
+// does not know, so restoring it loses nothing
+type Snapshot json.RawMessage
+
+// Client talks to one controller
+type Client struct {
+	base string
+	http *http.Client
+}
+
+// New returns a client for the controller at host, an IPv4 or IPv6 address
+// or host name with an optional port, or a base URL
+func New(host string) *Client {
+	base := host
+	if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		base = "[" + host + "]"
+	}
+	if !strings.Contains(base, "://") {
+		base = "http://" + base
+	}
+	return &Client{base: strings.TrimSuffix(base, "/"), http: &http.Client{Timeout: Timeout}}
+}
+
+func (c *Client) Info(ctx context.Context) (info Info, err error) {
+	return info, c.do(ctx, http.MethodGet, "/json/info", nil, &info)
+}
+
+func (c *Client) State(ctx context.Context) (state State, err error) {
+	return state, c.do(ctx, http.MethodGet, "/json/state", nil, &state)
+}
+
+// Segments returns the segments of the controller that span any LEDs
+func (c *Client) Segments(ctx context.Context) ([]Segment, error) {
+	state, err := c.State(ctx)
+	if err != nil {
+		return nil, err
+	}
+	segments := make([]Segment, 0, len(state.Segments))
+	for _, seg := range state.Segments {
+		if seg.Stop > seg.Start {
+			segments = append(segments, seg)
+		}
+	}
+	return segments, nil
+}
+
+// SetState applies u
+func (c *Client) SetState(ctx context.Context, u Update) error {
+	return c.do(ctx, http.MethodPost, "/json/state", u, nil)
+}
+
+func (c *Client) SetOn(ctx context.Context, on bool) error {
+	return c.SetState(ctx, Update{On: &on})
+}
+
+// SetBrightness sets the master brightness, 0-255
+func (c *Client) SetBrightness(ctx context.Context, brightness int) error {
+	if brightness < 0 || brightness > 255 {
+		return fmt.Errorf("brightness %d is out of range 0-255", brightness)
+	}
+	return c.SetState(ctx, Update{Brightness: &brightness})
+}
+
+func (c *Client) ApplyPreset(ctx context.Context, id int) error {
+	return c.SetState(ctx, Update{Preset: &id})
+}
+
+// SetLiveOverride sets whether realtime data is ignored, see LiveOverrideOff
+func (c *Client) SetLiveOverride(ctx context.Context, lor int) error {
+	if lor < LiveOverrideOff || lor > LiveOverrideOn {
+		return fmt.Errorf("live override %d is out of range 0-2", lor)
+	}
+	return c.SetState(ctx, Update{LiveOverride: &lor})
+}
+
+// Snapshot returns the current state for Restore
+func (c *Client) Snapshot(ctx context.Context) (Snapshot, error) {
+	var raw json.RawMessage
+	if err := c.do(ctx, http.MethodGet, "/json/state", nil, &raw); err != nil {
+		return nil, err
+	}
+	return Snapshot(raw), nil
+}
+
+// Restore leaves realtime mode and puts back the state of s
+func (c *Client) Restore(ctx context.Context, s Snapshot) error {
+	var state map[string]interface{}
+	if err := json.Unmarshal(s, &state); err != nil {
+		return fmt.Errorf("error decoding snapshot: %w", err)
+	}
+	state["live"] = false
+	return c.do(ctx, http.MethodPost, "/json/state", state, nil)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("error encoding request body: %w", err)
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, body)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: %s", method, path, res.Status)
+	}
+	if out == nil {
+		_, _ = io.Copy(io.Discard, res.Body)
+		return nil
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("error decoding response from %s %s: %w", method, path, err)
+	}
+	return nil
+}
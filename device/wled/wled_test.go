@@ -0,0 +1,116 @@
+package wled
+
+import (
+	"context"
+	"ledfx/device/wled/wledtest"
+	"testing"
+)
+
+const (
+	testInfo  = `{"ver": "0.14.0", "name": "Couch", "ip": "127.0.0.1", "udpport": 21324, "leds": {"count": 60, "rgbw": true}}`
+	testState = `{"on": false, "bri": 80, "transition": 7, "ps": 3, "pl": -1, "lor": 2, "mainseg": 0, "nl": {"on": false, "dur": 60},
+		"seg": [{"id": 0, "start": 0, "stop": 20, "n": "Left", "rev": true}, {"id": 1, "start": 20, "stop": 60}, {"id": 2, "start": 0, "stop": 0}]}`
+)
+
+func TestInfoAndState(t *testing.T) {
+	fake := wledtest.NewServer(testInfo, testState)
+	defer fake.Close()
+	c := New(fake.Host())
+	ctx := context.Background()
+
+	info, err := c.Info(ctx)
+	if err != nil {
+		t.Fatalf("Error getting info: %v\n", err)
+	}
+	if info.Name != "Couch" || info.Leds.Count != 60 || !info.Leds.Rgbw {
+		t.Errorf("Unexpected info: %+v", info)
+	}
+	state, err := c.State(ctx)
+	if err != nil {
+		t.Fatalf("Error getting state: %v\n", err)
+	}
+	if state.On || state.Brightness != 80 || state.Preset != 3 || state.LiveOverride != LiveOverrideOn || len(state.Segments) != 3 {
+		t.Errorf("Unexpected state: %+v", state)
+	}
+	segments, err := c.Segments(ctx)
+	if err != nil {
+		t.Fatalf("Error getting segments: %v\n", err)
+	}
+	if len(segments) != 2 || segments[0].Name != "Left" || !segments[0].Reverse || segments[1].Stop != 60 {
+		t.Errorf("Unexpected segments: %+v", segments)
+	}
+}
+
+func TestSetState(t *testing.T) {
+	fake := wledtest.NewServer(testInfo, testState)
+	defer fake.Close()
+	c := New(fake.URL)
+	ctx := context.Background()
+
+	for name, err := range map[string]error{
+		"SetOn":           c.SetOn(ctx, true),
+		"SetBrightness":   c.SetBrightness(ctx, 255),
+		"ApplyPreset":     c.ApplyPreset(ctx, 5),
+		"SetLiveOverride": c.SetLiveOverride(ctx, LiveOverrideOff),
+		"SetState":        c.SetState(ctx, Update{Segments: []Segment{{Id: 1, Start: 20, Stop: 40, On: true, Brightness: 255}}}),
+	} {
+		if err != nil {
+			t.Fatalf("Error calling %s: %v\n", name, err)
+		}
+	}
+	state, err := c.State(ctx)
+	if err != nil {
+		t.Fatalf("Error getting state: %v\n", err)
+	}
+	if !state.On || state.Brightness != 255 || state.Preset != 5 || state.LiveOverride != LiveOverrideOff || state.Segments[1].Stop != 40 {
+		t.Errorf("State was not set: %+v", state)
+	}
+
+	if err := c.SetBrightness(ctx, 256); err == nil {
+		t.Error("Expected error for brightness 256")
+	}
+	if err := c.SetLiveOverride(ctx, 3); err == nil {
+		t.Error("Expected error for live override 3")
+	}
+	if len(fake.Posts()) != 5 {
+		t.Errorf("Invalid values were sent: %v", fake.Posts())
+	}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	fake := wledtest.NewServer(testInfo, testState)
+	defer fake.Close()
+	c := New(fake.Host())
+	ctx := context.Background()
+
+	snapshot, err := c.Snapshot(ctx)
+	if err != nil {
+		t.Fatalf("Error taking snapshot: %v\n", err)
+	}
+	if err := c.SetOn(ctx, true); err != nil {
+		t.Fatalf("Error turning on: %v\n", err)
+	}
+	if err := c.SetBrightness(ctx, 10); err != nil {
+		t.Fatalf("Error setting brightness: %v\n", err)
+	}
+	if err := c.Restore(ctx, snapshot); err != nil {
+		t.Fatalf("Error restoring: %v\n", err)
+	}
+	state := fake.State()
+	if state["on"] != false || state["bri"] != float64(80) || state["nl"] == nil {
+		t.Errorf("State was not restored: %v", state)
+	}
+	posts := fake.Posts()
+	if last := posts[len(posts)-1]; last["live"] != false {
+		t.Errorf("Restore did not leave realtime mode: %v", last)
+	}
+}
+
+func TestUnreachable(t *testing.T) {
+	fake := wledtest.NewServer(testInfo, testState)
+	host := fake.Host()
+	fake.Close()
+	if _, err := New(host).Info(context.Background()); err == nil {
+		t.Error("Expected error from a closed controller")
+	}
+}
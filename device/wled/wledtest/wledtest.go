@@ -0,0 +1,113 @@
+// Package wledtest provides a fake WLED controller for tests
+package wledtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// Server answers /json/info and /json/state like a WLED controller. Posted
+// state is merged into the current state, segments by id.
+type Server struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	info  map[string]interface{}
+	state map[string]interface{}
+	posts []map[string]interface{}
+}
+
+// NewServer starts a controller with the given info and state, which are
+// JSON objects
+func NewServer(info, state string) *Server {
+	s := &Server{}
+	if err := json.Unmarshal([]byte(info), &s.info); err != nil {
+		panic("wledtest: invalid info: " + err.Error())
+	}
+	if err := json.Unmarshal([]byte(state), &s.state); err != nil {
+		panic("wledtest: invalid state: " + err.Error())
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Host is the address of the server without scheme
+func (s *Server) Host() string {
+	return strings.TrimPrefix(s.URL, "http://")
+}
+
+// State returns a copy of the current state
+func (s *Server) State() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return copyMap(s.state)
+}
+
+// Posts returns the states posted so far
+func (s *Server) Posts() []map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]map[string]interface{}{}, s.posts...)
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case r.URL.Path == "/json/info" && r.Method == http.MethodGet:
+		_ = json.NewEncoder(w).Encode(s.info)
+	case r.URL.Path == "/json/state" && r.Method == http.MethodGet:
+		_ = json.NewEncoder(w).Encode(s.state)
+	case r.URL.Path == "/json/state" && r.Method == http.MethodPost:
+		var update map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.posts = append(s.posts, update)
+		s.merge(update)
+		_, _ = w.Write([]byte(`{"success":true}`))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// merge applies an update, live only switches realtime mode and is not kept
+func (s *Server) merge(update map[string]interface{}) {
+	for key, value := range update {
+		switch key {
+		case "live":
+		case "seg":
+			segs, _ := value.([]interface{})
+			current, _ := s.state["seg"].([]interface{})
+			for _, seg := range segs {
+				seg, _ := seg.(map[string]interface{})
+				replaced := false
+				for i, cur := range current {
+					if cur, ok := cur.(map[string]interface{}); ok && cur["id"] == seg["id"] {
+						for k, v := range seg {
+							cur[k] = v
+						}
+						current[i], replaced = cur, true
+					}
+				}
+				if !replaced {
+					current = append(current, seg)
+				}
+			}
+			s.state["seg"] = current
+		default:
+			s.state[key] = value
+		}
+	}
+}
+
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	b, _ := json.Marshal(m)
+	var cp map[string]interface{}
+	_ = json.Unmarshal(b, &cp)
+	return cp
+}
@@ -0,0 +1,99 @@
+package device
+
+import (
+	"context"
+	"ledfx/config"
+	"ledfx/device/wled"
+	"ledfx/device/wled/wledtest"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSegmentVirtuals(t *testing.T) {
+	virtuals := segmentVirtuals("couch", "Couch", []wled.Segment{
+		{Id: 0, Name: "Left", Start: 0, Stop: 20, Reverse: true},
+		{Id: 2, Start: 20, Stop: 60},
+	})
+	if len(virtuals) != 2 {
+		t.Fatalf("Expected 2 virtuals, got %d\n", len(virtuals))
+	}
+	if v := virtuals[0]; v.Id != "couch-segment-0" || v.Config.Name != "Left" || v.IsDevice != "" ||
+		!reflect.DeepEqual(v.Segments, [][]interface{}{{"couch", 0, 19, true}}) {
+		t.Errorf("Unexpected first virtual: %+v", v)
+	}
+	if v := virtuals[1]; v.Id != "couch-segment-2" || v.Config.Name != "Couch Segment 2" ||
+		!reflect.DeepEqual(v.Segments, [][]interface{}{{"couch", 20, 59, false}}) {
+		t.Errorf("Unexpected second virtual: %+v", v)
+	}
+}
+
+func TestStreaming(t *testing.T) {
+	fake := wledtest.NewServer(`{"name": "Couch"}`, `{"on": false, "bri": 80, "lor": 2, "seg": []}`)
+	defer fake.Close()
+	dev := config.Device{Id: "couch", Type: "wled", Config: config.DeviceConfig{IpAddress: fake.Host()}}
+	ctx := context.Background()
+
+	if err := StartStreaming(ctx, dev); err != nil {
+		t.Fatalf("Error starting to stream: %v\n", err)
+	}
+	if state := fake.State(); state["on"] != true || state["lor"] != float64(0) {
+		t.Errorf("Device was not prepared for streaming: %v", state)
+	}
+	// Starting again must not overwrite the saved state
+	if err := StartStreaming(ctx, dev); err != nil {
+		t.Fatalf("Error starting to stream again: %v\n", err)
+	}
+	if err := StopStreaming(ctx, dev); err != nil {
+		t.Fatalf("Error stopping to stream: %v\n", err)
+	}
+	if state := fake.State(); state["on"] != false || state["lor"] != float64(2) {
+		t.Errorf("Device state was not restored: %v", state)
+	}
+	if err := StopStreaming(ctx, dev); err != nil || len(fake.Posts()) != 2 {
+		t.Errorf("Stopping twice talked to the device: %v, %v", err, fake.Posts())
+	}
+
+	udp := config.Device{Id: "desk", Type: "udp"}
+	if err := StartStreaming(ctx, udp); err != nil {
+		t.Errorf("Expected other device types to be left alone, got %v", err)
+	}
+}
+
+func TestStreamingHungDevice(t *testing.T) {
+	hung := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-hung
+	}))
+	defer slow.Close()
+	defer close(hung)
+	fake := wledtest.NewServer(`{"name": "Desk"}`, `{"on": false, "bri": 80, "lor": 0, "seg": []}`)
+	defer fake.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go StartStreaming(ctx, config.Device{Id: "hung", Type: "wled", Config: config.DeviceConfig{IpAddress: strings.TrimPrefix(slow.URL, "http://")}})
+	time.Sleep(50 * time.Millisecond)
+
+	// Another device goes on while the first one does not answer
+	done := make(chan error, 1)
+	go func() {
+		desk := config.Device{Id: "desk", Type: "wled", Config: config.DeviceConfig{IpAddress: fake.Host()}}
+		if err := StartStreaming(context.Background(), desk); err != nil {
+			done <- err
+			return
+		}
+		done <- StopStreaming(context.Background(), desk)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Error streaming to the other device: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("A hung device held up another one")
+	}
+}
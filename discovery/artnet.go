@@ -0,0 +1,104 @@
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"ledfx/config"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	artNetPort      = 6454
+	artOpPoll       = 0x2000
+	artOpPollReply  = 0x2100
+	artProtocol     = 14
+	artPollReplyLen = 207
+	// artChannels is the number of DMX channels of an Art-Net port
+	artChannels = 512
+)
+
+var artNetId = []byte("Art-Net\x00")
+
+// ArtNet polls for Art-Net nodes. Nodes answer to port 6454, so only one
+// program on the host can poll at a time.
+type ArtNet struct {
+	// Listen and Target default to port 6454 on all interfaces and the
+	// broadcast address
+	Listen   string
+	Target   string
+	Interval time.Duration
+}
+
+func (*ArtNet) Name() string { return "artnet" }
+
+func (a *ArtNet) Browse(ctx context.Context, report func(Found)) error {
+	p := poller{
+		listen:   a.Listen,
+		target:   a.Target,
+		interval: a.Interval,
+		query:    artPoll(),
+		parse:    parseArtPollReply,
+	}
+	if p.listen == "" {
+		p.listen = net.JoinHostPort("", strconv.Itoa(artNetPort))
+	}
+	if p.target == "" {
+		p.target = net.JoinHostPort(net.IPv4bcast.String(), strconv.Itoa(artNetPort))
+	}
+	if p.interval == 0 {
+		p.interval = 30 * time.Second
+	}
+	return p.run(ctx, report)
+}
+
+// artPoll returns an ArtPoll packet that asks for replies to the poll only
+func artPoll() []byte {
+	packet := make([]byte, 14)
+	copy(packet, artNetId)
+	binary.LittleEndian.PutUint16(packet[8:], artOpPoll)
+	binary.BigEndian.PutUint16(packet[10:], artProtocol)
+	return packet
+}
+
+// parseArtPollReply reads the name, address, MAC and ports of a node
+func parseArtPollReply(packet []byte, src *net.UDPAddr) (Found, bool) {
+	if len(packet) < artPollReplyLen || !bytes.HasPrefix(packet, artNetId) ||
+		binary.LittleEndian.Uint16(packet[8:]) != artOpPollReply {
+		return Found{}, false
+	}
+	ip := net.IP(append([]byte{}, packet[10:14]...))
+	if ip.IsUnspecified() && src != nil {
+		ip = src.IP
+	}
+	name := cString(packet[26:44])
+	if long := cString(packet[44:108]); long != "" && name == "" {
+		name = long
+	}
+	ports := int(binary.BigEndian.Uint16(packet[172:]))
+	return Found{
+		Name:      name,
+		Mac:       net.HardwareAddr(packet[201:207]).String(),
+		Addresses: []string{ip.String()},
+		Device: config.Device{
+			Type: "artnet",
+			Config: config.DeviceConfig{
+				Name:       name,
+				IpAddress:  ip.String(),
+				Port:       artNetPort,
+				PixelCount: ports * (artChannels / 3),
+			},
+		},
+	}, true
+}
+
+// cString returns the text of a NUL terminated string field
+func cString(field []byte) string {
+	if i := bytes.IndexByte(field, 0); i >= 0 {
+		field = field[:i]
+	}
+	return strings.TrimSpace(string(field))
+}
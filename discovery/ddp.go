@@ -0,0 +1,102 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"ledfx/config"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ddpPort      = 4048
+	ddpHeaderLen = 10
+	ddpVersion1  = 0x40
+	ddpTimecode  = 0x10
+	ddpReply     = 0x04
+	ddpQuery     = 0x02
+	// ddpStatus is the id of the status JSON of a display
+	ddpStatus = 251
+)
+
+// DDP queries displays that speak the Distributed Display Protocol for their
+// status, see http://www.3waylabs.com/ddp/
+type DDP struct {
+	// Listen and Target default to port 4048 on all interfaces and the
+	// broadcast address
+	Listen   string
+	Target   string
+	Interval time.Duration
+}
+
+func (*DDP) Name() string { return "ddp" }
+
+func (d *DDP) Browse(ctx context.Context, report func(Found)) error {
+	p := poller{
+		listen:   d.Listen,
+		target:   d.Target,
+		interval: d.Interval,
+		query:    ddpStatusQuery(),
+		parse:    parseDDPStatus,
+	}
+	if p.listen == "" {
+		p.listen = net.JoinHostPort("", strconv.Itoa(ddpPort))
+	}
+	if p.target == "" {
+		p.target = net.JoinHostPort(net.IPv4bcast.String(), strconv.Itoa(ddpPort))
+	}
+	if p.interval == 0 {
+		p.interval = 30 * time.Second
+	}
+	return p.run(ctx, report)
+}
+
+func ddpStatusQuery() []byte {
+	return []byte{ddpVersion1 | ddpQuery, 0, 0, ddpStatus, 0, 0, 0, 0, 0, 0}
+}
+
+// parseDDPStatus reads the reply to a status query, a header followed by
+// {"status": {"man": ..., "mod": ..., "ver": ..., "mac": ...}}
+func parseDDPStatus(packet []byte, src *net.UDPAddr) (Found, bool) {
+	if len(packet) < ddpHeaderLen || packet[0]&0xc0 != ddpVersion1 ||
+		packet[0]&ddpReply == 0 || packet[3] != ddpStatus || src == nil {
+		return Found{}, false
+	}
+	header := ddpHeaderLen
+	if packet[0]&ddpTimecode != 0 {
+		header += 4
+	}
+	if len(packet) < header {
+		return Found{}, false
+	}
+	var reply struct {
+		Status struct {
+			Manufacturer string `json:"man"`
+			Model        string `json:"mod"`
+			Version      string `json:"ver"`
+			Mac          string `json:"mac"`
+		} `json:"status"`
+	}
+	if err := json.Unmarshal(packet[header:], &reply); err != nil {
+		return Found{}, false
+	}
+	name := strings.TrimSpace(reply.Status.Manufacturer + " " + reply.Status.Model)
+	if name == "" {
+		name = "DDP " + src.IP.String()
+	}
+	return Found{
+		Name:      name,
+		Mac:       reply.Status.Mac,
+		Addresses: []string{src.IP.String()},
+		Device: config.Device{
+			Type: "ddp",
+			Config: config.DeviceConfig{
+				Name:      name,
+				IpAddress: src.IP.String(),
+				Port:      ddpPort,
+			},
+		},
+	}, true
+}
@@ -0,0 +1,501 @@
+// Package discovery finds LED controllers on the network. Browsers for
+// different protocols report what they find to a Service, which merges the
+// reports of the same controller into a list of pending devices. Nothing is
+// written to the config until the user accepts a pending device.
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ledfx/config"
+	"ledfx/device"
+	"ledfx/logger"
+	"ledfx/util"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// expireAfter is how long a pending device stays listed after it was last
+// seen
+const expireAfter = 10 * time.Minute
+
+// minBackoff and maxBackoff bound the wait before a failed browser is started
+// again
+var (
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+)
+
+var (
+	ErrNotFound    = errors.New("does not exist")
+	ErrUnsupported = errors.New("can not be driven by LedFx")
+	ErrExists      = errors.New("already exists")
+)
+
+// Found is what a browser knows about a controller
+type Found struct {
+	// Name is what the controller calls itself
+	Name string
+	// Mac identifies the controller across addresses and protocols, empty if
+	// the protocol does not tell
+	Mac string
+	// Addresses are the IP addresses of the controller
+	Addresses []string
+	// Device is the device to add for the controller, its id may be empty
+	Device config.Device
+	// Virtuals are added with the device, Virtuals[0] should represent it
+	Virtuals []config.Virtual
+}
+
+// Browser looks for controllers of one kind
+type Browser interface {
+	// Name identifies the browser in pending devices and logs
+	Name() string
+	// Browse reports controllers until ctx is done. An error other than one
+	// of ctx makes the service start Browse again after a while.
+	Browse(ctx context.Context, report func(Found)) error
+}
+
+// Pending is a controller that was found but is not in the config yet
+type Pending struct {
+	Id string `json:"id"`
+	// Source is the browser that found the controller, the one that knows
+	// most about it if several did
+	Source    string           `json:"source"`
+	Mac       string           `json:"mac,omitempty"`
+	Addresses []string         `json:"addresses"`
+	Device    config.Device    `json:"device"`
+	Virtuals  []config.Virtual `json:"virtuals"`
+	// Supported tells whether LedFx can drive the device, only those can
+	// be accepted
+	Supported bool      `json:"supported"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// priority orders sources by how much they know about a controller
+var priority = map[string]int{"wled": 0, "ddp": 1, "artnet": 2, "e131": 3}
+
+// drivable are the device types LedFx can send to
+var drivable = map[string]bool{"wled": true, "udp": true}
+
+// Service runs browsers and keeps the list of pending devices
+type Service struct {
+	browsers []Browser
+
+	mu      sync.Mutex
+	pending []*Pending
+	// dismissed holds the MACs and addresses of dismissed devices, so they
+	// are not listed again
+	dismissed map[string]bool
+	notify    func(Pending)
+	now       func() time.Time
+}
+
+// New returns a service that runs the given browsers
+func New(browsers ...Browser) *Service {
+	return &Service{browsers: browsers, dismissed: make(map[string]bool), now: time.Now}
+}
+
+// OnNew calls fn for every device that is added to the pending list
+func (s *Service) OnNew(fn func(Pending)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notify = fn
+}
+
+// Run runs every browser until ctx is done, starting those that fail again
+// with a growing delay
+func (s *Service) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, b := range s.browsers {
+		wg.Add(1)
+		go func(b Browser) {
+			defer wg.Done()
+			s.run(ctx, b)
+		}(b)
+	}
+	wg.Wait()
+}
+
+func (s *Service) run(ctx context.Context, b Browser) {
+	backoff := minBackoff
+	for {
+		started := time.Now()
+		err := b.Browse(ctx, func(f Found) { s.Add(b.Name(), f) })
+		if ctx.Err() != nil {
+			return
+		}
+		// A browser that ran for a while failed for a new reason
+		if time.Since(started) > maxBackoff {
+			backoff = minBackoff
+		}
+		logger.Logger.Warnf("%s discovery stopped, retrying in %v: %v", b.Name(), backoff, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// Add records a controller found by the browser named source. Reports of
+// the same controller are merged by MAC or address, keeping the device of
+// the source that knows most. Controllers that are configured or were
+// dismissed already are ignored.
+func (s *Service) Add(source string, f Found) {
+	f.Mac = normalizeMac(f.Mac)
+	if len(f.Addresses) == 0 && f.Device.Config.IpAddress != "" {
+		f.Addresses = []string{f.Device.Config.IpAddress}
+	}
+	if configured(f) {
+		return
+	}
+	now := s.now()
+
+	s.mu.Lock()
+	if s.isDismissed(f) {
+		s.mu.Unlock()
+		return
+	}
+	s.expire(now)
+	for _, p := range s.pending {
+		if !same(p, f) {
+			continue
+		}
+		p.LastSeen = now
+		p.Addresses = mergeAddresses(p.Addresses, f.Addresses)
+		if p.Mac == "" {
+			p.Mac = f.Mac
+		}
+		if rank(source) < rank(p.Source) {
+			p.Source = source
+			s.setDevice(p, f)
+		}
+		s.mu.Unlock()
+		return
+	}
+	p := &Pending{
+		Source:    source,
+		Mac:       f.Mac,
+		Addresses: mergeAddresses(nil, f.Addresses),
+		FirstSeen: now,
+		LastSeen:  now,
+	}
+	p.Id = s.uniqueId(f)
+	s.setDevice(p, f)
+	s.pending = append(s.pending, p)
+	notify, found := s.notify, *p
+	s.mu.Unlock()
+
+	logger.Logger.Infof("Found %s device '%s' at %s", source, found.Device.Config.Name, strings.Join(found.Addresses, ", "))
+	if notify != nil {
+		notify(found)
+	}
+}
+
+// setDevice makes the device of f the one of p, under the id of p
+func (s *Service) setDevice(p *Pending, f Found) {
+	from := f.Device.Id
+	p.Device = f.Device
+	p.Device.Id = p.Id
+	if p.Device.Config.Name == "" {
+		p.Device.Config.Name = f.Name
+	}
+	p.Virtuals = renameDevice(f.Virtuals, from, p.Id)
+	p.Supported = drivable[p.Device.Type]
+}
+
+// Pending returns the devices that were found lately and are not in the
+// config, in the order they were found
+func (s *Service) Pending() []Pending {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(s.now())
+	list := make([]Pending, 0, len(s.pending))
+	for _, p := range s.pending {
+		list = append(list, *p)
+	}
+	return list
+}
+
+// Get returns the pending device with the given id
+func (s *Service) Get(id string) (Pending, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if index := s.find(id); index >= 0 {
+		return *s.pending[index], true
+	}
+	return Pending{}, false
+}
+
+// Accept adds dev, usually the device of the pending device with the given
+// id as the user edited it, to the config together with the virtuals that
+// were found with it. A new device id or name is taken over by the virtual
+// that represents the device.
+func (s *Service) Accept(id string, dev config.Device) (config.Device, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	index := s.find(id)
+	if index < 0 {
+		return config.Device{}, fmt.Errorf("pending device '%s' %w", id, ErrNotFound)
+	}
+	p := s.pending[index]
+	if dev.Id == "" {
+		dev.Id = p.Id
+	}
+	if !drivable[dev.Type] {
+		return config.Device{}, fmt.Errorf("device type '%s' %w", dev.Type, ErrUnsupported)
+	}
+	if err := device.ValidateDevice(dev); err != nil {
+		return config.Device{}, err
+	}
+	virtuals := renameDevice(p.Virtuals, p.Id, dev.Id)
+	for i := range virtuals {
+		if virtuals[i].IsDevice == dev.Id {
+			virtuals[i].Config.Name = dev.Config.Name
+		}
+	}
+
+	err := config.Update(func(c *config.Config) error {
+		if err := device.CheckSupply(dev, c.PowerSupplies); err != nil {
+			return err
+		}
+		for _, d := range c.Devices {
+			if d.Id == dev.Id {
+				return fmt.Errorf("device '%s' %w", dev.Id, ErrExists)
+			}
+		}
+		for _, v := range c.Virtuals {
+			for _, virt := range virtuals {
+				if v.Id == virt.Id {
+					return fmt.Errorf("virtual '%s' %w", virt.Id, ErrExists)
+				}
+			}
+		}
+		c.Devices = append(c.Devices, dev)
+		c.Virtuals = append(c.Virtuals, virtuals...)
+		return nil
+	})
+	if err != nil {
+		return config.Device{}, err
+	}
+	s.pending = append(s.pending[:index], s.pending[index+1:]...)
+	return dev, nil
+}
+
+// Dismiss removes the pending device with the given id and ignores it from
+// now on
+func (s *Service) Dismiss(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	index := s.find(id)
+	if index < 0 {
+		return fmt.Errorf("pending device '%s' %w", id, ErrNotFound)
+	}
+	p := s.pending[index]
+	if p.Mac != "" {
+		s.dismissed[p.Mac] = true
+	}
+	for _, addr := range p.Addresses {
+		s.dismissed[addr] = true
+	}
+	s.pending = append(s.pending[:index], s.pending[index+1:]...)
+	return nil
+}
+
+func (s *Service) find(id string) int {
+	for i, p := range s.pending {
+		if p.Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// expire drops the devices that were not seen for a while. s.mu must be held.
+func (s *Service) expire(now time.Time) {
+	pending := s.pending[:0]
+	for _, p := range s.pending {
+		if now.Sub(p.LastSeen) < expireAfter {
+			pending = append(pending, p)
+		}
+	}
+	s.pending = pending
+}
+
+func (s *Service) isDismissed(f Found) bool {
+	if f.Mac != "" && s.dismissed[f.Mac] {
+		return true
+	}
+	for _, addr := range f.Addresses {
+		if s.dismissed[addr] {
+			return true
+		}
+	}
+	return false
+}
+
+// uniqueId generates an id from the name of f that neither a pending nor a
+// configured device has. s.mu must be held.
+func (s *Service) uniqueId(f Found) string {
+	name := f.Device.Id
+	if name == "" {
+		name = f.Name
+	}
+	if name == "" {
+		name = f.Device.Config.Name
+	}
+	base := util.GenerateId(name)
+	if base == "" && len(f.Addresses) > 0 {
+		base = util.GenerateId(f.Device.Type + " " + f.Addresses[0])
+	}
+	if base == "" {
+		base = "device"
+	}
+	taken := make(map[string]bool)
+	for _, p := range s.pending {
+		taken[p.Id] = true
+	}
+	for _, d := range config.Snapshot().Devices {
+		taken[d.Id] = true
+	}
+	id := base
+	for i := 2; taken[id]; i++ {
+		id = fmt.Sprintf("%s-%d", base, i)
+	}
+	return id
+}
+
+// configured reports whether a device in the config has an address of f
+func configured(f Found) bool {
+	for _, dev := range config.Snapshot().Devices {
+		for _, addr := range f.Addresses {
+			if dev.Config.IpAddress == addr {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// same reports whether p and f are the same controller
+func same(p *Pending, f Found) bool {
+	if p.Mac != "" && f.Mac != "" {
+		return p.Mac == f.Mac
+	}
+	for _, a := range p.Addresses {
+		for _, b := range f.Addresses {
+			if a == b {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func rank(source string) int {
+	if r, ok := priority[source]; ok {
+		return r
+	}
+	return len(priority)
+}
+
+// mergeAddresses adds the addresses of b missing from a, IPv4 first
+func mergeAddresses(a, b []string) []string {
+	merged := append([]string{}, a...)
+	for _, addr := range b {
+		found := false
+		for _, have := range merged {
+			found = found || have == addr
+		}
+		if !found {
+			merged = append(merged, addr)
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		return isIPv4(merged[i]) && !isIPv4(merged[j])
+	})
+	return merged
+}
+
+func isIPv4(addr string) bool {
+	ip := net.ParseIP(addr)
+	return ip != nil && ip.To4() != nil
+}
+
+// renameDevice returns a copy of virtuals in which the device id from is to,
+// including the ids of virtuals derived from it
+func renameDevice(virtuals []config.Virtual, from, to string) []config.Virtual {
+	renamed := make([]config.Virtual, 0, len(virtuals))
+	for _, v := range virtuals {
+		if from != "" && from != to {
+			if v.Id == from || strings.HasPrefix(v.Id, from+"-") {
+				v.Id = to + strings.TrimPrefix(v.Id, from)
+			}
+			if v.IsDevice == from {
+				v.IsDevice = to
+			}
+			segments := make([][]interface{}, 0, len(v.Segments))
+			for _, seg := range v.Segments {
+				seg = append([]interface{}{}, seg...)
+				if len(seg) > 0 && seg[0] == from {
+					seg[0] = to
+				}
+				segments = append(segments, seg)
+			}
+			v.Segments = segments
+		}
+		renamed = append(renamed, v)
+	}
+	return renamed
+}
+
+// normalizeMac returns mac as lowercase bytes separated by colons, or "" if
+// it is not a MAC. WLED sends MACs without separators.
+func normalizeMac(mac string) string {
+	mac = strings.NewReplacer(":", "", "-", "", ".", "").Replace(strings.ToLower(mac))
+	if len(mac) != 12 || strings.Trim(mac, "0123456789abcdef") != "" || mac == "000000000000" {
+		return ""
+	}
+	parts := make([]string, 0, 6)
+	for i := 0; i < 12; i += 2 {
+		parts = append(parts, mac[i:i+2])
+	}
+	return strings.Join(parts, ":")
+}
+
+// Default runs the browsers for all supported protocols
+var Default = New(&Wled{}, &ArtNet{}, &DDP{}, &E131{})
+
+// Run runs the default service until ctx is done
+func Run(ctx context.Context) {
+	Default.Run(ctx)
+}
+
+// List returns the pending devices of the default service
+func List() []Pending {
+	return Default.Pending()
+}
+
+// Get returns a pending device of the default service
+func Get(id string) (Pending, bool) {
+	return Default.Get(id)
+}
+
+// Accept accepts a pending device of the default service, see Service.Accept
+func Accept(id string, dev config.Device) (config.Device, error) {
+	return Default.Accept(id, dev)
+}
+
+// Dismiss dismisses a pending device of the default service
+func Dismiss(id string) error {
+	return Default.Dismiss(id)
+}
@@ -0,0 +1,222 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"ledfx/config"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func setupTestConfig(t *testing.T) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "go_config.json")
+	if err := os.WriteFile(path, []byte("{}\n"), 0644); err != nil {
+		t.Fatalf("Error writing test config: %v\n", err)
+	}
+	config.GlobalViper = viper.New()
+	config.WriteDelay = 0
+	config.GlobalViper.SetConfigFile(path)
+	if err := config.Replace(config.Config{
+		Devices: []config.Device{{
+			Id:     "couch",
+			Type:   "wled",
+			Config: config.DeviceConfig{Name: "Couch", IpAddress: "10.0.0.1", PixelCount: 36},
+		}},
+	}); err != nil {
+		t.Fatalf("Error setting test config: %v\n", err)
+	}
+}
+
+func wledFound(name, mac, ip string) Found {
+	return Found{
+		Name: name,
+		Mac:  mac,
+		Device: config.Device{
+			Id:     "shelf",
+			Type:   "wled",
+			Config: config.DeviceConfig{Name: name, IpAddress: ip, PixelCount: 30},
+		},
+		Virtuals: []config.Virtual{
+			{Id: "shelf", IsDevice: "shelf", Config: config.VirtualConfig{Name: name}, Segments: [][]interface{}{{"shelf", 0, 29, false}}},
+			{Id: "shelf-segment-1", Config: config.VirtualConfig{Name: "Top"}, Segments: [][]interface{}{{"shelf", 10, 29, false}}},
+		},
+	}
+}
+
+func TestAdd(t *testing.T) {
+	setupTestConfig(t)
+	s := New()
+	s.Add("artnet", Found{Name: "Node", Mac: "AA:BB:CC:00:11:22", Addresses: []string{"10.0.0.2"}, Device: config.Device{Type: "artnet"}})
+	s.Add("wled", wledFound("Shelf", "aabbcc001122", "fd00::2"))
+	s.Add("ddp", Found{Name: "DDP", Mac: "aa-bb-cc-00-11-22", Addresses: []string{"10.0.0.2"}, Device: config.Device{Type: "ddp"}})
+	s.Add("wled", wledFound("Couch", "", "10.0.0.1"))
+	s.Add("e131", Found{Name: "Bridge", Addresses: []string{"10.0.0.3"}, Device: config.Device{Type: "e131"}})
+	s.Add("e131", Found{Name: "Bridge", Addresses: []string{"10.0.0.3"}, Device: config.Device{Type: "e131"}})
+
+	pending := s.Pending()
+	if len(pending) != 2 {
+		t.Fatalf("Expected 2 pending devices, got %+v\n", pending)
+	}
+	p := pending[0]
+	if p.Id != "node" || p.Source != "wled" || p.Mac != "aa:bb:cc:00:11:22" || !p.Supported {
+		t.Errorf("Unexpected merged device: %+v", p)
+	}
+	if len(p.Addresses) != 2 || p.Addresses[0] != "10.0.0.2" || p.Addresses[1] != "fd00::2" {
+		t.Errorf("Unexpected addresses: %v", p.Addresses)
+	}
+	if p.Device.Id != "node" || p.Virtuals[0].Id != "node" || p.Virtuals[1].Id != "node-segment-1" || p.Virtuals[1].Segments[0][0] != "node" {
+		t.Errorf("Device was not renamed to its pending id: %+v %+v", p.Device, p.Virtuals)
+	}
+	if p := pending[1]; p.Id != "bridge" || p.Supported {
+		t.Errorf("Unexpected sACN device: %+v", p)
+	}
+}
+
+func TestExpire(t *testing.T) {
+	setupTestConfig(t)
+	now := time.Now()
+	s := New()
+	s.now = func() time.Time { return now }
+	s.Add("wled", wledFound("Shelf", "", "10.0.0.2"))
+	now = now.Add(expireAfter)
+	if pending := s.Pending(); len(pending) != 0 {
+		t.Errorf("Expected the device to expire, got %+v", pending)
+	}
+}
+
+func TestAccept(t *testing.T) {
+	setupTestConfig(t)
+	s := New()
+	s.Add("wled", wledFound("Shelf", "", "10.0.0.2"))
+	s.Add("artnet", Found{Name: "Node", Addresses: []string{"10.0.0.3"}, Device: config.Device{Type: "artnet"}})
+
+	p, ok := s.Get("shelf")
+	if !ok {
+		t.Fatalf("Pending device 'shelf' is missing\n")
+	}
+	dev := p.Device
+	dev.Id = "kitchen"
+	dev.Config.Name = "Kitchen"
+	if _, err := s.Accept("shelf", dev); err != nil {
+		t.Fatalf("Error accepting device: %v\n", err)
+	}
+	c := config.Snapshot()
+	if len(c.Devices) != 2 || c.Devices[1].Id != "kitchen" || len(c.Virtuals) != 2 {
+		t.Fatalf("Device was not added: %+v %+v\n", c.Devices, c.Virtuals)
+	}
+	if v := c.Virtuals[0]; v.Id != "kitchen" || v.IsDevice != "kitchen" || v.Config.Name != "Kitchen" || v.Segments[0][0] != "kitchen" {
+		t.Errorf("Device virtual was not renamed: %+v", v)
+	}
+	if v := c.Virtuals[1]; v.Id != "kitchen-segment-1" || v.Config.Name != "Top" {
+		t.Errorf("Segment virtual was not renamed: %+v", v)
+	}
+	if _, ok := s.Get("shelf"); ok {
+		t.Errorf("Accepted device is still pending")
+	}
+
+	tests := []struct {
+		name string
+		id   string
+		dev  func(Pending) config.Device
+		err  error
+	}{
+		{"unknown", "nothing", func(p Pending) config.Device { return p.Device }, ErrNotFound},
+		{"unsupported", "node", func(p Pending) config.Device { return p.Device }, ErrUnsupported},
+		{"taken", "node", func(p Pending) config.Device {
+			return config.Device{Id: "couch", Type: "wled", Config: config.DeviceConfig{Name: "Node", IpAddress: "10.0.0.3", PixelCount: 10}}
+		}, ErrExists},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, _ := s.Get(test.id)
+			if _, err := s.Accept(test.id, test.dev(p)); !errors.Is(err, test.err) {
+				t.Errorf("Expected %v, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestDismiss(t *testing.T) {
+	setupTestConfig(t)
+	s := New()
+	s.Add("wled", wledFound("Shelf", "aabbcc001122", "10.0.0.2"))
+	if err := s.Dismiss("shelf"); err != nil {
+		t.Fatalf("Error dismissing device: %v\n", err)
+	}
+	s.Add("ddp", Found{Mac: "aa:bb:cc:00:11:22", Addresses: []string{"10.0.0.9"}, Device: config.Device{Type: "ddp"}})
+	if pending := s.Pending(); len(pending) != 0 {
+		t.Errorf("Dismissed device is listed again: %+v", pending)
+	}
+	if err := s.Dismiss("shelf"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+// flaky fails its first browse and reports a device in the second
+type flaky struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (*flaky) Name() string { return "wled" }
+
+func (f *flaky) Browse(ctx context.Context, report func(Found)) error {
+	f.mu.Lock()
+	f.calls++
+	calls := f.calls
+	f.mu.Unlock()
+	if calls == 1 {
+		return errors.New("network is down")
+	}
+	report(wledFound("Shelf", "", "10.0.0.2"))
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestRunRetries(t *testing.T) {
+	setupTestConfig(t)
+	defer func(min time.Duration) { minBackoff = min }(minBackoff)
+	minBackoff = time.Millisecond
+
+	s := New(&flaky{})
+	ctx, cancel := context.WithCancel(context.Background())
+	found := make(chan Pending, 1)
+	s.OnNew(func(p Pending) { found <- p })
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	select {
+	case p := <-found:
+		if p.Id != "shelf" {
+			t.Errorf("Unexpected device: %+v", p)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("Browser was not started again")
+	}
+	cancel()
+	<-done
+}
+
+func TestNormalizeMac(t *testing.T) {
+	tests := map[string]string{
+		"AABBCC001122":      "aa:bb:cc:00:11:22",
+		"aa-bb-cc-00-11-22": "aa:bb:cc:00:11:22",
+		"aabb.cc00.1122":    "aa:bb:cc:00:11:22",
+		"000000000000":      "",
+		"aabbcc":            "",
+		"gg:bb:cc:00:11:22": "",
+	}
+	for in, want := range tests {
+		if got := normalizeMac(in); got != want {
+			t.Errorf("normalizeMac(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
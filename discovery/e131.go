@@ -0,0 +1,91 @@
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"ledfx/config"
+	"net"
+)
+
+const (
+	e131Port          = 5568
+	e131RootVector    = 0x00000008
+	e131FrameVector   = 0x00000002
+	e131ListVector    = 0x00000001
+	e131DiscoveryLen  = 120
+	e131UniverseBytes = 2
+)
+
+var (
+	acnId = []byte("ASC-E1.17\x00\x00\x00")
+	// e131Discovery is the multicast group of universe discovery
+	e131Discovery = &net.UDPAddr{IP: net.IPv4(239, 255, 250, 214), Port: e131Port}
+)
+
+// E131 listens for sACN universe discovery. Receivers do not announce
+// themselves in sACN, so this only finds nodes that send, like bridges that
+// forward universes they receive elsewhere.
+type E131 struct {
+	// Interface to join the discovery group on, nil for the default
+	Interface *net.Interface
+}
+
+func (*E131) Name() string { return "e131" }
+
+func (e *E131) Browse(ctx context.Context, report func(Found)) error {
+	conn, err := net.ListenMulticastUDP("udp4", e.Interface, e131Discovery)
+	if err != nil {
+		return fmt.Errorf("error joining %s: %w", e131Discovery, err)
+	}
+	defer conn.Close()
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	buf := make([]byte, maxPacket)
+	for {
+		n, src, err := conn.ReadFromUDP(buf)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if f, ok := parseUniverseDiscovery(buf[:n], src); ok {
+			report(f)
+		}
+	}
+}
+
+// parseUniverseDiscovery reads the source name and universes of a universe
+// discovery packet
+func parseUniverseDiscovery(packet []byte, src *net.UDPAddr) (Found, bool) {
+	if len(packet) < e131DiscoveryLen || src == nil ||
+		!bytes.Equal(packet[4:16], acnId) ||
+		binary.BigEndian.Uint32(packet[18:]) != e131RootVector ||
+		binary.BigEndian.Uint32(packet[40:]) != e131FrameVector ||
+		binary.BigEndian.Uint32(packet[114:]) != e131ListVector {
+		return Found{}, false
+	}
+	name := cString(packet[44:108])
+	if name == "" {
+		name = "sACN " + src.IP.String()
+	}
+	universes := (len(packet) - e131DiscoveryLen) / e131UniverseBytes
+	return Found{
+		Name:      name,
+		Addresses: []string{src.IP.String()},
+		Device: config.Device{
+			Type: "e131",
+			Config: config.DeviceConfig{
+				Name:       name,
+				IpAddress:  src.IP.String(),
+				Port:       e131Port,
+				PixelCount: universes * (artChannels / 3),
+			},
+		},
+	}, true
+}
@@ -0,0 +1,73 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// maxPacket is large enough for the replies of every protocol
+const maxPacket = 1500
+
+// poller broadcasts a query and reads the replies, for protocols whose
+// controllers answer a poll
+type poller struct {
+	// listen is the local address replies arrive at
+	listen string
+	// target is where the query is sent, usually a broadcast address
+	target   string
+	interval time.Duration
+	query    []byte
+	// parse turns a reply from src into a controller, ok is false for
+	// packets that are not replies
+	parse func(packet []byte, src *net.UDPAddr) (f Found, ok bool)
+}
+
+// run polls every interval and reports the replies until ctx is done
+func (p *poller) run(ctx context.Context, report func(Found)) error {
+	laddr, err := net.ResolveUDPAddr("udp4", p.listen)
+	if err != nil {
+		return err
+	}
+	target, err := net.ResolveUDPAddr("udp4", p.target)
+	if err != nil {
+		return err
+	}
+	conn, err := net.ListenUDP("udp4", laddr)
+	if err != nil {
+		return fmt.Errorf("error listening on %s: %w", p.listen, err)
+	}
+	defer conn.Close()
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	buf := make([]byte, maxPacket)
+	for {
+		if _, err := conn.WriteToUDP(p.query, target); err != nil && ctx.Err() == nil {
+			return fmt.Errorf("error sending poll to %s: %w", target, err)
+		}
+		if err := conn.SetReadDeadline(time.Now().Add(p.interval)); err != nil {
+			return err
+		}
+		for {
+			n, src, err := conn.ReadFromUDP(buf)
+			if ctx.Err() != nil {
+				return nil
+			}
+			var nerr net.Error
+			if errors.As(err, &nerr) && nerr.Timeout() {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if f, ok := p.parse(buf[:n], src); ok {
+				report(f)
+			}
+		}
+	}
+}
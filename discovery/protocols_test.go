@@ -0,0 +1,176 @@
+package discovery
+
+import (
+	"context"
+	"encoding/binary"
+	"ledfx/device/wled/wledtest"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func artPollReply(name string, ip net.IP, mac []byte, ports int) []byte {
+	packet := make([]byte, 239)
+	copy(packet, artNetId)
+	binary.LittleEndian.PutUint16(packet[8:], artOpPollReply)
+	copy(packet[10:], ip.To4())
+	copy(packet[26:], name)
+	copy(packet[44:], name+" Long")
+	binary.BigEndian.PutUint16(packet[172:], uint16(ports))
+	copy(packet[201:], mac)
+	return packet
+}
+
+func TestParseArtPollReply(t *testing.T) {
+	src := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 9)}
+	f, ok := parseArtPollReply(artPollReply("Stage", net.IPv4(10, 0, 0, 5), []byte{0xaa, 0xbb, 0xcc, 1, 2, 3}, 2), src)
+	if !ok {
+		t.Fatalf("Reply was not recognized\n")
+	}
+	if f.Name != "Stage" || f.Mac != "aa:bb:cc:01:02:03" || f.Addresses[0] != "10.0.0.5" ||
+		f.Device.Type != "artnet" || f.Device.Config.PixelCount != 340 {
+		t.Errorf("Unexpected node: %+v", f)
+	}
+
+	f, _ = parseArtPollReply(artPollReply("", net.IPv4zero, nil, 1), src)
+	if f.Name != "Long" || f.Addresses[0] != "10.0.0.9" {
+		t.Errorf("Expected the long name and source address, got %+v", f)
+	}
+
+	for name, packet := range map[string][]byte{
+		"poll":  append(artPoll(), make([]byte, 200)...),
+		"short": artPollReply("Stage", net.IPv4(10, 0, 0, 5), nil, 1)[:206],
+		"empty": nil,
+	} {
+		if _, ok := parseArtPollReply(packet, src); ok {
+			t.Errorf("Packet '%s' was taken for a reply", name)
+		}
+	}
+}
+
+// TestArtNetBrowse polls a fake node on the loopback interface
+func TestArtNetBrowse(t *testing.T) {
+	node, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Error listening: %v\n", err)
+	}
+	defer node.Close()
+	go func() {
+		buf := make([]byte, maxPacket)
+		for {
+			n, src, err := node.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			if string(buf[:n]) == string(artPoll()) {
+				node.WriteToUDP(artPollReply("Stage", net.IPv4(127, 0, 0, 1), []byte{1, 2, 3, 4, 5, 6}, 1), src)
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	b := &ArtNet{Listen: "127.0.0.1:0", Target: node.LocalAddr().String(), Interval: 50 * time.Millisecond}
+	found := make(chan Found, 10)
+	done := make(chan error)
+	go func() { done <- b.Browse(ctx, func(f Found) { found <- f }) }()
+	select {
+	case f := <-found:
+		if f.Name != "Stage" || f.Mac != "01:02:03:04:05:06" {
+			t.Errorf("Unexpected node: %+v", f)
+		}
+	case <-ctx.Done():
+		t.Errorf("No node was found")
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Error browsing: %v", err)
+	}
+}
+
+func TestParseDDPStatus(t *testing.T) {
+	src := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 7)}
+	status := `{"status": {"man": "WLED", "mod": "ESP32", "ver": "0.14", "mac": "a0b1c2d3e4f5"}}`
+	header := []byte{ddpVersion1 | ddpReply, 0, 0, ddpStatus, 0, 0, 0, 0, 0, byte(len(status))}
+	f, ok := parseDDPStatus(append(header, status...), src)
+	if !ok {
+		t.Fatalf("Reply was not recognized\n")
+	}
+	if f.Name != "WLED ESP32" || f.Mac != "a0b1c2d3e4f5" || f.Addresses[0] != "10.0.0.7" || f.Device.Type != "ddp" {
+		t.Errorf("Unexpected display: %+v", f)
+	}
+
+	timecode := []byte{ddpVersion1 | ddpReply | ddpTimecode, 0, 0, ddpStatus, 0, 0, 0, 0, 0, 0, 1, 2, 3, 4}
+	if f, ok := parseDDPStatus(append(timecode, status...), src); !ok || f.Name != "WLED ESP32" {
+		t.Errorf("Reply with timecode was not recognized: %+v", f)
+	}
+	for name, packet := range map[string][]byte{
+		"query":    ddpStatusQuery(),
+		"not json": append(header, "status"...),
+		"short":    header[:6],
+	} {
+		if _, ok := parseDDPStatus(packet, src); ok {
+			t.Errorf("Packet '%s' was taken for a reply", name)
+		}
+	}
+}
+
+func universeDiscovery(name string, universes ...uint16) []byte {
+	packet := make([]byte, e131DiscoveryLen, e131DiscoveryLen+2*len(universes))
+	binary.BigEndian.PutUint16(packet, 0x0010)
+	copy(packet[4:], acnId)
+	binary.BigEndian.PutUint32(packet[18:], e131RootVector)
+	binary.BigEndian.PutUint32(packet[40:], e131FrameVector)
+	copy(packet[44:], name)
+	binary.BigEndian.PutUint32(packet[114:], e131ListVector)
+	for _, u := range universes {
+		packet = append(packet, byte(u>>8), byte(u))
+	}
+	return packet
+}
+
+func TestParseUniverseDiscovery(t *testing.T) {
+	src := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 8)}
+	f, ok := parseUniverseDiscovery(universeDiscovery("Bridge", 1, 2, 3), src)
+	if !ok {
+		t.Fatalf("Packet was not recognized\n")
+	}
+	if f.Name != "Bridge" || f.Addresses[0] != "10.0.0.8" || f.Device.Type != "e131" || f.Device.Config.PixelCount != 510 {
+		t.Errorf("Unexpected source: %+v", f)
+	}
+	data := universeDiscovery("Bridge")
+	binary.BigEndian.PutUint32(data[40:], 0x00000004) // a data packet
+	if _, ok := parseUniverseDiscovery(data, src); ok {
+		t.Errorf("Data packet was taken for universe discovery")
+	}
+}
+
+func TestResolveWled(t *testing.T) {
+	fake := wledtest.NewServer(
+		`{"name": "Shelf", "mac": "a0b1c2d3e4f5", "leds": {"count": 30, "rgbw": true}}`,
+		`{"seg": [{"id": 0, "start": 0, "stop": 10}, {"id": 1, "start": 10, "stop": 30}]}`,
+	)
+	defer fake.Close()
+	_, port, _ := net.SplitHostPort(fake.Host())
+	p, _ := strconv.Atoi(port)
+
+	// The first address does not answer, the second one does
+	f, err := resolveWled(context.Background(), "shelf", []net.IP{net.ParseIP("::1"), net.IPv4(127, 0, 0, 1)}, p)
+	if err != nil {
+		t.Fatalf("Error resolving WLED: %v\n", err)
+	}
+	if f.Name != "Shelf" || f.Mac != "a0b1c2d3e4f5" || f.Device.Config.IpAddress != "127.0.0.1" ||
+		f.Device.Config.UdpPacketType != "DRGBW" || len(f.Virtuals) != 3 || len(f.Addresses) != 2 {
+		t.Errorf("Unexpected WLED: %+v", f)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := resolveWled(ctx, "gone", []net.IP{net.IPv4(127, 0, 0, 1)}, 1); err == nil {
+		t.Errorf("Expected an error resolving an unreachable WLED")
+	}
+	if _, err := resolveWled(ctx, "none", nil, 80); err == nil {
+		t.Errorf("Expected an error resolving a WLED without addresses")
+	}
+}
@@ -0,0 +1,122 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"ledfx/device"
+	"ledfx/device/wled"
+	"ledfx/logger"
+	"ledfx/util"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/grandcat/zeroconf"
+)
+
+const (
+	// wledAttempts is how often every address of a WLED controller is tried
+	wledAttempts = 3
+	wledRetry    = time.Second
+)
+
+// Wled browses mDNS for WLED controllers and asks them for their LEDs.
+// WLED announces itself every few minutes and when it starts, so on some
+// systems a controller only shows up after a restart.
+type Wled struct{}
+
+func (*Wled) Name() string { return "wled" }
+
+func (w *Wled) Browse(ctx context.Context, report func(Found)) error {
+	resolver, err := zeroconf.NewResolver(nil)
+	if err != nil {
+		return fmt.Errorf("error initializing mDNS resolver: %w", err)
+	}
+	entries := make(chan *zeroconf.ServiceEntry)
+	if err := resolver.Browse(ctx, "_wled._tcp", "local.", entries); err != nil {
+		return fmt.Errorf("error browsing mDNS: %w", err)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case entry, ok := <-entries:
+			if !ok {
+				return nil
+			}
+			go func(entry *zeroconf.ServiceEntry) {
+				f, err := resolveWled(ctx, entry.Instance, entryAddresses(entry), entry.Port)
+				if err != nil {
+					logger.Logger.Warnf("Error resolving WLED '%s': %v", entry.Instance, err)
+					return
+				}
+				report(f)
+			}(entry)
+		}
+	}
+}
+
+// entryAddresses returns the addresses of an mDNS entry that can be
+// connected to, IPv4 first. Link-local IPv6 addresses need the interface
+// they were seen on, which the entry does not tell, so they are left out.
+func entryAddresses(entry *zeroconf.ServiceEntry) []net.IP {
+	addrs := make([]net.IP, 0, len(entry.AddrIPv4)+len(entry.AddrIPv6))
+	addrs = append(addrs, entry.AddrIPv4...)
+	for _, ip := range entry.AddrIPv6 {
+		if !ip.IsLinkLocalUnicast() {
+			addrs = append(addrs, ip)
+		}
+	}
+	return addrs
+}
+
+// resolveWled asks the controller named instance for its info and segments,
+// trying every address a few times
+func resolveWled(ctx context.Context, instance string, addrs []net.IP, port int) (Found, error) {
+	if len(addrs) == 0 {
+		return Found{}, fmt.Errorf("no usable address")
+	}
+	var err error
+	for attempt := 0; attempt < wledAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return Found{}, ctx.Err()
+			case <-time.After(wledRetry):
+			}
+		}
+		for _, ip := range addrs {
+			var f Found
+			if f, err = queryWled(ctx, instance, ip, port); err == nil {
+				for _, other := range addrs {
+					f.Addresses = append(f.Addresses, other.String())
+				}
+				return f, nil
+			}
+		}
+	}
+	return Found{}, err
+}
+
+func queryWled(ctx context.Context, instance string, ip net.IP, port int) (Found, error) {
+	ctx, cancel := context.WithTimeout(ctx, wled.Timeout)
+	defer cancel()
+	host := ip.String()
+	if port != 0 && port != 80 {
+		host = net.JoinHostPort(host, strconv.Itoa(port))
+	}
+	client := wled.New(host)
+	info, err := client.Info(ctx)
+	if err != nil {
+		return Found{}, err
+	}
+	segments, err := client.Segments(ctx)
+	if err != nil {
+		logger.Logger.Warnf("Error getting WLED segments from %s: %v", host, err)
+	}
+	if info.Name == "" {
+		info.Name = instance
+	}
+	dev, virtuals := device.WledDevice(util.GenerateId(instance), ip.String(), info, segments)
+	return Found{Name: info.Name, Mac: info.Mac, Device: dev, Virtuals: virtuals}, nil
+}
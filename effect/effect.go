@@ -24,8 +24,42 @@ type Config struct {
 	Background color.Color
 }
 
+// Types maps the effect type ids known to the API to their display names
+var Types = map[string]string{
+	"singleColor": "Single Color",
+	"audioRandom": "Audio Random",
+}
+
+func init() {
+	// Effect configs refer to user colors and gradients by name
+	config.Watch(func(c *config.Config) {
+		color.SetUser(c.UserColors, c.UserGradients)
+	})
+}
+
+// ValidateEffect checks that the effect type is known and its colors parse
+func ValidateEffect(e config.Effect) error {
+	if _, ok := Types[e.Type]; !ok {
+		return fmt.Errorf("unknown effect type '%s'", e.Type)
+	}
+	return ValidateEffectConfig(e.Config)
+}
+
+// ValidateEffectConfig checks that the colors set on an effect config parse
+func ValidateEffectConfig(c config.EffectConfig) error {
+	for field, clr := range map[string]string{"color": c.Color, "background_color": c.BackgroundColor} {
+		if clr == "" {
+			continue
+		}
+		if _, err := color.NewColor(clr); err != nil {
+			return fmt.Errorf("invalid %s '%s': %w", field, clr, err)
+		}
+	}
+	return nil
+}
+
 // StartEffect starts a specific effect on a device at a given FPS
-func StartEffect(deviceConfig config.DeviceConfig, effect Effect, clr string, fps int, done <-chan bool) error {
+func StartEffect(target config.Device, effect Effect, clr string, fps int, done <-chan bool) error {
 	logger.Logger.Debug(fmt.Sprintf("fps: %v", fps))
 	usPerFrame := (float64(1.0) / float64(fps))
 	usPerFrameDuration := time.Duration(usPerFrame*1000000.0) * time.Microsecond
@@ -33,15 +67,8 @@ func StartEffect(deviceConfig config.DeviceConfig, effect Effect, clr string, fp
 	ticker := time.NewTicker(usPerFrameDuration)
 	phase := 0.0 // phase of the effect (range 0.0 to 2π)
 
-	// TODO: choose type of device dynamically based on the deviceConfig
-	var device = &device.UDPDevice{
-		Name:     deviceConfig.Name,
-		Port:     deviceConfig.Port,
-		Protocol: device.UDPProtocols[deviceConfig.UdpPacketType],
-		Config:   deviceConfig,
-	}
-
-	err := device.Init()
+	// TODO: choose type of device dynamically based on the device config
+	device, err := device.OpenUDPDevice(target)
 	if err != nil {
 		logger.Logger.Fatal(err)
 	}
@@ -63,12 +90,7 @@ func StartEffect(deviceConfig config.DeviceConfig, effect Effect, clr string, fp
 			if err != nil {
 				return err
 			}
-			err = device.SendData(effect.AssembleFrame(phase, device.Config.PixelCount, newColor), 0x00)
-			if err != nil {
-				return err
-			}
-			device.Close()
-			return nil
+			return device.SendData(effect.AssembleFrame(phase, device.Config.PixelCount, newColor), 0x00)
 		case <-ticker.C:
 			// TODO: get pixelCount and color from config
 			// TODO: this should be
@@ -88,15 +110,10 @@ func StartEffect(deviceConfig config.DeviceConfig, effect Effect, clr string, fp
 		}
 	}
 }
-func StopEffect(deviceConfig config.DeviceConfig, effect Effect, clr string, fps int, done <-chan bool) error {
-
-	// TODO: choose type of device dynamically based on the deviceConfig
-	var device = &device.UDPDevice{
-		Name:     deviceConfig.Name,
-		Port:     deviceConfig.Port,
-		Protocol: device.UDPProtocols[deviceConfig.UdpPacketType],
-		Config:   deviceConfig,
-	}
+func StopEffect(target config.Device, effect Effect, clr string, fps int, done <-chan bool) error {
+
+	// TODO: choose type of device dynamically based on the device config
+	var device = device.NewUDPDevice(target)
 
 	err := device.Init()
 	if err != nil {
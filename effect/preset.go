@@ -0,0 +1,51 @@
+package effect
+
+import (
+	"errors"
+	"fmt"
+	"ledfx/config"
+)
+
+// AddPresetToConfig adds a preset or replaces the preset with the same id
+func AddPresetToConfig(preset config.Preset) (exists bool, err error) {
+	if preset.Id == "" {
+		return exists, errors.New("preset id is empty. Please provide Id to add preset to config")
+	}
+
+	err = config.Update(func(c *config.Config) error {
+		for index, p := range c.Presets {
+			if p.Id == preset.Id {
+				exists = true
+				c.Presets[index] = preset
+				return nil
+			}
+		}
+		c.Presets = append(c.Presets, preset)
+		return nil
+	})
+	return exists, err
+}
+
+// RemovePresetFromConfig removes the preset with the given id
+func RemovePresetFromConfig(id string) error {
+	return config.Update(func(c *config.Config) error {
+		for index, p := range c.Presets {
+			if p.Id == id {
+				c.Presets = append(c.Presets[:index], c.Presets[index+1:]...)
+				return nil
+			}
+		}
+		return fmt.Errorf("preset '%s' does not exist", id)
+	})
+}
+
+// ValidatePreset checks that a preset can be applied to a virtual
+func ValidatePreset(preset config.Preset) error {
+	switch {
+	case preset.Id == "":
+		return errors.New("preset id is empty")
+	case preset.Name == "":
+		return errors.New("preset name is empty")
+	}
+	return ValidateEffect(config.Effect{Type: preset.Type, Config: preset.Config})
+}
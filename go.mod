@@ -79,7 +79,7 @@ require (
 	github.com/dop251/goja v0.0.0-20220124171016-cfb079cdc7b4 // indirect
 	github.com/kkdai/youtube/v2 v2.7.10
 	github.com/schollz/progressbar/v3 v3.8.6
-	golang.org/x/crypto v0.0.0-20220210151621-f4118a5b28e2 // indirect
+	golang.org/x/crypto v0.0.0-20220210151621-f4118a5b28e2
 	golang.org/x/net v0.0.0-20220111093109-d55c255bac03 // indirect
 	golang.org/x/sys v0.0.0-20220209214540-3681064d5158 // indirect
 )
@@ -192,5 +192,10 @@ func record(client *rtsp.Client, session *rtsp.Session, codecType CodecType) (st
 	if resp.Status != rtsp.Ok {
 		return nil, fmt.Errorf("Non-ok status returned: %s", resp.Status.String())
 	}
+	if latency, ok := resp.Headers["Audio-Latency"]; ok {
+		if session.Latency, err = strconv.Atoi(strings.TrimSpace(latency)); err != nil {
+			log.Logger.WithField("category", "RAOP Client").Warnf("Ignoring invalid Audio-Latency '%s'", latency)
+		}
+	}
 	return nil, nil
 }
@@ -34,10 +34,13 @@ type Session struct {
 	decrypter   Decrypter
 	RemotePorts PortSet
 	LocalPorts  PortSet
-	dataConn    net.Conn
-	DataChan    chan []byte
-	stopChan    chan struct{}
-	buf         *bytes.Buffer
+	// Latency is the audio latency in frames the receiver reported when
+	// recording started, 0 if it reported none
+	Latency  int
+	dataConn net.Conn
+	DataChan chan []byte
+	stopChan chan struct{}
+	buf      *bytes.Buffer
 
 	sendBuf    []byte
 	packetChan chan []byte
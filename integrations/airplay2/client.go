@@ -84,6 +84,15 @@ func (cl *Client) SampleRate() int {
 	return cl.dev.AudioSampleRate()
 }
 
+// Latency returns how long the receiver said it takes to play audio it
+// receives, 0 if it didn't say
+func (cl *Client) Latency() time.Duration {
+	if cl.session == nil || cl.session.Latency <= 0 || cl.SampleRate() <= 0 {
+		return 0
+	}
+	return time.Duration(cl.session.Latency) * time.Second / time.Duration(cl.SampleRate())
+}
+
 func (cl *Client) WriterID() string {
 	return cl.session.RemotePorts.Address
 }
@@ -2,7 +2,7 @@ package airplay2
 
 import (
 	"encoding/json"
-	"fmt"
+	"io"
 	"ledfx/audio"
 	"ledfx/handlers/player"
 	"ledfx/handlers/raop"
@@ -17,7 +17,7 @@ type audioPlayer struct {
 	/* Variables that are looped through often belong at the top of the struct */
 	wg sync.WaitGroup
 
-	byteWriter *audio.AsyncMultiWriter
+	out io.Writer
 
 	hasClients, sessionActive, muted bool
 
@@ -58,13 +58,13 @@ func (p *audioPlayer) MarshalJSON() (b []byte, err error) {
 		Muted:         p.muted,
 	})
 }
-func newPlayer(byteWriter *audio.AsyncMultiWriter) *audioPlayer {
+func newPlayer(out io.Writer) *audioPlayer {
 	p := &audioPlayer{
-		apClients:  make([]*Client, 0),
-		volume:     1,
-		quit:       make(chan bool),
-		wg:         sync.WaitGroup{},
-		byteWriter: byteWriter,
+		apClients: make([]*Client, 0),
+		volume:    1,
+		quit:      make(chan bool),
+		wg:        sync.WaitGroup{},
+		out:       out,
 	}
 
 	return p
@@ -97,8 +97,8 @@ func (p *audioPlayer) Play(session *rtsp.Session) {
 						recvBuf = dc.Decode(recvBuf)
 						codec.NormalizeAudio(recvBuf, p.volume)
 
-						if _, err := p.byteWriter.Write(recvBuf); err != nil {
-							log.Logger.WithField("category", "AirPlay Player").Errorf("Error writing to byteWriter: %v", err)
+						if _, err := p.out.Write(recvBuf); err != nil {
+							log.Logger.WithField("category", "AirPlay Player").Errorf("Error writing to output: %v", err)
 						}
 					}()
 				}
@@ -123,16 +123,27 @@ func twoBytesToInt16Unsafe(p []byte) (out int16) {
 	return *(*int16)(unsafe.Pointer(&p[0]))
 }
 
+// AddClient passes the volume, track and artwork of the session on to client.
+// The audio reaches clients through the outputs of the bridge.
 func (p *audioPlayer) AddClient(client *Client) (err error) {
 	p.hasClients = true
 	p.apClients = append(p.apClients, client)
-	if err := p.byteWriter.AddWriter(p.apClients[p.numClients], client.WriterID()); err != nil {
-		return fmt.Errorf("error adding writer: %w", err)
-	}
 	p.numClients++
 	return nil
 }
 
+// RemoveClient stops passing the session parameters on to client
+func (p *audioPlayer) RemoveClient(client *Client) {
+	for i, c := range p.apClients {
+		if c == client {
+			p.apClients = append(p.apClients[:i], p.apClients[i+1:]...)
+			p.numClients--
+			break
+		}
+	}
+	p.hasClients = p.numClients > 0
+}
+
 func (p *audioPlayer) SetVolume(volume float64) {
 	p.volume = volume
 	if p.hasClients {
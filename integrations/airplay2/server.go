@@ -2,7 +2,7 @@ package airplay2
 
 import (
 	"encoding/json"
-	"ledfx/audio"
+	"io"
 	"ledfx/handlers/raop"
 	log "ledfx/logger"
 	"math/rand"
@@ -43,8 +43,9 @@ func (s *Server) Artwork() (b []byte) {
 	return s.player.GetAlbumArt()
 }
 
-func NewServer(conf Config, byteWriter *audio.AsyncMultiWriter) (s *Server) {
-	pl := newPlayer(byteWriter)
+// NewServer returns a server that writes the audio it receives to out
+func NewServer(conf Config, out io.Writer) (s *Server) {
+	pl := newPlayer(out)
 
 	if conf.Port == 0 {
 		conf.Port = 7000
@@ -69,6 +70,10 @@ func (s *Server) AddClient(client *Client) error {
 	return s.player.AddClient(client)
 }
 
+func (s *Server) RemoveClient(client *Client) {
+	s.player.RemoveClient(client)
+}
+
 func (s *Server) Start() error {
 	errCh := make(chan error)
 	go func() {
@@ -0,0 +1,155 @@
+// Package lifecycle starts the subsystems of LedFx in order and stops them in
+// reverse order, each within a deadline.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	log "ledfx/logger"
+	"sync"
+	"time"
+)
+
+// DefaultStopTimeout is how long Stop waits for each subsystem to stop
+const DefaultStopTimeout = 5 * time.Second
+
+var ErrStarted = errors.New("lifecycle manager already started")
+
+// StartFunc starts a subsystem. The context is cancelled right before the
+// subsystem is stopped, so background goroutines may use it to exit.
+type StartFunc func(ctx context.Context) error
+
+// StopFunc stops a subsystem. The context expires at the deadline of that
+// subsystem.
+type StopFunc func(ctx context.Context) error
+
+type subsystem struct {
+	name   string
+	start  StartFunc
+	stop   StopFunc
+	cancel context.CancelFunc
+}
+
+type Manager struct {
+	mu         sync.Mutex
+	subsystems []*subsystem
+	// started is the number of subsystems started so far, in order
+	started int
+	running bool
+	timeout time.Duration
+}
+
+// New returns a manager whose Stop gives up on a subsystem after timeout.
+// A timeout <= 0 uses DefaultStopTimeout.
+func New(timeout time.Duration) *Manager {
+	if timeout <= 0 {
+		timeout = DefaultStopTimeout
+	}
+	return &Manager{timeout: timeout}
+}
+
+// Add registers a subsystem. Subsystems start in the order they are added.
+// Either func may be nil.
+func (m *Manager) Add(name string, start StartFunc, stop StopFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.subsystems = append(m.subsystems, &subsystem{name: name, start: start, stop: stop})
+}
+
+// Start starts every subsystem in order with a context derived from ctx.
+// If one fails, the ones already started are stopped again and the error is
+// returned.
+func (m *Manager) Start(ctx context.Context) error {
+	m.mu.Lock()
+	if m.running {
+		m.mu.Unlock()
+		return ErrStarted
+	}
+	m.running = true
+	subsystems := m.subsystems
+	m.mu.Unlock()
+
+	for _, s := range subsystems {
+		log.Logger.WithField("category", "Lifecycle").Debugf("Starting %s", s.name)
+		var subCtx context.Context
+		subCtx, s.cancel = context.WithCancel(ctx)
+		if s.start != nil {
+			if err := s.start(subCtx); err != nil {
+				s.cancel()
+				if stopErr := m.Stop(); stopErr != nil {
+					log.Logger.WithField("category", "Lifecycle").Warnf("Error stopping after failed start: %v", stopErr)
+				}
+				return fmt.Errorf("error starting %s: %w", s.name, err)
+			}
+		}
+		m.mu.Lock()
+		m.started++
+		m.mu.Unlock()
+	}
+	return nil
+}
+
+// Stop stops the started subsystems in reverse order. Each gets the stop
+// timeout of its own, so one that hangs doesn't keep the ones started before
+// it, like the config flush, from stopping. Calling Stop again is a no-op.
+func (m *Manager) Stop() error {
+	m.mu.Lock()
+	started := m.subsystems[:m.started]
+	m.started = 0
+	m.running = false
+	m.mu.Unlock()
+
+	var errs []error
+	for i := len(started) - 1; i >= 0; i-- {
+		s := started[i]
+		s.cancel()
+		if s.stop == nil {
+			continue
+		}
+		log.Logger.WithField("category", "Lifecycle").Debugf("Stopping %s", s.name)
+		if err := stopWithin(m.timeout, s.stop); err != nil {
+			errs = append(errs, fmt.Errorf("error stopping %s: %w", s.name, err))
+		}
+	}
+	return joinErrors(errs)
+}
+
+// Run starts every subsystem, waits until ctx is done and stops them again
+func (m *Manager) Run(ctx context.Context) error {
+	if err := m.Start(ctx); err != nil {
+		return err
+	}
+	<-ctx.Done()
+	return m.Stop()
+}
+
+// stopWithin runs stop but returns after timeout even if stop hangs
+func stopWithin(timeout time.Duration, stop StopFunc) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- stop(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func joinErrors(errs []error) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+	msg := ""
+	for _, err := range errs[1:] {
+		msg += "; " + err.Error()
+	}
+	return fmt.Errorf("%w%s", errs[0], msg)
+}
@@ -0,0 +1,140 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStartStopOrder(t *testing.T) {
+	var events []string
+	m := New(time.Second)
+	for _, name := range []string{"devices", "bridge", "http"} {
+		name := name
+		m.Add(name, func(ctx context.Context) error {
+			events = append(events, "start "+name)
+			return nil
+		}, func(ctx context.Context) error {
+			events = append(events, "stop "+name)
+			return nil
+		})
+	}
+	if err := m.Start(context.Background()); err != nil {
+		t.Fatalf("Error starting: %v\n", err)
+	}
+	if err := m.Stop(); err != nil {
+		t.Fatalf("Error stopping: %v\n", err)
+	}
+	// Stopping twice is a no-op
+	if err := m.Stop(); err != nil {
+		t.Fatalf("Error stopping twice: %v\n", err)
+	}
+	expected := []string{"start devices", "start bridge", "start http", "stop http", "stop bridge", "stop devices"}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("Expected %v but got %v", expected, events)
+	}
+}
+
+func TestStartFailure(t *testing.T) {
+	var events []string
+	m := New(time.Second)
+	m.Add("devices", nil, func(ctx context.Context) error {
+		events = append(events, "stop devices")
+		return nil
+	})
+	m.Add("http", func(ctx context.Context) error {
+		return errors.New("address in use")
+	}, func(ctx context.Context) error {
+		events = append(events, "stop http")
+		return nil
+	})
+	m.Add("zeroconf", func(ctx context.Context) error {
+		events = append(events, "start zeroconf")
+		return nil
+	}, nil)
+
+	if err := m.Start(context.Background()); err == nil {
+		t.Fatalf("Expected an error from Start")
+	}
+	if expected := []string{"stop devices"}; !reflect.DeepEqual(events, expected) {
+		t.Errorf("Expected %v but got %v", expected, events)
+	}
+}
+
+func TestSubsystemContext(t *testing.T) {
+	m := New(time.Second)
+	var first, second context.Context
+	m.Add("first", func(ctx context.Context) error {
+		first = ctx
+		return nil
+	}, nil)
+	m.Add("second", func(ctx context.Context) error {
+		second = ctx
+		return nil
+	}, func(ctx context.Context) error {
+		// stopped before first, so first is still running
+		if first.Err() != nil {
+			t.Errorf("First subsystem was cancelled before second stopped")
+		}
+		return nil
+	})
+	if err := m.Start(context.Background()); err != nil {
+		t.Fatalf("Error starting: %v\n", err)
+	}
+	if first.Err() != nil || second.Err() != nil {
+		t.Fatalf("Contexts cancelled while running")
+	}
+	if err := m.Stop(); err != nil {
+		t.Fatalf("Error stopping: %v\n", err)
+	}
+	if first.Err() == nil || second.Err() == nil {
+		t.Errorf("Contexts not cancelled after Stop")
+	}
+}
+
+func TestStopDeadline(t *testing.T) {
+	m := New(50 * time.Millisecond)
+	var stopped bool
+	m.Add("devices", nil, func(ctx context.Context) error {
+		// With time left of its own
+		stopped = ctx.Err() == nil
+		return nil
+	})
+	m.Add("stuck", nil, func(ctx context.Context) error {
+		select {}
+	})
+	if err := m.Start(context.Background()); err != nil {
+		t.Fatalf("Error starting: %v\n", err)
+	}
+
+	begin := time.Now()
+	err := m.Stop()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a deadline error, got %v", err)
+	}
+	if elapsed := time.Since(begin); elapsed > time.Second {
+		t.Errorf("Stop took %v, longer than the deadline of each subsystem", elapsed)
+	}
+	if !stopped {
+		t.Errorf("Subsystems started before a stuck one should still be stopped")
+	}
+}
+
+func TestRun(t *testing.T) {
+	m := New(time.Second)
+	var stopped bool
+	m.Add("devices", nil, func(ctx context.Context) error {
+		stopped = true
+		return nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := m.Run(ctx); err != nil {
+		t.Fatalf("Error running: %v\n", err)
+	}
+	if !stopped {
+		t.Errorf("Run did not stop the subsystems")
+	}
+}
@@ -2,6 +2,7 @@ package logger
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -31,3 +32,17 @@ func init() {
 	})
 	Logger.SetReportCaller(true)
 }
+
+// LogToFile appends all further log output to the file at path. Colors are
+// turned off since the output no longer goes to a terminal.
+func LogToFile(path string) (io.Closer, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening log file: %w", err)
+	}
+	if formatter, ok := Logger.Formatter.(*nested.Formatter); ok {
+		formatter.NoColors = true
+	}
+	Logger.SetOutput(f)
+	return f, nil
+}
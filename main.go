@@ -1,11 +1,13 @@
 package main
 
 import (
-	"flag"
+	"context"
 	"fmt"
 	"ledfx/audio"
 	"ledfx/config"
 	"ledfx/constants"
+	"ledfx/discovery"
+	"ledfx/lifecycle"
 	"ledfx/logger"
 	"ledfx/utils"
 	"ledfx/virtual"
@@ -14,22 +16,10 @@ import (
 	"os/signal"
 	"syscall"
 
-	"github.com/getlantern/systray"
+	"github.com/spf13/pflag"
 )
 
 func init() {
-	flag.StringVar(&ip, "ip", "0.0.0.0", "The IP address the frontend will run on")
-	flag.IntVar(&port, "port", 8080, "The port the frontend will run on")
-
-	// Capture ctrl-c or sigterm to gracefully shutdown
-	c := make(chan os.Signal, 2)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		<-c
-		shutdown()
-		os.Exit(1)
-	}()
-
 	// Initialize Config
 	err := config.InitConfig()
 	if err != nil {
@@ -38,66 +28,128 @@ func init() {
 
 }
 
-var (
-	ip   string
-	port int
-)
-
 func main() {
+	conf := config.Snapshot()
+
 	// Just print version and return if flag is set
-	if config.GlobalConfig.Version {
+	if conf.Version {
 		fmt.Println("LedFx " + constants.VERSION)
 		return
 	}
 
-	// Print the cli logo
-	err := utils.PrintLogo()
-	if err != nil {
-		logger.Logger.Fatal(err)
+	if args := pflag.Args(); len(args) > 0 {
+		os.Exit(runCommand(args))
+	}
+
+	if conf.LogFile != "" {
+		logFile, err := logger.LogToFile(conf.LogFile)
+		if err != nil {
+			logger.Logger.Fatal(err)
+		}
+		defer logFile.Close()
+	}
+
+	headless := conf.Headless || !utils.TraySupported
+	if !headless {
+		// Print the cli logo
+		err := utils.PrintLogo()
+		if err != nil {
+			logger.Logger.Fatal(err)
+		}
+		fmt.Println("Welcome to LedFx " + constants.VERSION)
+		fmt.Println()
 	}
-	fmt.Println("Welcome to LedFx " + constants.VERSION)
-	fmt.Println()
 
 	logger.Logger.Info("Verbose logging enabled")
 	logger.Logger.Debug("Very verbose logging enabled")
 
 	// TODO: handle other flags
 	/**
-	  OpenUi
-	  Host
-	  Offline
 	  SentryCrash
 	*/
 
 	audio.LogAudioDevices()
 	//go audio.CaptureDemo()
 
-	go func() {
-		utils.SetupRoutes()
-	}()
+	// Capture ctrl-c or sigterm to gracefully shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	go func() {
-		utils.InitFrontend(ip, port)
-	}()
+	manager := newLifecycle(conf)
+	if err := manager.Start(ctx); err != nil {
+		logger.Logger.Fatal(err)
+	}
 
-	go func() {
-		err = utils.ScanZeroconf()
-		if err != nil {
-			logger.Logger.Fatal(err)
+	if headless {
+		logger.Logger.Infof("Running headless on %s:%d", conf.Host, conf.Port)
+		<-ctx.Done()
+	} else {
+		utils.PrintBanner(conf.Port)
+		if conf.OpenUi {
+			utils.Openbrowser(utils.FrontendURL(conf.Port))
 		}
-	}()
+		utils.RunTray(ctx, conf.Port)
+	}
+	shutdown(manager)
+}
 
-	systray.Run(utils.OnReady, utils.OnExit)
-	os.TempDir()
+// newLifecycle registers the subsystems in start order, they stop in reverse
+func newLifecycle(conf config.Config) *lifecycle.Manager {
+	manager := lifecycle.New(lifecycle.DefaultStopTimeout)
+	var frontend *utils.Frontend
 
-	err = virtual.LoadVirtuals()
-	if err != nil {
-		logger.Logger.Warn(err)
+	manager.Add("config", nil, func(ctx context.Context) error {
+		return config.Flush()
+	})
+
+	manager.Add("virtuals", func(ctx context.Context) error {
+		return virtual.LoadVirtuals()
+	}, virtual.Shutdown)
+
+	manager.Add("audio bridge", func(ctx context.Context) (err error) {
+		frontend, err = utils.InitFrontend(conf.Host, conf.Port)
+		return err
+	}, func(ctx context.Context) error {
+		frontend.StopBridge()
+		return nil
+	})
+
+	manager.Add("http server", func(ctx context.Context) error {
+		utils.SetupRoutes()
+		return frontend.Serve()
+	}, func(ctx context.Context) error {
+		return frontend.Shutdown(ctx)
+	})
+
+	// Discovery broadcasts on the network, which offline rules out
+	if !conf.Offline {
+		manager.Add("discovery", func(ctx context.Context) error {
+			discovery.Default.OnNew(func(p discovery.Pending) {
+				if utils.Ws != nil {
+					utils.SendWs(utils.Ws, "info", "New "+p.Source+" device found: "+p.Device.Config.Name)
+				}
+			})
+			go discovery.Run(ctx)
+			return nil
+		}, nil)
 	}
+
+	manager.Add("audio devices", func(ctx context.Context) error {
+		go audio.WatchDevices(ctx, func(e audio.DeviceEvent) {
+			logger.Logger.Infof("Audio device %s: %s", e.Type, e.Device.Name)
+			if utils.Ws != nil {
+				utils.SendWs(utils.Ws, "info", "Audio device "+e.Type+": "+e.Device.Name)
+			}
+		})
+		return nil
+	}, nil)
+
+	return manager
 }
 
-func shutdown() {
+func shutdown(manager *lifecycle.Manager) {
 	logger.Logger.Info("Shutting down LedFx")
-	// kill systray
-	systray.Quit()
+	if err := manager.Stop(); err != nil {
+		logger.Logger.Warn(err)
+	}
 }
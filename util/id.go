@@ -0,0 +1,25 @@
+package util
+
+import (
+	"strings"
+	"unicode"
+)
+
+// GenerateId turns a display name into a lowercase, dash separated id,
+// e.g. "Living Room #2" -> "living-room-2"
+func GenerateId(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	return b.String()
+}
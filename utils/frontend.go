@@ -1,23 +1,37 @@
 package utils
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"ledfx/api"
 	"ledfx/audio"
+	"ledfx/audio/analysis"
+	"ledfx/audio/pcm"
+	"ledfx/auth"
 	"ledfx/bridgeapi"
+	"ledfx/config"
 	log "ledfx/logger"
+	"net"
 	"net/http"
 	"path/filepath"
+	"reflect"
 	"runtime"
+	"strings"
+	"sync/atomic"
 
 	pretty "github.com/fatih/color"
 	"github.com/rs/cors"
 )
 
 func ServeHttp() {
-	DownloadFrontend()
+	if config.Snapshot().Offline {
+		log.Logger.WithField("category", "HTTP Server").Infoln("Offline, serving the frontend already on disk")
+	} else {
+		DownloadFrontend()
+	}
 	serveFrontend := http.FileServer(http.Dir("frontend"))
-	api.HandleApi()
+	api.HandleApi(http.DefaultServeMux)
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		log.Logger.WithField("category", "HTTP Regexp").Debugf("Request asked for %s", r.URL.Path)
 		if filepath.Ext(r.URL.Path) == "" {
@@ -30,21 +44,112 @@ func ServeHttp() {
 	})
 }
 
-func InitFrontend(ip string, port int) {
-	fxHandler, err := audio.NewFxHandler()
-	if err != nil {
-		log.Logger.Fatalf("Error initializing new FX handler: %v", err)
+// corsHandler lets every origin read until an allow-list is configured,
+// only listed origins may change anything
+func corsHandler(origins []string) *cors.Cors {
+	if len(origins) == 0 {
+		return cors.New(cors.Options{
+			AllowedOrigins: []string{"*"},
+			AllowedMethods: []string{http.MethodGet, http.MethodHead},
+			AllowedHeaders: []string{"Content-Type", "Origin", "Accept", "Authorization", "token"},
+		})
 	}
-	go func(fxh *audio.FxHandler) {
-		err := bridgeapi.NewServer(fxh.Callback, http.DefaultServeMux)
-		if err != nil {
-			log.Logger.Fatal(err)
+	return cors.New(cors.Options{
+		AllowedOrigins: origins,
+		AllowedMethods: []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions},
+		AllowedHeaders: []string{"Content-Type", "Origin", "Accept", "Authorization", "token"},
+	})
+}
+
+// originHandler applies the CORS allow-list currently in the config
+type originHandler struct {
+	handler atomic.Value // http.Handler
+}
+
+func (h *originHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.handler.Load().(http.Handler).ServeHTTP(w, r)
+}
+
+// newOriginHandler wraps next in CORS and rebuilds the wrapper whenever the
+// allowed origins change, until stop is called
+func newOriginHandler(next http.Handler) (h *originHandler, stop func()) {
+	h = &originHandler{}
+	origins := config.Snapshot().Auth.AllowedOrigins
+	h.handler.Store(corsHandler(origins).Handler(next))
+
+	changes, stop := config.Subscribe()
+	go func() {
+		for conf := range changes {
+			if !reflect.DeepEqual(conf.Auth.AllowedOrigins, origins) {
+				origins = conf.Auth.AllowedOrigins
+				h.handler.Store(corsHandler(origins).Handler(next))
+			}
 		}
+	}()
+	return h, stop
+}
 
-		if err = http.ListenAndServe(fmt.Sprintf("%s:%d", ip, port), cors.AllowAll().Handler(http.DefaultServeMux)); err != nil {
-			log.Logger.Fatal(err)
+// Frontend serves the frontend, the API and the audio bridge over HTTP
+type Frontend struct {
+	server      *http.Server
+	bridge      *bridgeapi.Server
+	stopOrigins func()
+}
+
+// InitFrontend sets up the audio bridge and the HTTP server for ip:port.
+// Nothing is served until Serve is called.
+func InitFrontend(ip string, port int) (*Frontend, error) {
+	fxHandler, err := audio.NewFxHandler(pcm.CD, analysis.Backend(config.Snapshot().Audio.Analysis))
+	if err != nil {
+		return nil, fmt.Errorf("error initializing new FX handler: %w", err)
+	}
+	bridge, err := bridgeapi.NewServer(fxHandler.Callback, http.DefaultServeMux)
+	if err != nil {
+		return nil, err
+	}
+	handler, stopOrigins := newOriginHandler(auth.Middleware(http.DefaultServeMux))
+	return &Frontend{
+		server: &http.Server{
+			Addr:    fmt.Sprintf("%s:%d", ip, port),
+			Handler: handler,
+		},
+		bridge:      bridge,
+		stopOrigins: stopOrigins,
+	}, nil
+}
+
+// Serve starts listening and serves in the background until Shutdown
+func (f *Frontend) Serve() error {
+	ln, err := net.Listen("tcp", f.server.Addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := f.server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Logger.WithField("category", "HTTP Server").Errorf("Error serving HTTP: %v", err)
 		}
-	}(fxHandler)
+	}()
+	return nil
+}
+
+// Shutdown stops accepting connections and waits for active requests
+func (f *Frontend) Shutdown(ctx context.Context) error {
+	f.stopOrigins()
+	return f.server.Shutdown(ctx)
+}
+
+// StopBridge stops the audio bridge
+func (f *Frontend) StopBridge() {
+	f.bridge.Stop()
+}
+
+// FrontendURL is the link to the frontend served on port
+func FrontendURL(port int) string {
+	return fmt.Sprintf("http://localhost:%d/#/?newCore=1", port)
+}
+
+// PrintBanner prints the link to the frontend served on port
+func PrintBanner(port int) {
 	borderPrinter := pretty.New(pretty.BgBlack, pretty.FgRed)
 	boldPrinter := pretty.New(pretty.BgBlack, pretty.FgRed, pretty.Bold)
 	namePrinter := pretty.New(pretty.BgBlack, pretty.FgWhite, pretty.Faint)
@@ -66,19 +171,19 @@ func InitFrontend(ip string, port int) {
 	fmt.Println()
 	borderPrinter.Print("│   ")
 
-	switch runtime.GOOS {
-	case "darwin":
-		keyCombPrinter.Print("[CMD]+Click: ")
-	default:
-		keyCombPrinter.Print("[CTRL]+Click: ")
+	keyComb := "[CTRL]+Click: "
+	if runtime.GOOS == "darwin" {
+		keyComb = "[CMD]+Click: "
 	}
-	linkPrinter.Print("http://localhost:8080/#/?newCore=1")
-	switch runtime.GOOS {
-	case "darwin":
-		borderPrinter.Print("     │")
-	default:
-		borderPrinter.Print("    │")
+	link := FrontendURL(port)
+	keyCombPrinter.Print(keyComb)
+	linkPrinter.Print(link)
+	// Pad to the 55 columns inside the border
+	padding := 55 - 3 - len(keyComb) - len(link)
+	if padding < 1 {
+		padding = 1
 	}
+	borderPrinter.Print(strings.Repeat(" ", padding) + "│")
 	fmt.Println()
 	borderPrinter.Print("│                                                       │")
 	fmt.Println()
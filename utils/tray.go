@@ -1,7 +1,11 @@
+//go:build !notray && cgo
+// +build !notray,cgo
+
 //go:generate goversioninfo -icon=assets/logo.ico
 package utils
 
 import (
+	"context"
 	_ "embed"
 	log "ledfx/logger"
 
@@ -11,7 +15,21 @@ import (
 //go:embed assets/logo.ico
 var logo []byte
 
-func OnReady() {
+// TraySupported is false in builds with the notray tag, which do not link
+// against the desktop libraries the tray needs
+const TraySupported = true
+
+// RunTray shows the tray icon, which opens the frontend served on port, and
+// blocks until Quit is clicked or ctx is done
+func RunTray(ctx context.Context, port int) {
+	go func() {
+		<-ctx.Done()
+		systray.Quit()
+	}()
+	systray.Run(func() { onReady(port) }, OnExit)
+}
+
+func onReady(port int) {
 	systray.SetIcon(logo)
 	systray.SetTooltip("LedFx-Go")
 	mOpen := systray.AddMenuItem("Open", "Open LedFx Frontend in Browser")
@@ -22,7 +40,7 @@ func OnReady() {
 		for {
 			select {
 			case <-mOpen.ClickedCh:
-				Openbrowser("http://localhost:8080/#/?newCore=1")
+				Openbrowser(FrontendURL(port))
 			case <-mGithub.ClickedCh:
 				Openbrowser("https://github.com/LedFx/ledfx_rewrite")
 			case <-mQuit.ClickedCh:
@@ -0,0 +1,15 @@
+//go:build notray || !cgo
+// +build notray !cgo
+
+package utils
+
+import "context"
+
+// TraySupported is false in builds with the notray tag or without cgo, which
+// do not link against the desktop libraries the tray needs
+const TraySupported = false
+
+// RunTray blocks until ctx is done
+func RunTray(ctx context.Context, port int) {
+	<-ctx.Done()
+}
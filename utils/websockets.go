@@ -2,6 +2,7 @@ package utils
 
 import (
 	"encoding/json"
+	"ledfx/auth"
 	"ledfx/logger"
 	"net/http"
 
@@ -14,11 +15,9 @@ var Upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
 
-	// We'll need to check the origin of our connection
-	// this will allow us to make requests from our React
-	// development server to here.
-	// For now, we'll do no checking and just allow any connection
-	CheckOrigin: func(r *http.Request) bool { return true },
+	// Same origin requests are allowed, other origins (like the React
+	// development server) have to be in the auth allow-list
+	CheckOrigin: auth.CheckOrigin,
 }
 
 // Msg defines a reader which will listen for
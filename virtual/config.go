@@ -0,0 +1,145 @@
+package virtual
+
+import (
+	"errors"
+	"fmt"
+	"ledfx/config"
+	"ledfx/device"
+	"ledfx/effect"
+)
+
+// AddVirtualToConfig adds a virtual or replaces the virtual with the same id
+func AddVirtualToConfig(virtual config.Virtual) (exists bool, err error) {
+	if virtual.Id == "" {
+		return exists, errors.New("virtual id is empty. Please provide Id to add virtual to config")
+	}
+
+	err = config.Update(func(c *config.Config) error {
+		for index, virt := range c.Virtuals {
+			if virt.Id == virtual.Id {
+				exists = true
+				c.Virtuals[index] = virtual
+				return nil
+			}
+		}
+		c.Virtuals = append(c.Virtuals, virtual)
+		return nil
+	})
+	return exists, err
+}
+
+// RemoveVirtualFromConfig removes the virtual with the given id
+func RemoveVirtualFromConfig(id string) error {
+	err := config.Update(func(c *config.Config) error {
+		for index, virt := range c.Virtuals {
+			if virt.Id == id {
+				c.Virtuals = append(c.Virtuals[:index], c.Virtuals[index+1:]...)
+				return nil
+			}
+		}
+		return fmt.Errorf("virtual '%s' does not exist", id)
+	})
+	if err == nil {
+		dropDevice(id)
+	}
+	return err
+}
+
+// ReleaseDevice stops the active virtuals streaming to the device with the
+// given id and closes the device, ahead of removing it
+func ReleaseDevice(id string) error {
+	for _, virt := range config.Snapshot().Virtuals {
+		if virt.IsDevice != id {
+			continue
+		}
+		if virt.Active {
+			if err := StopVirtual(virt.Id); err != nil {
+				return fmt.Errorf("error stopping virtual '%s': %w", virt.Id, err)
+			}
+		}
+		dropDevice(virt.Id)
+	}
+	device.CloseUDPDevice(id)
+	return nil
+}
+
+// ValidateVirtual checks that a virtual only references existing devices and
+// that its segments fit on them
+func ValidateVirtual(virtual config.Virtual) error {
+	return ValidateVirtualOn(virtual, config.Snapshot().Devices)
+}
+
+// ValidateVirtualOn is ValidateVirtual against the given devices instead of
+// the configured ones
+func ValidateVirtualOn(virtual config.Virtual, devices []config.Device) error {
+	switch {
+	case virtual.Id == "":
+		return errors.New("virtual id is empty")
+	case virtual.Config.Name == "":
+		return errors.New("virtual name is empty")
+	}
+
+	if virtual.IsDevice != "" {
+		if _, ok := findDevice(devices, virtual.IsDevice); !ok {
+			return fmt.Errorf("is_device references unknown device '%s'", virtual.IsDevice)
+		}
+	}
+
+	for i, seg := range virtual.Segments {
+		if len(seg) != 4 {
+			return fmt.Errorf("segment %d must be [device_id, start, end, reverse]", i)
+		}
+		id, ok := seg[0].(string)
+		if !ok {
+			return fmt.Errorf("segment %d device id must be a string", i)
+		}
+		dev, ok := findDevice(devices, id)
+		if !ok {
+			return fmt.Errorf("segment %d references unknown device '%s'", i, id)
+		}
+		start, ok1 := segmentIndex(seg[1])
+		end, ok2 := segmentIndex(seg[2])
+		if !ok1 || !ok2 {
+			return fmt.Errorf("segment %d start and end must be integers", i)
+		}
+		if start < 0 || end < start || end >= dev.Config.PixelCount {
+			return fmt.Errorf("segment %d range %d-%d does not fit device '%s' with %d pixels", i, start, end, id, dev.Config.PixelCount)
+		}
+		if _, ok := seg[3].(bool); !ok {
+			return fmt.Errorf("segment %d reverse flag must be a boolean", i)
+		}
+	}
+
+	if virtual.Effect.Type != "" {
+		if err := effect.ValidateEffect(virtual.Effect); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func findDevice(devices []config.Device, id string) (config.Device, bool) {
+	for _, dev := range devices {
+		if dev.Id == id {
+			return dev, true
+		}
+	}
+	return config.Device{}, false
+}
+
+// segmentIndex accepts both the ints written by LedFx and the float64s
+// produced when segments are decoded from JSON
+func segmentIndex(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int64:
+		return int(n), true
+	case float64:
+		if n != float64(int(n)) {
+			return 0, false
+		}
+		return int(n), true
+	}
+	return 0, false
+}
@@ -0,0 +1,49 @@
+package virtual
+
+import (
+	"ledfx/color"
+	"ledfx/config"
+	"ledfx/device"
+	"testing"
+)
+
+func TestReleaseDevice(t *testing.T) {
+	devices := []config.Device{
+		{Id: "couch", Type: "wled", Config: config.DeviceConfig{Name: "Couch", IpAddress: testAddress, PixelCount: 10}},
+		{Id: "desk", Type: "wled", Config: config.DeviceConfig{Name: "Desk", IpAddress: testAddress, PixelCount: 10}},
+	}
+	setupTestConfig(t, config.Config{
+		Devices: devices,
+		Virtuals: []config.Virtual{
+			{Id: "couch", IsDevice: "couch", Config: config.VirtualConfig{Name: "Couch"}},
+			{Id: "desk", IsDevice: "desk", Config: config.VirtualConfig{Name: "Desk"}},
+		},
+	})
+
+	cached := make(map[string]*device.UDPDevice)
+	for _, dev := range devices {
+		udp, err := device.OpenUDPDevice(dev)
+		if err != nil {
+			t.Fatalf("Error opening %s: %v\n", dev.Id, err)
+		}
+		cacheDevice(dev.Id, udp)
+		cached[dev.Id] = udp
+	}
+	defer device.CloseUDPDevice("desk")
+
+	if err := ReleaseDevice("couch"); err != nil {
+		t.Fatalf("Error releasing device: %v\n", err)
+	}
+	if _, ok := cachedDevice("couch"); ok {
+		t.Errorf("Socket of the released device is still cached")
+	}
+	if err := cached["couch"].SendData(make([]color.Color, 10), 0); err == nil {
+		t.Errorf("Socket of the released device was not closed")
+	}
+	if _, ok := cachedDevice("desk"); !ok {
+		t.Errorf("Socket of another device was dropped")
+	}
+	if err := cached["desk"].SendData(make([]color.Color, 10), 0); err != nil {
+		t.Errorf("Socket of another device was closed: %v", err)
+	}
+}
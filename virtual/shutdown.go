@@ -0,0 +1,98 @@
+package virtual
+
+import (
+	"context"
+	"fmt"
+	"ledfx/config"
+	"ledfx/device"
+	log "ledfx/logger"
+	"strings"
+	"sync"
+)
+
+func cachedDevice(id string) (*device.UDPDevice, bool) {
+	devMu.Lock()
+	defer devMu.Unlock()
+	dev, ok := devMap[id]
+	return dev, ok
+}
+
+func cacheDevice(id string, dev *device.UDPDevice) {
+	devMu.Lock()
+	defer devMu.Unlock()
+	devMap[id] = dev
+}
+
+// dropDevice forgets the device a virtual streams to, the device itself
+// stays open for the other virtuals on it
+func dropDevice(id string) {
+	devMu.Lock()
+	defer devMu.Unlock()
+	delete(devMap, id)
+}
+
+// Shutdown stops running effects and waits for them until ctx is done, then
+// closes the cached device sockets and releases every configured device from
+// realtime mode with a black frame. WLED devices then get back the state they
+// had before streaming. Releasing has a timeout of its own, so effects that
+// took all of ctx don't keep devices from being released. Devices that are
+// not released are reported in the error.
+func Shutdown(ctx context.Context) error {
+	effectsMu.Lock()
+	stopEffects()
+	effectsMu.Unlock()
+
+	effectsDone := make(chan struct{})
+	go func() {
+		running.Wait()
+		close(effectsDone)
+	}()
+	select {
+	case <-effectsDone:
+	case <-ctx.Done():
+		log.Logger.WithField("category", "Virtual Shutdown").Warnln("Effects still running, releasing devices anyway")
+	}
+
+	devMu.Lock()
+	devMap = make(map[string]*device.UDPDevice)
+	devMu.Unlock()
+	device.CloseUDPDevices()
+
+	releaseCtx, cancel := context.WithTimeout(context.Background(), streamTimeout)
+	defer cancel()
+	var (
+		mu     sync.Mutex
+		failed []string
+		wg     sync.WaitGroup
+	)
+	for _, dev := range config.Snapshot().Devices {
+		wg.Add(1)
+		go func(dev config.Device) {
+			defer wg.Done()
+			err := device.Blackout(dev)
+			if err == nil {
+				err = device.StopStreaming(releaseCtx, dev)
+			}
+			if err != nil {
+				mu.Lock()
+				failed = append(failed, fmt.Sprintf("%s: %v", dev.Id, err))
+				mu.Unlock()
+			}
+		}(dev)
+	}
+
+	released := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(released)
+	}()
+	select {
+	case <-released:
+	case <-releaseCtx.Done():
+		return fmt.Errorf("error releasing devices: %w", releaseCtx.Err())
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("error releasing devices: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package virtual
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"ledfx/color"
@@ -9,13 +10,35 @@ import (
 	"ledfx/effect"
 	"ledfx/logger"
 	log "ledfx/logger"
+	"sync"
 	"time"
 )
 
 var (
 	devMap map[string]*device.UDPDevice
+	devMu  sync.Mutex
+	// running tracks the effect goroutines so Shutdown can wait for them
+	running sync.WaitGroup
+	// effects is cancelled by Shutdown, effect loops exit when it is done and
+	// no new ones start. effectsMu orders starting one with Shutdown.
+	effects, stopEffects = context.WithCancel(context.Background())
+	effectsMu            sync.Mutex
 )
 
+var ErrShutdown = errors.New("virtuals are shut down")
+
+// startEffect counts an effect goroutine about to start, unless Shutdown was
+// called
+func startEffect() bool {
+	effectsMu.Lock()
+	defer effectsMu.Unlock()
+	if effects.Err() != nil {
+		return false
+	}
+	running.Add(1)
+	return true
+}
+
 func init() {
 	devMap = make(map[string]*device.UDPDevice)
 }
@@ -28,6 +51,9 @@ func RepeatN(virtualID string, playState bool, clr string, n int) error {
 	if virtualID == "" {
 		return errors.New("virtual id is empty. Please provide Id to add virtual to config")
 	}
+	if effects.Err() != nil {
+		return ErrShutdown
+	}
 
 	var timeout byte
 	if playState {
@@ -41,50 +67,28 @@ func RepeatN(virtualID string, playState bool, clr string, n int) error {
 		return fmt.Errorf("error generating new color: %w", err)
 	}
 
-	dev, ok := devMap[virtualID]
-	if ok {
-		data := make([]color.Color, dev.Config.PixelCount)
-		for i2 := 0; i2 < n-1; i2++ {
-			if len(data) <= i2 {
-				break
-			}
-			data[i2] = newColor
+	dev, ok := cachedDevice(virtualID)
+	if !ok {
+		target, isDevice, err := setActive(virtualID, playState)
+		if err != nil || !isDevice {
+			return err
 		}
-
-		if err := dev.SendData(data, timeout); err != nil {
-			return fmt.Errorf("error sending data to WLED: %w", err)
+		if dev, err = device.OpenUDPDevice(target); err != nil {
+			return fmt.Errorf("error during device init: %w", err)
 		}
-		return nil
+		cacheDevice(virtualID, dev)
 	}
 
-	var virtualExists bool
-	for i, d := range config.GlobalConfig.Virtuals {
-		if d.Id == virtualID {
-			virtualExists = true
-			config.GlobalConfig.Virtuals[i].Active = playState
-			if config.GlobalConfig.Virtuals[i].IsDevice != "" {
-				for in, de := range config.GlobalConfig.Devices {
-					if de.Id == config.GlobalConfig.Virtuals[i].IsDevice {
-						devMap[virtualID] = &device.UDPDevice{
-							Name:     config.GlobalConfig.Devices[in].Config.Name,
-							Port:     config.GlobalConfig.Devices[in].Config.Port,
-							Protocol: device.UDPProtocols[config.GlobalConfig.Devices[in].Config.UdpPacketType],
-							Config:   config.GlobalConfig.Devices[in].Config,
-						}
-
-						if err := devMap[virtualID].Init(); err != nil {
-							return fmt.Errorf("error during device init: %w", err)
-						}
-
-						return RepeatN(virtualID, playState, clr, n)
-					}
-				}
-			}
+	data := make([]color.Color, dev.Config.PixelCount)
+	for i2 := 0; i2 < n-1; i2++ {
+		if len(data) <= i2 {
+			break
 		}
+		data[i2] = newColor
 	}
-	if virtualExists {
-		config.GlobalViper.Set("virtuals", config.GlobalConfig.Virtuals)
-		err = config.GlobalViper.WriteConfig()
+
+	if err := dev.SendData(data, timeout); err != nil {
+		return fmt.Errorf("error sending data to WLED: %w", err)
 	}
 	return nil
 }
@@ -93,6 +97,9 @@ func RepeatNSmooth(virtualID string, playState bool, clr string, n int) error {
 	if virtualID == "" {
 		return errors.New("virtual id is empty. Please provide Id to add virtual to config")
 	}
+	if effects.Err() != nil {
+		return ErrShutdown
+	}
 
 	var timeout byte
 	if playState {
@@ -106,59 +113,44 @@ func RepeatNSmooth(virtualID string, playState bool, clr string, n int) error {
 		return fmt.Errorf("error generating new color: %w", err)
 	}
 
-	var virtualExists bool
-	for i, d := range config.GlobalConfig.Virtuals {
-		if d.Id == virtualID {
-			virtualExists = true
-			config.GlobalConfig.Virtuals[i].Active = playState
-			if config.GlobalConfig.Virtuals[i].IsDevice != "" {
-				for in, de := range config.GlobalConfig.Devices {
-					if de.Id == config.GlobalConfig.Virtuals[i].IsDevice {
-						var dev = &device.UDPDevice{
-							Name:     config.GlobalConfig.Devices[in].Config.Name,
-							Port:     config.GlobalConfig.Devices[in].Config.Port,
-							Protocol: device.UDPProtocols[config.GlobalConfig.Devices[in].Config.UdpPacketType],
-							Config:   config.GlobalConfig.Devices[in].Config,
-						}
-
-						if err := dev.Init(); err != nil {
-							return fmt.Errorf("error initializing dev: %w", err)
-						}
-
-						data := make([]color.Color, de.Config.PixelCount)
-						for i2 := 0; i2 < n-1; i2++ {
-							if len(data) <= i2 {
-								break
-							}
-							data[i2] = newColor
-							time.Sleep(5 * time.Millisecond)
-							if err := dev.SendData(data, timeout); err != nil {
-								log.Logger.Errorf("Error sending data to WLED: %v", err)
-							}
-						}
-
-						go func() {
-							noColor, _ := color.NewColor("#000000")
-							for i2 := len(data) - 1; ; i2-- {
-								if 0 > i2 {
-									break
-								}
-								data[i2] = noColor
-								time.Sleep(5 * time.Millisecond)
-								if err := dev.SendData(data, timeout); err != nil {
-									log.Logger.Errorf("Error sending data to WLED: %v", err)
-								}
-							}
-						}()
-					}
-				}
-			}
+	target, isDevice, err := setActive(virtualID, playState)
+	if err != nil || !isDevice {
+		return err
+	}
+	dev, err := device.OpenUDPDevice(target)
+	if err != nil {
+		return fmt.Errorf("error initializing dev: %w", err)
+	}
+
+	data := make([]color.Color, target.Config.PixelCount)
+	for i2 := 0; i2 < n-1; i2++ {
+		if len(data) <= i2 {
+			break
+		}
+		data[i2] = newColor
+		time.Sleep(5 * time.Millisecond)
+		if err := dev.SendData(data, timeout); err != nil {
+			log.Logger.Errorf("Error sending data to WLED: %v", err)
 		}
 	}
-	if virtualExists {
-		config.GlobalViper.Set("virtuals", config.GlobalConfig.Virtuals)
-		err = config.GlobalViper.WriteConfig()
+
+	if !startEffect() {
+		return ErrShutdown
 	}
+	go func() {
+		defer running.Done()
+		noColor, _ := color.NewColor("#000000")
+		for i2 := len(data) - 1; ; i2-- {
+			if 0 > i2 || effects.Err() != nil {
+				break
+			}
+			data[i2] = noColor
+			time.Sleep(5 * time.Millisecond)
+			if err := dev.SendData(data, timeout); err != nil {
+				log.Logger.Errorf("Error sending data to WLED: %v", err)
+			}
+		}
+	}()
 	return nil
 }
 
@@ -174,58 +166,40 @@ func PlayVirtual(virtualID string, playState bool, clr string) (err error) {
 	if virtualID == "" {
 		return errors.New("virtual id is empty. Please provide Id to add virtual to config")
 	}
-
-	var virtualExists bool
+	if effects.Err() != nil {
+		return ErrShutdown
+	}
 
 	newColor, err := color.NewColor(clr)
 	if err != nil {
 		return fmt.Errorf("error generating new color: %w", err)
 	}
 
-	for i, d := range config.GlobalConfig.Virtuals {
-		if d.Id == virtualID {
-			virtualExists = true
-			config.GlobalConfig.Virtuals[i].Active = playState
-			if config.GlobalConfig.Virtuals[i].IsDevice != "" {
-				for in, de := range config.GlobalConfig.Devices {
-					if de.Id == config.GlobalConfig.Virtuals[i].IsDevice {
-						var dev = &device.UDPDevice{
-							Name:     config.GlobalConfig.Devices[in].Config.Name,
-							Port:     config.GlobalConfig.Devices[in].Config.Port,
-							Protocol: device.UDPProtocols[config.GlobalConfig.Devices[in].Config.UdpPacketType],
-							Config:   config.GlobalConfig.Devices[in].Config,
-						}
-
-						data := make([]color.Color, de.Config.PixelCount)
-						for i2 := range data {
-							data[i2] = newColor
-						}
-
-						if err := dev.Init(); err != nil {
-							return fmt.Errorf("error initializing dev: %w", err)
-						}
-
-						var timeout byte
-						if playState {
-							timeout = 0xff
-						} else {
-							timeout = 0x00
-						}
-
-						if err := dev.SendData(data, timeout); err != nil {
-							return fmt.Errorf("error sending data to WLED: %w", err)
-						}
-					}
-				}
-			}
-		}
+	target, isDevice, err := setActive(virtualID, playState)
+	if err != nil || !isDevice {
+		return err
+	}
+	dev, err := device.OpenUDPDevice(target)
+	if err != nil {
+		return fmt.Errorf("error initializing dev: %w", err)
 	}
 
-	if virtualExists {
-		config.GlobalViper.Set("virtuals", config.GlobalConfig.Virtuals)
-		err = config.GlobalViper.WriteConfig()
+	data := make([]color.Color, target.Config.PixelCount)
+	for i2 := range data {
+		data[i2] = newColor
 	}
-	return
+
+	var timeout byte
+	if playState {
+		timeout = 0xff
+	} else {
+		timeout = 0x00
+	}
+
+	if err := dev.SendData(data, timeout); err != nil {
+		return fmt.Errorf("error sending data to WLED: %w", err)
+	}
+	return nil
 }
 
 func StopVirtual(virtualid string) (err error) {
@@ -236,38 +210,89 @@ func StopVirtual(virtualid string) (err error) {
 		return
 	}
 
-	for i, d := range config.GlobalConfig.Virtuals {
-		if d.Id == virtualid {
-			if config.GlobalConfig.Virtuals[i].IsDevice != "" {
-				fmt.Println("WTF Clear Effect of ", config.GlobalConfig.Virtuals[i].Effect.Name)
-				for in, de := range config.GlobalConfig.Devices {
-					if de.Id == config.GlobalConfig.Virtuals[i].IsDevice {
-						var currentEffect effect.Effect = &effect.PulsingEffect{}
-						go func() {
-							err := effect.StopEffect(config.GlobalConfig.Devices[in].Config, currentEffect, "#000000", 60, done)
-							if err != nil {
-								logger.Logger.Warn(err)
-							}
-						}()
+	conf := config.Snapshot()
+	for _, virt := range conf.Virtuals {
+		if virt.Id != virtualid || virt.IsDevice == "" {
+			continue
+		}
+		fmt.Println("WTF Clear Effect of ", virt.Effect.Name)
+		for _, de := range conf.Devices {
+			if de.Id == virt.IsDevice {
+				var currentEffect effect.Effect = &effect.PulsingEffect{}
+				target := de
+				if !startEffect() {
+					return ErrShutdown
+				}
+				go func() {
+					defer running.Done()
+					err := effect.StopEffect(target, currentEffect, "#000000", 60, done)
+					if err != nil {
+						logger.Logger.Warn(err)
 					}
+				}()
+			}
+		}
+	}
+	return
+}
 
+// setActive stores the play state of a virtual and returns the device it
+// represents, if any. The config is only written when the play
+// state changed, since this runs on every audio onset.
+func setActive(virtualID string, active bool) (target config.Device, isDevice bool, err error) {
+	changed := false
+	err = config.Update(func(c *config.Config) error {
+		for i := range c.Virtuals {
+			virt := &c.Virtuals[i]
+			if virt.Id != virtualID {
+				continue
+			}
+			for _, dev := range c.Devices {
+				if virt.IsDevice != "" && dev.Id == virt.IsDevice {
+					target, isDevice = dev, true
 				}
 			}
+			if virt.Active == active {
+				return config.ErrUnchanged
+			}
+			virt.Active = active
+			changed = true
+			return nil
 		}
+		return config.ErrUnchanged
+	})
+	if err == nil && changed && isDevice {
+		streaming(target, active)
+	}
+	return target, isDevice, err
+}
+
+// streamTimeout limits talking to a device when streaming starts or stops
+const streamTimeout = 2 * time.Second
+
+// streaming prepares a device for realtime data when its virtual starts
+// playing and restores it when it stops
+func streaming(target config.Device, active bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), streamTimeout)
+	defer cancel()
+	var err error
+	if active {
+		err = device.StartStreaming(ctx, target)
+	} else {
+		err = device.StopStreaming(ctx, target)
+	}
+	if err != nil {
+		log.Logger.Warn(err)
 	}
-	return
 }
 
 // LoadVirtuals loads the virtuals from the config file and plays any effects that are active on them
 func LoadVirtuals() (err error) {
 	// TODO: load all virtuals from config
 
-	// c := &config.GlobalConfig
-	// v := config.GlobalViper
-
-	// for i, virtualConfig := range config.GlobalConfig.Virtuals {
-	// 	if config.GlobalConfig.Virtuals[i].Active == true {
-	// 		if config.GlobalConfig.Virtuals[i].IsDevice != "" {
+	// for _, virtualConfig := range config.Snapshot().Virtuals {
+	// 	if virtualConfig.Active == true {
+	// 		if virtualConfig.IsDevice != "" {
 	//       // TODO: instantiate a virtual
 	// 			// PlayVirtual(virtual)
 	// 		}
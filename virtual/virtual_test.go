@@ -0,0 +1,114 @@
+package virtual
+
+import (
+	"ledfx/config"
+	"ledfx/device"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// testAddress is where the test devices are, apart from the API tests that
+// stand in for a device on 127.0.0.1
+const testAddress = "127.0.0.2"
+
+func setupTestConfig(t *testing.T, conf config.Config) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "go_config.json")
+	if err := os.WriteFile(path, []byte("{}\n"), 0644); err != nil {
+		t.Fatalf("Error writing test config: %v\n", err)
+	}
+	config.GlobalViper = viper.New()
+	config.WriteDelay = 0
+	config.GlobalViper.SetConfigFile(path)
+	if err := config.Replace(conf); err != nil {
+		t.Fatalf("Error setting test config: %v\n", err)
+	}
+}
+
+// listenDevice stands in for the WLED devices at testAddress
+func listenDevice(t *testing.T) net.PacketConn {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", net.JoinHostPort(testAddress, "21324"))
+	if err != nil {
+		t.Skipf("Can't stand in for a device at %s: %v", testAddress, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestPlayVirtualPower(t *testing.T) {
+	listenDevice(t)
+	setupTestConfig(t, config.Config{
+		Devices: []config.Device{{Id: "couch", Type: "wled", Config: config.DeviceConfig{
+			Name:       "Couch",
+			IpAddress:  testAddress,
+			PixelCount: 10,
+			// 10 white pixels would draw 600 mA at 20 mA per channel
+			Power: config.Power{MaxMilliamps: 300},
+		}}},
+		Virtuals: []config.Virtual{{Id: "couch", IsDevice: "couch", Config: config.VirtualConfig{Name: "Couch"}}},
+	})
+	defer device.CloseUDPDevice("couch")
+
+	if err := PlayVirtual("couch", true, "#ffffff"); err != nil {
+		t.Fatalf("Error playing virtual: %v\n", err)
+	}
+	limited, ok := device.Power().Devices["couch"]
+	if !ok || limited.Scale > 0.5 {
+		t.Fatalf("Expected the white frame to be dimmed to half, got %+v", limited)
+	}
+
+	// A dark frame is within budget, the brightness comes back slowly
+	if err := PlayVirtual("couch", true, "#000000"); err != nil {
+		t.Fatalf("Error playing virtual: %v\n", err)
+	}
+	draw, ok := device.Power().Devices["couch"]
+	if !ok {
+		t.Fatalf("Draw of the device is not reported after a frame")
+	}
+	if draw.Scale <= limited.Scale || draw.Scale >= 1 {
+		t.Errorf("Expected the brightness to come back slowly from %v, got %v", limited.Scale, draw.Scale)
+	}
+}
+
+func TestPlayVirtualDither(t *testing.T) {
+	conn := listenDevice(t)
+	setupTestConfig(t, config.Config{
+		Devices: []config.Device{{Id: "couch", Type: "wled", Config: config.DeviceConfig{
+			Name:        "Couch",
+			IpAddress:   testAddress,
+			PixelCount:  1,
+			Calibration: config.Calibration{Gamma: 2, Dither: true},
+		}}},
+		Virtuals: []config.Virtual{{Id: "couch", IsDevice: "couch", Config: config.VirtualConfig{Name: "Couch"}}},
+	})
+	defer device.CloseUDPDevice("couch")
+
+	// #808080 with gamma 2 is 64.25 of 255, dithered the first frame rounds
+	// down and the rounding error tips the second one over to 65
+	var got []byte
+	buf := make([]byte, 1500)
+	for i := 0; i < 2; i++ {
+		if err := PlayVirtual("couch", true, "#808080"); err != nil {
+			t.Fatalf("Error playing virtual: %v\n", err)
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("Error reading frame: %v\n", err)
+		}
+		// DNRGB: protocol, timeout, two bytes of offset, then the pixel
+		if n != 7 {
+			t.Fatalf("Unexpected packet %v", buf[:n])
+		}
+		got = append(got, buf[4])
+	}
+	if got[0] != 64 || got[1] != 65 {
+		t.Errorf("Expected dithered red 64 then 65, got %v", got)
+	}
+}